@@ -37,6 +37,24 @@ func NewDatabase(dataSourceName string) (*Database, error) {
 	return database, nil
 }
 
+// columnMigrations lists columns added after the first release; database files
+// created before them get the columns added on startup
+var columnMigrations = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"events", "question_number", "INTEGER DEFAULT 0"},
+	{"events", "team_size", "INTEGER DEFAULT 1"},
+	{"events", "qrcode", "TEXT DEFAULT ''"},
+	{"events", "room_code", "TEXT DEFAULT ''"},
+	{"users", "event_id", "INTEGER DEFAULT 0"},
+	{"teams", "event_id", "INTEGER DEFAULT 0"},
+	{"answers", "event_id", "INTEGER DEFAULT 0"},
+	{"emoji_reactions", "event_id", "INTEGER DEFAULT 0"},
+	{"event_progress", "slide", "TEXT DEFAULT ''"},
+}
+
 func (db *Database) InitSchema() error {
 	schemaPath := filepath.Join("database", "init.sql")
 	schema, err := os.ReadFile(schemaPath)
@@ -44,6 +62,14 @@ func (db *Database) InitSchema() error {
 		return fmt.Errorf("failed to read schema file: %v", err)
 	}
 
+	// Runs first so that indexes on new columns can be created by the schema
+	if err := db.migrateColumns(); err != nil {
+		return fmt.Errorf("failed to migrate schema: %v", err)
+	}
+	if err := db.removeDuplicateAnswers(); err != nil {
+		return fmt.Errorf("failed to migrate schema: %v", err)
+	}
+
 	if _, err := db.Exec(string(schema)); err != nil {
 		return fmt.Errorf("failed to execute schema: %v", err)
 	}
@@ -52,6 +78,65 @@ func (db *Database) InitSchema() error {
 	return nil
 }
 
+// migrateColumns adds missing columns to tables that already exist
+func (db *Database) migrateColumns() error {
+	for _, m := range columnMigrations {
+		columns, err := db.tableColumns(m.table)
+		if err != nil {
+			return err
+		}
+		if len(columns) == 0 || columns[m.column] {
+			continue // Created by the schema, or already migrated
+		}
+
+		query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.table, m.column, m.definition)
+		if _, err := db.Exec(query); err != nil {
+			return fmt.Errorf("adding %s.%s: %v", m.table, m.column, err)
+		}
+		log.Printf("Database migrated: added %s.%s", m.table, m.column)
+	}
+	return nil
+}
+
+// removeDuplicateAnswers keeps the latest of answers recorded twice for the same
+// question, so that the schema can add the unique index on them
+func (db *Database) removeDuplicateAnswers() error {
+	columns, err := db.tableColumns("answers")
+	if err != nil || len(columns) == 0 {
+		return err
+	}
+
+	query := `DELETE FROM answers WHERE id NOT IN (SELECT MAX(id) FROM answers GROUP BY event_id, user_id, question_number)`
+	result, err := db.Exec(query)
+	if err != nil {
+		return fmt.Errorf("removing duplicate answers: %v", err)
+	}
+	if removed, _ := result.RowsAffected(); removed > 0 {
+		log.Printf("Database migrated: removed %d duplicate answers", removed)
+	}
+	return nil
+}
+
+func (db *Database) tableColumns(table string) (map[string]bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
 func (db *Database) Close() error {
 	return db.DB.Close()
 }
@@ -14,6 +14,8 @@ const (
 	ErrCodeUserNotFound      ErrorCode = "USER_NOT_FOUND"
 	ErrCodeUserAlreadyJoined ErrorCode = "USER_ALREADY_JOINED"
 	ErrCodeSessionExpired    ErrorCode = "SESSION_EXPIRED"
+	ErrCodeSessionRequired   ErrorCode = "SESSION_REQUIRED"
+	ErrCodeInvalidSession    ErrorCode = "INVALID_SESSION"
 	ErrCodeInvalidNickname   ErrorCode = "INVALID_NICKNAME"
 
 	// Question-related errors
@@ -27,6 +29,7 @@ const (
 	ErrCodeStateNotAllowed        ErrorCode = "STATE_NOT_ALLOWED"
 	ErrCodeEventNotStarted        ErrorCode = "EVENT_NOT_STARTED"
 	ErrCodeEventAlreadyStarted    ErrorCode = "EVENT_ALREADY_STARTED"
+	ErrCodeStateConflict          ErrorCode = "STATE_CONFLICT"
 
 	// Team-related errors
 	ErrCodeTeamNotFound         ErrorCode = "TEAM_NOT_FOUND"
@@ -45,6 +48,14 @@ const (
 	ErrCodeDataNotFound  ErrorCode = "DATA_NOT_FOUND"
 	ErrCodeDataCorrupted ErrorCode = "DATA_CORRUPTED"
 
+	// Admin and room errors
+	ErrCodeUnauthorized        ErrorCode = "UNAUTHORIZED"
+	ErrCodeForbidden           ErrorCode = "FORBIDDEN"
+	ErrCodeInvalidCredentials  ErrorCode = "INVALID_CREDENTIALS"
+	ErrCodeOperatorLocked      ErrorCode = "OPERATOR_LOCKED"
+	ErrCodeIdempotencyConflict ErrorCode = "IDEMPOTENCY_KEY_REUSED"
+	ErrCodeRoomNotFound        ErrorCode = "ROOM_NOT_FOUND"
+
 	// Validation errors
 	ErrCodeValidationFailed ErrorCode = "VALIDATION_FAILED"
 	ErrCodeInvalidRequest   ErrorCode = "INVALID_REQUEST"
@@ -56,15 +67,17 @@ const (
 	ErrCodeServiceUnavailable ErrorCode = "SERVICE_UNAVAILABLE"
 	ErrCodeTimeout            ErrorCode = "TIMEOUT"
 	ErrCodeResourceExhausted  ErrorCode = "RESOURCE_EXHAUSTED"
+	ErrCodeRateLimited        ErrorCode = "RATE_LIMITED"
 )
 
 // QuizError represents a standardized error in the quiz application
 type QuizError struct {
-	Code       ErrorCode `json:"code"`
-	Message    string    `json:"message"`
-	Details    string    `json:"details,omitempty"`
-	HTTPStatus int       `json:"-"`
-	Cause      error     `json:"-"`
+	Code       ErrorCode      `json:"code"`
+	Message    string         `json:"message"`
+	Details    string         `json:"details,omitempty"`
+	Data       map[string]any `json:"data,omitempty"` // Values clients act on, such as retry_after
+	HTTPStatus int            `json:"-"`
+	Cause      error          `json:"-"`
 }
 
 // Error implements the error interface
@@ -95,6 +108,17 @@ func (e *QuizError) WithDetails(details string) *QuizError {
 	return &newErr
 }
 
+// WithData adds a value for clients to the error
+func (e *QuizError) WithData(key string, value any) *QuizError {
+	newErr := *e
+	newErr.Data = make(map[string]any, len(e.Data)+1)
+	for k, v := range e.Data {
+		newErr.Data[k] = v
+	}
+	newErr.Data[key] = value
+	return &newErr
+}
+
 // WithCause adds a cause error
 func (e *QuizError) WithCause(cause error) *QuizError {
 	newErr := *e
@@ -132,6 +156,18 @@ var (
 		http.StatusUnauthorized,
 	)
 
+	ErrSessionRequired = NewQuizError(
+		ErrCodeSessionRequired,
+		"セッションがありません。参加し直してください",
+		http.StatusUnauthorized,
+	)
+
+	ErrInvalidSession = NewQuizError(
+		ErrCodeInvalidSession,
+		"セッションが無効です。参加し直してください",
+		http.StatusUnauthorized,
+	)
+
 	ErrInvalidNickname = NewQuizError(
 		ErrCodeInvalidNickname,
 		"ニックネームが無効です",
@@ -188,6 +224,12 @@ var (
 		http.StatusConflict,
 	)
 
+	ErrStateConflict = NewQuizError(
+		ErrCodeStateConflict,
+		"画面の表示後に状態が変わりました。最新の状態を確認してください",
+		http.StatusConflict,
+	)
+
 	// Team errors
 	ErrTeamNotFound = NewQuizError(
 		ErrCodeTeamNotFound,
@@ -239,6 +281,43 @@ var (
 		http.StatusNotFound,
 	)
 
+	// Admin and room errors
+	ErrUnauthorized = NewQuizError(
+		ErrCodeUnauthorized,
+		"ログインが必要です",
+		http.StatusUnauthorized,
+	)
+
+	ErrForbidden = NewQuizError(
+		ErrCodeForbidden,
+		"この操作を行う権限がありません",
+		http.StatusForbidden,
+	)
+
+	ErrInvalidCredentials = NewQuizError(
+		ErrCodeInvalidCredentials,
+		"ユーザー名またはパスワードが違います",
+		http.StatusUnauthorized,
+	)
+
+	ErrOperatorLocked = NewQuizError(
+		ErrCodeOperatorLocked,
+		"他の管理画面が操作権を持っています",
+		http.StatusLocked,
+	)
+
+	ErrIdempotencyConflict = NewQuizError(
+		ErrCodeIdempotencyConflict,
+		"同じリクエストキーが別の操作に使われています",
+		http.StatusUnprocessableEntity,
+	)
+
+	ErrRoomNotFound = NewQuizError(
+		ErrCodeRoomNotFound,
+		"ルームが見つかりません",
+		http.StatusNotFound,
+	)
+
 	// Validation errors
 	ErrValidationFailed = NewQuizError(
 		ErrCodeValidationFailed,
@@ -258,6 +337,12 @@ var (
 		http.StatusBadRequest,
 	)
 
+	ErrInvalidParameter = NewQuizError(
+		ErrCodeInvalidParameter,
+		"パラメータが無効です",
+		http.StatusBadRequest,
+	)
+
 	// System errors
 	ErrInternalError = NewQuizError(
 		ErrCodeInternalError,
@@ -276,6 +361,12 @@ var (
 		"処理がタイムアウトしました",
 		http.StatusRequestTimeout,
 	)
+
+	ErrRateLimited = NewQuizError(
+		ErrCodeRateLimited,
+		"リクエストが多すぎます。少し待ってから再度お試しください",
+		http.StatusTooManyRequests,
+	)
 )
 
 // ErrorResponse represents the standardized error response format
@@ -375,7 +466,8 @@ func IsUserError(err error) bool {
 	if qErr, ok := err.(*QuizError); ok {
 		switch qErr.Code {
 		case ErrCodeUserNotFound, ErrCodeUserAlreadyJoined,
-			ErrCodeSessionExpired, ErrCodeInvalidNickname:
+			ErrCodeSessionExpired, ErrCodeSessionRequired,
+			ErrCodeInvalidSession, ErrCodeInvalidNickname:
 			return true
 		}
 	}
@@ -387,7 +479,8 @@ func IsStateError(err error) bool {
 	if qErr, ok := err.(*QuizError); ok {
 		switch qErr.Code {
 		case ErrCodeInvalidStateTransition, ErrCodeStateNotAllowed,
-			ErrCodeEventNotStarted, ErrCodeEventAlreadyStarted:
+			ErrCodeEventNotStarted, ErrCodeEventAlreadyStarted,
+			ErrCodeStateConflict:
 			return true
 		}
 	}
@@ -1,14 +1,21 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	quizerrors "quiz100/errors"
+	"quiz100/middleware"
 	"quiz100/models"
 	"quiz100/services"
+	"quiz100/validation"
 	"quiz100/websocket"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -23,6 +30,12 @@ type AdminHandlers struct {
 	teamAssignmentSvc *models.TeamAssignmentService
 	hubManager        *websocket.HubManager
 	stateService      *services.StateService
+	presence          *services.PresenceService
+	tally             *services.AnswerTally
+	pingManager       *websocket.PingManager
+	operators         *services.OperatorService
+	validator         *validation.RequestValidator
+	actionMu          sync.Mutex // Serializes state changes so expected_state holds while an action runs
 	logger            models.QuizLogger
 	config            *models.Config
 	currentEvent      *models.Event
@@ -30,9 +43,22 @@ type AdminHandlers struct {
 	dbResetCallback   func() error
 }
 
+// ActionGuard protects a change made from a console against stale state and repeats
+type ActionGuard struct {
+	ExpectedState          string `json:"expected_state"`           // Rejected with 409 when the state has moved on
+	ExpectedQuestionNumber *int   `json:"expected_question_number"` // Same for the question number
+	IdempotencyKey         string `json:"idempotency_key"`          // Retries with the key get the first response
+}
+
 // AdminRequest represents a general admin action request
 type AdminRequest struct {
 	Action string `json:"action" binding:"required"`
+	ActionGuard
+}
+
+// OperatorLockRequest takes or releases the operator lock; force takes it from another console
+type OperatorLockRequest struct {
+	Force bool `json:"force"`
 }
 
 // JumpStateRequest represents a state jump request
@@ -41,6 +67,21 @@ type JumpStateRequest struct {
 	QuestionNumber *int   `json:"question_number,omitempty"`
 }
 
+// ScreenChannelRequest sets what the screens of a channel show
+type ScreenChannelRequest struct {
+	Channel string                 `json:"channel" binding:"required"`
+	Shows   []models.ScreenContent `json:"shows" binding:"required"`
+	ActionGuard
+}
+
+// SlideRequest shows a configured slide (slide_id) or an ad-hoc text announcement
+type SlideRequest struct {
+	SlideID string `json:"slide_id"`
+	Title   string `json:"title"`
+	Text    string `json:"text"`
+	ActionGuard
+}
+
 // NewAdminHandlers creates a new AdminHandlers instance
 func NewAdminHandlers(
 	eventRepo *models.EventRepository,
@@ -61,6 +102,8 @@ func NewAdminHandlers(
 		teamAssignmentSvc: teamAssignmentSvc,
 		hubManager:        hubManager,
 		stateService:      stateService,
+		operators:         services.NewOperatorService(hubManager),
+		validator:         validation.NewRequestValidator(config),
 		logger:            logger,
 		config:            config,
 	}
@@ -70,41 +113,145 @@ func NewAdminHandlers(
 func (ah *AdminHandlers) AdminAction(c *gin.Context) {
 	var req AdminRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		middleware.RespondError(c, invalidRequest(err))
+		return
+	}
+
+	ah.runGuarded(c, req.Action, req.ActionGuard, func() (gin.H, *quizerrors.QuizError) {
+		return ah.runAction(req.Action)
+	})
+}
+
+// runGuarded runs a change made from a console: one at a time, once per
+// idempotency key, only by the holder of the operator lock and only while the
+// state is the one the console showed. Other consoles are told who made it.
+func (ah *AdminHandlers) runGuarded(c *gin.Context, action string, guard ActionGuard, run func() (gin.H, *quizerrors.QuizError)) {
+	key := guard.IdempotencyKey
+	if key == "" {
+		key = c.GetHeader("Idempotency-Key")
+	}
+	operator := operatorFromRequest(c)
+
+	ah.actionMu.Lock()
+	defer ah.actionMu.Unlock()
+
+	// A retry (double click, resent request) gets the first response instead of running again
+	if key != "" {
+		if result, ok := ah.operators.Recall(key); ok {
+			if result.Action != action {
+				middleware.RespondError(c, quizerrors.ErrIdempotencyConflict)
+				return
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.JSON(result.Status, result.Body)
+			return
+		}
+	}
+
+	if !ah.checkOperatorLock(c, operator) {
+		return
+	}
+
+	fromState := ah.stateService.GetCurrentState()
+	fromQuestion := ah.stateService.GetQuestionNumber()
+	if (guard.ExpectedState != "" && models.EventState(guard.ExpectedState) != fromState) ||
+		(guard.ExpectedQuestionNumber != nil && *guard.ExpectedQuestionNumber != fromQuestion) {
+		middleware.RespondError(c, quizerrors.ErrStateConflict.
+			WithData("current_state", fromState).
+			WithData("question_number", fromQuestion))
 		return
 	}
 
-	switch req.Action {
-	case "start_event":
-		ah.handleStartEvent(c)
-	case "show_title":
-		ah.handleShowTitle(c)
-	case "assign_teams":
-		ah.handleAssignTeams(c)
-	case "next_question":
-		ah.handleNextQuestion(c)
-	case "countdown_alert":
-		ah.handleCountdownAlert(c)
-	case "show_answer_stats":
-		ah.handleShowAnswerStats(c)
-	case "reveal_answer":
-		ah.handleRevealAnswer(c)
-	case "show_results":
-		ah.handleShowResults(c)
-	case "celebration":
-		ah.handleCelebration(c)
+	body, actionErr := run()
+	if actionErr != nil {
+		if key != "" {
+			response := quizerrors.NewErrorResponse(actionErr, middleware.GetRequestID(c))
+			ah.operators.Remember(key, services.ActionResult{Action: action, Status: actionErr.HTTPStatus, Body: response})
+		}
+		middleware.RespondError(c, actionErr)
+		return
+	}
+
+	if key != "" {
+		ah.operators.Remember(key, services.ActionResult{Action: action, Status: http.StatusOK, Body: body})
+	}
+	err := ah.operators.AnnounceAction(operator, action, fromState,
+		ah.stateService.GetCurrentState(), ah.stateService.GetQuestionNumber())
+	if err != nil {
+		ah.logger.LogError("broadcasting admin action", err)
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// ExecuteAction runs an action outside of an HTTP request (flow timers)
+func (ah *AdminHandlers) ExecuteAction(action string) error {
+	ah.actionMu.Lock()
+	defer ah.actionMu.Unlock()
+
+	if _, err := ah.runAction(action); err != nil {
+		return fmt.Errorf("%s failed: %v", action, err)
+	}
+	return nil
+}
+
+// runAction dispatches an action to its handler; the flow decides whether it is allowed
+func (ah *AdminHandlers) runAction(action string) (gin.H, *quizerrors.QuizError) {
+	if result := ah.validator.ValidateAdminAction(action); !result.Valid {
+		return nil, result.GetCombinedError()
+	}
+
+	switch action {
+	case models.ActionStartEvent:
+		return ah.handleStartEvent()
+	case models.ActionShowTitle:
+		return ah.handleShowTitle()
+	case models.ActionAssignTeams:
+		return ah.handleAssignTeams()
+	case models.ActionNextQuestion:
+		return ah.handleNextQuestion()
+	case models.ActionCountdownAlert:
+		return ah.handleCountdownAlert()
+	case models.ActionCloseAnswers:
+		return ah.handleCloseAnswers()
+	case models.ActionReopenAnswers:
+		return ah.handleReopenAnswers()
+	case models.ActionShowAnswerStats:
+		return ah.handleShowAnswerStats()
+	case models.ActionRevealAnswer:
+		return ah.handleRevealAnswer()
+	case models.ActionShowLeaderboard:
+		return ah.handleShowLeaderboard()
+	case models.ActionIntermission:
+		return ah.handleIntermission()
+	case models.ActionShowResults:
+		return ah.handleShowResults()
+	case models.ActionCelebration:
+		return ah.handleCelebration()
+	case models.ActionFinish:
+		return ah.handleFinish()
+	case models.ActionGoBack:
+		return ah.handleGoBack()
+	case models.ActionPause:
+		return ah.handlePause()
+	case models.ActionResume:
+		return ah.handleResume()
+	case models.ActionAutopilotSkip, models.ActionAutopilotExtend, models.ActionAutopilotManual, models.ActionAutopilotAuto:
+		return ah.handleAutopilot(action)
 	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid action"})
+		return nil, quizerrors.ErrInvalidRequest.WithDetails("Invalid action")
 	}
 }
 
 // GetAvailableActions returns the available actions for the current state
 func (ah *AdminHandlers) GetAvailableActions(c *gin.Context) {
-	actions := ah.stateService.GetAvailableActions()
 	c.JSON(http.StatusOK, gin.H{
-		"available_actions": actions,
+		"available_actions": ah.stateService.GetAvailableActions(),
+		"actions":           ah.stateService.GetAvailableActionDetails(),
 		"current_state":     ah.stateService.GetCurrentState(),
 		"question_number":   ah.stateService.GetQuestionNumber(),
+		"paused":            ah.stateService.IsPaused(),
+		"slide":             ah.stateService.GetSlide(),
+		"autopilot":         ah.stateService.GetAutopilotStatus(),
 	})
 }
 
@@ -112,60 +259,44 @@ func (ah *AdminHandlers) GetAvailableActions(c *gin.Context) {
 func (ah *AdminHandlers) AdminJumpState(c *gin.Context) {
 	var req JumpStateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		middleware.RespondError(c, invalidRequest(err))
+		return
+	}
+
+	ah.actionMu.Lock()
+	defer ah.actionMu.Unlock()
+	if !ah.checkOperatorLock(c, operatorFromRequest(c)) {
 		return
 	}
 
 	// Convert string to EventState using constants
 	targetState, err := models.StringToState(req.State)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		middleware.RespondError(c, quizerrors.ErrInvalidParameter.WithDetails(err.Error()))
 		return
 	}
 
-	// Set question number if provided
+	// Jump with the question number in one change; the state service broadcasts it
+	var result *services.StateTransitionResult
 	if req.QuestionNumber != nil {
-		if err := ah.stateService.SetQuestionNumber(*req.QuestionNumber); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question number: " + err.Error()})
-			return
-		}
-
-		// Also update current event if it exists
-		if ah.currentEvent != nil {
-			err := ah.eventRepo.UpdateQuestionNumber(ah.currentEvent.ID, *req.QuestionNumber)
-			if err != nil {
-				ah.logger.LogError("updating current question in database", err)
-			} else {
-				ah.currentEvent.QuestionNumber = *req.QuestionNumber
-			}
-		}
+		result = ah.stateService.JumpToQuestion(targetState, *req.QuestionNumber)
+	} else {
+		result = ah.stateService.JumpToState(targetState)
 	}
-
-	// Perform state jump using state service
-	result := ah.stateService.JumpToState(targetState)
 	if !result.Success {
-		c.JSON(http.StatusBadRequest, gin.H{"error": result.Error.Error()})
+		middleware.RespondError(c, actionRefused(result.Error))
 		return
 	}
 
-	// Broadcast state change with question data if jumping to question-related state
-	stateData := gin.H{
-		"new_state":       result.NewState,
-		"question_number": ah.stateService.GetQuestionNumber(),
-		"jumped":          true,
-	}
-
-	// Add current question data if jumping to question-related state
-	if req.QuestionNumber != nil && *req.QuestionNumber > 0 && *req.QuestionNumber <= len(ah.config.Questions) {
-		question := ah.config.Questions[*req.QuestionNumber-1]
-		ah.currentQuestion = &question
-		stateData["question"] = question
-		stateData["question_number"] = *req.QuestionNumber
-		stateData["total_questions"] = len(ah.config.Questions)
-	}
-
-	if err := ah.hubManager.BroadcastStateChanged(stateData); err != nil {
-		ah.logger.LogError("broadcasting state change", err)
+	if req.QuestionNumber != nil {
+		// Also update current event if it exists (saved to the database with the jump)
+		if ah.currentEvent != nil {
+			ah.currentEvent.QuestionNumber = *req.QuestionNumber
+		}
+		if *req.QuestionNumber > 0 && *req.QuestionNumber <= len(ah.config.Questions) {
+			question := ah.config.Questions[*req.QuestionNumber-1]
+			ah.currentQuestion = &question
+		}
 	}
 
 	response := gin.H{
@@ -188,81 +319,319 @@ func (ah *AdminHandlers) GetAvailableStates(c *gin.Context) {
 	})
 }
 
-// Private action handlers
+// GetSlides returns the configured slides and the one currently shown
+func (ah *AdminHandlers) GetSlides(c *gin.Context) {
+	slides := make([]gin.H, 0, len(ah.config.Slides))
+	for _, slide := range ah.config.Slides {
+		slides = append(slides, gin.H{
+			"id":    slide.ID,
+			"type":  slide.Type,
+			"label": slide.DisplayLabel(),
+		})
+	}
 
-func (ah *AdminHandlers) handleStartEvent(c *gin.Context) {
-	result := ah.stateService.TransitionTo(models.StateStarted)
-	if !result.Success {
-		c.JSON(http.StatusBadRequest, gin.H{"error": result.Error.Error()})
+	c.JSON(http.StatusOK, gin.H{
+		"slides": slides,
+		"active": ah.stateService.GetSlide(),
+	})
+}
+
+// GetScreenChannels lists the screen channels and what each one shows
+func (ah *AdminHandlers) GetScreenChannels(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"channels": ah.hubManager.GetScreenChannels(),
+		"contents": models.AllScreenContents(),
+	})
+}
+
+// SetScreenChannel chooses what the screens of a channel show
+func (ah *AdminHandlers) SetScreenChannel(c *gin.Context) {
+	var req ScreenChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.RespondError(c, invalidRequest(err))
 		return
 	}
 
-	event, err := ah.eventRepo.CreateEvent(ah.config.Event.Title, ah.config.Event.TeamMode, ah.config.Event.TeamSize, ah.config.Event.QrCode)
+	name, err := websocket.ParseScreenChannel(req.Channel)
+	if err == nil {
+		err = models.ValidateScreenContents(req.Shows)
+	}
 	if err != nil {
-		ah.logger.LogError("creating event", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create event"})
+		middleware.RespondError(c, quizerrors.ErrInvalidParameter.WithDetails(err.Error()))
 		return
 	}
 
-	ah.currentEvent = event
+	ah.runGuarded(c, "set_screen_channel", req.ActionGuard, func() (gin.H, *quizerrors.QuizError) {
+		channel, err := ah.hubManager.SetScreenChannel(name, req.Shows)
+		if err != nil {
+			ah.logger.LogError("setting screen channel", err)
+		}
+
+		return gin.H{
+			"message": fmt.Sprintf("スクリーン %s の表示内容を変更しました", channel.Channel),
+			"channel": channel,
+		}, nil
+	})
+}
+
+// operatorFromRequest identifies the console by the X-Operator-ID and
+// X-Operator-Name headers (the name is URL-encoded, headers are ASCII only)
+func operatorFromRequest(c *gin.Context) services.Operator {
+	name := c.GetHeader("X-Operator-Name")
+	if decoded, err := url.QueryUnescape(name); err == nil {
+		name = decoded
+	}
+	return services.Operator{ID: c.GetHeader("X-Operator-ID"), Name: name}
+}
+
+// checkOperatorLock answers 423 when another console holds the operator lock
+func (ah *AdminHandlers) checkOperatorLock(c *gin.Context, operator services.Operator) bool {
+	lock, ok := ah.operators.Allows(operator)
+	if !ok {
+		middleware.RespondError(c, quizerrors.ErrOperatorLocked.WithData("lock", lock))
+	}
+	return ok
+}
+
+// GetOperatorLock returns the operator lock, null when any console may drive the event
+func (ah *AdminHandlers) GetOperatorLock(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"lock": ah.operators.Lock()})
+}
+
+// AcquireOperatorLock lets only the calling console drive the event
+func (ah *AdminHandlers) AcquireOperatorLock(c *gin.Context) {
+	ah.changeOperatorLock(c, ah.operators.Acquire)
+}
+
+// ReleaseOperatorLock lets every console drive the event again
+func (ah *AdminHandlers) ReleaseOperatorLock(c *gin.Context) {
+	ah.changeOperatorLock(c, ah.operators.Release)
+}
+
+func (ah *AdminHandlers) changeOperatorLock(c *gin.Context, change func(services.Operator, bool) (*services.OperatorLock, bool, error)) {
+	var req OperatorLockRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			middleware.RespondError(c, invalidRequest(err))
+			return
+		}
+	}
+
+	operator := operatorFromRequest(c)
+	if operator.ID == "" {
+		middleware.RespondError(c, quizerrors.ErrMissingParameter.WithDetails("X-Operator-ID header is required"))
+		return
+	}
+
+	lock, ok, err := change(operator, req.Force)
+	if err != nil {
+		ah.logger.LogError("broadcasting operator lock", err)
+	}
+	if !ok {
+		middleware.RespondError(c, quizerrors.ErrOperatorLocked.WithData("lock", lock))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"lock": lock})
+}
+
+// GetPresence lists every participant as online, away or offline
+func (ah *AdminHandlers) GetPresence(c *gin.Context) {
+	if ah.presence == nil {
+		middleware.RespondError(c, quizerrors.ErrServiceUnavailable.WithDetails("Presence tracking not available"))
+		return
+	}
+
+	participants, err := ah.presence.GetPresence()
+	if err != nil {
+		ah.logger.LogError("getting presence", err)
+		middleware.RespondError(c, quizerrors.ErrDatabaseError)
+		return
+	}
+
+	counts := map[models.PresenceStatus]int{}
+	for _, participant := range participants {
+		counts[participant.Status]++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"participants": participants,
+		"online":       counts[models.PresenceOnline],
+		"away":         counts[models.PresenceAway],
+		"offline":      counts[models.PresenceOffline],
+	})
+}
+
+// GetConnectionHistory returns the latest connections of a participant
+func (ah *AdminHandlers) GetConnectionHistory(c *gin.Context) {
+	if ah.presence == nil {
+		middleware.RespondError(c, quizerrors.ErrServiceUnavailable.WithDetails("Presence tracking not available"))
+		return
+	}
+
+	userID := 0
+	if _, err := fmt.Sscanf(c.Param("user_id"), "%d", &userID); err != nil {
+		middleware.RespondError(c, quizerrors.ErrInvalidParameter.WithDetails("Invalid user ID"))
+		return
+	}
+
+	history, err := ah.presence.GetConnectionHistory(userID)
+	if err != nil {
+		ah.logger.LogError("getting connection history", err)
+		middleware.RespondError(c, quizerrors.ErrDatabaseError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":     userID,
+		"status":      ah.presence.Status(userID),
+		"connections": history,
+	})
+}
+
+// GetConnectionQuality returns the latency statistics of the connected
+// participants and flags the degrading connections
+func (ah *AdminHandlers) GetConnectionQuality(c *gin.Context) {
+	if ah.pingManager == nil {
+		middleware.RespondError(c, quizerrors.ErrServiceUnavailable.WithDetails("Connection measurement not available"))
+		return
+	}
+
+	c.JSON(http.StatusOK, ah.pingManager.ConnectionQuality())
+}
+
+// ShowSlide pushes an announcement slide over the current state
+func (ah *AdminHandlers) ShowSlide(c *gin.Context) {
+	var req SlideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.RespondError(c, invalidRequest(err))
+		return
+	}
+
+	ah.runGuarded(c, "show_slide", req.ActionGuard, func() (gin.H, *quizerrors.QuizError) {
+		var slide models.Slide
+		if req.SlideID != "" {
+			configured, ok := ah.config.FindSlide(req.SlideID)
+			if !ok {
+				return nil, quizerrors.ErrDataNotFound.WithDetails("Slide not found")
+			}
+			slide = *configured
+		} else {
+			slide = models.Slide{Type: models.SlideTypeText, Title: req.Title, Text: req.Text}
+		}
+
+		if err := ah.stateService.ShowSlide(slide); err != nil {
+			return nil, actionRefused(err)
+		}
+
+		return gin.H{
+			"message": fmt.Sprintf("スライド「%s」を表示しました", slide.DisplayLabel()),
+			"slide":   slide,
+			"state":   ah.stateService.GetCurrentState(),
+		}, nil
+	})
+}
+
+// DismissSlide removes the announcement and returns clients to the current state
+func (ah *AdminHandlers) DismissSlide(c *gin.Context) {
+	// The guard is optional, so is the body
+	var guard ActionGuard
+	if err := c.ShouldBindJSON(&guard); err != nil && !errors.Is(err, io.EOF) {
+		middleware.RespondError(c, invalidRequest(err))
+		return
+	}
+
+	ah.runGuarded(c, "dismiss_slide", guard, func() (gin.H, *quizerrors.QuizError) {
+		slide, err := ah.stateService.DismissSlide()
+		if err != nil {
+			return nil, actionRefused(err)
+		}
+
+		return gin.H{
+			"message": fmt.Sprintf("スライド「%s」を閉じました", slide.DisplayLabel()),
+			"state":   ah.stateService.GetCurrentState(),
+		}, nil
+	})
+}
+
+// Private action handlers
+
+func (ah *AdminHandlers) handleStartEvent() (gin.H, *quizerrors.QuizError) {
+	// The event row of the room is created at startup
+	if ah.currentEvent == nil {
+		return nil, quizerrors.ErrInternalError.WithDetails("No event for this room")
+	}
+
+	result := ah.stateService.PerformAction(models.ActionStartEvent)
+	if !result.Success {
+		return nil, actionRefused(result.Error)
+	}
+
 	ah.logger.LogEventStart(ah.config.Event.Title, ah.config.Event.TeamMode, 0)
 
-	eventData := gin.H{
-		"event": ah.currentEvent,
-		"title": ah.config.Event.Title,
-		"state": ah.stateService.GetCurrentState(),
+	eventData := websocket.EventStartedData{
+		Event: ah.currentEvent,
+		Title: ah.config.Event.Title,
+		State: ah.stateService.GetCurrentState(),
 	}
 
 	if err := ah.hubManager.BroadcastEventStarted(eventData); err != nil {
 		ah.logger.LogError("broadcasting event started", err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	return gin.H{
 		"message": "イベントを開始しました",
 		"event":   ah.currentEvent,
 		"state":   ah.stateService.GetCurrentState(),
-	})
+	}, nil
 }
 
-func (ah *AdminHandlers) handleShowTitle(c *gin.Context) {
-	result := ah.stateService.TransitionTo(models.StateTitleDisplay)
+func (ah *AdminHandlers) handleShowTitle() (gin.H, *quizerrors.QuizError) {
+	result := ah.stateService.PerformAction(models.ActionShowTitle)
 	if !result.Success {
-		c.JSON(http.StatusBadRequest, gin.H{"error": result.Error.Error()})
-		return
+		return nil, actionRefused(result.Error)
 	}
 
-	titleData := gin.H{
-		"title": ah.config.Event.Title,
-		"state": ah.stateService.GetCurrentState(),
+	titleData := websocket.TitleDisplayData{
+		Title: ah.config.Event.Title,
+		State: ah.stateService.GetCurrentState(),
 	}
 
 	if err := ah.hubManager.BroadcastTitleDisplay(titleData); err != nil {
 		ah.logger.LogError("broadcasting title display", err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	return gin.H{
 		"message": "タイトルを表示しました",
 		"state":   ah.stateService.GetCurrentState(),
-	})
+	}, nil
 }
 
-func (ah *AdminHandlers) handleAssignTeams(c *gin.Context) {
-	result := ah.stateService.TransitionTo(models.StateTeamAssignment)
+func (ah *AdminHandlers) handleAssignTeams() (gin.H, *quizerrors.QuizError) {
+	result := ah.stateService.PerformAction(models.ActionAssignTeams)
 	if !result.Success {
-		c.JSON(http.StatusBadRequest, gin.H{"error": result.Error.Error()})
-		return
+		return nil, actionRefused(result.Error)
+	}
+
+	var ratings models.PlayerRatings
+	if ah.config.TeamBalance.Mode == models.TeamBalanceRating {
+		loaded, err := ah.teamAssignmentSvc.LoadRatings()
+		if err != nil {
+			// Fall back to the default shuffle rather than blocking the event
+			ah.logger.LogError("loading player ratings", err)
+		} else {
+			ratings = loaded
+		}
 	}
 
-	teams, err := ah.teamAssignmentSvc.CreateTeamsAndAssignUsers()
+	teams, err := ah.teamAssignmentSvc.CreateTeamsAndAssignUsers(ratings)
 	if err != nil {
 		ah.logger.LogError("creating teams", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create teams"})
-		return
+		return nil, quizerrors.ErrInternalError.WithDetails("Failed to create teams")
 	}
 
-	teamsData := gin.H{
-		"teams": teams,
-		"state": ah.stateService.GetCurrentState(),
+	teamsData := websocket.TeamAssignmentData{
+		Teams: teams,
+		State: ah.stateService.GetCurrentState(),
 	}
 
 	if err := ah.hubManager.BroadcastTeamAssignment(teamsData); err != nil {
@@ -271,168 +640,266 @@ func (ah *AdminHandlers) handleAssignTeams(c *gin.Context) {
 
 	ah.logger.LogTeamAssignment(len(teams), ah.getTotalUsersInTeams(teams))
 
-	c.JSON(http.StatusOK, gin.H{
+	return gin.H{
 		"message": "チーム分けを実行しました",
 		"teams":   teams,
 		"state":   ah.stateService.GetCurrentState(),
-	})
+	}, nil
 }
 
-func (ah *AdminHandlers) handleNextQuestion(c *gin.Context) {
-	result := ah.stateService.NextQuestion()
+func (ah *AdminHandlers) handleNextQuestion() (gin.H, *quizerrors.QuizError) {
+	result := ah.stateService.PerformAction(models.ActionNextQuestion)
 	if !result.Success {
-		c.JSON(http.StatusBadRequest, gin.H{"error": result.Error.Error()})
-		return
+		return nil, actionRefused(result.Error)
 	}
 
 	questionNum := ah.stateService.GetQuestionNumber()
 	if questionNum > len(ah.config.Questions) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No more questions"})
-		return
+		return nil, quizerrors.ErrQuestionNotFound.WithDetails("No more questions")
 	}
 
 	question := ah.config.Questions[questionNum-1]
 	ah.currentQuestion = &question
 
 	if ah.currentEvent != nil {
-		err := ah.eventRepo.UpdateQuestionNumber(ah.currentEvent.ID, questionNum)
-		if err != nil {
-			ah.logger.LogError("updating current question", err)
-		} else {
-			ah.currentEvent.QuestionNumber = questionNum
-		}
+		ah.currentEvent.QuestionNumber = questionNum
 	}
 
 	ah.logger.LogQuestionStart(questionNum, question.Text)
 
-	// websocket 本文
-	questionAndAnswerData := gin.H{
-		"question_number": questionNum,
-		"question":        question,
-		"total_questions": len(ah.config.Questions),
-		"correct":         question.Correct,
-	}
+	questionData := ah.broadcastQuestion(questionNum, question)
+
+	return gin.H{
+		"message":  "次の問題を開始しました",
+		"question": questionData,
+		"state":    ah.stateService.GetCurrentState(),
+	}, nil
+}
 
-	questionData := gin.H{
-		"question_number": questionNum,
-		"question": gin.H{
-			"type":    question.Type,
-			"text":    question.Text,
-			"image":   question.Image,
-			"choices": question.Choices,
-		},
+// broadcastQuestion sends the question to all clients (with the answer for admins only)
+func (ah *AdminHandlers) broadcastQuestion(questionNum int, question models.Question) websocket.QuestionStartData {
+	questionData := websocket.QuestionStartData{
+		QuestionNumber: questionNum,
+		Question:       websocket.NewQuestionContent(question),
 	}
 
+	// 管理者には正解と配点も送る
+	questionAndAnswerData := questionData
+	questionAndAnswerData.Question.Correct = question.Correct
+	questionAndAnswerData.Question.Point = question.Point
+	questionAndAnswerData.TotalQuestions = len(ah.config.Questions)
+	questionAndAnswerData.Correct = question.Correct
+
 	if err := ah.hubManager.BroadcastQuestionStart(questionData, questionAndAnswerData); err != nil {
 		ah.logger.LogError("broadcasting question start", err)
 	}
+	if ah.tally != nil {
+		ah.tally.Open(questionNum) // Live counts start from 0 (or the kept answers when reopened)
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":  "次の問題を開始しました",
-		"question": questionData,
-		"state":    ah.stateService.GetCurrentState(),
-	})
+	return questionData
 }
 
-func (ah *AdminHandlers) handleCountdownAlert(c *gin.Context) {
-	result := ah.stateService.StartCountdownWithAutoTransition()
+func (ah *AdminHandlers) handleCountdownAlert() (gin.H, *quizerrors.QuizError) {
+	result := ah.stateService.PerformAction(models.ActionCountdownAlert)
 	if !result.Success {
-		c.JSON(http.StatusBadRequest, gin.H{"error": result.Error.Error()})
-		return
+		return nil, actionRefused(result.Error)
+	}
+
+	countdownData := websocket.CountdownData{
+		SecondsLeft: 5,
+	}
+	if err := ah.hubManager.BroadcastCountdown(countdownData); err != nil {
+		ah.logger.LogError("broadcasting countdown", err)
 	}
 
 	ah.logger.LogAlert("5秒カウントダウン開始")
 
-	c.JSON(http.StatusOK, gin.H{
+	return gin.H{
 		"message": "5秒カウントダウンを開始しました",
 		"state":   ah.stateService.GetCurrentState(),
-	})
+	}, nil
 }
 
-func (ah *AdminHandlers) handleShowAnswerStats(c *gin.Context) {
-	// The transition may have already occurred from countdown auto-transition
-	if ah.stateService.GetCurrentState() != models.StateAnswerStats {
-		result := ah.stateService.TransitionTo(models.StateAnswerStats)
-		if !result.Success {
-			c.JSON(http.StatusBadRequest, gin.H{"error": result.Error.Error()})
-			return
-		}
+func (ah *AdminHandlers) handleCloseAnswers() (gin.H, *quizerrors.QuizError) {
+	result := ah.stateService.PerformAction(models.ActionCloseAnswers)
+	if !result.Success {
+		return nil, actionRefused(result.Error)
+	}
+
+	if err := ah.hubManager.BroadcastQuestionEnd(websocket.QuestionEndData{}); err != nil {
+		ah.logger.LogError("broadcasting question end", err)
 	}
 
-	users, _ := ah.userRepo.GetAllUsers()
-	answeredCount := 0
-	correctCount := 0
-	currentQuestionNum := ah.stateService.GetQuestionNumber()
+	return gin.H{
+		"message": "回答を締め切りました",
+		"state":   ah.stateService.GetCurrentState(),
+	}, nil
+}
+
+func (ah *AdminHandlers) handleReopenAnswers() (gin.H, *quizerrors.QuizError) {
+	result := ah.stateService.PerformAction(models.ActionReopenAnswers)
+	if !result.Success {
+		return nil, actionRefused(result.Error)
+	}
 
-	// 現在の問題情報を取得
 	if ah.currentQuestion == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No current question"})
-		return
+		return nil, quizerrors.ErrQuestionNotFound.WithDetails("No current question")
 	}
 
-	// 各選択肢の回答数をカウント
-	choicesCounts := make([]int, len(ah.currentQuestion.Choices))
+	// Answers already given are kept; participants can answer or change again
+	questionNum := ah.stateService.GetQuestionNumber()
+	questionData := ah.broadcastQuestion(questionNum, *ah.currentQuestion)
 
-	for _, user := range users {
-		answer, _ := ah.answerRepo.GetAnswerByUserAndQuestion(user.ID, currentQuestionNum)
-		if answer != nil {
-			answeredCount++
-			if answer.IsCorrect {
-				correctCount++
-			}
-			// 回答インデックス（1-based）を0-basedに変換してカウント
-			if answer.AnswerIndex >= 1 && answer.AnswerIndex <= len(choicesCounts) {
-				choicesCounts[answer.AnswerIndex-1]++
-			}
-		}
+	ah.logger.LogAlert(fmt.Sprintf("問題 %d の回答受付を再開", questionNum))
+
+	return gin.H{
+		"message":  "回答受付を再開しました",
+		"question": questionData,
+		"state":    ah.stateService.GetCurrentState(),
+	}, nil
+}
+
+func (ah *AdminHandlers) handleShowAnswerStats() (gin.H, *quizerrors.QuizError) {
+	result := ah.stateService.PerformAction(models.ActionShowAnswerStats)
+	if !result.Success {
+		return nil, actionRefused(result.Error)
+	}
+
+	if ah.currentQuestion == nil {
+		return nil, quizerrors.ErrQuestionNotFound.WithDetails("No current question")
+	}
+	if ah.tally == nil {
+		return nil, quizerrors.ErrServiceUnavailable.WithDetails("Answer counts unavailable")
 	}
 
-	statsData := gin.H{
-		"total_participants": len(users),
-		"choices_counts":     choicesCounts,
+	// 回答率の分母は接続中の参加者と回答済みの参加者 (離脱した参加者は数えない)
+	counts, err := ah.tally.Counts(ah.stateService.GetQuestionNumber())
+	if err != nil {
+		ah.logger.LogError("counting answers", err)
+		return nil, quizerrors.ErrDatabaseError
+	}
+
+	answerRate := 0.0
+	if counts.TotalParticipants > 0 {
+		answerRate = float64(counts.AnsweredCount) * 100 / float64(counts.TotalParticipants)
+	}
+
+	statsData := websocket.AnswerStatsData{
+		TotalParticipants: counts.TotalParticipants,
+		AnsweredCount:     counts.AnsweredCount,
+		AnswerRate:        answerRate,
+		ChoicesCounts:     counts.ChoicesCounts,
 	}
 
 	if err := ah.hubManager.BroadcastAnswerStats(statsData); err != nil {
 		ah.logger.LogError("broadcasting answer stats", err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	return gin.H{
 		"message": "回答状況を表示しました",
 		"state":   ah.stateService.GetCurrentState(),
-	})
+	}, nil
 }
 
-func (ah *AdminHandlers) handleRevealAnswer(c *gin.Context) {
-	result := ah.stateService.TransitionTo(models.StateAnswerReveal)
+func (ah *AdminHandlers) handleRevealAnswer() (gin.H, *quizerrors.QuizError) {
+	result := ah.stateService.PerformAction(models.ActionRevealAnswer)
 	if !result.Success {
-		c.JSON(http.StatusBadRequest, gin.H{"error": result.Error.Error()})
-		return
+		return nil, actionRefused(result.Error)
 	}
 
 	if ah.currentQuestion == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No current question"})
-		return
+		return nil, quizerrors.ErrQuestionNotFound.WithDetails("No current question")
 	}
 
-	revealData := gin.H{
-		"correct": ah.currentQuestion.Correct,
+	revealData := websocket.AnswerRevealData{
+		Correct: ah.currentQuestion.Correct,
 	}
 
 	if err := ah.hubManager.BroadcastAnswerReveal(revealData); err != nil {
 		ah.logger.LogError("broadcasting answer reveal", err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	return gin.H{
 		"message": "回答を発表しました",
 		"state":   ah.stateService.GetCurrentState(),
+	}, nil
+}
+
+func (ah *AdminHandlers) handleShowLeaderboard() (gin.H, *quizerrors.QuizError) {
+	result := ah.stateService.PerformAction(models.ActionShowLeaderboard)
+	if !result.Success {
+		return nil, actionRefused(result.Error)
+	}
+
+	users, err := ah.userRepo.GetAllUsers()
+	if err != nil {
+		ah.logger.LogError("getting leaderboard", err)
+		users = []models.User{}
+	}
+	sort.SliceStable(users, func(i, j int) bool {
+		return users[i].Score > users[j].Score
 	})
+
+	var teams []models.Team
+	if ah.config.Event.TeamMode {
+		teams, err = ah.teamAssignmentSvc.CalculateTeamScores()
+		if err != nil {
+			ah.logger.LogError("calculating team scores", err)
+			teams = []models.Team{}
+		}
+	}
+
+	leaderboardData := websocket.LeaderboardData{
+		Results:        users,
+		Teams:          teams,
+		TeamMode:       ah.config.Event.TeamMode,
+		QuestionNumber: ah.stateService.GetQuestionNumber(),
+		TotalQuestions: len(ah.config.Questions),
+	}
+
+	if err := ah.hubManager.BroadcastLeaderboard(leaderboardData); err != nil {
+		ah.logger.LogError("broadcasting leaderboard", err)
+	}
+
+	return gin.H{
+		"message": "途中経過を発表しました",
+		"results": users,
+		"teams":   teams,
+		"state":   ah.stateService.GetCurrentState(),
+	}, nil
 }
 
-func (ah *AdminHandlers) handleShowResults(c *gin.Context) {
-	result := ah.stateService.TransitionTo(models.StateResults)
+func (ah *AdminHandlers) handleIntermission() (gin.H, *quizerrors.QuizError) {
+	result := ah.stateService.PerformAction(models.ActionIntermission)
 	if !result.Success {
-		c.JSON(http.StatusBadRequest, gin.H{"error": result.Error.Error()})
-		return
+		return nil, actionRefused(result.Error)
+	}
+
+	message := ""
+	if result.Transition != nil {
+		message = result.Transition.Message
+	}
+
+	intermissionData := websocket.IntermissionData{
+		Message:        message,
+		QuestionNumber: ah.stateService.GetQuestionNumber(),
+	}
+
+	if err := ah.hubManager.BroadcastIntermission(intermissionData); err != nil {
+		ah.logger.LogError("broadcasting intermission", err)
+	}
+
+	return gin.H{
+		"message": "休憩に入りました",
+		"state":   ah.stateService.GetCurrentState(),
+	}, nil
+}
+
+func (ah *AdminHandlers) handleShowResults() (gin.H, *quizerrors.QuizError) {
+	result := ah.stateService.PerformAction(models.ActionShowResults)
+	if !result.Success {
+		return nil, actionRefused(result.Error)
 	}
 
 	users, err := ah.userRepo.GetAllUsers()
@@ -450,46 +917,134 @@ func (ah *AdminHandlers) handleShowResults(c *gin.Context) {
 		}
 	}
 
-	resultsData := gin.H{
-		"results":   users,
-		"teams":     teams,
-		"team_mode": ah.config.Event.TeamMode,
+	if err := ah.teamAssignmentSvc.RecordEventResults(); err != nil {
+		ah.logger.LogError("recording event results", err)
+	}
+
+	resultsData := websocket.FinalResultsData{
+		Results:  users,
+		Teams:    teams,
+		TeamMode: ah.config.Event.TeamMode,
 	}
 
 	if err := ah.hubManager.BroadcastFinalResults(resultsData); err != nil {
 		ah.logger.LogError("broadcasting final results", err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	return gin.H{
 		"message": "結果を発表しました",
 		"results": users,
 		"teams":   teams,
 		"state":   ah.stateService.GetCurrentState(),
-	})
+	}, nil
 }
 
-func (ah *AdminHandlers) handleCelebration(c *gin.Context) {
-	result := ah.stateService.TransitionTo(models.StateCelebration)
+func (ah *AdminHandlers) handleCelebration() (gin.H, *quizerrors.QuizError) {
+	result := ah.stateService.PerformAction(models.ActionCelebration)
 	if !result.Success {
-		c.JSON(http.StatusBadRequest, gin.H{"error": result.Error.Error()})
-		return
+		return nil, actionRefused(result.Error)
 	}
 
-	celebrationData := gin.H{
-		"state": ah.stateService.GetCurrentState(),
+	celebrationData := websocket.CelebrationData{
+		State: ah.stateService.GetCurrentState(),
 	}
 
 	if err := ah.hubManager.BroadcastCelebration(celebrationData); err != nil {
 		ah.logger.LogError("broadcasting celebration", err)
 	}
 
-	// Auto-transition to finished after 5 seconds
-	ah.stateService.AutoTransitionToCelebration(5)
-
-	c.JSON(http.StatusOK, gin.H{
+	return gin.H{
 		"message": "🎉 お疲れ様でした！",
 		"state":   ah.stateService.GetCurrentState(),
-	})
+	}, nil
+}
+
+func (ah *AdminHandlers) handleFinish() (gin.H, *quizerrors.QuizError) {
+	result := ah.stateService.PerformAction(models.ActionFinish)
+	if !result.Success {
+		return nil, actionRefused(result.Error)
+	}
+
+	return gin.H{
+		"message": "イベントを終了しました",
+		"state":   ah.stateService.GetCurrentState(),
+	}, nil
+}
+
+func (ah *AdminHandlers) handleGoBack() (gin.H, *quizerrors.QuizError) {
+	result := ah.stateService.GoBack()
+	if !result.Success {
+		return nil, actionRefused(result.Error)
+	}
+
+	ah.restoreCurrentQuestion()
+
+	return gin.H{
+		"message":         fmt.Sprintf("「%s」に戻りました", models.GetStateLabel(result.NewState)),
+		"state":           ah.stateService.GetCurrentState(),
+		"question_number": ah.stateService.GetQuestionNumber(),
+	}, nil
+}
+
+func (ah *AdminHandlers) handlePause() (gin.H, *quizerrors.QuizError) {
+	status, err := ah.stateService.Pause()
+	if err != nil {
+		return nil, actionRefused(err)
+	}
+
+	return gin.H{
+		"message":      "イベントを一時停止しました",
+		"state":        status.State,
+		"paused":       status.Paused,
+		"remaining_ms": status.RemainingMs,
+	}, nil
+}
+
+func (ah *AdminHandlers) handleResume() (gin.H, *quizerrors.QuizError) {
+	status, err := ah.stateService.Resume()
+	if err != nil {
+		return nil, actionRefused(err)
+	}
+
+	return gin.H{
+		"message":      "イベントを再開しました",
+		"state":        status.State,
+		"paused":       status.Paused,
+		"remaining_ms": status.RemainingMs,
+	}, nil
+}
+
+func (ah *AdminHandlers) handleAutopilot(action string) (gin.H, *quizerrors.QuizError) {
+	var message string
+	switch action {
+	case models.ActionAutopilotSkip:
+		if err := ah.stateService.AutopilotSkip(); err != nil {
+			return nil, actionRefused(err)
+		}
+		message = "次のステップに進みます"
+	case models.ActionAutopilotExtend:
+		step, err := ah.stateService.AutopilotExtend()
+		if err != nil {
+			return nil, actionRefused(err)
+		}
+		message = fmt.Sprintf("%s延長しました", step)
+	case models.ActionAutopilotManual:
+		if err := ah.stateService.SetAutopilotActive(false); err != nil {
+			return nil, actionRefused(err)
+		}
+		message = "手動操作に切り替えました"
+	case models.ActionAutopilotAuto:
+		if err := ah.stateService.SetAutopilotActive(true); err != nil {
+			return nil, actionRefused(err)
+		}
+		message = "自動進行に切り替えました"
+	}
+
+	return gin.H{
+		"message":   message,
+		"state":     ah.stateService.GetCurrentState(),
+		"autopilot": ah.stateService.GetAutopilotStatus(),
+	}, nil
 }
 
 // Helper methods
@@ -502,9 +1057,40 @@ func (ah *AdminHandlers) getTotalUsersInTeams(teams []models.Team) int {
 	return total
 }
 
+// restoreCurrentQuestion rebuilds the current question from the state manager's question number
+func (ah *AdminHandlers) restoreCurrentQuestion() {
+	questionNum := ah.stateService.GetQuestionNumber()
+	if questionNum > 0 && questionNum <= len(ah.config.Questions) {
+		question := ah.config.Questions[questionNum-1]
+		ah.currentQuestion = &question
+	} else {
+		ah.currentQuestion = nil
+	}
+
+	if ah.currentEvent != nil {
+		ah.currentEvent.QuestionNumber = questionNum
+	}
+}
+
 // SetCurrentEvent sets the current event (for handlers that need it)
 func (ah *AdminHandlers) SetCurrentEvent(event *models.Event) {
 	ah.currentEvent = event
+	ah.restoreCurrentQuestion()
+}
+
+// SetPresence sets the service answer rates and the presence endpoints read
+func (ah *AdminHandlers) SetPresence(presence *services.PresenceService) {
+	ah.presence = presence
+}
+
+// SetAnswerTally sets the live answer counts the answer stats are taken from
+func (ah *AdminHandlers) SetAnswerTally(tally *services.AnswerTally) {
+	ah.tally = tally
+}
+
+// SetPingManager sets the ping manager the connection statistics come from
+func (ah *AdminHandlers) SetPingManager(pingManager *websocket.PingManager) {
+	ah.pingManager = pingManager
 }
 
 // SetDBResetCallback sets the callback function for database reset
@@ -514,13 +1100,16 @@ func (ah *AdminHandlers) SetDBResetCallback(callback func() error) {
 
 // ResetDatabase handles database reset request
 func (ah *AdminHandlers) ResetDatabase(c *gin.Context) {
+	if !ah.checkOperatorLock(c, operatorFromRequest(c)) {
+		return
+	}
 	dbPath := "database/quiz.db"
 
 	// Create backup
 	backupDir := "logs"
 	if err := os.MkdirAll(backupDir, 0755); err != nil {
 		ah.logger.LogError("creating backup directory", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create backup directory"})
+		middleware.RespondError(c, quizerrors.ErrInternalError.WithDetails("Failed to create backup directory"))
 		return
 	}
 
@@ -530,15 +1119,15 @@ func (ah *AdminHandlers) ResetDatabase(c *gin.Context) {
 	// Copy database file
 	if err := copyFile(dbPath, backupPath); err != nil {
 		ah.logger.LogError("creating database backup", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create backup"})
+		middleware.RespondError(c, quizerrors.ErrInternalError.WithDetails("Failed to create backup"))
 		return
 	}
 
 	ah.logger.Info("Database backup created: %s", backupPath)
 
 	// Broadcast reset notification to all clients
-	resetData := gin.H{
-		"message": "データベースがリセットされました。ページをリロードしてください。",
+	resetData := websocket.DatabaseResetData{
+		Message: "データベースがリセットされました。ページをリロードしてください。",
 	}
 	if err := ah.hubManager.BroadcastDatabaseReset(resetData); err != nil {
 		ah.logger.LogError("broadcasting database reset", err)
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"net/http"
+	quizerrors "quiz100/errors"
+	"quiz100/middleware"
+	"quiz100/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthHandlers log staff in and out of the admin console and the screens.
+// They are shared by all rooms.
+type AuthHandlers struct {
+	auth     *middleware.Auth
+	accounts *models.AdminAccounts
+	sessions *models.AdminSessionRepository
+	throttle *models.LoginThrottle
+	logger   *models.QuizLogger
+	ttl      time.Duration
+}
+
+// LoginRequest represents a staff login
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// NewAuthHandlers creates a new AuthHandlers instance
+func NewAuthHandlers(auth *middleware.Auth, accounts *models.AdminAccounts, sessions *models.AdminSessionRepository, logger *models.QuizLogger) *AuthHandlers {
+	return &AuthHandlers{
+		auth:     auth,
+		accounts: accounts,
+		sessions: sessions,
+		throttle: models.NewLoginThrottle(),
+		logger:   logger,
+		ttl:      models.DefaultAdminSessionTTL,
+	}
+}
+
+// Login checks the password and sets the session cookie. An IP that sent
+// too many wrong passwords gets 429 with Retry-After.
+func (ah *AuthHandlers) Login(c *gin.Context) {
+	ip := c.ClientIP()
+	if ok, wait := ah.throttle.Allow(ip); !ok {
+		seconds := models.RetryAfterSeconds(wait)
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		middleware.RespondError(c, quizerrors.ErrRateLimited.WithData("retry_after", seconds))
+		return
+	}
+
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.RespondError(c, invalidRequest(err))
+		return
+	}
+
+	account, ok := ah.accounts.Authenticate(req.Username, req.Password)
+	if !ok {
+		ah.throttle.Failed(ip)
+		ah.logger.Warning("Admin login failed for %q from %s", req.Username, ip)
+		middleware.RespondError(c, quizerrors.ErrInvalidCredentials)
+		return
+	}
+	ah.throttle.Succeeded(ip)
+
+	token, expiresAt, err := ah.sessions.Create(account.Username, ah.ttl)
+	if err != nil {
+		ah.logger.LogError("creating admin session", err)
+		middleware.RespondError(c, quizerrors.ErrDatabaseError)
+		return
+	}
+	ah.setCookie(c, token, int(ah.ttl.Seconds()))
+	ah.logger.Info("Admin login: %s (%s)", account.Username, account.Role)
+
+	c.JSON(http.StatusOK, gin.H{
+		"username":   account.Username,
+		"role":       account.Role,
+		"expires_at": expiresAt,
+	})
+}
+
+// Logout ends the session of the cookie
+func (ah *AuthHandlers) Logout(c *gin.Context) {
+	if token, err := c.Cookie(middleware.SessionCookie); err == nil && token != "" {
+		if err := ah.sessions.Delete(token); err != nil {
+			ah.logger.LogError("deleting admin session", err)
+			middleware.RespondError(c, quizerrors.ErrDatabaseError)
+			return
+		}
+	}
+	ah.setCookie(c, "", -1)
+	c.JSON(http.StatusOK, gin.H{"status": "Logged out"})
+}
+
+// Me returns who is logged in, so pages can adapt to the role
+func (ah *AuthHandlers) Me(c *gin.Context) {
+	identity, err := ah.auth.Identify(c)
+	if err != nil {
+		ah.logger.LogError("identifying admin", err)
+		middleware.RespondError(c, quizerrors.ErrInternalError.WithDetails("authentication failed"))
+		return
+	}
+	if identity == nil {
+		middleware.RespondError(c, quizerrors.ErrUnauthorized)
+		return
+	}
+	c.JSON(http.StatusOK, identity)
+}
+
+func (ah *AuthHandlers) setCookie(c *gin.Context, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(middleware.SessionCookie, value, maxAge, "/", "", c.Request.TLS != nil, true)
+}
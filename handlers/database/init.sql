@@ -1,5 +1,6 @@
 CREATE TABLE IF NOT EXISTS users (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER DEFAULT 0,
     session_id TEXT UNIQUE NOT NULL,
     nickname TEXT NOT NULL,
     team_id INTEGER,
@@ -11,6 +12,7 @@ CREATE TABLE IF NOT EXISTS users (
 
 CREATE TABLE IF NOT EXISTS teams (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER DEFAULT 0,
     name TEXT NOT NULL,
     score INTEGER DEFAULT 0,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
@@ -19,8 +21,9 @@ CREATE TABLE IF NOT EXISTS teams (
 
 CREATE TABLE IF NOT EXISTS events (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
+    room_code TEXT DEFAULT '',
     title TEXT NOT NULL,
-    status TEXT DEFAULT 'waiting', -- waiting, started, finished
+    status TEXT DEFAULT 'waiting', -- current EventState (finished when over)
     question_number INTEGER DEFAULT 0,
     team_mode BOOLEAN DEFAULT false,
     team_size INTEGER DEFAULT 1,
@@ -31,6 +34,7 @@ CREATE TABLE IF NOT EXISTS events (
 
 CREATE TABLE IF NOT EXISTS answers (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER DEFAULT 0,
     user_id INTEGER,
     question_number INTEGER,
     answer_index INTEGER,
@@ -41,6 +45,7 @@ CREATE TABLE IF NOT EXISTS answers (
 
 CREATE TABLE IF NOT EXISTS emoji_reactions (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER DEFAULT 0,
     user_id INTEGER,
     emoji TEXT NOT NULL,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
@@ -49,4 +54,59 @@ CREATE TABLE IF NOT EXISTS emoji_reactions (
 
 CREATE INDEX IF NOT EXISTS idx_users_session_id ON users(session_id);
 CREATE INDEX IF NOT EXISTS idx_answers_user_question ON answers(user_id, question_number);
-CREATE INDEX IF NOT EXISTS idx_emoji_reactions_created_at ON emoji_reactions(created_at);
\ No newline at end of file
+CREATE UNIQUE INDEX IF NOT EXISTS idx_answers_event_user_question ON answers(event_id, user_id, question_number);
+CREATE INDEX IF NOT EXISTS idx_emoji_reactions_created_at ON emoji_reactions(created_at);
+CREATE INDEX IF NOT EXISTS idx_users_event ON users(event_id);
+CREATE INDEX IF NOT EXISTS idx_teams_event ON teams(event_id);
+CREATE INDEX IF NOT EXISTS idx_events_room ON events(room_code, status);
+
+CREATE TABLE IF NOT EXISTS player_results (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER NOT NULL,
+    nickname TEXT NOT NULL,
+    event_title TEXT NOT NULL,
+    correct_count INTEGER DEFAULT 0,
+    question_count INTEGER DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_player_results_nickname ON player_results(nickname);
+CREATE INDEX IF NOT EXISTS idx_player_results_event ON player_results(event_id);
+
+CREATE TABLE IF NOT EXISTS user_connections (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id INTEGER DEFAULT 0,
+    user_id INTEGER,
+    transport TEXT DEFAULT 'websocket', -- websocket or sse
+    connected_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    disconnected_at DATETIME, -- NULL while connected
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_connections_user ON user_connections(event_id, user_id);
+
+CREATE TABLE IF NOT EXISTS event_progress (
+    event_id INTEGER PRIMARY KEY,
+    history TEXT DEFAULT '[]', -- JSON list of states for go back
+    paused BOOLEAN DEFAULT false,
+    paused_at DATETIME,
+    timer_action TEXT DEFAULT '',
+    timer_deadline DATETIME,
+    timer_remaining_ms INTEGER DEFAULT 0,
+    slide TEXT DEFAULT '', -- JSON of the announcement shown over the state
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (event_id) REFERENCES events (id)
+);
+
+CREATE TABLE IF NOT EXISTS server_secrets (
+    name TEXT PRIMARY KEY,
+    value TEXT NOT NULL, -- hex encoded
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS admin_sessions (
+    token_hash TEXT PRIMARY KEY, -- SHA-256 of the cookie value
+    username TEXT NOT NULL,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	quizerrors "quiz100/errors"
+)
+
+// invalidRequest is the error for a request body that does not bind
+func invalidRequest(err error) *quizerrors.QuizError {
+	return quizerrors.ErrInvalidRequest.WithDetails(err.Error())
+}
+
+// actionRefused is the error for an action the current state does not allow
+func actionRefused(err error) *quizerrors.QuizError {
+	return quizerrors.ErrStateNotAllowed.WithDetails(err.Error())
+}
@@ -2,17 +2,20 @@ package handlers
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
 
-	"quiz100/database"
+	"quiz100/middleware"
 	"quiz100/models"
+	"quiz100/services"
 	"quiz100/websocket"
 
 	"github.com/gin-gonic/gin"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -21,11 +24,21 @@ func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
 
-func setupTestHandler() (*ParticipantHandlers, error) {
-	// Create test database
-	db, err := database.NewDatabase(":memory:")
+// setupTestHandler wires the participant handlers of one room the way
+// rooms.NewRoom does, on an in-memory database
+func setupTestHandler(t *testing.T) (*ParticipantHandlers, *services.StateService) {
+	db, err := sql.Open("sqlite3", ":memory:")
 	if err != nil {
-		return nil, err
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.SetMaxOpenConns(1) // Every connection to :memory: is a new database
+	t.Cleanup(func() { db.Close() })
+	schema, err := os.ReadFile("../database/init.sql")
+	if err != nil {
+		t.Fatalf("Failed to read schema: %v", err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatalf("Failed to execute schema: %v", err)
 	}
 
 	// Create test config
@@ -41,40 +54,72 @@ func setupTestHandler() (*ParticipantHandlers, error) {
 				Text:    "Test question 1?",
 				Choices: []string{"A", "B", "C", "D"},
 				Correct: 1,
+				Point:   1,
 			},
 			{
 				Type:    "text",
 				Text:    "Test question 2?",
-				Choices: []string{"X", "Y", "Z"},
+				Choices: []string{"W", "X", "Y", "Z"},
 				Correct: 2,
+				Point:   1,
 			},
 		},
 	}
 
 	// Create test logger
-	logger, err := models.NewQuizLogger("logs")
+	logger, err := models.NewQuizLogger(t.TempDir())
 	if err != nil {
-		return nil, err
+		t.Fatalf("Failed to create logger: %v", err)
 	}
 
+	event, err := models.NewEventRepository(db).CreateEvent("main", config.Event.Title, config.Event.TeamMode, config.Event.TeamSize, "")
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	// Create repositories
+	userRepo := models.NewUserRepository(db, event.ID)
+	answerRepo := models.NewAnswerRepository(db, event.ID)
+	emojiReactionRepo := models.NewEmojiReactionRepository(db, event.ID)
+	teamRepo := models.NewTeamRepository(db, event.ID)
+
 	// Create WebSocket hub
-	hub := websocket.NewHub()
+	hub := websocket.NewHub(answerRepo)
 	go hub.Run()
-
-	// Create WebSocket hub manager
 	hubManager := websocket.NewHubManager(hub)
 
-	// Create repositories
-	userRepo := models.NewUserRepository(db.DB)
-	answerRepo := models.NewAnswerRepository(db.DB)
-	emojiReactionRepo := models.NewEmojiReactionRepository(db.DB)
+	stateManager := models.NewEventStateManager(config.Event.TeamMode, len(config.Questions), config.Flow)
+	stateService := services.NewStateService(stateManager, hubManager, hub, logger, config, userRepo, teamRepo, answerRepo)
+	answerTally := services.NewAnswerTally(userRepo, answerRepo, hubManager, logger, config)
+	answerService := services.NewAnswerService(userRepo, answerRepo, emojiReactionRepo, hubManager, stateService, answerTally, logger, config)
+
+	sessionTokens, err := models.NewSessionTokens([][]byte{[]byte("handlers-test-session-key")}, 0)
+	if err != nil {
+		t.Fatalf("Failed to create session tokens: %v", err)
+	}
 
-	return NewParticipantHandlers(userRepo, answerRepo, emojiReactionRepo, hubManager, *logger, config), nil
+	handler := NewParticipantHandlers(userRepo, teamRepo, answerRepo, emojiReactionRepo, hubManager, stateService, answerService, sessionTokens, *logger, config)
+	return handler, stateService
+}
+
+// joinTestUser joins through the handler and returns the session token
+func joinTestUser(t *testing.T, router *gin.Engine, nickname string) string {
+	jsonData, _ := json.Marshal(JoinRequest{Nickname: nickname})
+	req, _ := http.NewRequest("POST", "/join", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Join failed with status %d: %s", w.Code, w.Body.String())
+	}
+
+	var joinResponse map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &joinResponse)
+	return joinResponse["session_token"].(string)
 }
 
 func TestHealthCheck(t *testing.T) {
-	_, err := setupTestHandler()
-	assert.NoError(t, err)
+	setupTestHandler(t)
 
 	router := gin.New()
 	router.GET("/health", func(c *gin.Context) {
@@ -92,7 +137,7 @@ func TestHealthCheck(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	var response map[string]interface{}
-	err = json.Unmarshal(w.Body.Bytes(), &response)
+	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
 	assert.Equal(t, "ok", response["status"])
 	assert.NotNil(t, response["memory"])
@@ -100,8 +145,7 @@ func TestHealthCheck(t *testing.T) {
 }
 
 func TestJoinUser(t *testing.T) {
-	handler, err := setupTestHandler()
-	assert.NoError(t, err)
+	handler, _ := setupTestHandler(t)
 
 	router := gin.New()
 	router.POST("/join", handler.Join)
@@ -119,10 +163,10 @@ func TestJoinUser(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	var response map[string]interface{}
-	err = json.Unmarshal(w.Body.Bytes(), &response)
+	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
 	assert.NotNil(t, response["user"])
-	assert.NotNil(t, response["session_id"])
+	assert.NotNil(t, response["session_token"])
 
 	user := response["user"].(map[string]interface{})
 	assert.Equal(t, "TestUser", user["nickname"])
@@ -130,8 +174,7 @@ func TestJoinUser(t *testing.T) {
 }
 
 func TestGetStatus(t *testing.T) {
-	_, err := setupTestHandler()
-	assert.NoError(t, err)
+	setupTestHandler(t)
 
 	router := gin.New()
 	router.GET("/status", func(c *gin.Context) {
@@ -149,7 +192,7 @@ func TestGetStatus(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	var response map[string]interface{}
-	err = json.Unmarshal(w.Body.Bytes(), &response)
+	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
 	assert.NotNil(t, response["config"])
 	assert.NotNil(t, response["users"])
@@ -157,89 +200,120 @@ func TestGetStatus(t *testing.T) {
 }
 
 func TestAnswerQuestion(t *testing.T) {
-	handler, err := setupTestHandler()
-	assert.NoError(t, err)
+	handler, stateService := setupTestHandler(t)
 
 	router := gin.New()
 	router.POST("/join", handler.Join)
 	router.POST("/answer", handler.Answer)
 
 	// First join a user
-	joinRequest := JoinRequest{
-		Nickname: "TestUser",
-	}
-	jsonData, _ := json.Marshal(joinRequest)
-
-	req, _ := http.NewRequest("POST", "/join", bytes.NewBuffer(jsonData))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
+	sessionToken := joinTestUser(t, router, "TestUser")
 
-	var joinResponse map[string]interface{}
-	json.Unmarshal(w.Body.Bytes(), &joinResponse)
-	sessionID := joinResponse["session_id"].(string)
-
-	// Then answer a question
+	// Then answer a question while answers are accepted
+	if result := stateService.JumpToQuestion(models.StateQuestionActive, 1); !result.Success {
+		t.Fatalf("Failed to start question 1: %v", result.Error)
+	}
 	answerRequest := AnswerRequest{
 		QuestionNumber: 1,
 		AnswerIndex:    1, // Correct answer
 	}
-	jsonData, _ = json.Marshal(answerRequest)
+	jsonData, _ := json.Marshal(answerRequest)
 
-	req, _ = http.NewRequest("POST", "/answer", bytes.NewBuffer(jsonData))
+	req, _ := http.NewRequest("POST", "/answer", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Session-ID", sessionID)
-	w = httptest.NewRecorder()
+	req.Header.Set("X-Session-Token", sessionToken)
+	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	var response map[string]interface{}
-	err = json.Unmarshal(w.Body.Bytes(), &response)
+	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
 	assert.Equal(t, true, response["is_correct"])
 	assert.Equal(t, float64(1), response["new_score"])
 }
 
 func TestSendEmoji(t *testing.T) {
-	handler, err := setupTestHandler()
-	assert.NoError(t, err)
+	handler, _ := setupTestHandler(t)
 
 	router := gin.New()
 	router.POST("/join", handler.Join)
 	router.POST("/emoji", handler.SendEmoji)
 
 	// First join a user
-	joinRequest := JoinRequest{
-		Nickname: "TestUser",
-	}
-	jsonData, _ := json.Marshal(joinRequest)
-
-	req, _ := http.NewRequest("POST", "/join", bytes.NewBuffer(jsonData))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
-
-	var joinResponse map[string]interface{}
-	json.Unmarshal(w.Body.Bytes(), &joinResponse)
-	sessionID := joinResponse["session_id"].(string)
+	sessionToken := joinTestUser(t, router, "TestUser")
 
 	// Then send an emoji
 	emojiRequest := EmojiRequest{
-		Emoji: "😀",
+		Emoji: "👏",
 	}
-	jsonData, _ = json.Marshal(emojiRequest)
+	jsonData, _ := json.Marshal(emojiRequest)
 
-	req, _ = http.NewRequest("POST", "/emoji", bytes.NewBuffer(jsonData))
+	req, _ := http.NewRequest("POST", "/emoji", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Session-ID", sessionID)
-	w = httptest.NewRecorder()
+	req.Header.Set("X-Session-Token", sessionToken)
+	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	var response map[string]interface{}
-	err = json.Unmarshal(w.Body.Bytes(), &response)
+	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
 	assert.Equal(t, "sent", response["status"])
 }
+
+func TestErrorResponses(t *testing.T) {
+	handler, _ := setupTestHandler(t)
+
+	router := gin.New()
+	router.Use(middleware.RequestID())
+	router.POST("/join", handler.Join)
+	router.POST("/answer", handler.Answer)
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		header     map[string]string
+		wantStatus int
+		wantCode   string
+	}{
+		{"empty nickname", "/join", `{"nickname":"   "}`, nil, http.StatusBadRequest, "VALIDATION_FAILED"},
+		{"malformed body", "/join", `{`, nil, http.StatusBadRequest, "INVALID_REQUEST"},
+		{"answer without session", "/answer", `{"question_number":1,"answer_index":1}`, nil, http.StatusUnauthorized, "SESSION_REQUIRED"},
+		{"answer with forged session", "/answer", `{"question_number":1,"answer_index":1}`,
+			map[string]string{"X-Session-Token": "forged"}, http.StatusUnauthorized, "INVALID_SESSION"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(middleware.RequestIDHeader, "req-42")
+			for name, value := range tt.header {
+				req.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+
+			var response struct {
+				Success bool `json:"success"`
+				Error   struct {
+					Code    string `json:"code"`
+					Message string `json:"message"`
+				} `json:"error"`
+				RequestID string `json:"request_id"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.False(t, response.Success)
+			assert.Equal(t, tt.wantCode, response.Error.Code)
+			assert.NotEmpty(t, response.Error.Message)
+			assert.Equal(t, "req-42", response.RequestID)
+			assert.Equal(t, "req-42", w.Header().Get(middleware.RequestIDHeader))
+		})
+	}
+}
@@ -2,9 +2,13 @@ package handlers
 
 import (
 	"net/http"
+	quizerrors "quiz100/errors"
+	"quiz100/middleware"
 	"quiz100/models"
 	"quiz100/services"
+	"quiz100/validation"
 	"quiz100/websocket"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -18,6 +22,10 @@ type ParticipantHandlers struct {
 	emojiReactionRepo *models.EmojiReactionRepository
 	hubManager        *websocket.HubManager
 	stateService      *services.StateService
+	answerService     *services.AnswerService
+	presence          *services.PresenceService
+	sessionTokens     *models.SessionTokens
+	validator         *validation.RequestValidator
 	logger            models.QuizLogger
 	config            *models.Config
 }
@@ -46,6 +54,8 @@ func NewParticipantHandlers(
 	emojiReactionRepo *models.EmojiReactionRepository,
 	hubManager *websocket.HubManager,
 	stateService *services.StateService,
+	answerService *services.AnswerService,
+	sessionTokens *models.SessionTokens,
 	logger models.QuizLogger,
 	config *models.Config,
 ) *ParticipantHandlers {
@@ -56,64 +66,83 @@ func NewParticipantHandlers(
 		emojiReactionRepo: emojiReactionRepo,
 		hubManager:        hubManager,
 		stateService:      stateService,
+		answerService:     answerService,
+		sessionTokens:     sessionTokens,
+		validator:         validation.NewRequestValidator(config),
 		logger:            logger,
 		config:            config,
 	}
 }
 
+// SetPresence sets the service that tracks whether participants are connected
+func (ph *ParticipantHandlers) SetPresence(presence *services.PresenceService) {
+	ph.presence = presence
+}
+
 // Join handles participant joining the quiz
 func (ph *ParticipantHandlers) Join(c *gin.Context) {
 	var req JoinRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		middleware.RespondError(c, invalidRequest(err))
 		return
 	}
 
-	sessionID := c.GetHeader("X-Session-ID")
-	if sessionID == "" {
-		sessionID = uuid.New().String()
+	// Check if this is a session rejoin attempt
+	isRejoinAttempt := req.Nickname == "Rejoining..."
+	nickname := strings.TrimSpace(req.Nickname)
+	if !isRejoinAttempt {
+		if result := ph.validator.ValidateNickname(nickname); !result.Valid {
+			middleware.RespondError(c, result.GetCombinedError())
+			return
+		}
 	}
 
-	existingUser, err := ph.userRepo.GetUserBySessionID(sessionID)
-	if err != nil {
-		ph.logger.LogError("checking existing user", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+	// A missing, forged or expired token starts a new session
+	sessionID, _ := verifySession(c, ph.sessionTokens)
+	var existingUser *models.User
+	var err error
+	if sessionID != "" {
+		existingUser, err = ph.userRepo.GetUserBySessionID(sessionID)
+		if err != nil {
+			ph.logger.LogError("checking existing user", err)
+			middleware.RespondError(c, quizerrors.ErrDatabaseError)
+			return
+		}
 	}
 
 	var user *models.User
 	var assignedTeam *models.Team
 
-	// Check if this is a session rejoin attempt
-	isRejoinAttempt := req.Nickname == "Rejoining..."
-
 	if existingUser != nil {
 		user = existingUser
-		ph.logger.LogUserReconnect(user.Nickname, sessionID)
+		ph.logger.LogUserReconnect(user.Nickname, user.ID)
 
 		// Get team if user is assigned to a team
 		if user.TeamID != nil {
 			assignedTeam, err = ph.teamRepo.GetTeamByID(*user.TeamID)
 			if err != nil {
 				ph.logger.LogError("error during acquiring team by id", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Team database error"})
+				middleware.RespondError(c, quizerrors.ErrDatabaseError)
 				return
 			}
 		}
 	} else {
 		// If this is a rejoin attempt but no existing user found, return error
 		if isRejoinAttempt {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired or not found"})
+			middleware.RespondError(c, quizerrors.ErrSessionExpired)
 			return
 		}
 
-		user, err = ph.userRepo.CreateUser(sessionID, req.Nickname)
+		// A stored session may belong to another room; sessions are unique across rooms
+		sessionID = uuid.New().String()
+
+		user, err = ph.userRepo.CreateUser(sessionID, nickname)
 		if err != nil {
 			ph.logger.LogError("creating user", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+			middleware.RespondError(c, quizerrors.ErrDatabaseError)
 			return
 		}
-		ph.logger.LogUserJoin(req.Nickname, sessionID)
+		ph.logger.LogUserJoin(nickname, user.ID)
 
 		// Check if teams exist and team mode is enabled for automatic team assignment
 		if ph.config.Event.TeamMode {
@@ -126,206 +155,112 @@ func (ph *ParticipantHandlers) Join(c *gin.Context) {
 	if err != nil {
 		ph.logger.LogError("updating user connection", err)
 	}
+	if ph.presence != nil {
+		// Counts as away unless the participant connects within the grace period
+		ph.presence.Joined(user.ID)
+	}
 
 	teamname := ""
 	if assignedTeam != nil {
 		teamname = assignedTeam.Name
 	}
 	// Broadcast user joined notification
-	userData := gin.H{
-		"user":     user,
-		"nickname": user.Nickname,
-		"teamname": teamname,
+	userData := websocket.UserJoinedData{
+		User:     user,
+		Nickname: user.Nickname,
+		TeamName: teamname,
 	}
 
 	if err := ph.hubManager.BroadcastUserJoined(userData); err != nil {
 		ph.logger.LogError("broadcasting user joined", err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"user":          user,
-		"session_id":    sessionID,
-		"assigned_team": assignedTeam,
-	})
+	// Rejoining rotates the token as well
+	response := sessionResponse(ph.sessionTokens, sessionID)
+	response["user"] = user
+	response["assigned_team"] = assignedTeam
+	c.JSON(http.StatusOK, response)
 }
 
-// Answer handles participant answer submission
-func (ph *ParticipantHandlers) Answer(c *gin.Context) {
-	var req AnswerRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	sessionID := c.GetHeader("X-Session-ID")
-	if sessionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID required"})
+// RefreshSession trades a valid session token for a new one, so participants
+// who stay longer than the token lifetime keep their session
+func (ph *ParticipantHandlers) RefreshSession(c *gin.Context) {
+	sessionID, sessionErr := verifySession(c, ph.sessionTokens)
+	if sessionErr != nil {
+		middleware.RespondError(c, sessionErr)
 		return
 	}
 
 	user, err := ph.userRepo.GetUserBySessionID(sessionID)
-	if err != nil || user == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "User not found"})
+	if err != nil {
+		ph.logger.LogError("checking existing user", err)
+		middleware.RespondError(c, quizerrors.ErrDatabaseError)
 		return
 	}
-
-	if req.QuestionNumber < 1 || req.QuestionNumber > len(ph.config.Questions) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question number"})
+	if user == nil {
+		middleware.RespondError(c, quizerrors.ErrSessionExpired)
 		return
 	}
 
-	// 現在回答受付中かどうか判断する
-	currentState := ph.stateService.GetCurrentState()
-	currentQuestion := ph.stateService.GetQuestionNumber()
-	if currentState != models.StateQuestionActive && currentState != models.StateCountdownActive {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Not currently accepting answers"})
-		return
-	}
-	if currentQuestion != req.QuestionNumber { // XXX: 数字あっているか？
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Not currently acception answers number"})
-		return
-	}
+	c.JSON(http.StatusOK, sessionResponse(ph.sessionTokens, sessionID))
+}
 
-	existingAnswer, err := ph.answerRepo.GetAnswerByUserAndQuestion(user.ID, req.QuestionNumber)
-	if err != nil {
-		ph.logger.LogError("checking existing answer", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+// Answer handles participant answer submission (fallback for the WebSocket answer message)
+func (ph *ParticipantHandlers) Answer(c *gin.Context) {
+	var req AnswerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.RespondError(c, invalidRequest(err))
 		return
 	}
 
-	question := ph.config.Questions[req.QuestionNumber-1]
-	// Convert 1-based answer index to 0-based for comparison with 1-based correct answer
-	isCorrect := req.AnswerIndex == question.Correct
-
-	if existingAnswer == nil {
-		// 新規回答
-		err = ph.answerRepo.CreateAnswer(user.ID, req.QuestionNumber, req.AnswerIndex, isCorrect)
-		if err != nil {
-			ph.logger.LogError("creating answer", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save answer"})
-			return
-		}
-	} else {
-		// 回答済み、選択肢変更
-		err = ph.answerRepo.ChangeAnswer(user.ID, req.QuestionNumber, req.AnswerIndex, isCorrect)
-		if err != nil {
-			ph.logger.LogError("changing answer", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change answer"})
-			return
-		}
-	}
-
-	ph.logger.LogAnswer(user.Nickname, req.QuestionNumber, req.AnswerIndex, isCorrect)
-
-	// Update user score based on answer correctness
-	scoreChange := 0
-	if existingAnswer != nil {
-		// If changing answer, adjust score based on previous and new answer
-		if existingAnswer.IsCorrect && !isCorrect {
-			// Was correct, now incorrect: subtract points
-			scoreChange = -question.Point
-		} else if !existingAnswer.IsCorrect && isCorrect {
-			// Was incorrect, now correct: add points
-			scoreChange = question.Point
-		}
-		// If both correct or both incorrect, no score change
-	} else {
-		// New answer: add points if correct
-		if isCorrect {
-			scoreChange = question.Point
-		}
-	}
-
-	// Apply score change if any
-	newScore := user.Score
-	if scoreChange != 0 {
-		newScore = user.Score + scoreChange
-		err = ph.userRepo.UpdateUserScore(user.ID, newScore)
-		if err != nil {
-			ph.logger.LogError("updating user score", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update score"})
-			return
-		}
-		ph.logger.Info("User %s score updated: %d -> %d (change: %+d)", user.Nickname, user.Score, newScore, scoreChange)
+	sessionID, sessionErr := verifySession(c, ph.sessionTokens)
+	if sessionErr != nil {
+		middleware.RespondError(c, sessionErr)
+		return
 	}
 
-	// Get the saved answer from database
-	savedAnswer, err := ph.answerRepo.GetAnswerByUserAndQuestion(user.ID, req.QuestionNumber)
+	result, err := ph.answerService.SubmitAnswer(sessionID, req.QuestionNumber, req.AnswerIndex)
 	if err != nil {
-		ph.logger.LogError("getting saved answer", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve answer"})
+		middleware.RespondError(c, quizerrors.FromStandardError(err))
 		return
 	}
 
-	// Broadcast answer received notification
-	answerData := gin.H{
-		"nickname":        user.Nickname,
-		"question_number": req.QuestionNumber,
-		"answer":          req.AnswerIndex,
-	}
-
-	if err := ph.hubManager.BroadcastAnswerReceived(answerData); err != nil {
-		ph.logger.LogError("broadcasting answer received", err)
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"answer_index": savedAnswer.AnswerIndex,
-		"is_correct":   isCorrect,
-		"new_score":    newScore,
-		"score_change": scoreChange,
-	})
+	c.JSON(http.StatusOK, result)
 }
 
-// SendEmoji handles participant emoji reactions
+// SendEmoji handles participant emoji reactions (fallback for the WebSocket emoji message)
 func (ph *ParticipantHandlers) SendEmoji(c *gin.Context) {
 	var req EmojiRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		middleware.RespondError(c, invalidRequest(err))
 		return
 	}
 
-	sessionID := c.GetHeader("X-Session-ID")
-	if sessionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID required"})
+	sessionID, sessionErr := verifySession(c, ph.sessionTokens)
+	if sessionErr != nil {
+		middleware.RespondError(c, sessionErr)
 		return
 	}
 
-	user, err := ph.userRepo.GetUserBySessionID(sessionID)
-	if err != nil || user == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "User not found"})
+	if err := ph.answerService.SendEmoji(sessionID, req.Emoji); err != nil {
+		middleware.RespondError(c, quizerrors.FromStandardError(err))
 		return
 	}
 
-	ph.logger.LogEmojiReaction(user.Nickname, req.Emoji)
-
-	// Broadcast emoji reaction
-	emojiData := gin.H{
-		"nickname": user.Nickname,
-		"emoji":    req.Emoji,
-	}
-
-	if err := ph.hubManager.BroadcastEmojiReaction(emojiData); err != nil {
-		ph.logger.LogError("broadcasting emoji reaction", err)
-	}
-
-	if err = ph.emojiReactionRepo.CreateReaction(user.ID, req.Emoji); err != nil {
-		ph.logger.LogError("Emoji create failed", err)
-	}
-
-	c.JSON(http.StatusOK, gin.H{})
+	c.JSON(http.StatusOK, gin.H{"status": "sent"})
 }
 
 // ResetSession handles participant session reset
 func (ph *ParticipantHandlers) ResetSession(c *gin.Context) {
-	sessionID := c.GetHeader("X-Session-ID")
-	if sessionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID required"})
+	sessionID, sessionErr := verifySession(c, ph.sessionTokens)
+	if sessionErr != nil {
+		middleware.RespondError(c, sessionErr)
 		return
 	}
 
 	user, err := ph.userRepo.GetUserBySessionID(sessionID)
 	if err != nil || user == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "User not found"})
+		middleware.RespondError(c, quizerrors.ErrUserNotFound)
 		return
 	}
 
@@ -333,15 +268,16 @@ func (ph *ParticipantHandlers) ResetSession(c *gin.Context) {
 	err = ph.answerRepo.DeleteAnswersByUserID(user.ID)
 	if err != nil {
 		ph.logger.LogError("deleting user answers", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user answers"})
+		middleware.RespondError(c, quizerrors.ErrDatabaseError)
 		return
 	}
+	ph.answerService.ForgetUser(user.ID)
 
 	// Delete user's emoji reactions
 	err = ph.emojiReactionRepo.DeleteReactionsByUserID(user.ID)
 	if err != nil {
 		ph.logger.LogError("deleting user emoji reactions", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user reactions"})
+		middleware.RespondError(c, quizerrors.ErrDatabaseError)
 		return
 	}
 
@@ -349,16 +285,16 @@ func (ph *ParticipantHandlers) ResetSession(c *gin.Context) {
 	err = ph.userRepo.DeleteUserBySessionID(sessionID)
 	if err != nil {
 		ph.logger.LogError("deleting user", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		middleware.RespondError(c, quizerrors.ErrDatabaseError)
 		return
 	}
 
-	ph.logger.LogUserSessionReset(user.Nickname, sessionID)
+	ph.logger.LogUserSessionReset(user.Nickname, user.ID)
 
 	// Broadcast user left notification
-	userData := gin.H{
-		"user_id":  user.ID,
-		"nickname": user.Nickname,
+	userData := websocket.UserLeftData{
+		UserID:   user.ID,
+		Nickname: user.Nickname,
 	}
 
 	if err := ph.hubManager.BroadcastUserLeft(userData); err != nil {
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	quizerrors "quiz100/errors"
+	"quiz100/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionTokenHeader carries the signed session token of a participant;
+// WebSocket and SSE connections pass it as the session_token query parameter
+const SessionTokenHeader = "X-Session-Token"
+
+// SessionToken returns the session token sent with a request, if any
+func SessionToken(c *gin.Context) string {
+	if token := c.GetHeader(SessionTokenHeader); token != "" {
+		return token
+	}
+	return c.Query("session_token")
+}
+
+// verifySession returns the session ID of a request with a valid token, or
+// the error to answer with
+func verifySession(c *gin.Context, tokens *models.SessionTokens) (string, *quizerrors.QuizError) {
+	token := SessionToken(c)
+	if token == "" {
+		return "", quizerrors.ErrSessionRequired
+	}
+	claims, err := tokens.Verify(token)
+	if errors.Is(err, models.ErrExpiredSessionToken) {
+		return "", quizerrors.ErrSessionExpired
+	}
+	if err != nil {
+		return "", quizerrors.ErrInvalidSession
+	}
+	return claims.SessionID, nil
+}
+
+// sessionResponse issues a fresh token for the session
+func sessionResponse(tokens *models.SessionTokens, sessionID string) gin.H {
+	token, expiresAt := tokens.Issue(sessionID)
+	return gin.H{"session_token": token, "expires_at": expiresAt}
+}
+
+// SessionOf returns a lookup of the session ID of requests with a valid
+// token, for middleware that cannot import this package
+func SessionOf(tokens *models.SessionTokens) func(*gin.Context) string {
+	return func(c *gin.Context) string {
+		sessionID, _ := verifySession(c, tokens)
+		return sessionID
+	}
+}
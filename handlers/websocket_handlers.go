@@ -3,6 +3,8 @@ package handlers
 import (
 	"fmt"
 	"net/http"
+	quizerrors "quiz100/errors"
+	"quiz100/middleware"
 	"quiz100/models"
 	"quiz100/services"
 	"quiz100/websocket"
@@ -24,6 +26,8 @@ type WebSocketHandlers struct {
 	config         *models.Config
 	currentEvent   *models.Event
 	stateService   *services.StateService
+	sessionTokens  *models.SessionTokens
+	rateLimiter    *models.RateLimiter
 }
 
 // NewWebSocketHandlers creates a new WebSocketHandlers instance
@@ -37,6 +41,8 @@ func NewWebSocketHandlers(
 	logger models.QuizLogger,
 	config *models.Config,
 	stateService *services.StateService,
+	sessionTokens *models.SessionTokens,
+	rateLimiter *models.RateLimiter,
 ) *WebSocketHandlers {
 	return &WebSocketHandlers{
 		hub:            hub,
@@ -48,26 +54,46 @@ func NewWebSocketHandlers(
 		logger:         logger,
 		config:         config,
 		stateService:   stateService,
+		sessionTokens:  sessionTokens,
+		rateLimiter:    rateLimiter,
 	}
 }
 
 // ParticipantWebSocket handles WebSocket connections for participants
 func (wh *WebSocketHandlers) ParticipantWebSocket(c *gin.Context) {
-	sessionID := c.Query("session_id")
-	if sessionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID required"})
+	sessionID, sessionErr := verifySession(c, wh.sessionTokens)
+	if sessionErr != nil {
+		middleware.RespondError(c, sessionErr)
 		return
 	}
 
 	user, err := wh.userRepo.GetUserBySessionID(sessionID)
 	if err != nil || user == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "User not found"})
+		middleware.RespondError(c, quizerrors.ErrUserNotFound)
 		return
 	}
 
 	websocket.ServeWS(wh.hub, c.Writer, c.Request, websocket.ClientTypeParticipant, user.ID, sessionID, wh.messageHandler)
 }
 
+// ParticipantSSE streams the participant messages over Server-Sent Events for
+// networks where WebSocket does not work
+func (wh *WebSocketHandlers) ParticipantSSE(c *gin.Context) {
+	sessionID, sessionErr := verifySession(c, wh.sessionTokens)
+	if sessionErr != nil {
+		middleware.RespondError(c, sessionErr)
+		return
+	}
+
+	user, err := wh.userRepo.GetUserBySessionID(sessionID)
+	if err != nil || user == nil {
+		middleware.RespondError(c, quizerrors.ErrUserNotFound)
+		return
+	}
+
+	websocket.ServeSSE(wh.hub, c.Writer, c.Request, websocket.ClientTypeParticipant, user.ID, sessionID)
+}
+
 // AdminWebSocket handles WebSocket connections for admin clients
 func (wh *WebSocketHandlers) AdminWebSocket(c *gin.Context) {
 	websocket.ServeWS(wh.hub, c.Writer, c.Request, websocket.ClientTypeAdmin, 0, "admin", wh.messageHandler)
@@ -168,6 +194,8 @@ func (wh *WebSocketHandlers) DebugInfo(c *gin.Context) {
 			"current_event": wh.currentEvent,
 			"config":        wh.config.Event,
 		},
+		// Shared by all rooms
+		"rate_limits": wh.rateLimiter.Stats(),
 	}
 
 	c.JSON(http.StatusOK, debugInfo)
@@ -178,7 +206,7 @@ func (wh *WebSocketHandlers) GetTeams(c *gin.Context) {
 	teams, err := wh.teamRepo.GetAllTeamsWithMembers()
 	if err != nil {
 		wh.logger.LogError("getting teams", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get teams"})
+		middleware.RespondError(c, quizerrors.ErrDatabaseError)
 		return
 	}
 
@@ -195,9 +223,7 @@ func (wh *WebSocketHandlers) SetCurrentEvent(event *models.Event) {
 // GetSyncStatus returns synchronization status for all clients
 func (wh *WebSocketHandlers) GetSyncStatus(c *gin.Context) {
 	if wh.stateService == nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error": "State service not available",
-		})
+		middleware.RespondError(c, quizerrors.ErrServiceUnavailable.WithDetails("State service not available"))
 		return
 	}
 
@@ -208,9 +234,7 @@ func (wh *WebSocketHandlers) GetSyncStatus(c *gin.Context) {
 // RequestClientSync manually requests synchronization for a specific client
 func (wh *WebSocketHandlers) RequestClientSync(c *gin.Context) {
 	if wh.stateService == nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error": "State service not available",
-		})
+		middleware.RespondError(c, quizerrors.ErrServiceUnavailable.WithDetails("State service not available"))
 		return
 	}
 
@@ -220,9 +244,7 @@ func (wh *WebSocketHandlers) RequestClientSync(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request format",
-		})
+		middleware.RespondError(c, invalidRequest(err))
 		return
 	}
 
@@ -242,9 +264,7 @@ func (wh *WebSocketHandlers) RequestClientSync(c *gin.Context) {
 // SyncAllClients forces synchronization for all connected participants
 func (wh *WebSocketHandlers) SyncAllClients(c *gin.Context) {
 	if wh.stateService == nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error": "State service not available",
-		})
+		middleware.RespondError(c, quizerrors.ErrServiceUnavailable.WithDetails("State service not available"))
 		return
 	}
 
@@ -258,18 +278,14 @@ func (wh *WebSocketHandlers) SyncAllClients(c *gin.Context) {
 // CheckClientSync checks if a specific client is synchronized
 func (wh *WebSocketHandlers) CheckClientSync(c *gin.Context) {
 	if wh.stateService == nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error": "State service not available",
-		})
+		middleware.RespondError(c, quizerrors.ErrServiceUnavailable.WithDetails("State service not available"))
 		return
 	}
 
 	userIDStr := c.Param("user_id")
 	userID := 0
 	if _, err := fmt.Sscanf(userIDStr, "%d", &userID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid user ID",
-		})
+		middleware.RespondError(c, quizerrors.ErrInvalidRequest.WithDetails("Invalid user ID"))
 		return
 	}
 
@@ -1,23 +1,31 @@
 package main
 
 import (
+	"bufio"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/exec"
+	"strings"
 
 	"quiz100/database"
 	"quiz100/handlers"
 	"quiz100/middleware"
 	"quiz100/models"
-	"quiz100/services"
+	"quiz100/rooms"
 	"quiz100/websocket"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	config, err := models.LoadConfig("config/quiz.toml")
+	if len(os.Args) > 1 && os.Args[1] == "hash-password" {
+		hashPassword()
+		return
+	}
+
+	roomConfigs, err := models.LoadRoomConfigs("config/quiz.toml")
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
@@ -35,44 +43,42 @@ func main() {
 	}
 	defer db.Close()
 
-	// Initialize repositories
-	userRepo := models.NewUserRepository(db.DB)
-	answerRepo := models.NewAnswerRepository(db.DB)
-	emojiReactionRepo := models.NewEmojiReactionRepository(db.DB)
-	eventRepo := models.NewEventRepository(db.DB)
-	teamRepo := models.NewTeamRepository(db.DB)
-
-	// Initialize team assignment service
-	teamAssignmentSvc := models.NewTeamAssignmentService(userRepo, teamRepo, config)
-
-	// Initialize WebSocket hub and manager
-	hub := websocket.NewHub(answerRepo)
-	hubManager := websocket.NewHubManager(hub)
-
-	// Initialize ping manager with adapter
-	userRepoAdapter := websocket.NewUserRepositoryAdapter(userRepo)
-	pingManager := websocket.NewPingManager(hubManager, userRepoAdapter)
-	messageHandler := websocket.NewMessageHandler(pingManager)
-
-	go hub.Run()
-	go pingManager.Start()
-
-	// Initialize state manager and service
-	stateManager := models.NewEventStateManager(config.Event.TeamMode, len(config.Questions))
-	stateService := services.NewStateService(stateManager, hubManager, hub, logger, config, userRepo, teamRepo, answerRepo)
-
-	// Initialize split handlers
-	participantHandlers := handlers.NewParticipantHandlers(userRepo, teamRepo, answerRepo, emojiReactionRepo, hubManager, stateService, *logger, config)
-	adminHandlers := handlers.NewAdminHandlers(eventRepo, userRepo, answerRepo, teamRepo, teamAssignmentSvc, hubManager, stateService, *logger, config)
-	websocketHandlers := handlers.NewWebSocketHandlers(hub, hubManager, messageHandler, userRepo, teamRepo, eventRepo, *logger, config, stateService)
+	// Participants authenticate with signed tokens; QUIZ_SESSION_SECRET takes
+	// comma-separated keys (the first one signs) to rotate the secret
+	sessionTokens, err := models.LoadSessionTokens(db.DB, os.Getenv("QUIZ_SESSION_SECRET"), os.Getenv("QUIZ_SESSION_TTL"))
+	if err != nil {
+		log.Fatalf("Failed to set up session tokens: %v", err)
+	}
 
-	// Initialize current event (empty initially)
-	var currentEvent *models.Event = nil
-	adminHandlers.SetCurrentEvent(currentEvent)
-	websocketHandlers.SetCurrentEvent(currentEvent)
+	// Staff accounts with roles; the QUIZ_ADMIN_* methods keep working alongside them
+	adminAccounts, err := models.LoadAdminAccounts("config/admins.toml")
+	if err != nil {
+		log.Fatalf("Failed to load admin accounts: %v", err)
+	}
+	adminSessions := models.NewAdminSessionRepository(db.DB)
+	auth := middleware.NewAuth(adminAccounts, adminSessions)
+	authHandlers := handlers.NewAuthHandlers(auth, adminAccounts, adminSessions, logger)
+
+	// WebSocket connections are only accepted from our own pages and QUIZ_ALLOWED_ORIGINS
+	websocket.AllowOrigins(os.Getenv("QUIZ_ALLOWED_ORIGINS"))
+
+	// Participant requests are limited per session and per IP across all
+	// rooms, with the [rate_limits] of the main config
+	rateLimiter := models.NewRateLimiter(roomConfigs[0].Config.RateLimits)
+	sessionOf := handlers.SessionOf(sessionTokens)
+
+	// Each room runs its own event, state machine and hub
+	roomManager := rooms.NewManager(db.DB, sessionTokens)
+	for _, roomConfig := range roomConfigs {
+		room, err := rooms.NewRoom(db.DB, logger, sessionTokens, rateLimiter, roomConfig)
+		if err != nil {
+			log.Fatalf("Failed to set up room %s: %v", roomConfig.Code, err)
+		}
+		roomManager.Add(room)
+	}
 
-	// Set up database reset callback
-	adminHandlers.SetDBResetCallback(func() error {
+	// Set up database reset callback (the database is shared by all rooms)
+	resetDatabase := func() error {
 		logger.Info("Database reset requested - restarting application")
 
 		// Close database connection
@@ -108,10 +114,23 @@ func main() {
 		// Exit current process
 		os.Exit(0)
 		return nil
-	})
+	}
+	for _, room := range roomManager.Rooms() {
+		room.Admin.SetDBResetCallback(resetDatabase)
+	}
 
 	gin.SetMode(gin.ReleaseMode)
-	r := gin.Default()
+	r := gin.New()
+	r.Use(middleware.RequestID(), middleware.AccessLogger(), gin.Recovery())
+
+	// Forwarding headers are only believed from the configured reverse proxies
+	trustedProxies, err := middleware.TrustedProxies()
+	if err != nil {
+		log.Fatalf("Failed to load trusted proxies: %v", err)
+	}
+	if err := middleware.ConfigureClientIP(r, trustedProxies); err != nil {
+		log.Fatalf("Failed to configure client IP resolution: %v", err)
+	}
 
 	// r.Use(middleware.LogMACInfo())
 
@@ -125,23 +144,31 @@ func main() {
 	// HTML page handlers (temporarily keeping old handler until we create page handlers)
 	// TODO: Create dedicated page handlers or add to websocket handlers
 	r.GET("/", func(c *gin.Context) { c.HTML(http.StatusOK, "index.html", gin.H{}) })
-	r.GET("/admin", middleware.AdminAuth(), func(c *gin.Context) { c.HTML(http.StatusOK, "admin.html", gin.H{}) })
-	r.GET("/show", middleware.ScreenAuth(), func(c *gin.Context) { c.HTML(http.StatusOK, "screen.html", gin.H{}) })
+	r.GET("/login", func(c *gin.Context) { c.HTML(http.StatusOK, "login.html", gin.H{}) })
+	r.GET("/admin", auth.AdminAuth(), func(c *gin.Context) { c.HTML(http.StatusOK, "admin.html", gin.H{}) })
+	r.GET("/show", auth.ScreenAuth(), func(c *gin.Context) { c.HTML(http.StatusOK, "screen.html", gin.H{}) })
 
 	api := r.Group("/api")
 	{
 		// Participant API endpoints
-		api.POST("/join", participantHandlers.Join)
-		api.POST("/answer", participantHandlers.Answer)
-		api.POST("/emoji", participantHandlers.SendEmoji)
-		api.POST("/reset-session", participantHandlers.ResetSession)
+		api.POST("/join", middleware.RateLimit(rateLimiter, models.RateLimitJoin, sessionOf), roomManager.Participant((*handlers.ParticipantHandlers).Join))
+		api.POST("/answer", middleware.RateLimit(rateLimiter, models.RateLimitAnswer, sessionOf), roomManager.Participant((*handlers.ParticipantHandlers).Answer))
+		api.POST("/emoji", middleware.RateLimit(rateLimiter, models.RateLimitEmoji, sessionOf), roomManager.Participant((*handlers.ParticipantHandlers).SendEmoji))
+		api.POST("/reset-session", roomManager.Participant((*handlers.ParticipantHandlers).ResetSession))
+		api.POST("/session/refresh", roomManager.Participant((*handlers.ParticipantHandlers).RefreshSession))
+
+		// Staff login for the admin console and the screens
+		api.POST("/auth/login", authHandlers.Login)
+		api.POST("/auth/logout", authHandlers.Logout)
+		api.GET("/auth/me", authHandlers.Me)
 
 		// General API endpoints
-		api.GET("/status", websocketHandlers.GetStatus)
-		api.GET("/health", websocketHandlers.HealthCheck)
+		api.GET("/status", roomManager.WebSocket((*handlers.WebSocketHandlers).GetStatus))
+		api.GET("/health", roomManager.HealthCheck)
+		api.GET("/rooms", roomManager.RoomInfo)
 
 		admin := api.Group("/admin")
-		admin.Use(middleware.AdminAuth())
+		admin.Use(auth.AdminAuth())
 		{
 			// Legacy endpoints (to be deprecated)
 			// admin.POST("/start", handler.AdminStart)
@@ -150,50 +177,95 @@ func main() {
 			// admin.POST("/stop", handler.AdminStop)
 			// admin.POST("/teams", handler.AdminCreateTeams)
 
-			admin.GET("/teams", websocketHandlers.GetTeams)
-			admin.GET("/debug", websocketHandlers.DebugInfo)
+			admin.GET("/rooms", roomManager.ListRooms)
+			admin.GET("/teams", roomManager.WebSocket((*handlers.WebSocketHandlers).GetTeams))
+			admin.GET("/debug", roomManager.WebSocket((*handlers.WebSocketHandlers).DebugInfo))
 
 			// New State-Based Action System
-			admin.POST("/action", adminHandlers.AdminAction)
-			admin.GET("/actions", adminHandlers.GetAvailableActions)
+			admin.POST("/action", roomManager.Admin((*handlers.AdminHandlers).AdminAction))
+			admin.GET("/actions", roomManager.Admin((*handlers.AdminHandlers).GetAvailableActions))
 
 			// Debug State Jump System
-			admin.POST("/jump-state", adminHandlers.AdminJumpState)
-			admin.GET("/available-states", adminHandlers.GetAvailableStates)
+			admin.POST("/jump-state", roomManager.Admin((*handlers.AdminHandlers).AdminJumpState))
+			admin.GET("/available-states", roomManager.Admin((*handlers.AdminHandlers).GetAvailableStates))
+
+			// Announcement slides shown over the current state
+			admin.GET("/slides", roomManager.Admin((*handlers.AdminHandlers).GetSlides))
+			admin.POST("/slide", roomManager.Admin((*handlers.AdminHandlers).ShowSlide))
+			admin.POST("/slide/dismiss", roomManager.Admin((*handlers.AdminHandlers).DismissSlide))
+
+			// Screen channels (what each projector or TV shows)
+			admin.GET("/screens", roomManager.Admin((*handlers.AdminHandlers).GetScreenChannels))
+			admin.POST("/screens", roomManager.Admin((*handlers.AdminHandlers).SetScreenChannel))
+
+			// Operator lock so only one console drives the event
+			admin.GET("/lock", roomManager.Admin((*handlers.AdminHandlers).GetOperatorLock))
+			admin.POST("/lock", roomManager.Admin((*handlers.AdminHandlers).AcquireOperatorLock))
+			admin.POST("/lock/release", roomManager.Admin((*handlers.AdminHandlers).ReleaseOperatorLock))
+
+			// Participant presence and connection history
+			admin.GET("/presence", roomManager.Admin((*handlers.AdminHandlers).GetPresence))
+			admin.GET("/presence/:user_id", roomManager.Admin((*handlers.AdminHandlers).GetConnectionHistory))
+			admin.GET("/connections", roomManager.Admin((*handlers.AdminHandlers).GetConnectionQuality))
 
 			// Database Reset System
-			admin.POST("/reset-database", adminHandlers.ResetDatabase)
+			admin.POST("/reset-database", roomManager.Admin((*handlers.AdminHandlers).ResetDatabase))
 		}
 
 		screen := api.Group("/screen")
-		screen.Use(middleware.ScreenAuth())
+		screen.Use(auth.ScreenAuth())
 		{
-			screen.GET("/info", websocketHandlers.GetScreenInfo)
+			screen.GET("/info", roomManager.WebSocket((*handlers.WebSocketHandlers).GetScreenInfo))
 		}
 	}
 
+	// WebSocket fallback for networks that break the upgrade
+	r.GET("/sse/participant", roomManager.WebSocket((*handlers.WebSocketHandlers).ParticipantSSE))
+
 	ws := r.Group("/ws")
 	{
-		ws.GET("/participant", websocketHandlers.ParticipantWebSocket)
-		ws.GET("/admin", middleware.AdminAuth(), websocketHandlers.AdminWebSocket)
-		ws.GET("/screen", middleware.ScreenAuth(), websocketHandlers.ScreenWebSocket)
+		ws.GET("/participant", roomManager.WebSocket((*handlers.WebSocketHandlers).ParticipantWebSocket))
+		ws.GET("/admin", auth.AdminAuth(), roomManager.WebSocket((*handlers.WebSocketHandlers).AdminWebSocket))
+		ws.GET("/screen", auth.ScreenAuth(), roomManager.WebSocket((*handlers.WebSocketHandlers).ScreenWebSocket))
 
 		// State synchronization endpoints
-		ws.GET("/sync-status", middleware.AdminAuth(), websocketHandlers.GetSyncStatus)
-		ws.POST("/sync-client", middleware.AdminAuth(), websocketHandlers.RequestClientSync)
-		ws.POST("/sync-all", middleware.AdminAuth(), websocketHandlers.SyncAllClients)
-		ws.GET("/sync-check/:user_id", middleware.AdminAuth(), websocketHandlers.CheckClientSync)
+		ws.GET("/sync-status", auth.AdminAuth(), roomManager.WebSocket((*handlers.WebSocketHandlers).GetSyncStatus))
+		ws.POST("/sync-client", auth.AdminAuth(), roomManager.WebSocket((*handlers.WebSocketHandlers).RequestClientSync))
+		ws.POST("/sync-all", auth.AdminAuth(), roomManager.WebSocket((*handlers.WebSocketHandlers).SyncAllClients))
+		ws.GET("/sync-check/:user_id", auth.AdminAuth(), roomManager.WebSocket((*handlers.WebSocketHandlers).CheckClientSync))
 	}
 
 	logger.Info("=== QUIZ SYSTEM STARTING ===")
-	logger.Info("Event: %s", config.Event.Title)
-	logger.Info("Team mode: %v", config.Event.TeamMode)
-	logger.Info("Team size: %d", config.Event.TeamSize)
-	logger.Info("Questions: %d", len(config.Questions))
-	logger.Info("Avoid groups: %v", config.TeamSeparation.AvoidGroups)
+	for _, room := range roomManager.Rooms() {
+		config := room.Config
+		logger.Info("Room %s (event %d)", room.Code, room.Event.ID)
+		logger.Info("Event: %s", config.Event.Title)
+		logger.Info("Team mode: %v", config.Event.TeamMode)
+		logger.Info("Team size: %d", config.Event.TeamSize)
+		logger.Info("Questions: %d", len(config.Questions))
+		logger.Info("Avoid groups: %v", config.TeamSeparation.AvoidGroups)
+		logger.Info("Team balance: %s", config.TeamBalance.Mode)
+		logger.Info("Flow: %d transitions, %d timers", len(config.Flow.Transitions), len(config.Flow.Timers))
+	}
+	logger.Info("Admin accounts: %d", adminAccounts.Len())
 	logger.Info("Server starting on :8080")
 
 	if err := http.ListenAndServe(":8080", r); err != nil {
 		log.Fatalf("Server failed to start: %v", err)
 	}
 }
+
+// hashPassword prints the bcrypt hash of a password read from stdin, for the
+// password_hash of an account in config/admins.toml
+func hashPassword() {
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		log.Fatalf("Failed to read password: %v", err)
+	}
+	hash, err := models.HashPassword(strings.TrimRight(password, "\r\n"))
+	if err != nil {
+		log.Fatalf("Failed to hash password: %v", err)
+	}
+	fmt.Println(hash)
+}
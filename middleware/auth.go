@@ -1,37 +1,20 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	quizerrors "quiz100/errors"
+	"quiz100/models"
 	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-func getClientIP(c *gin.Context) string {
-	clientIP := c.ClientIP()
-
-	if clientIP == "127.0.0.1" || clientIP == "::1" {
-		return clientIP
-	}
-
-	xForwardedFor := c.GetHeader("X-Forwarded-For")
-	if xForwardedFor != "" {
-		ips := strings.Split(xForwardedFor, ",")
-		clientIP = strings.TrimSpace(ips[0])
-	}
-
-	xRealIP := c.GetHeader("X-Real-IP")
-	if xRealIP != "" {
-		clientIP = xRealIP
-	}
-
-	return clientIP
-}
-
 func getMACAddresses(ip string) ([]string, error) {
 	if ip == "127.0.0.1" || ip == "::1" || ip == "localhost" {
 		interfaces, err := net.Interfaces()
@@ -114,34 +97,16 @@ func isAdminIP(clientIP string) bool {
 	return false
 }
 
+// hasValidAdminToken checks the shared token in the Authorization header; a
+// token in the URL would end up in logs and browser history
 func hasValidAdminToken(c *gin.Context) bool {
 	adminToken := getAdminToken()
 	if adminToken == "" {
 		return false
 	}
 
-	// Check Authorization header
-	authHeader := c.GetHeader("Authorization")
-	if authHeader != "" {
-		if strings.HasPrefix(authHeader, "Bearer ") {
-			token := strings.TrimPrefix(authHeader, "Bearer ")
-			return token == adminToken
-		}
-	}
-
-	// Check query parameter
-	queryToken := c.Query("token")
-	if queryToken == adminToken {
-		return true
-	}
-
-	// Check form parameter
-	formToken := c.PostForm("token")
-	if formToken == adminToken {
-		return true
-	}
-
-	return false
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
 }
 
 func isAdminMAC(clientMACs []string) bool {
@@ -157,86 +122,134 @@ func isAdminMAC(clientMACs []string) bool {
 	return false
 }
 
-func AdminAuth() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		clientIP := getClientIP(c)
-		// log.Printf("Admin access attempt from IP: %s", clientIP)
+// SessionCookie holds the login of a staff account
+const SessionCookie = "quiz_admin_session"
 
-		// Method 1: Token-based authentication (highest priority)
-		if hasValidAdminToken(c) {
-			// log.Printf("Admin access granted for IP %s via TOKEN authentication", clientIP)
-			c.Next()
-			return
-		}
+const identityKey = "admin_identity"
 
-		// Method 2: IP-based authentication
-		if isAdminIP(clientIP) {
-			// log.Printf("Admin access granted for IP %s via IP authentication", clientIP)
-			c.Next()
-			return
-		}
+// Identity is the staff member or machine behind a request
+type Identity struct {
+	Username string           `json:"username,omitempty"` // Empty without an account
+	Role     models.AdminRole `json:"role"`
+	Method   string           `json:"method"` // password, token, ip or mac
+}
 
-		// Method 3: MAC-based authentication (fallback for native execution)
-		clientMACs, err := getMACAddresses(clientIP)
-		if err == nil && len(clientMACs) > 0 {
-			log.Printf("Client MACs: %v", clientMACs)
-			if isAdminMAC(clientMACs) {
-				log.Printf("Admin access granted for IP %s via MAC authentication", clientIP)
-				c.Next()
-				return
+// Auth decides who may use the admin console and the screens. Staff log in
+// with an account and get its role; the shared token, IP allow-list and MAC
+// methods are kept for automation and setups without accounts, and grant the
+// operator role.
+type Auth struct {
+	accounts *models.AdminAccounts
+	sessions *models.AdminSessionRepository
+}
+
+// NewAuth creates the admin authentication
+func NewAuth(accounts *models.AdminAccounts, sessions *models.AdminSessionRepository) *Auth {
+	return &Auth{accounts: accounts, sessions: sessions}
+}
+
+// Identify returns who sent the request, or nil for anonymous requests
+func (a *Auth) Identify(c *gin.Context) (*Identity, error) {
+	if token, err := c.Cookie(SessionCookie); err == nil && token != "" {
+		session, err := a.sessions.Get(token)
+		if err != nil {
+			return nil, err
+		}
+		// The role comes from the accounts file, so edits apply to open sessions
+		if session != nil {
+			if account, ok := a.accounts.Get(session.Username); ok {
+				return &Identity{Username: account.Username, Role: account.Role, Method: "password"}, nil
 			}
 		}
+	}
+
+	if hasValidAdminToken(c) {
+		return &Identity{Role: models.RoleOperator, Method: "token"}, nil
+	}
 
-		log.Printf("Access denied for IP %s - No valid authentication method", clientIP)
-		c.JSON(http.StatusForbidden, gin.H{
-			"error": "Access denied - Admin authentication required",
-			"hint":  "Use token, IP whitelist, or MAC address authentication",
-		})
-		c.Abort()
+	clientIP := c.ClientIP()
+	if isAdminIP(clientIP) {
+		return &Identity{Role: models.RoleOperator, Method: "ip"}, nil
+	}
+
+	// Fallback for native execution
+	clientMACs, err := getMACAddresses(clientIP)
+	if err == nil && len(clientMACs) > 0 && isAdminMAC(clientMACs) {
+		return &Identity{Role: models.RoleOperator, Method: "mac"}, nil
 	}
+	return nil, nil
 }
 
-func ScreenAuth() gin.HandlerFunc {
+// AdminAuth lets viewers read the admin console and APIs and only operators
+// change anything
+func (a *Auth) AdminAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		clientIP := getClientIP(c)
-		log.Printf("Screen access attempt from IP: %s", clientIP)
-
-		// Method 1: Token-based authentication (highest priority)
-		if hasValidAdminToken(c) {
-			log.Printf("Screen access granted for IP %s via TOKEN authentication", clientIP)
-			c.Next()
-			return
+		required := models.RoleOperator
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			required = models.RoleViewer
 		}
+		a.authorize(c, required)
+	}
+}
+
+// ScreenAuth lets screen accounts, and everyone allowed on the admin console,
+// open the projector screen
+func (a *Auth) ScreenAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		a.authorize(c, models.RoleScreen)
+	}
+}
 
-		// Method 2: IP-based authentication
-		if isAdminIP(clientIP) {
-			log.Printf("Screen access granted for IP %s via IP authentication", clientIP)
-			c.Next()
+// RequireRole admits requests whose role includes the given one
+func (a *Auth) RequireRole(role models.AdminRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		a.authorize(c, role)
+	}
+}
+
+func (a *Auth) authorize(c *gin.Context, required models.AdminRole) {
+	identity, err := a.Identify(c)
+	if err != nil {
+		log.Printf("Admin authentication failed: %v", err)
+		RespondError(c, quizerrors.ErrInternalError.WithDetails("authentication failed"))
+		return
+	}
+
+	if identity == nil {
+		log.Printf("Access denied for IP %s - Not logged in", c.ClientIP())
+		// Pages send the browser to the login page and come back afterwards
+		if c.Request.Method == http.MethodGet && strings.Contains(c.GetHeader("Accept"), "text/html") {
+			c.Redirect(http.StatusFound, "/login?next="+url.QueryEscape(c.Request.URL.RequestURI()))
+			c.Abort()
 			return
 		}
+		RespondError(c, quizerrors.ErrUnauthorized.WithDetails("Log in at /login, or use the admin token, IP whitelist or MAC address authentication"))
+		return
+	}
 
-		// Method 3: MAC-based authentication (fallback for native execution)
-		clientMACs, err := getMACAddresses(clientIP)
-		if err == nil && len(clientMACs) > 0 {
-			if isAdminMAC(clientMACs) {
-				log.Printf("Screen access granted for IP %s via MAC authentication", clientIP)
-				c.Next()
-				return
-			}
-		}
+	if !identity.Role.Allows(required) {
+		log.Printf("Access denied for %s (%s) - %s role required", identity.Username, identity.Role, required)
+		RespondError(c, quizerrors.ErrForbidden.
+			WithDetails(string(required)+" role required").
+			WithData("role", identity.Role))
+		return
+	}
 
-		log.Printf("Screen access denied for IP %s - No valid authentication method", clientIP)
-		c.JSON(http.StatusForbidden, gin.H{
-			"error": "Access denied - Screen authentication required",
-			"hint":  "Use token, IP whitelist, or MAC address authentication",
-		})
-		c.Abort()
+	c.Set(identityKey, identity)
+	c.Next()
+}
+
+// CurrentIdentity returns the identity admitted by AdminAuth, ScreenAuth or RequireRole
+func CurrentIdentity(c *gin.Context) *Identity {
+	if identity, ok := c.Get(identityKey); ok {
+		return identity.(*Identity)
 	}
+	return nil
 }
 
 func LogMACInfo() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		clientIP := getClientIP(c)
+		clientIP := c.ClientIP()
 		clientMACs, err := getMACAddresses(clientIP)
 
 		if err != nil {
@@ -279,9 +292,15 @@ func init() {
 		log.Println("❌ Token authentication disabled: No QUIZ_ADMIN_TOKEN configured")
 	}
 
+	// Check proxies allowed to forward the client IP
+	if proxies, err := TrustedProxies(); err == nil && len(proxies) > 0 {
+		log.Printf("✅ Forwarded client IPs accepted from: %v", proxies)
+	} else if len(adminIPs) > 0 {
+		log.Println("ℹ️  No QUIZ_TRUSTED_PROXIES configured: IP authentication uses the connection address")
+	}
+
 	if len(adminMACs) == 0 && len(adminIPs) == 0 && adminToken == "" {
-		log.Println("⚠️  WARNING: No authentication methods configured! Admin access will be denied.")
-		log.Println("   Set at least one of: QUIZ_ADMIN_MAC_ADDR, QUIZ_ADMIN_IP_ADDR, or QUIZ_ADMIN_TOKEN")
+		log.Println("ℹ️  No QUIZ_ADMIN_* method configured: only admin accounts can log in")
 	}
 }
 
@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"quiz100/models"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	adminIP    = "192.168.1.100"
+	attackerIP = "192.168.1.50"
+	proxyIP    = "10.0.0.1"
+)
+
+// newTestRouter serves /admin and /show behind the auth middleware; only the
+// IP allow-list is configured
+func newTestRouter(t *testing.T, proxies []string) *gin.Engine {
+	t.Setenv("QUIZ_ADMIN_IP_ADDR", adminIP)
+	t.Setenv("QUIZ_ADMIN_TOKEN", "")
+	t.Setenv("QUIZ_ADMIN_MAC_ADDR", "")
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	schema, err := os.ReadFile("../database/init.sql")
+	if err != nil {
+		t.Fatalf("Failed to read schema: %v", err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatalf("Failed to execute schema: %v", err)
+	}
+
+	accounts, err := models.NewAdminAccounts(nil)
+	if err != nil {
+		t.Fatalf("Failed to create accounts: %v", err)
+	}
+	auth := NewAuth(accounts, models.NewAdminSessionRepository(db))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	if err := ConfigureClientIP(r, proxies); err != nil {
+		t.Fatalf("ConfigureClientIP failed: %v", err)
+	}
+	ok := func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) }
+	r.GET("/admin", auth.AdminAuth(), ok)
+	r.GET("/show", auth.ScreenAuth(), ok)
+	return r
+}
+
+func TestAuthRejectsSpoofedClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		proxies    []string
+		remoteAddr string
+		headers    map[string]string
+		want       int
+	}{
+		{
+			name:       "direct from admin IP",
+			remoteAddr: adminIP + ":50000",
+			want:       http.StatusOK,
+		},
+		{
+			name:       "X-Forwarded-For without trusted proxies",
+			remoteAddr: attackerIP + ":50000",
+			headers:    map[string]string{"X-Forwarded-For": adminIP},
+			want:       http.StatusUnauthorized,
+		},
+		{
+			name:       "X-Real-IP without trusted proxies",
+			remoteAddr: attackerIP + ":50000",
+			headers:    map[string]string{"X-Real-IP": adminIP},
+			want:       http.StatusUnauthorized,
+		},
+		{
+			name:       "forwarding headers from loopback without trusted proxies",
+			remoteAddr: "127.0.0.1:50000",
+			headers:    map[string]string{"X-Forwarded-For": adminIP, "X-Real-IP": adminIP},
+			want:       http.StatusUnauthorized,
+		},
+		{
+			name:       "X-Forwarded-For from a client that is not a trusted proxy",
+			proxies:    []string{proxyIP},
+			remoteAddr: attackerIP + ":50000",
+			headers:    map[string]string{"X-Forwarded-For": adminIP},
+			want:       http.StatusUnauthorized,
+		},
+		{
+			name:       "X-Real-IP from a client that is not a trusted proxy",
+			proxies:    []string{"10.0.0.0/8"},
+			remoteAddr: attackerIP + ":50000",
+			headers:    map[string]string{"X-Real-IP": adminIP},
+			want:       http.StatusUnauthorized,
+		},
+		{
+			name:       "admin forwarded by a trusted proxy",
+			proxies:    []string{proxyIP},
+			remoteAddr: proxyIP + ":50000",
+			headers:    map[string]string{"X-Forwarded-For": adminIP},
+			want:       http.StatusOK,
+		},
+		{
+			name:       "admin forwarded by a proxy in a trusted CIDR",
+			proxies:    []string{"10.0.0.0/8"},
+			remoteAddr: proxyIP + ":50000",
+			headers:    map[string]string{"X-Forwarded-For": adminIP},
+			want:       http.StatusOK,
+		},
+		{
+			name:       "spoofed entry prepended to the chain of a trusted proxy",
+			proxies:    []string{proxyIP},
+			remoteAddr: proxyIP + ":50000",
+			headers:    map[string]string{"X-Forwarded-For": adminIP + ", " + attackerIP},
+			want:       http.StatusUnauthorized,
+		},
+	}
+
+	for _, path := range []string{"/admin", "/show"} {
+		for _, tt := range tests {
+			t.Run(path+" "+tt.name, func(t *testing.T) {
+				r := newTestRouter(t, tt.proxies)
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				req.RemoteAddr = tt.remoteAddr
+				for name, value := range tt.headers {
+					req.Header.Set(name, value)
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+
+				if w.Code != tt.want {
+					t.Errorf("Got status %d, want %d (body %s)", w.Code, tt.want, w.Body.String())
+				}
+			})
+		}
+	}
+}
+
+func TestAuthAdminTokenOnlyInHeader(t *testing.T) {
+	for _, path := range []string{"/admin", "/show"} {
+		r := newTestRouter(t, nil)
+		t.Setenv("QUIZ_ADMIN_TOKEN", "secret-token")
+
+		for name, tt := range map[string]struct {
+			url    string
+			header string
+			want   int
+		}{
+			"bearer header": {url: path, header: "Bearer secret-token", want: http.StatusOK},
+			"wrong token":   {url: path, header: "Bearer guess", want: http.StatusUnauthorized},
+			"query string":  {url: path + "?token=secret-token", want: http.StatusUnauthorized},
+		} {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			req.RemoteAddr = attackerIP + ":50000"
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("%s %s: got status %d, want %d", path, name, w.Code, tt.want)
+			}
+		}
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	t.Setenv("QUIZ_TRUSTED_PROXIES", " 10.0.0.1, 172.16.0.0/12 ,")
+	proxies, err := TrustedProxies()
+	if err != nil {
+		t.Fatalf("TrustedProxies failed: %v", err)
+	}
+	if len(proxies) != 2 || proxies[0] != "10.0.0.1" || proxies[1] != "172.16.0.0/12" {
+		t.Errorf("Got %v, want [10.0.0.1 172.16.0.0/12]", proxies)
+	}
+
+	t.Setenv("QUIZ_TRUSTED_PROXIES", "")
+	if proxies, err := TrustedProxies(); err != nil || len(proxies) != 0 {
+		t.Errorf("Got %v, %v; want no proxies", proxies, err)
+	}
+
+	t.Setenv("QUIZ_TRUSTED_PROXIES", "proxy.local")
+	if _, err := TrustedProxies(); err == nil {
+		t.Error("Host names should be rejected")
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// secretQueryParams matches query parameters that must not reach the logs;
+// WebSocket and SSE connections can only pass the session token in the URL
+var secretQueryParams = regexp.MustCompile(`((?:^|[?&])session_token=)[^&]*`)
+
+// AccessLogger logs requests like gin's default logger with session tokens
+// removed from the URL and the request ID appended
+func AccessLogger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v | %s\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			param.StatusCode,
+			param.Latency.Truncate(time.Microsecond),
+			param.ClientIP,
+			param.Method,
+			redactURL(param.Path),
+			param.Keys[requestIDKey],
+			param.ErrorMessage,
+		)
+	})
+}
+
+// redactURL replaces the values of secret query parameters
+func redactURL(url string) string {
+	return secretQueryParams.ReplaceAllString(url, "${1}REDACTED")
+}
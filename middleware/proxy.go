@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrustedProxies returns the reverse proxies from QUIZ_TRUSTED_PROXIES (IPs
+// or CIDRs, comma-separated). Only requests from them may name the client
+// with X-Forwarded-For or X-Real-IP.
+func TrustedProxies() ([]string, error) {
+	var proxies []string
+	for _, entry := range strings.Split(os.Getenv("QUIZ_TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(entry); err != nil && net.ParseIP(entry) == nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: expected an IP address or CIDR", entry)
+		}
+		proxies = append(proxies, entry)
+	}
+	return proxies, nil
+}
+
+// ConfigureClientIP makes c.ClientIP() read the forwarding headers only on
+// requests from the trusted proxies; without proxies the connection's
+// address is always used, so no header can fake an admin IP
+func ConfigureClientIP(r *gin.Engine, proxies []string) error {
+	r.ForwardedByClientIP = true
+	r.RemoteIPHeaders = []string{"X-Forwarded-For", "X-Real-IP"}
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		return fmt.Errorf("failed to set trusted proxies: %v", err)
+	}
+	return nil
+}
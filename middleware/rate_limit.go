@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	quizerrors "quiz100/errors"
+	"quiz100/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit refuses requests over the limits of the endpoint with 429 and a
+// Retry-After header. sessionOf returns the participant session of a request,
+// or "" before joining, in which case only the client IP is limited.
+func RateLimit(limiter *models.RateLimiter, endpoint string, sessionOf func(*gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ok, retryAfter := limiter.Allow(endpoint, sessionOf(c), c.ClientIP())
+		if ok {
+			c.Next()
+			return
+		}
+
+		seconds := models.RetryAfterSeconds(retryAfter)
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		RespondError(c, quizerrors.ErrRateLimited.WithData("retry_after", seconds))
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"quiz100/models"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimitRespondsWithRetryAfter(t *testing.T) {
+	limiter := models.NewRateLimiter(models.RateLimitsConfig{
+		Emoji: models.EndpointRateLimits{PerSession: models.RateLimit{Burst: 2, Every: "30s"}},
+	})
+	sessionOf := func(c *gin.Context) string { return c.GetHeader("X-Test-Session") }
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/emoji", RateLimit(limiter, models.RateLimitEmoji, sessionOf), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	send := func(session string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/emoji", nil)
+		req.RemoteAddr = attackerIP + ":50000"
+		req.Header.Set("X-Test-Session", session)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := send("s1"); w.Code != http.StatusOK {
+			t.Fatalf("Request %d: got status %d, want 200", i+1, w.Code)
+		}
+	}
+	w := send("s1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Got status %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Got Retry-After %q, want 30", got)
+	}
+
+	if w := send("s2"); w.Code != http.StatusOK {
+		t.Errorf("Other session: got status %d, want 200", w.Code)
+	}
+}
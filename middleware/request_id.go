@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	quizerrors "quiz100/errors"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the ID of a request in both directions
+const RequestIDHeader = "X-Request-ID"
+
+const requestIDKey = "request_id"
+
+// validRequestID limits the IDs taken from clients and proxies to what is safe to log
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID gives every request an ID, returned in the X-Request-ID header
+// and in error responses so a report from the venue can be found in the logs.
+// An ID sent by the client (or a proxy in front) is kept.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.New().String()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// GetRequestID returns the ID set by RequestID, or "" without the middleware
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// RespondError aborts the request with the structured error response of err
+func RespondError(c *gin.Context, err *quizerrors.QuizError) {
+	c.AbortWithStatusJSON(err.HTTPStatus, quizerrors.NewErrorResponse(err, GetRequestID(c)))
+}
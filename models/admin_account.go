@@ -0,0 +1,132 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AdminRole is what a staff account may do
+type AdminRole string
+
+const (
+	RoleOperator AdminRole = "operator" // Drives the event from the admin console
+	RoleViewer   AdminRole = "viewer"   // Watches the admin console without changing anything
+	RoleScreen   AdminRole = "screen"   // Runs a projector or TV screen only
+)
+
+// roleRank orders the roles; a role includes everything ranked below it
+var roleRank = map[AdminRole]int{RoleScreen: 1, RoleViewer: 2, RoleOperator: 3}
+
+// Valid reports whether the role is known
+func (r AdminRole) Valid() bool {
+	return roleRank[r] > 0
+}
+
+// Allows reports whether the role includes the required one
+func (r AdminRole) Allows(required AdminRole) bool {
+	return r.Valid() && roleRank[r] >= roleRank[required]
+}
+
+// AdminAccount is a staff login from the accounts file
+type AdminAccount struct {
+	Username     string    `toml:"username"`
+	PasswordHash string    `toml:"password_hash"` // bcrypt, from "quiz100 hash-password"
+	Role         AdminRole `toml:"role"`
+}
+
+type adminAccountsFile struct {
+	Accounts []AdminAccount `toml:"accounts"`
+}
+
+// AdminAccounts are the staff accounts that can log in
+type AdminAccounts struct {
+	accounts map[string]AdminAccount // Normalized username -> account
+}
+
+// dummyPasswordHash is compared against for unknown users so a login takes
+// as long whether or not the username exists
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("quiz100-unknown-user"), bcrypt.DefaultCost)
+	return hash
+})
+
+// NewAdminAccounts validates accounts and indexes them by username
+func NewAdminAccounts(accounts []AdminAccount) (*AdminAccounts, error) {
+	index := make(map[string]AdminAccount, len(accounts))
+	for i, account := range accounts {
+		name := normalizeUsername(account.Username)
+		if name == "" {
+			return nil, fmt.Errorf("account %d: username is required", i+1)
+		}
+		if _, ok := index[name]; ok {
+			return nil, fmt.Errorf("account %d: duplicate username %q", i+1, account.Username)
+		}
+		if !account.Role.Valid() {
+			return nil, fmt.Errorf("account %q: role must be operator, viewer or screen", account.Username)
+		}
+		if _, err := bcrypt.Cost([]byte(account.PasswordHash)); err != nil {
+			return nil, fmt.Errorf("account %q: password_hash is not a bcrypt hash: %v", account.Username, err)
+		}
+		index[name] = account
+	}
+	return &AdminAccounts{accounts: index}, nil
+}
+
+// LoadAdminAccounts loads the accounts file; without the file there are no
+// accounts and only the other admin authentication methods work
+func LoadAdminAccounts(path string) (*AdminAccounts, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return NewAdminAccounts(nil)
+	}
+
+	var file adminAccountsFile
+	if _, err := toml.DecodeFile(path, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode accounts file: %v", err)
+	}
+	return NewAdminAccounts(file.Accounts)
+}
+
+// Len returns the number of accounts
+func (a *AdminAccounts) Len() int {
+	return len(a.accounts)
+}
+
+// Get returns the account with the username
+func (a *AdminAccounts) Get(username string) (AdminAccount, bool) {
+	account, ok := a.accounts[normalizeUsername(username)]
+	return account, ok
+}
+
+// Authenticate checks a username and password
+func (a *AdminAccounts) Authenticate(username, password string) (AdminAccount, bool) {
+	account, ok := a.Get(username)
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return AdminAccount{}, false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(account.PasswordHash), []byte(password)) != nil {
+		return AdminAccount{}, false
+	}
+	return account, true
+}
+
+// HashPassword returns the bcrypt hash to put in the accounts file
+func HashPassword(password string) (string, error) {
+	if len(password) < 8 {
+		return "", fmt.Errorf("password must be at least 8 characters")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %v", err)
+	}
+	return string(hash), nil
+}
+
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
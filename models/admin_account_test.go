@@ -0,0 +1,151 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func testPasswordHash(t *testing.T, password string) string {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	return string(hash)
+}
+
+func TestAdminRoleAllows(t *testing.T) {
+	tests := []struct {
+		role     AdminRole
+		required AdminRole
+		want     bool
+	}{
+		{RoleOperator, RoleOperator, true},
+		{RoleOperator, RoleViewer, true},
+		{RoleOperator, RoleScreen, true},
+		{RoleViewer, RoleOperator, false},
+		{RoleViewer, RoleViewer, true},
+		{RoleViewer, RoleScreen, true},
+		{RoleScreen, RoleViewer, false},
+		{RoleScreen, RoleScreen, true},
+		{AdminRole("admin"), RoleScreen, false},
+		{AdminRole(""), RoleScreen, false},
+	}
+	for _, tt := range tests {
+		if got := tt.role.Allows(tt.required); got != tt.want {
+			t.Errorf("%q.Allows(%q) = %v, want %v", tt.role, tt.required, got, tt.want)
+		}
+	}
+}
+
+func TestAdminAccountsAuthenticate(t *testing.T) {
+	accounts, err := NewAdminAccounts([]AdminAccount{
+		{Username: "Host", PasswordHash: testPasswordHash(t, "host-password"), Role: RoleOperator},
+		{Username: "tv", PasswordHash: testPasswordHash(t, "tv-password"), Role: RoleScreen},
+	})
+	if err != nil {
+		t.Fatalf("NewAdminAccounts failed: %v", err)
+	}
+
+	account, ok := accounts.Authenticate(" host ", "host-password")
+	if !ok || account.Role != RoleOperator || account.Username != "Host" {
+		t.Errorf("Got %+v, %v; want the operator account", account, ok)
+	}
+	if _, ok := accounts.Authenticate("host", "tv-password"); ok {
+		t.Error("Wrong password should be rejected")
+	}
+	if _, ok := accounts.Authenticate("nobody", "host-password"); ok {
+		t.Error("Unknown user should be rejected")
+	}
+}
+
+func TestNewAdminAccountsValidation(t *testing.T) {
+	hash := testPasswordHash(t, "password")
+	tests := []struct {
+		name     string
+		accounts []AdminAccount
+	}{
+		{"missing username", []AdminAccount{{PasswordHash: hash, Role: RoleViewer}}},
+		{"duplicate username", []AdminAccount{{Username: "a", PasswordHash: hash, Role: RoleViewer}, {Username: "A", PasswordHash: hash, Role: RoleScreen}}},
+		{"unknown role", []AdminAccount{{Username: "a", PasswordHash: hash, Role: "admin"}}},
+		{"plain password", []AdminAccount{{Username: "a", PasswordHash: "password", Role: RoleViewer}}},
+	}
+	for _, tt := range tests {
+		if _, err := NewAdminAccounts(tt.accounts); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestLoadAdminAccounts(t *testing.T) {
+	dir := t.TempDir()
+
+	accounts, err := LoadAdminAccounts(filepath.Join(dir, "missing.toml"))
+	if err != nil || accounts.Len() != 0 {
+		t.Fatalf("Missing file should give no accounts, got %v, %v", accounts, err)
+	}
+
+	path := filepath.Join(dir, "admins.toml")
+	content := "[[accounts]]\nusername = \"viewer\"\npassword_hash = \"" + testPasswordHash(t, "viewer-password") + "\"\nrole = \"viewer\"\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write accounts file: %v", err)
+	}
+	accounts, err = LoadAdminAccounts(path)
+	if err != nil {
+		t.Fatalf("LoadAdminAccounts failed: %v", err)
+	}
+	if account, ok := accounts.Get("viewer"); !ok || account.Role != RoleViewer {
+		t.Errorf("Got %+v, %v; want the viewer account", account, ok)
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	if _, err := HashPassword("short"); err == nil {
+		t.Error("Short passwords should be rejected")
+	}
+	hash, err := HashPassword("long enough password")
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte("long enough password")) != nil {
+		t.Error("Hash does not match the password")
+	}
+}
+
+func TestAdminSessions(t *testing.T) {
+	repo := NewAdminSessionRepository(setupProgressDB(t))
+
+	token, expiresAt, err := repo.Create("host", time.Hour)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if time.Until(expiresAt) <= 0 {
+		t.Errorf("Session should expire in the future, got %v", expiresAt)
+	}
+
+	session, err := repo.Get(token)
+	if err != nil || session == nil || session.Username != "host" {
+		t.Fatalf("Got %+v, %v; want the session of host", session, err)
+	}
+	if session, _ := repo.Get(token + "x"); session != nil {
+		t.Error("Unknown token should not find a session")
+	}
+
+	expired, _, err := repo.Create("host", -time.Minute)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if session, _ := repo.Get(expired); session != nil {
+		t.Error("Expired session should not be returned")
+	}
+
+	if err := repo.Delete(token); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if session, _ := repo.Get(token); session != nil {
+		t.Error("Session should be gone after logout")
+	}
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// DefaultAdminSessionTTL is how long a staff login lasts
+const DefaultAdminSessionTTL = 24 * time.Hour
+
+// AdminSession is a staff login behind a session cookie
+type AdminSession struct {
+	Username  string
+	ExpiresAt time.Time
+}
+
+// AdminSessionRepository keeps staff logins. Only a hash of the cookie value
+// is stored, so the table cannot be used to log in.
+type AdminSessionRepository struct {
+	db *sql.DB
+}
+
+// NewAdminSessionRepository creates a new AdminSessionRepository instance
+func NewAdminSessionRepository(db *sql.DB) *AdminSessionRepository {
+	return &AdminSessionRepository{db: db}
+}
+
+// Create starts a session and returns the cookie value
+func (r *AdminSessionRepository) Create(username string, ttl time.Duration) (string, time.Time, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate session: %v", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+	expiresAt := time.Now().UTC().Add(ttl)
+
+	// Logins are rare, so expired sessions are cleaned up here
+	if _, err := r.db.Exec(`DELETE FROM admin_sessions WHERE expires_at < ?`, time.Now().UTC()); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to delete expired sessions: %v", err)
+	}
+	if _, err := r.db.Exec(`INSERT INTO admin_sessions (token_hash, username, expires_at) VALUES (?, ?, ?)`, hashSessionToken(token), username, expiresAt); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to create session: %v", err)
+	}
+	return token, expiresAt, nil
+}
+
+// Get returns the session of a cookie value, or nil when it is unknown or expired
+func (r *AdminSessionRepository) Get(token string) (*AdminSession, error) {
+	if token == "" {
+		return nil, nil
+	}
+	var session AdminSession
+	err := r.db.QueryRow(`SELECT username, expires_at FROM admin_sessions WHERE token_hash = ?`, hashSessionToken(token)).Scan(&session.Username, &session.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %v", err)
+	}
+	if !time.Now().Before(session.ExpiresAt) {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+// Delete ends a session
+func (r *AdminSessionRepository) Delete(token string) error {
+	if _, err := r.db.Exec(`DELETE FROM admin_sessions WHERE token_hash = ?`, hashSessionToken(token)); err != nil {
+		return fmt.Errorf("failed to delete session: %v", err)
+	}
+	return nil
+}
+
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Built-in actions the admin uses to steer the autopilot
+const (
+	ActionAutopilotSkip   = "autopilot_skip"   // Advance right now
+	ActionAutopilotExtend = "autopilot_extend" // Give the current step more time
+	ActionAutopilotManual = "autopilot_manual" // Hand control back to the admin
+	ActionAutopilotAuto   = "autopilot_auto"   // Let the autopilot drive again
+)
+
+// AutopilotConfig is the run-sheet used to run an event without an operator.
+// Each entry is how long the event stays in a state before the autopilot
+// advances; states without an entry wait for the admin.
+type AutopilotConfig struct {
+	Enabled      bool   `toml:"enabled"`
+	Start        string `toml:"start"`        // started: before showing the title
+	Title        string `toml:"title"`        // title_display
+	Teams        string `toml:"teams"`        // team_assignment
+	Question     string `toml:"question"`     // Time limit per question, including the final countdown
+	Stats        string `toml:"stats"`        // answer_stats
+	Reveal       string `toml:"reveal"`       // answer_reveal
+	Leaderboard  string `toml:"leaderboard"`  // leaderboard
+	Intermission string `toml:"intermission"` // intermission
+	Results      string `toml:"results"`      // results
+	Extend       string `toml:"extend"`       // Time added by the extend button (default 30s)
+}
+
+// DefaultAutopilotExtend is used when no extend step is configured
+const DefaultAutopilotExtend = 30 * time.Second
+
+// Validate checks that every run-sheet entry is a positive duration
+func (a *AutopilotConfig) Validate() error {
+	for name, value := range a.entries() {
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s: invalid duration: %v", name, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("%s: duration must be positive", name)
+		}
+	}
+	return nil
+}
+
+// DurationFor returns how long the run-sheet keeps the event in a state
+func (a *AutopilotConfig) DurationFor(state EventState) (time.Duration, bool) {
+	var value string
+	switch state {
+	case StateStarted:
+		value = a.Start
+	case StateTitleDisplay:
+		value = a.Title
+	case StateTeamAssignment:
+		value = a.Teams
+	case StateQuestionActive:
+		value = a.Question
+	case StateAnswerStats:
+		value = a.Stats
+	case StateAnswerReveal:
+		value = a.Reveal
+	case StateLeaderboard:
+		value = a.Leaderboard
+	case StateIntermission:
+		value = a.Intermission
+	case StateResults:
+		value = a.Results
+	}
+
+	if value == "" {
+		return 0, false
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, false
+	}
+	return d, true
+}
+
+// ExtendStep returns the time added by the extend action
+func (a *AutopilotConfig) ExtendStep() time.Duration {
+	if d, err := time.ParseDuration(a.Extend); err == nil && d > 0 {
+		return d
+	}
+	return DefaultAutopilotExtend
+}
+
+func (a *AutopilotConfig) entries() map[string]string {
+	return map[string]string{
+		"start":        a.Start,
+		"title":        a.Title,
+		"teams":        a.Teams,
+		"question":     a.Question,
+		"stats":        a.Stats,
+		"reveal":       a.Reveal,
+		"leaderboard":  a.Leaderboard,
+		"intermission": a.Intermission,
+		"results":      a.Results,
+		"extend":       a.Extend,
+	}
+}
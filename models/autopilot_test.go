@@ -0,0 +1,39 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutopilotRunSheet(t *testing.T) {
+	autopilot := AutopilotConfig{
+		Enabled:  true,
+		Title:    "10s",
+		Question: "30s",
+		Stats:    "5s",
+	}
+
+	if err := autopilot.Validate(); err != nil {
+		t.Fatalf("Expected valid run-sheet: %v", err)
+	}
+
+	if d, ok := autopilot.DurationFor(StateQuestionActive); !ok || d != 30*time.Second {
+		t.Errorf("Expected 30s for question_active, got %v (ok: %v)", d, ok)
+	}
+	if _, ok := autopilot.DurationFor(StateAnswerReveal); ok {
+		t.Error("Expected answer_reveal to wait for the admin when no reveal time is set")
+	}
+	if autopilot.ExtendStep() != DefaultAutopilotExtend {
+		t.Errorf("Expected default extend step, got %v", autopilot.ExtendStep())
+	}
+
+	autopilot.Reveal = "soon"
+	if err := autopilot.Validate(); err == nil {
+		t.Error("Expected error for invalid duration")
+	}
+
+	autopilot.Reveal = "-1s"
+	if err := autopilot.Validate(); err == nil {
+		t.Error("Expected error for negative duration")
+	}
+}
@@ -10,10 +10,20 @@ import (
 )
 
 type Config struct {
-	Event          EventConfig          `toml:"event"`
-	TeamSeparation TeamSeparationConfig `toml:"team_separation"`
-	Questions      []Question           `toml:"questions"`
-	TeamNames      []string             // Loaded from team.toml
+	Event          EventConfig           `toml:"event"`
+	TeamSeparation TeamSeparationConfig  `toml:"team_separation"`
+	TeamBalance    TeamBalanceConfig     `toml:"team_balance"`
+	Flow           FlowConfig            `toml:"flow"`
+	Autopilot      AutopilotConfig       `toml:"autopilot"`
+	Presence       PresenceConfig        `toml:"presence"`
+	LiveAnswers    LiveAnswersConfig     `toml:"live_answers"`
+	Ping           PingConfig            `toml:"ping"`
+	RateLimits     RateLimitsConfig      `toml:"rate_limits"` // Participant request limits shared by all rooms (main config only)
+	Questions      []Question            `toml:"questions"`
+	Slides         []Slide               `toml:"slides"`
+	Screens        []ScreenChannelConfig `toml:"screens"` // What each screen channel shows (all channels show everything by default)
+	Rooms          []RoomEntry           `toml:"rooms"`   // Additional rooms run on the same server (main config only)
+	TeamNames      []string              // Loaded from team.toml
 }
 
 type EventConfig struct {
@@ -21,12 +31,25 @@ type EventConfig struct {
 	TeamMode bool   `toml:"team_mode"`
 	TeamSize int    `toml:"team_size"`
 	QrCode   string `toml:"qrcode"`
+	RoomCode string `toml:"room_code"` // Join code of the room (default "main")
 }
 
 type TeamSeparationConfig struct {
 	AvoidGroups []string `toml:"avoid_groups"`
 }
 
+// Team balance modes
+const (
+	TeamBalanceShuffle = "shuffle" // シャッフル + ラウンドロビン (デフォルト)
+	TeamBalanceRating  = "rating"  // 過去の成績から戦力を均等化
+)
+
+type TeamBalanceConfig struct {
+	Mode           string `toml:"mode"`             // "shuffle" or "rating"
+	RatingsFile    string `toml:"ratings_file"`     // Optional ratings history file (relative to config dir)
+	UsePastResults bool   `toml:"use_past_results"` // Use results of prior events stored in the DB
+}
+
 type TeamConfig struct {
 	TeamNames []string `toml:"team_names"`
 }
@@ -59,6 +82,16 @@ func LoadConfig(configPath string) (*Config, error) {
 	}
 	config.TeamNames = teamNames
 
+	// Resolve ratings file relative to the config directory
+	if config.TeamBalance.RatingsFile != "" && !filepath.IsAbs(config.TeamBalance.RatingsFile) {
+		config.TeamBalance.RatingsFile = filepath.Join(filepath.Dir(configPath), config.TeamBalance.RatingsFile)
+	}
+
+	// Use the standard flow unless the config declares its own
+	if config.Flow.IsEmpty() {
+		config.Flow = DefaultFlow()
+	}
+
 	if err := config.Validate(); err != nil {
 		return nil, fmt.Errorf("config validation failed: %v", err)
 	}
@@ -93,10 +126,54 @@ func (c *Config) Validate() error {
 		return errors.New("team_size must be greater than 0 when team_mode is enabled")
 	}
 
+	switch c.TeamBalance.Mode {
+	case "", TeamBalanceShuffle, TeamBalanceRating:
+	default:
+		return fmt.Errorf("team_balance.mode must be '%s' or '%s'", TeamBalanceShuffle, TeamBalanceRating)
+	}
+
+	if c.TeamBalance.Mode == TeamBalanceRating && c.TeamBalance.RatingsFile == "" && !c.TeamBalance.UsePastResults {
+		return errors.New("team_balance.mode 'rating' requires ratings_file or use_past_results")
+	}
+
+	if !c.Flow.IsEmpty() {
+		if err := c.Flow.Validate(); err != nil {
+			return fmt.Errorf("flow: %v", err)
+		}
+	}
+
+	if err := c.Autopilot.Validate(); err != nil {
+		return fmt.Errorf("autopilot: %v", err)
+	}
+
+	if err := c.Presence.Validate(); err != nil {
+		return fmt.Errorf("presence: %v", err)
+	}
+
+	if err := c.LiveAnswers.Validate(); err != nil {
+		return fmt.Errorf("live_answers: %v", err)
+	}
+
+	if err := c.Ping.Validate(); err != nil {
+		return fmt.Errorf("ping: %v", err)
+	}
+
+	if err := c.RateLimits.Validate(); err != nil {
+		return fmt.Errorf("rate_limits: %v", err)
+	}
+
 	if len(c.Questions) == 0 {
 		return errors.New("at least one question is required")
 	}
 
+	if err := c.validateSlides(); err != nil {
+		return err
+	}
+
+	if err := c.validateScreens(); err != nil {
+		return err
+	}
+
 	for i, q := range c.Questions {
 		if err := q.Validate(); err != nil {
 			return fmt.Errorf("question %d: %v", i+1, err)
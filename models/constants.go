@@ -15,6 +15,8 @@ const (
 	StateCountdownActive EventState = "countdown_active"
 	StateAnswerStats     EventState = "answer_stats"
 	StateAnswerReveal    EventState = "answer_reveal"
+	StateLeaderboard     EventState = "leaderboard"
+	StateIntermission    EventState = "intermission"
 	StateResults         EventState = "results"
 	StateCelebration     EventState = "celebration"
 	StateFinished        EventState = "finished"
@@ -30,6 +32,8 @@ var StateLabels = map[EventState]string{
 	StateCountdownActive: "カウントダウン中",
 	StateAnswerStats:     "回答状況表示",
 	StateAnswerReveal:    "回答発表",
+	StateLeaderboard:     "途中経過発表",
+	StateIntermission:    "休憩",
 	StateResults:         "結果発表",
 	StateCelebration:     "お疲れ様画面",
 	StateFinished:        "終了",
@@ -46,6 +50,8 @@ func AllStates() []EventState {
 		StateCountdownActive,
 		StateAnswerStats,
 		StateAnswerReveal,
+		StateLeaderboard,
+		StateIntermission,
 		StateResults,
 		StateCelebration,
 		StateFinished,
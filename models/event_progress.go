@@ -0,0 +1,131 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// EventProgress is the persisted position of an event, used to resume after a restart
+type EventProgress struct {
+	EventID        int
+	State          EventState
+	QuestionNumber int
+	History        []StateSnapshot
+	Paused         bool
+	PausedAt       time.Time
+	TimerAction    string        // Flow timer pending in the current state
+	TimerDeadline  time.Time     // When the running timer fires
+	TimerRemaining time.Duration // Time left on the timer while paused
+	Slide          *Slide        // Announcement shown over the state (nil when none)
+}
+
+// GetUnfinishedEvent returns the latest event of a room that has not reached the finished state
+func (r *EventRepository) GetUnfinishedEvent(roomCode string) (*Event, error) {
+	event := &Event{}
+	query := `SELECT id, room_code, title, status, question_number, team_mode, created_at, updated_at FROM events WHERE room_code = ? AND status != ? ORDER BY created_at DESC, id DESC LIMIT 1`
+
+	err := r.db.QueryRow(query, roomCode, string(StateFinished)).Scan(
+		&event.ID, &event.RoomCode, &event.Title, &event.Status, &event.QuestionNumber,
+		&event.TeamMode, &event.CreatedAt, &event.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return event, err
+}
+
+// SaveProgress stores the state and question on the event and the rest of the progress next to it
+func (r *EventRepository) SaveProgress(progress *EventProgress) error {
+	history, err := json.Marshal(progress.History)
+	if err != nil {
+		return fmt.Errorf("failed to encode history: %v", err)
+	}
+
+	slide := ""
+	if progress.Slide != nil {
+		encoded, err := json.Marshal(progress.Slide)
+		if err != nil {
+			return fmt.Errorf("failed to encode slide: %v", err)
+		}
+		slide = string(encoded)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE events SET status = ?, question_number = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	if _, err := tx.Exec(query, string(progress.State), progress.QuestionNumber, progress.EventID); err != nil {
+		return err
+	}
+
+	query = `
+		INSERT OR REPLACE INTO event_progress (event_id, history, paused, paused_at, timer_action, timer_deadline, timer_remaining_ms, slide, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`
+	_, err = tx.Exec(query,
+		progress.EventID, string(history), progress.Paused, nullTime(progress.PausedAt),
+		progress.TimerAction, nullTime(progress.TimerDeadline), progress.TimerRemaining.Milliseconds(), slide,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// LoadProgress reads the saved progress of an event; events saved before progress
+// tracking existed only restore their state and question
+func (r *EventRepository) LoadProgress(eventID int) (*EventProgress, error) {
+	event, err := r.GetEvent(eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := &EventProgress{
+		EventID:        event.ID,
+		State:          EventState(event.Status),
+		QuestionNumber: event.QuestionNumber,
+	}
+
+	var history, slide string
+	var pausedAt, timerDeadline sql.NullTime
+	var remainingMs int64
+	query := `SELECT history, paused, paused_at, timer_action, timer_deadline, timer_remaining_ms, slide FROM event_progress WHERE event_id = ?`
+	err = r.db.QueryRow(query, eventID).Scan(
+		&history, &progress.Paused, &pausedAt, &progress.TimerAction, &timerDeadline, &remainingMs, &slide,
+	)
+	if err == sql.ErrNoRows {
+		return progress, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if history != "" {
+		if err := json.Unmarshal([]byte(history), &progress.History); err != nil {
+			return nil, fmt.Errorf("failed to decode history: %v", err)
+		}
+	}
+	if slide != "" {
+		progress.Slide = &Slide{}
+		if err := json.Unmarshal([]byte(slide), progress.Slide); err != nil {
+			return nil, fmt.Errorf("failed to decode slide: %v", err)
+		}
+	}
+	progress.PausedAt = pausedAt.Time
+	progress.TimerDeadline = timerDeadline.Time
+	progress.TimerRemaining = time.Duration(remainingMs) * time.Millisecond
+
+	return progress, nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
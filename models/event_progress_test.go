@@ -0,0 +1,121 @@
+package models
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func setupProgressDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	schema, err := os.ReadFile("../database/init.sql")
+	if err != nil {
+		t.Fatalf("Failed to read schema: %v", err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatalf("Failed to execute schema: %v", err)
+	}
+	return db
+}
+
+func TestEventProgressRoundTrip(t *testing.T) {
+	repo := NewEventRepository(setupProgressDB(t))
+
+	event, err := repo.CreateEvent("main", "Test Quiz", false, 1, "")
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	progress, err := repo.LoadProgress(event.ID)
+	if err != nil {
+		t.Fatalf("Failed to load progress of a new event: %v", err)
+	}
+	if progress.State != StateWaiting || len(progress.History) != 0 {
+		t.Errorf("Expected fresh progress, got %+v", progress)
+	}
+
+	pausedAt := time.Now().UTC().Truncate(time.Second)
+	saved := &EventProgress{
+		EventID:        event.ID,
+		State:          StateCountdownActive,
+		QuestionNumber: 2,
+		History: []StateSnapshot{
+			{State: StateQuestionActive, QuestionNumber: 1},
+			{State: StateQuestionActive, QuestionNumber: 2},
+		},
+		Paused:         true,
+		PausedAt:       pausedAt,
+		TimerAction:    ActionCloseAnswers,
+		TimerRemaining: 3200 * time.Millisecond,
+		Slide:          &Slide{ID: "dinner", Type: SlideTypeText, Title: "お食事をどうぞ"},
+	}
+	if err := repo.SaveProgress(saved); err != nil {
+		t.Fatalf("Failed to save progress: %v", err)
+	}
+
+	loaded, err := repo.LoadProgress(event.ID)
+	if err != nil {
+		t.Fatalf("Failed to load progress: %v", err)
+	}
+	if loaded.State != saved.State || loaded.QuestionNumber != saved.QuestionNumber {
+		t.Errorf("Expected %s/%d, got %s/%d", saved.State, saved.QuestionNumber, loaded.State, loaded.QuestionNumber)
+	}
+	if len(loaded.History) != 2 || loaded.History[1] != saved.History[1] {
+		t.Errorf("Expected history %v, got %v", saved.History, loaded.History)
+	}
+	if !loaded.Paused || !loaded.PausedAt.Equal(pausedAt) {
+		t.Errorf("Expected paused at %v, got %v (paused: %v)", pausedAt, loaded.PausedAt, loaded.Paused)
+	}
+	if loaded.TimerAction != ActionCloseAnswers || loaded.TimerRemaining != saved.TimerRemaining {
+		t.Errorf("Expected timer %s/%v, got %s/%v", ActionCloseAnswers, saved.TimerRemaining, loaded.TimerAction, loaded.TimerRemaining)
+	}
+
+	if loaded.Slide == nil || *loaded.Slide != *saved.Slide {
+		t.Errorf("Expected slide %v, got %v", saved.Slide, loaded.Slide)
+	}
+
+	unfinished, err := repo.GetUnfinishedEvent("main")
+	if err != nil || unfinished == nil || unfinished.ID != event.ID {
+		t.Fatalf("Expected event %d to be unfinished, got %v (err: %v)", event.ID, unfinished, err)
+	}
+
+	saved.State = StateFinished
+	saved.Paused = false
+	if err := repo.SaveProgress(saved); err != nil {
+		t.Fatalf("Failed to save progress: %v", err)
+	}
+	if unfinished, err := repo.GetUnfinishedEvent("main"); err != nil || unfinished != nil {
+		t.Errorf("Expected no unfinished event, got %v (err: %v)", unfinished, err)
+	}
+}
+
+func TestUnfinishedEventPerRoom(t *testing.T) {
+	repo := NewEventRepository(setupProgressDB(t))
+
+	main, err := repo.CreateEvent("main", "Main Quiz", false, 1, "")
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	other, err := repo.CreateEvent("B", "Other Quiz", false, 1, "")
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	for roomCode, want := range map[string]int{"main": main.ID, "B": other.ID} {
+		event, err := repo.GetUnfinishedEvent(roomCode)
+		if err != nil || event == nil || event.ID != want {
+			t.Errorf("Expected event %d for room %s, got %v (err: %v)", want, roomCode, event, err)
+		}
+	}
+	if event, err := repo.GetUnfinishedEvent("C"); err != nil || event != nil {
+		t.Errorf("Expected no event for unknown room, got %v (err: %v)", event, err)
+	}
+}
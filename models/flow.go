@@ -0,0 +1,248 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Admin actions that can trigger a flow transition
+const (
+	ActionStartEvent      = "start_event"
+	ActionShowTitle       = "show_title"
+	ActionAssignTeams     = "assign_teams"
+	ActionNextQuestion    = "next_question"
+	ActionCountdownAlert  = "countdown_alert"
+	ActionCloseAnswers    = "close_answers"
+	ActionReopenAnswers   = "reopen_answers"
+	ActionShowAnswerStats = "show_answer_stats"
+	ActionRevealAnswer    = "reveal_answer"
+	ActionShowLeaderboard = "show_leaderboard"
+	ActionIntermission    = "show_intermission"
+	ActionShowResults     = "show_results"
+	ActionCelebration     = "celebration"
+	ActionFinish          = "finish"
+)
+
+// Built-in actions that are not part of the flow config
+const (
+	ActionGoBack = "go_back" // Returns to the previous state while there is history
+	ActionPause  = "pause"   // Freezes timers and answers
+	ActionResume = "resume"  // Continues a paused event
+)
+
+// ActionLabels provides Japanese button labels for each action
+var ActionLabels = map[string]string{
+	ActionStartEvent:      "🚀 イベント開始",
+	ActionShowTitle:       "📺 タイトル表示",
+	ActionAssignTeams:     "👥 チーム分け",
+	ActionNextQuestion:    "❓ 次の問題",
+	ActionCountdownAlert:  "⏰ 残り5秒アラート",
+	ActionCloseAnswers:    "🔒 回答締め切り",
+	ActionReopenAnswers:   "🔓 回答再受付",
+	ActionShowAnswerStats: "📊 回答状況表示",
+	ActionRevealAnswer:    "✅ 回答発表",
+	ActionShowLeaderboard: "📈 途中経過発表",
+	ActionIntermission:    "☕ 休憩",
+	ActionShowResults:     "🏆 結果発表",
+	ActionCelebration:     "🎉 発表",
+	ActionFinish:          "🏁 終了",
+	ActionGoBack:          "↩️ 戻る",
+	ActionPause:           "⏸️ 一時停止",
+	ActionResume:          "▶️ 再開",
+	ActionAutopilotSkip:   "⏭️ スキップ",
+	ActionAutopilotExtend: "⏱️ 延長",
+	ActionAutopilotManual: "✋ 手動操作に切替",
+	ActionAutopilotAuto:   "🤖 自動進行に切替",
+}
+
+// Transition conditions usable in the "when" field
+const (
+	FlowWhenTeamMode        = "team_mode"         // チーム戦のとき
+	FlowWhenIndividualMode  = "individual_mode"   // 個人戦のとき
+	FlowWhenHasNextQuestion = "has_next_question" // 未出題の問題が残っているとき
+	FlowWhenLastQuestion    = "last_question"     // 最後の問題を出題済みのとき
+)
+
+// FlowConfig declares the event flow graph
+type FlowConfig struct {
+	Initial     string           `toml:"initial"`
+	Transitions []FlowTransition `toml:"transitions"`
+	Timers      []FlowTimer      `toml:"timers"`
+}
+
+// FlowTransition moves the event from one state to another when an action is performed
+type FlowTransition struct {
+	From    string `toml:"from" json:"from"`
+	To      string `toml:"to" json:"to"`
+	Action  string `toml:"action" json:"action"`
+	When    string `toml:"when" json:"when,omitempty"`       // Optional condition (team_mode, individual_mode, has_next_question, last_question)
+	Every   int    `toml:"every" json:"every,omitempty"`     // Only available after every N-th question
+	Label   string `toml:"label" json:"label,omitempty"`     // Overrides the admin button label
+	Message string `toml:"message" json:"message,omitempty"` // Text shown on screens (intermission)
+}
+
+// FlowTimer performs an action automatically after the event stayed in a state for a while
+type FlowTimer struct {
+	State  string `toml:"state" json:"state"`
+	After  string `toml:"after" json:"after"` // Go duration string such as "5.5s"
+	Action string `toml:"action" json:"action"`
+}
+
+// AvailableAction describes an action the admin can perform in the current state
+type AvailableAction struct {
+	Action string     `json:"action"`
+	Label  string     `json:"label"`
+	To     EventState `json:"to"`
+}
+
+// DefaultFlow returns the standard quiz flow
+func DefaultFlow() FlowConfig {
+	return FlowConfig{
+		Initial: string(StateStarted),
+		Transitions: []FlowTransition{
+			{From: string(StateWaiting), Action: ActionStartEvent, To: string(StateStarted)},
+			{From: string(StateStarted), Action: ActionShowTitle, To: string(StateTitleDisplay)},
+			{From: string(StateTitleDisplay), Action: ActionAssignTeams, To: string(StateTeamAssignment), When: FlowWhenTeamMode},
+			{From: string(StateTitleDisplay), Action: ActionNextQuestion, To: string(StateQuestionActive), When: FlowWhenIndividualMode},
+			{From: string(StateTeamAssignment), Action: ActionNextQuestion, To: string(StateQuestionActive)},
+			{From: string(StateQuestionActive), Action: ActionCountdownAlert, To: string(StateCountdownActive)},
+			{From: string(StateCountdownActive), Action: ActionCloseAnswers, To: string(StateAnswerStats)},
+			{From: string(StateAnswerStats), Action: ActionShowAnswerStats, To: string(StateAnswerStats)},
+			{From: string(StateAnswerStats), Action: ActionReopenAnswers, To: string(StateQuestionActive)},
+			{From: string(StateAnswerStats), Action: ActionRevealAnswer, To: string(StateAnswerReveal)},
+			{From: string(StateAnswerReveal), Action: ActionNextQuestion, To: string(StateQuestionActive), When: FlowWhenHasNextQuestion},
+			{From: string(StateAnswerReveal), Action: ActionShowResults, To: string(StateResults), When: FlowWhenLastQuestion},
+			{From: string(StateResults), Action: ActionCelebration, To: string(StateCelebration)},
+			{From: string(StateCelebration), Action: ActionFinish, To: string(StateFinished)},
+		},
+		Timers: []FlowTimer{
+			{State: string(StateCountdownActive), After: "5.5s", Action: ActionCloseAnswers},
+			{State: string(StateCelebration), After: "5s", Action: ActionFinish},
+		},
+	}
+}
+
+// IsEmpty reports whether no flow was declared in the config
+func (f *FlowConfig) IsEmpty() bool {
+	return len(f.Transitions) == 0
+}
+
+// InitialState returns the state a new event starts in
+func (f *FlowConfig) InitialState() EventState {
+	if f.Initial == "" {
+		return StateStarted
+	}
+	return EventState(f.Initial)
+}
+
+// Validate checks that the flow only refers to known states and actions
+func (f *FlowConfig) Validate() error {
+	if f.IsEmpty() {
+		return errors.New("at least one transition is required")
+	}
+
+	if f.Initial != "" && !IsValidState(EventState(f.Initial)) {
+		return fmt.Errorf("unknown initial state: %s", f.Initial)
+	}
+
+	for i, t := range f.Transitions {
+		if !IsValidState(EventState(t.From)) {
+			return fmt.Errorf("transition %d: unknown state: %s", i+1, t.From)
+		}
+		if !IsValidState(EventState(t.To)) {
+			return fmt.Errorf("transition %d: unknown state: %s", i+1, t.To)
+		}
+		if !IsValidAction(t.Action) {
+			return fmt.Errorf("transition %d: unknown action: %s", i+1, t.Action)
+		}
+		switch t.When {
+		case "", FlowWhenTeamMode, FlowWhenIndividualMode, FlowWhenHasNextQuestion, FlowWhenLastQuestion:
+		default:
+			return fmt.Errorf("transition %d: unknown condition: %s", i+1, t.When)
+		}
+		if t.Every < 0 {
+			return fmt.Errorf("transition %d: every must not be negative", i+1)
+		}
+	}
+
+	for i, timer := range f.Timers {
+		if !IsValidState(EventState(timer.State)) {
+			return fmt.Errorf("timer %d: unknown state: %s", i+1, timer.State)
+		}
+		delay, err := time.ParseDuration(timer.After)
+		if err != nil {
+			return fmt.Errorf("timer %d: invalid duration: %v", i+1, err)
+		}
+		if delay <= 0 {
+			return fmt.Errorf("timer %d: duration must be positive", i+1)
+		}
+		if !f.hasTransition(EventState(timer.State), timer.Action) {
+			return fmt.Errorf("timer %d: no transition for action %s from state %s", i+1, timer.Action, timer.State)
+		}
+	}
+
+	return nil
+}
+
+// TimerFor returns the automatic timer declared for a state
+func (f *FlowConfig) TimerFor(state EventState) (action string, delay time.Duration, ok bool) {
+	for _, timer := range f.Timers {
+		if EventState(timer.State) != state {
+			continue
+		}
+		delay, err := time.ParseDuration(timer.After)
+		if err != nil {
+			return "", 0, false
+		}
+		return timer.Action, delay, true
+	}
+	return "", 0, false
+}
+
+func (f *FlowConfig) hasTransition(from EventState, action string) bool {
+	for _, t := range f.Transitions {
+		if EventState(t.From) == from && t.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+// AllActions returns all known admin actions
+func AllActions() []string {
+	return []string{
+		ActionStartEvent,
+		ActionShowTitle,
+		ActionAssignTeams,
+		ActionNextQuestion,
+		ActionCountdownAlert,
+		ActionCloseAnswers,
+		ActionReopenAnswers,
+		ActionShowAnswerStats,
+		ActionRevealAnswer,
+		ActionShowLeaderboard,
+		ActionIntermission,
+		ActionShowResults,
+		ActionCelebration,
+		ActionFinish,
+	}
+}
+
+// IsValidAction checks if an action is known
+func IsValidAction(action string) bool {
+	for _, validAction := range AllActions() {
+		if action == validAction {
+			return true
+		}
+	}
+	return false
+}
+
+// GetActionLabel returns the Japanese button label for an action
+func GetActionLabel(action string) string {
+	if label, exists := ActionLabels[action]; exists {
+		return label
+	}
+	return action
+}
@@ -0,0 +1,215 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestDefaultFlowIsValid(t *testing.T) {
+	flow := DefaultFlow()
+	if err := flow.Validate(); err != nil {
+		t.Fatalf("Expected default flow to be valid, got %v", err)
+	}
+}
+
+func TestDefaultFlowMatchesStandardEvent(t *testing.T) {
+	esm := NewEventStateManager(false, 2, FlowConfig{})
+
+	steps := []struct {
+		action string
+		state  EventState
+	}{
+		{ActionShowTitle, StateTitleDisplay},
+		{ActionNextQuestion, StateQuestionActive},
+		{ActionCountdownAlert, StateCountdownActive},
+		{ActionCloseAnswers, StateAnswerStats},
+		{ActionShowAnswerStats, StateAnswerStats},
+		{ActionRevealAnswer, StateAnswerReveal},
+		{ActionNextQuestion, StateQuestionActive},
+		{ActionCountdownAlert, StateCountdownActive},
+		{ActionCloseAnswers, StateAnswerStats},
+		{ActionRevealAnswer, StateAnswerReveal},
+		{ActionShowResults, StateResults},
+		{ActionCelebration, StateCelebration},
+		{ActionFinish, StateFinished},
+	}
+
+	for i, step := range steps {
+		if _, err := esm.PerformAction(step.action); err != nil {
+			t.Fatalf("Step %d (%s): unexpected error: %v", i+1, step.action, err)
+		}
+		if esm.GetCurrentState() != step.state {
+			t.Fatalf("Step %d (%s): expected state %s, got %s", i+1, step.action, step.state, esm.GetCurrentState())
+		}
+	}
+
+	if esm.GetQuestionNumber() != 2 {
+		t.Errorf("Expected question number 2, got %d", esm.GetQuestionNumber())
+	}
+}
+
+func TestFlowConditions(t *testing.T) {
+	teamESM := NewEventStateManager(true, 1, FlowConfig{})
+	teamESM.JumpToState(StateTitleDisplay)
+	if actions := flowActions(teamESM); len(actions) != 1 || actions[0] != ActionAssignTeams {
+		t.Errorf("Expected only assign_teams in team mode, got %v", actions)
+	}
+
+	soloESM := NewEventStateManager(false, 1, FlowConfig{})
+	soloESM.JumpToState(StateAnswerReveal)
+	soloESM.SetQuestionNumber(1)
+	if actions := flowActions(soloESM); len(actions) != 1 || actions[0] != ActionShowResults {
+		t.Errorf("Expected only show_results after the last question, got %v", actions)
+	}
+	if _, err := soloESM.PerformAction(ActionNextQuestion); err == nil {
+		t.Error("Expected next_question to be rejected after the last question")
+	}
+}
+
+func TestFlowLeaderboardEveryN(t *testing.T) {
+	flow := DefaultFlow()
+	flow.Transitions = append(flow.Transitions,
+		FlowTransition{From: string(StateAnswerReveal), Action: ActionShowLeaderboard, To: string(StateLeaderboard), Every: 5, When: FlowWhenHasNextQuestion},
+		FlowTransition{From: string(StateLeaderboard), Action: ActionNextQuestion, To: string(StateQuestionActive)},
+	)
+	if err := flow.Validate(); err != nil {
+		t.Fatalf("Expected flow to be valid, got %v", err)
+	}
+
+	esm := NewEventStateManager(false, 10, flow)
+	esm.JumpToState(StateAnswerReveal)
+
+	for question := 1; question <= 10; question++ {
+		esm.SetQuestionNumber(question)
+		hasLeaderboard := false
+		for _, action := range esm.GetAvailableActions() {
+			if action == ActionShowLeaderboard {
+				hasLeaderboard = true
+			}
+		}
+		expected := question == 5
+		if hasLeaderboard != expected {
+			t.Errorf("Question %d: expected leaderboard available=%v, got %v", question, expected, hasLeaderboard)
+		}
+	}
+}
+
+func TestFlowValidation(t *testing.T) {
+	testCases := []struct {
+		name string
+		flow FlowConfig
+	}{
+		{"unknown state", FlowConfig{Transitions: []FlowTransition{{From: "nowhere", To: string(StateStarted), Action: ActionShowTitle}}}},
+		{"unknown action", FlowConfig{Transitions: []FlowTransition{{From: string(StateStarted), To: string(StateTitleDisplay), Action: "dance"}}}},
+		{"unknown condition", FlowConfig{Transitions: []FlowTransition{{From: string(StateStarted), To: string(StateTitleDisplay), Action: ActionShowTitle, When: "sometimes"}}}},
+		{"bad timer duration", FlowConfig{
+			Transitions: []FlowTransition{{From: string(StateStarted), To: string(StateTitleDisplay), Action: ActionShowTitle}},
+			Timers:      []FlowTimer{{State: string(StateStarted), After: "soon", Action: ActionShowTitle}},
+		}},
+		{"timer without transition", FlowConfig{
+			Transitions: []FlowTransition{{From: string(StateStarted), To: string(StateTitleDisplay), Action: ActionShowTitle}},
+			Timers:      []FlowTimer{{State: string(StateTitleDisplay), After: "3s", Action: ActionShowTitle}},
+		}},
+	}
+
+	for _, tc := range testCases {
+		if err := tc.flow.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", tc.name)
+		}
+	}
+}
+
+func TestGoBackRestoresStateAndQuestion(t *testing.T) {
+	esm := NewEventStateManager(false, 3, FlowConfig{})
+
+	if _, err := esm.GoBack(); err == nil {
+		t.Error("Expected error when there is no history")
+	}
+
+	for _, action := range []string{ActionShowTitle, ActionNextQuestion, ActionCountdownAlert, ActionCloseAnswers, ActionShowAnswerStats, ActionRevealAnswer, ActionNextQuestion} {
+		if _, err := esm.PerformAction(action); err != nil {
+			t.Fatalf("%s: unexpected error: %v", action, err)
+		}
+	}
+
+	if esm.GetQuestionNumber() != 2 || esm.GetCurrentState() != StateQuestionActive {
+		t.Fatalf("Expected question 2 active, got question %d in %s", esm.GetQuestionNumber(), esm.GetCurrentState())
+	}
+
+	// A mis-clicked next_question goes back to the revealed answer of question 1
+	snapshot, err := esm.GoBack()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if snapshot.State != StateAnswerReveal || esm.GetCurrentState() != StateAnswerReveal {
+		t.Errorf("Expected answer_reveal, got %s", esm.GetCurrentState())
+	}
+	if esm.GetQuestionNumber() != 1 {
+		t.Errorf("Expected question 1, got %d", esm.GetQuestionNumber())
+	}
+
+	// The show_answer_stats self-loop is not recorded
+	if _, err := esm.GoBack(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if esm.GetCurrentState() != StateAnswerStats {
+		t.Errorf("Expected answer_stats, got %s", esm.GetCurrentState())
+	}
+}
+
+func TestReopenAnswers(t *testing.T) {
+	esm := NewEventStateManager(false, 2, FlowConfig{})
+	esm.JumpToState(StateAnswerStats)
+	esm.SetQuestionNumber(1)
+
+	if _, err := esm.PerformAction(ActionReopenAnswers); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if esm.GetCurrentState() != StateQuestionActive || esm.GetQuestionNumber() != 1 {
+		t.Errorf("Expected question 1 active again, got question %d in %s", esm.GetQuestionNumber(), esm.GetCurrentState())
+	}
+}
+
+// flowActions returns the available actions declared by the flow (without go_back)
+func flowActions(esm *EventStateManager) []string {
+	actions := []string{}
+	for _, action := range esm.GetAvailableActions() {
+		if action != ActionGoBack {
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
+
+func TestRestoreState(t *testing.T) {
+	esm := NewEventStateManager(false, 3, FlowConfig{})
+
+	history := []StateSnapshot{
+		{State: StateTitleDisplay, QuestionNumber: 0},
+		{State: StateQuestionActive, QuestionNumber: 1},
+	}
+	if err := esm.Restore(StateAnswerReveal, 1, history); err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+
+	if esm.GetCurrentState() != StateAnswerReveal || esm.GetQuestionNumber() != 1 {
+		t.Errorf("Expected answer_reveal/1, got %s/%d", esm.GetCurrentState(), esm.GetQuestionNumber())
+	}
+	if _, err := esm.PerformAction(ActionNextQuestion); err != nil {
+		t.Errorf("Expected the flow to continue after restore: %v", err)
+	}
+	if esm.GetQuestionNumber() != 2 {
+		t.Errorf("Expected question 2, got %d", esm.GetQuestionNumber())
+	}
+
+	// Two restored snapshots plus the one pushed by next_question
+	if len(esm.GetHistory()) != 3 {
+		t.Errorf("Expected 3 history entries, got %d", len(esm.GetHistory()))
+	}
+
+	if err := esm.Restore(EventState("unknown"), 0, nil); err == nil {
+		t.Error("Expected error for unknown state")
+	}
+	if err := esm.Restore(StateQuestionActive, 4, nil); err == nil {
+		t.Error("Expected error for out-of-range question")
+	}
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// LiveAnswerDistribution tells who sees how the answers split over the
+// choices while a question is open. Everyone sees how many have answered.
+type LiveAnswerDistribution string
+
+const (
+	LiveDistributionAdmin LiveAnswerDistribution = "admin" // Admins only (default)
+	LiveDistributionAll   LiveAnswerDistribution = "all"   // Admins and screens
+	LiveDistributionNone  LiveAnswerDistribution = "none"  // Nobody until the answer stats
+)
+
+// DefaultLiveAnswerInterval is the shortest time between two live updates
+const DefaultLiveAnswerInterval = 500 * time.Millisecond
+
+// LiveAnswersConfig controls the answer counts streamed while a question is open
+type LiveAnswersConfig struct {
+	Interval     string                 `toml:"interval"`     // At most one update per interval (default 500ms)
+	Distribution LiveAnswerDistribution `toml:"distribution"` // "admin", "all" or "none"
+}
+
+// Validate checks the interval and the distribution setting
+func (l *LiveAnswersConfig) Validate() error {
+	if l.Interval != "" {
+		d, err := time.ParseDuration(l.Interval)
+		if err != nil {
+			return fmt.Errorf("interval: invalid duration: %v", err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("interval: duration must be positive")
+		}
+	}
+
+	switch l.Distribution {
+	case "", LiveDistributionAdmin, LiveDistributionAll, LiveDistributionNone:
+		return nil
+	}
+	return fmt.Errorf("distribution: unknown value %q (use admin, all or none)", l.Distribution)
+}
+
+// UpdateInterval returns the shortest time between two live updates
+func (l *LiveAnswersConfig) UpdateInterval() time.Duration {
+	if d, err := time.ParseDuration(l.Interval); err == nil && d > 0 {
+		return d
+	}
+	return DefaultLiveAnswerInterval
+}
+
+// ShowsDistribution reports whether clients of the kind see the distribution
+func (l *LiveAnswersConfig) ShowsDistribution(admin bool) bool {
+	switch l.Distribution {
+	case LiveDistributionAll:
+		return true
+	case LiveDistributionNone:
+		return false
+	}
+	return admin
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLiveAnswersConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  LiveAnswersConfig
+		wantErr bool
+	}{
+		{"defaults", LiveAnswersConfig{}, false},
+		{"all", LiveAnswersConfig{Interval: "1s", Distribution: LiveDistributionAll}, false},
+		{"bad interval", LiveAnswersConfig{Interval: "soon"}, true},
+		{"zero interval", LiveAnswersConfig{Interval: "0s"}, true},
+		{"unknown distribution", LiveAnswersConfig{Distribution: "participants"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	defaults := LiveAnswersConfig{}
+	if defaults.UpdateInterval() != DefaultLiveAnswerInterval {
+		t.Errorf("Expected default interval, got %v", defaults.UpdateInterval())
+	}
+	if !defaults.ShowsDistribution(true) || defaults.ShowsDistribution(false) {
+		t.Error("Expected the distribution to be shown to admins only by default")
+	}
+
+	all := LiveAnswersConfig{Interval: "2s", Distribution: LiveDistributionAll}
+	if all.UpdateInterval() != 2*time.Second || !all.ShowsDistribution(false) {
+		t.Errorf("Expected 2s and the distribution on screens, got %+v", all)
+	}
+	none := LiveAnswersConfig{Distribution: LiveDistributionNone}
+	if none.ShowsDistribution(true) {
+		t.Error("Expected no distribution with none")
+	}
+}
+
+func TestGetAnswersByQuestion(t *testing.T) {
+	db := setupProgressDB(t)
+	event, err := NewEventRepository(db).CreateEvent("main", "Test Quiz", false, 1, "")
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	userRepo := NewUserRepository(db, event.ID)
+	answerRepo := NewAnswerRepository(db, event.ID)
+
+	for i, nickname := range []string{"a", "b", "c"} {
+		user, err := userRepo.CreateUser("session-"+nickname, nickname)
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		if err := answerRepo.CreateAnswer(user.ID, 1, i+1, i == 0); err != nil {
+			t.Fatalf("Failed to create answer: %v", err)
+		}
+	}
+	first, _ := userRepo.GetUserBySessionID("session-a")
+	if err := answerRepo.CreateAnswer(first.ID, 2, 4, false); err != nil {
+		t.Fatalf("Failed to create answer: %v", err)
+	}
+
+	answers, err := answerRepo.GetAnswersByQuestion(1)
+	if err != nil {
+		t.Fatalf("Failed to get answers: %v", err)
+	}
+	if len(answers) != 3 {
+		t.Fatalf("Expected 3 answers to question 1, got %d", len(answers))
+	}
+	for _, answer := range answers {
+		if answer.QuestionNumber != 1 {
+			t.Errorf("Expected only question 1, got %+v", answer)
+		}
+	}
+}
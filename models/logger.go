@@ -109,16 +109,16 @@ func (ql *QuizLogger) LogEventEnd(title string, participantCount int, totalQuest
 	ql.Info("Total Questions: %d", totalQuestions)
 }
 
-func (ql *QuizLogger) LogUserJoin(nickname string, sessionID string) {
-	ql.Info("User joined: %s (Session: %s)", nickname, sessionID[:8])
+func (ql *QuizLogger) LogUserJoin(nickname string, userID int) {
+	ql.Info("User joined: %s (User ID: %d)", nickname, userID)
 }
 
-func (ql *QuizLogger) LogUserReconnect(nickname string, sessionID string) {
-	ql.Info("User reconnected: %s (Session: %s)", nickname, sessionID[:8])
+func (ql *QuizLogger) LogUserReconnect(nickname string, userID int) {
+	ql.Info("User reconnected: %s (User ID: %d)", nickname, userID)
 }
 
-func (ql *QuizLogger) LogUserSessionReset(nickname string, sessionID string) {
-	ql.Info("User session reset: %s (Session: %s)", nickname, sessionID[:8])
+func (ql *QuizLogger) LogUserSessionReset(nickname string, userID int) {
+	ql.Info("User session reset: %s (User ID: %d)", nickname, userID)
 }
 
 func (ql *QuizLogger) LogQuestionStart(questionNumber int, questionText string) {
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// MaxLoginFailures is how many wrong passwords an IP may send in a row
+	MaxLoginFailures = 10
+	// LoginRetryInterval is the wait between attempts once an IP hit the limit
+	LoginRetryInterval = 30 * time.Second
+	// loginFailuresForgotten drops the failures of an IP that stopped trying
+	loginFailuresForgotten = time.Hour
+)
+
+// LoginThrottle slows down password guessing per client IP: after
+// MaxLoginFailures failed logins, the IP gets one attempt per
+// LoginRetryInterval until it logs in or stops trying for an hour.
+type LoginThrottle struct {
+	mu       sync.Mutex
+	failures map[string]*loginFailures
+	now      func() time.Time
+}
+
+type loginFailures struct {
+	count int
+	last  time.Time
+}
+
+// NewLoginThrottle creates a new LoginThrottle instance
+func NewLoginThrottle() *LoginThrottle {
+	return &LoginThrottle{failures: make(map[string]*loginFailures), now: time.Now}
+}
+
+// Allow reports whether the IP may try to log in now, or how long it must wait
+func (lt *LoginThrottle) Allow(ip string) (bool, time.Duration) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	failures, ok := lt.failures[ip]
+	if !ok || failures.count < MaxLoginFailures {
+		return true, 0
+	}
+	if wait := LoginRetryInterval - lt.now().Sub(failures.last); wait > 0 {
+		return false, wait
+	}
+	return true, 0
+}
+
+// Failed counts a wrong password from the IP
+func (lt *LoginThrottle) Failed(ip string) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	now := lt.now()
+	for key, failures := range lt.failures {
+		if now.Sub(failures.last) > loginFailuresForgotten {
+			delete(lt.failures, key)
+		}
+	}
+
+	failures, ok := lt.failures[ip]
+	if !ok {
+		failures = &loginFailures{}
+		lt.failures[ip] = failures
+	}
+	failures.count++
+	failures.last = now
+}
+
+// Succeeded forgets the failures of an IP that logged in
+func (lt *LoginThrottle) Succeeded(ip string) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	delete(lt.failures, ip)
+}
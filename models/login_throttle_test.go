@@ -0,0 +1,44 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginThrottle(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttle := NewLoginThrottle()
+	throttle.now = func() time.Time { return now }
+
+	const attacker, staff = "203.0.113.7", "192.168.1.10"
+	for i := 0; i < MaxLoginFailures; i++ {
+		if ok, _ := throttle.Allow(attacker); !ok {
+			t.Fatalf("Attempt %d: expected to be allowed", i+1)
+		}
+		throttle.Failed(attacker)
+	}
+
+	ok, wait := throttle.Allow(attacker)
+	if ok || wait != LoginRetryInterval {
+		t.Fatalf("After %d failures: got allowed %v, wait %v; want a wait of %v", MaxLoginFailures, ok, wait, LoginRetryInterval)
+	}
+	if ok, _ := throttle.Allow(staff); !ok {
+		t.Error("Expected another IP to be allowed")
+	}
+
+	// Once the interval has passed there is one more attempt, then a wait again
+	now = now.Add(LoginRetryInterval)
+	if ok, _ := throttle.Allow(attacker); !ok {
+		t.Fatal("Expected an attempt after the retry interval")
+	}
+	throttle.Failed(attacker)
+	if ok, _ := throttle.Allow(attacker); ok {
+		t.Error("Expected another wait after a failed retry")
+	}
+
+	// Logging in clears the failures
+	throttle.Succeeded(attacker)
+	if ok, _ := throttle.Allow(attacker); !ok {
+		t.Error("Expected to be allowed after logging in")
+	}
+}
@@ -1,14 +1,17 @@
 package models
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 )
 
 type User struct {
 	ID        int       `json:"id" db:"id"`
-	SessionID string    `json:"session_id" db:"session_id"`
+	SessionID string    `json:"-" db:"session_id"` // Secret; participants hold a signed token instead
 	Nickname  string    `json:"nickname" db:"nickname"`
 	TeamID    *int      `json:"team_id" db:"team_id"`
 	Score     int       `json:"score" db:"score"`
@@ -28,6 +31,7 @@ type Team struct {
 
 type Event struct {
 	ID             int       `json:"id" db:"id"`
+	RoomCode       string    `json:"room_code" db:"room_code"`
 	Title          string    `json:"title" db:"title"`
 	Status         string    `json:"status" db:"status"`
 	QuestionNumber int       `json:"question_number" db:"question_number"`
@@ -52,12 +56,16 @@ type EmojiReaction struct {
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
 
+// Repositories for participant data only see the rows of one event (room)
+
 type UserRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	eventID int
 }
 
 type TeamRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	eventID int
 }
 
 type EventRepository struct {
@@ -65,39 +73,51 @@ type EventRepository struct {
 }
 
 type AnswerRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	eventID int
 }
 
 type EmojiReactionRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	eventID int
 }
 
-func NewUserRepository(db *sql.DB) *UserRepository {
-	return &UserRepository{db: db}
+func NewUserRepository(db *sql.DB, eventID int) *UserRepository {
+	return &UserRepository{db: db, eventID: eventID}
 }
 
-func NewTeamRepository(db *sql.DB) *TeamRepository {
-	return &TeamRepository{db: db}
+func NewTeamRepository(db *sql.DB, eventID int) *TeamRepository {
+	return &TeamRepository{db: db, eventID: eventID}
 }
 
 func NewEventRepository(db *sql.DB) *EventRepository {
 	return &EventRepository{db: db}
 }
 
-func NewAnswerRepository(db *sql.DB) *AnswerRepository {
-	return &AnswerRepository{db: db}
+func NewAnswerRepository(db *sql.DB, eventID int) *AnswerRepository {
+	return &AnswerRepository{db: db, eventID: eventID}
 }
 
-func NewEmojiReactionRepository(db *sql.DB) *EmojiReactionRepository {
-	return &EmojiReactionRepository{db: db}
+func NewEmojiReactionRepository(db *sql.DB, eventID int) *EmojiReactionRepository {
+	return &EmojiReactionRepository{db: db, eventID: eventID}
+}
+
+// FindEventIDBySession returns the event a participant session belongs to, or 0
+func FindEventIDBySession(db *sql.DB, sessionID string) (int, error) {
+	var eventID int
+	err := db.QueryRow(`SELECT event_id FROM users WHERE session_id = ?`, sessionID).Scan(&eventID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return eventID, err
 }
 
 func (r *UserRepository) CreateUser(sessionID, nickname string) (*User, error) {
 	query := `
-		INSERT INTO users (session_id, nickname, created_at, updated_at)
-		VALUES (?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		INSERT INTO users (event_id, session_id, nickname, created_at, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 	`
-	result, err := r.db.Exec(query, sessionID, nickname)
+	result, err := r.db.Exec(query, r.eventID, sessionID, nickname)
 	if err != nil {
 		return nil, err
 	}
@@ -112,9 +132,9 @@ func (r *UserRepository) CreateUser(sessionID, nickname string) (*User, error) {
 
 func (r *UserRepository) GetUserBySessionID(sessionID string) (*User, error) {
 	user := &User{}
-	query := `SELECT id, session_id, nickname, team_id, score, connected, created_at, updated_at FROM users WHERE session_id = ?`
+	query := `SELECT id, session_id, nickname, team_id, score, connected, created_at, updated_at FROM users WHERE session_id = ? AND event_id = ?`
 
-	err := r.db.QueryRow(query, sessionID).Scan(
+	err := r.db.QueryRow(query, sessionID, r.eventID).Scan(
 		&user.ID, &user.SessionID, &user.Nickname, &user.TeamID,
 		&user.Score, &user.Connected, &user.CreatedAt, &user.UpdatedAt,
 	)
@@ -128,9 +148,9 @@ func (r *UserRepository) GetUserBySessionID(sessionID string) (*User, error) {
 
 func (r *UserRepository) GetUserByID(id int) (*User, error) {
 	user := &User{}
-	query := `SELECT id, session_id, nickname, team_id, score, connected, created_at, updated_at FROM users WHERE id = ?`
+	query := `SELECT id, session_id, nickname, team_id, score, connected, created_at, updated_at FROM users WHERE id = ? AND event_id = ?`
 
-	err := r.db.QueryRow(query, id).Scan(
+	err := r.db.QueryRow(query, id, r.eventID).Scan(
 		&user.ID, &user.SessionID, &user.Nickname, &user.TeamID,
 		&user.Score, &user.Connected, &user.CreatedAt, &user.UpdatedAt,
 	)
@@ -139,8 +159,8 @@ func (r *UserRepository) GetUserByID(id int) (*User, error) {
 }
 
 func (r *UserRepository) GetAllUsers() ([]User, error) {
-	query := `SELECT id, session_id, nickname, team_id, score, connected, created_at, updated_at FROM users ORDER BY score DESC`
-	rows, err := r.db.Query(query)
+	query := `SELECT id, session_id, nickname, team_id, score, connected, created_at, updated_at FROM users WHERE event_id = ? ORDER BY score DESC`
+	rows, err := r.db.Query(query, r.eventID)
 	if err != nil {
 		return nil, err
 	}
@@ -163,23 +183,30 @@ func (r *UserRepository) GetAllUsers() ([]User, error) {
 }
 
 func (r *UserRepository) UpdateUserConnection(sessionID string, connected bool) error {
-	query := `UPDATE users SET connected = ?, updated_at = CURRENT_TIMESTAMP WHERE session_id = ?`
-	_, err := r.db.Exec(query, connected, sessionID)
+	query := `UPDATE users SET connected = ?, updated_at = CURRENT_TIMESTAMP WHERE session_id = ? AND event_id = ?`
+	_, err := r.db.Exec(query, connected, sessionID, r.eventID)
+	return err
+}
+
+// SetUserConnected updates the presence flag that team assignment reads
+func (r *UserRepository) SetUserConnected(userID int, connected bool) error {
+	query := `UPDATE users SET connected = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND event_id = ?`
+	_, err := r.db.Exec(query, connected, userID, r.eventID)
 	return err
 }
 
 func (r *UserRepository) UpdateUserScore(userID int, score int) error {
-	query := `UPDATE users SET score = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
-	_, err := r.db.Exec(query, score, userID)
+	query := `UPDATE users SET score = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND event_id = ?`
+	_, err := r.db.Exec(query, score, userID, r.eventID)
 	return err
 }
 
-func (r *EventRepository) CreateEvent(title string, teamMode bool, teamSize int, qrcode string) (*Event, error) {
+func (r *EventRepository) CreateEvent(roomCode, title string, teamMode bool, teamSize int, qrcode string) (*Event, error) {
 	query := `
-		INSERT INTO events (title, status, team_mode, team_size, qrcode, created_at, updated_at)
-		VALUES (?, 'waiting', ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		INSERT INTO events (room_code, title, status, team_mode, team_size, qrcode, created_at, updated_at)
+		VALUES (?, ?, 'waiting', ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 	`
-	result, err := r.db.Exec(query, title, teamMode, teamSize, qrcode)
+	result, err := r.db.Exec(query, roomCode, title, teamMode, teamSize, qrcode)
 	if err != nil {
 		return nil, err
 	}
@@ -194,10 +221,10 @@ func (r *EventRepository) CreateEvent(title string, teamMode bool, teamSize int,
 
 func (r *EventRepository) GetCurrentEvent() (*Event, error) {
 	event := &Event{}
-	query := `SELECT id, title, status, question_number, team_mode, created_at, updated_at FROM events ORDER BY created_at DESC LIMIT 1`
+	query := `SELECT id, room_code, title, status, question_number, team_mode, created_at, updated_at FROM events ORDER BY created_at DESC LIMIT 1`
 
 	err := r.db.QueryRow(query).Scan(
-		&event.ID, &event.Title, &event.Status, &event.QuestionNumber,
+		&event.ID, &event.RoomCode, &event.Title, &event.Status, &event.QuestionNumber,
 		&event.TeamMode, &event.CreatedAt, &event.UpdatedAt,
 	)
 
@@ -210,10 +237,10 @@ func (r *EventRepository) GetCurrentEvent() (*Event, error) {
 
 func (r *EventRepository) GetEvent(id int) (*Event, error) {
 	event := &Event{}
-	query := `SELECT id, title, status, question_number, team_mode, created_at, updated_at FROM events WHERE id = ?`
+	query := `SELECT id, room_code, title, status, question_number, team_mode, created_at, updated_at FROM events WHERE id = ?`
 
 	err := r.db.QueryRow(query, id).Scan(
-		&event.ID, &event.Title, &event.Status, &event.QuestionNumber,
+		&event.ID, &event.RoomCode, &event.Title, &event.Status, &event.QuestionNumber,
 		&event.TeamMode, &event.CreatedAt, &event.UpdatedAt,
 	)
 
@@ -234,16 +261,16 @@ func (r *EventRepository) UpdateQuestionNumber(id int, questionNumber int) error
 
 func (r *AnswerRepository) CreateAnswer(userID, questionNumber, answerIndex int, isCorrect bool) error {
 	query := `
-		INSERT INTO answers (user_id, question_number, answer_index, is_correct, answer_time)
-		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		INSERT INTO answers (event_id, user_id, question_number, answer_index, is_correct, answer_time)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
 	`
-	_, err := r.db.Exec(query, userID, questionNumber, answerIndex, isCorrect)
+	_, err := r.db.Exec(query, r.eventID, userID, questionNumber, answerIndex, isCorrect)
 	return err
 }
 
 func (r *AnswerRepository) DeleteAnswersByUserID(userID int) error {
-	query := `DELETE FROM answers WHERE user_id = ?`
-	_, err := r.db.Exec(query, userID)
+	query := `DELETE FROM answers WHERE user_id = ? AND event_id = ?`
+	_, err := r.db.Exec(query, userID, r.eventID)
 	return err
 }
 
@@ -251,17 +278,102 @@ func (r *AnswerRepository) ChangeAnswer(userID, questionNumber, answerIndex int,
 	query := `
 		UPDATE answers
 		SET answer_index = ?, is_correct = ?, answer_time = CURRENT_TIMESTAMP
-		WHERE user_id = ? AND question_number = ?
+		WHERE user_id = ? AND question_number = ? AND event_id = ?
 	`
-	_, err := r.db.Exec(query, answerIndex, isCorrect, userID, questionNumber)
+	_, err := r.db.Exec(query, answerIndex, isCorrect, userID, questionNumber, r.eventID)
 	return err
 }
 
+// SavedAnswer is the outcome of SaveAnswer
+type SavedAnswer struct {
+	ScoreChange int
+	NewScore    int
+}
+
+// SaveAnswer records the user's answer to a question, replacing an earlier one,
+// and adjusts their score by the question's points in one transaction, so that
+// the same answer arriving twice at once (WebSocket and HTTP retry) is scored once
+func (r *AnswerRepository) SaveAnswer(userID, questionNumber, answerIndex int, isCorrect bool, point int) (*SavedAnswer, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Writing first takes the database write lock, so a concurrent submission
+	// waits here instead of reading the same previous answer
+	result, err := tx.Exec(`UPDATE users SET updated_at = CURRENT_TIMESTAMP WHERE id = ? AND event_id = ?`, userID, r.eventID)
+	if err != nil {
+		return nil, err
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if rows == 0 {
+		return nil, fmt.Errorf("user %d not found", userID)
+	}
+
+	answered, wasCorrect := true, false
+	err = tx.QueryRow(`SELECT is_correct FROM answers WHERE event_id = ? AND user_id = ? AND question_number = ?`, r.eventID, userID, questionNumber).Scan(&wasCorrect)
+	if err == sql.ErrNoRows {
+		answered = false
+	} else if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO answers (event_id, user_id, question_number, answer_index, is_correct, answer_time)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT (event_id, user_id, question_number) DO UPDATE
+		SET answer_index = excluded.answer_index, is_correct = excluded.is_correct, answer_time = excluded.answer_time
+	`
+	if _, err := tx.Exec(query, r.eventID, userID, questionNumber, answerIndex, isCorrect); err != nil {
+		return nil, err
+	}
+
+	// Points are added for a correct answer and taken back when it is changed to a wrong one
+	saved := &SavedAnswer{}
+	if isCorrect && (!answered || !wasCorrect) {
+		saved.ScoreChange = point
+	} else if !isCorrect && answered && wasCorrect {
+		saved.ScoreChange = -point
+	}
+
+	err = tx.QueryRow(`UPDATE users SET score = score + ? WHERE id = ? AND event_id = ? RETURNING score`, saved.ScoreChange, userID, r.eventID).Scan(&saved.NewScore)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// GetAnswersByQuestion returns every answer to a question
+func (r *AnswerRepository) GetAnswersByQuestion(questionNumber int) ([]Answer, error) {
+	query := `SELECT id, user_id, question_number, answer_index, is_correct, answer_time FROM answers WHERE question_number = ? AND event_id = ?`
+	rows, err := r.db.Query(query, questionNumber, r.eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var answers []Answer
+	for rows.Next() {
+		var answer Answer
+		if err := rows.Scan(&answer.ID, &answer.UserID, &answer.QuestionNumber, &answer.AnswerIndex, &answer.IsCorrect, &answer.AnswerTime); err != nil {
+			return nil, err
+		}
+		answers = append(answers, answer)
+	}
+	return answers, rows.Err()
+}
+
 func (r *AnswerRepository) GetAnswerByUserAndQuestion(userID, questionNumber int) (*Answer, error) {
 	answer := &Answer{}
-	query := `SELECT id, user_id, question_number, answer_index, is_correct, answer_time FROM answers WHERE user_id = ? AND question_number = ?`
+	query := `SELECT id, user_id, question_number, answer_index, is_correct, answer_time FROM answers WHERE user_id = ? AND question_number = ? AND event_id = ?`
 
-	err := r.db.QueryRow(query, userID, questionNumber).Scan(
+	err := r.db.QueryRow(query, userID, questionNumber, r.eventID).Scan(
 		&answer.ID, &answer.UserID, &answer.QuestionNumber,
 		&answer.AnswerIndex, &answer.IsCorrect, &answer.AnswerTime,
 	)
@@ -274,8 +386,8 @@ func (r *AnswerRepository) GetAnswerByUserAndQuestion(userID, questionNumber int
 }
 
 func (r *EmojiReactionRepository) CreateReaction(userID int, emoji string) error {
-	query := `INSERT INTO emoji_reactions (user_id, emoji, created_at) VALUES (?, ?, CURRENT_TIMESTAMP)`
-	_, err := r.db.Exec(query, userID, emoji)
+	query := `INSERT INTO emoji_reactions (event_id, user_id, emoji, created_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)`
+	_, err := r.db.Exec(query, r.eventID, userID, emoji)
 	return err
 }
 
@@ -283,10 +395,10 @@ func (r *EmojiReactionRepository) GetRecentReactions(minutes int) ([]EmojiReacti
 	query := `
 		SELECT id, user_id, emoji, created_at 
 		FROM emoji_reactions 
-		WHERE created_at >= datetime('now', '-' || ? || ' minutes')
+		WHERE event_id = ? AND created_at >= datetime('now', '-' || ? || ' minutes')
 		ORDER BY created_at DESC
 	`
-	rows, err := r.db.Query(query, minutes)
+	rows, err := r.db.Query(query, r.eventID, minutes)
 	if err != nil {
 		return nil, err
 	}
@@ -308,10 +420,10 @@ func (r *EmojiReactionRepository) GetRecentReactions(minutes int) ([]EmojiReacti
 // Team Repository Methods
 func (r *TeamRepository) CreateTeam(name string) (*Team, error) {
 	query := `
-		INSERT INTO teams (name, created_at, updated_at)
-		VALUES (?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		INSERT INTO teams (event_id, name, created_at, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 	`
-	result, err := r.db.Exec(query, name)
+	result, err := r.db.Exec(query, r.eventID, name)
 	if err != nil {
 		return nil, err
 	}
@@ -326,9 +438,9 @@ func (r *TeamRepository) CreateTeam(name string) (*Team, error) {
 
 func (r *TeamRepository) GetTeamByID(id int) (*Team, error) {
 	team := &Team{}
-	query := `SELECT id, name, score, created_at, updated_at FROM teams WHERE id = ?`
+	query := `SELECT id, name, score, created_at, updated_at FROM teams WHERE id = ? AND event_id = ?`
 
-	err := r.db.QueryRow(query, id).Scan(
+	err := r.db.QueryRow(query, id, r.eventID).Scan(
 		&team.ID, &team.Name, &team.Score, &team.CreatedAt, &team.UpdatedAt,
 	)
 
@@ -336,8 +448,8 @@ func (r *TeamRepository) GetTeamByID(id int) (*Team, error) {
 }
 
 func (r *TeamRepository) GetAllTeams() ([]Team, error) {
-	query := `SELECT id, name, score, created_at, updated_at FROM teams ORDER BY score DESC`
-	rows, err := r.db.Query(query)
+	query := `SELECT id, name, score, created_at, updated_at FROM teams WHERE event_id = ? ORDER BY score DESC`
+	rows, err := r.db.Query(query, r.eventID)
 	if err != nil {
 		return nil, err
 	}
@@ -365,8 +477,8 @@ func (r *TeamRepository) GetTeamWithMembers(id int) (*Team, error) {
 	}
 
 	// Get team members
-	query := `SELECT id, session_id, nickname, team_id, score, connected, created_at, updated_at FROM users WHERE team_id = ?`
-	rows, err := r.db.Query(query, id)
+	query := `SELECT id, session_id, nickname, team_id, score, connected, created_at, updated_at FROM users WHERE team_id = ? AND event_id = ?`
+	rows, err := r.db.Query(query, id, r.eventID)
 	if err != nil {
 		return team, nil // Return team without members if query fails
 	}
@@ -406,33 +518,33 @@ func (r *TeamRepository) GetAllTeamsWithMembers() ([]Team, error) {
 }
 
 func (r *TeamRepository) UpdateTeamScore(id int, score int) error {
-	query := `UPDATE teams SET score = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
-	_, err := r.db.Exec(query, score, id)
+	query := `UPDATE teams SET score = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND event_id = ?`
+	_, err := r.db.Exec(query, score, id, r.eventID)
 	return err
 }
 
 func (r *TeamRepository) DeleteAllTeams() error {
 	// First remove team associations from users
-	_, err := r.db.Exec("UPDATE users SET team_id = NULL WHERE team_id IS NOT NULL")
+	_, err := r.db.Exec("UPDATE users SET team_id = NULL WHERE team_id IS NOT NULL AND event_id = ?", r.eventID)
 	if err != nil {
 		return err
 	}
 
 	// Then delete all teams
-	_, err = r.db.Exec("DELETE FROM teams")
+	_, err = r.db.Exec("DELETE FROM teams WHERE event_id = ?", r.eventID)
 	return err
 }
 
 // User Repository Methods for Team Assignment
 func (r *UserRepository) AssignUserToTeam(userID int, teamID int) error {
-	query := `UPDATE users SET team_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
-	_, err := r.db.Exec(query, teamID, userID)
+	query := `UPDATE users SET team_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND event_id = ?`
+	_, err := r.db.Exec(query, teamID, userID, r.eventID)
 	return err
 }
 
 func (r *UserRepository) GetUsersWithoutTeam() ([]User, error) {
-	query := `SELECT id, session_id, nickname, team_id, score, connected, created_at, updated_at FROM users WHERE team_id IS NULL AND connected = 1`
-	rows, err := r.db.Query(query)
+	query := `SELECT id, session_id, nickname, team_id, score, connected, created_at, updated_at FROM users WHERE team_id IS NULL AND connected = 1 AND event_id = ?`
+	rows, err := r.db.Query(query, r.eventID)
 	if err != nil {
 		return nil, err
 	}
@@ -455,154 +567,411 @@ func (r *UserRepository) GetUsersWithoutTeam() ([]User, error) {
 }
 
 func (r *UserRepository) DeleteUserBySessionID(sessionID string) error {
-	query := `DELETE FROM users WHERE session_id = ?`
-	_, err := r.db.Exec(query, sessionID)
+	query := `DELETE FROM users WHERE session_id = ? AND event_id = ?`
+	_, err := r.db.Exec(query, sessionID, r.eventID)
 	return err
 }
 
 func (r *EmojiReactionRepository) DeleteReactionsByUserID(userID int) error {
-	query := `DELETE FROM emoji_reactions WHERE user_id = ?`
-	_, err := r.db.Exec(query, userID)
+	query := `DELETE FROM emoji_reactions WHERE user_id = ? AND event_id = ?`
+	_, err := r.db.Exec(query, userID, r.eventID)
 	return err
 }
 
 // Event State Management System
 // Note: EventState constants are now defined in constants.go
 
+// EventStateManager is the event's state machine. It is safe for concurrent use:
+// HTTP handlers, flow timers and the autopilot all move it. Changes are made one
+// at a time and reported to the hooks registered with OnBeforeTransition and
+// OnTransition (see state_hooks.go).
 type EventStateManager struct {
-	currentState     EventState
-	currentQuestion  int
-	totalQuestions   int
-	teamMode         bool
-	validTransitions map[EventState][]EventState
+	mu              sync.RWMutex // Guards the position fields below
+	currentState    EventState
+	currentQuestion int
+	totalQuestions  int
+	teamMode        bool
+	flow            FlowConfig
+	history         []StateSnapshot
+
+	transitionMu sync.Mutex // Serializes changes together with their before hooks
+	hookMu       sync.RWMutex
+	beforeHooks  []BeforeTransitionHook
+	afterHooks   []TransitionListener
+
+	notifyMu  sync.Mutex       // Guards pending and notifying
+	delivered *sync.Cond       // Signaled when a change was delivered or delivery stopped
+	pending   []*appliedChange // Applied changes the listeners have not seen yet
+	notifying bool             // A goroutine is delivering pending changes
 }
 
-func NewEventStateManager(teamMode bool, totalQuestions int) *EventStateManager {
+// StateSnapshot is a point in the event progress that can be returned to
+type StateSnapshot struct {
+	State          EventState `json:"state"`
+	QuestionNumber int        `json:"question_number"`
+}
+
+// maxStateHistory bounds how far back the operator can go
+const maxStateHistory = 100
+
+// NewEventStateManager creates a state manager driven by the given flow (the default flow when empty)
+func NewEventStateManager(teamMode bool, totalQuestions int, flow FlowConfig) *EventStateManager {
+	if flow.IsEmpty() {
+		flow = DefaultFlow()
+	}
+
 	esm := &EventStateManager{
-		currentState:    StateStarted,
+		currentState:    flow.InitialState(),
 		currentQuestion: 0,
 		totalQuestions:  totalQuestions,
 		teamMode:        teamMode,
+		flow:            flow,
 	}
-
-	esm.initValidTransitions()
+	esm.delivered = sync.NewCond(&esm.notifyMu)
 	return esm
 }
 
-func (esm *EventStateManager) initValidTransitions() {
-	esm.validTransitions = map[EventState][]EventState{
-		StateStarted:         {StateTitleDisplay},
-		StateTitleDisplay:    {StateTeamAssignment, StateQuestionActive},
-		StateTeamAssignment:  {StateQuestionActive},
-		StateQuestionActive:  {StateCountdownActive},
-		StateCountdownActive: {StateAnswerStats},
-		StateAnswerStats:     {StateAnswerReveal},
-		StateAnswerReveal:    {StateQuestionActive, StateResults},
-		StateResults:         {StateCelebration},
-		StateCelebration:     {StateFinished},
-		StateFinished:        {},
-	}
-
-	// チーム戦でない場合はチーム分け状態をスキップ
-	if !esm.teamMode {
-		esm.validTransitions[StateTitleDisplay] = []EventState{StateQuestionActive}
-	}
+// GetFlow returns the flow graph the manager follows
+func (esm *EventStateManager) GetFlow() FlowConfig {
+	return esm.flow
 }
 
 func (esm *EventStateManager) GetCurrentState() EventState {
+	esm.mu.RLock()
+	defer esm.mu.RUnlock()
 	return esm.currentState
 }
 
 func (esm *EventStateManager) GetQuestionNumber() int {
+	esm.mu.RLock()
+	defer esm.mu.RUnlock()
 	return esm.currentQuestion
 }
 
+// GetSnapshot returns the state and question number read together
+func (esm *EventStateManager) GetSnapshot() StateSnapshot {
+	esm.mu.RLock()
+	defer esm.mu.RUnlock()
+	return esm.snapshot()
+}
+
 func (esm *EventStateManager) SetQuestionNumber(questionNumber int) error {
-	if questionNumber < 0 || questionNumber > esm.totalQuestions {
-		return fmt.Errorf("invalid question number: %d (valid range: 0-%d)", questionNumber, esm.totalQuestions)
-	}
-	esm.currentQuestion = questionNumber
-	return nil
+	_, err := esm.SetQuestionNumberContext(context.Background(), questionNumber)
+	return err
 }
 
+// SetQuestionNumberContext changes the question number without changing the state
+func (esm *EventStateManager) SetQuestionNumberContext(ctx context.Context, questionNumber int) (*StateChange, error) {
+	return esm.apply(ctx, func() (*StateChange, error) {
+		if err := esm.validateQuestionNumber(questionNumber); err != nil {
+			return nil, err
+		}
+		return esm.newChange(TransitionSetQuestion, StateSnapshot{State: esm.currentState, QuestionNumber: questionNumber}), nil
+	}, func(change *StateChange) {
+		esm.currentQuestion = change.To.QuestionNumber
+	})
+}
+
+// CanTransitionTo checks if any currently enabled transition leads to the target state
 func (esm *EventStateManager) CanTransitionTo(targetState EventState) bool {
-	validStates, exists := esm.validTransitions[esm.currentState]
-	if !exists {
-		return false
+	esm.mu.RLock()
+	defer esm.mu.RUnlock()
+	return esm.canTransitionTo(targetState)
+}
+
+func (esm *EventStateManager) TransitionTo(targetState EventState) error {
+	_, err := esm.TransitionToContext(context.Background(), targetState)
+	return err
+}
+
+// TransitionToContext moves to the target state if an enabled transition leads there
+func (esm *EventStateManager) TransitionToContext(ctx context.Context, targetState EventState) (*StateChange, error) {
+	return esm.apply(ctx, func() (*StateChange, error) {
+		if !esm.canTransitionTo(targetState) {
+			return nil, fmt.Errorf("invalid transition from %s to %s", esm.currentState, targetState)
+		}
+		return esm.newChange(TransitionDirect, StateSnapshot{State: targetState, QuestionNumber: esm.currentQuestion}), nil
+	}, esm.commitWithHistory)
+}
+
+// JumpToState allows jumping to any state without transition validation (for admin use)
+func (esm *EventStateManager) JumpToState(targetState EventState) error {
+	_, err := esm.JumpToStateContext(context.Background(), targetState)
+	return err
+}
+
+// JumpToStateContext jumps to any state, keeping the question number
+func (esm *EventStateManager) JumpToStateContext(ctx context.Context, targetState EventState) (*StateChange, error) {
+	return esm.apply(ctx, func() (*StateChange, error) {
+		// Validate that the target state exists using the constants
+		if !IsValidState(targetState) {
+			return nil, fmt.Errorf("invalid state: %s", targetState)
+		}
+		return esm.newChange(TransitionJump, StateSnapshot{State: targetState, QuestionNumber: esm.currentQuestion}), nil
+	}, esm.commitWithHistory)
+}
+
+// JumpToQuestionContext jumps to any state and question number in one change
+func (esm *EventStateManager) JumpToQuestionContext(ctx context.Context, targetState EventState, questionNumber int) (*StateChange, error) {
+	return esm.apply(ctx, func() (*StateChange, error) {
+		if !IsValidState(targetState) {
+			return nil, fmt.Errorf("invalid state: %s", targetState)
+		}
+		if err := esm.validateQuestionNumber(questionNumber); err != nil {
+			return nil, err
+		}
+		return esm.newChange(TransitionJump, StateSnapshot{State: targetState, QuestionNumber: questionNumber}), nil
+	}, esm.commitWithHistory)
+}
+
+// PerformAction follows the first enabled transition for the action and returns it
+func (esm *EventStateManager) PerformAction(action string) (*FlowTransition, error) {
+	change, err := esm.PerformActionContext(context.Background(), action)
+	if err != nil {
+		return nil, err
 	}
+	return change.Transition, nil
+}
+
+// PerformActionContext follows the first enabled transition for the action
+func (esm *EventStateManager) PerformActionContext(ctx context.Context, action string) (*StateChange, error) {
+	return esm.apply(ctx, func() (*StateChange, error) {
+		for _, t := range esm.enabledTransitions() {
+			if t.Action != action {
+				continue
+			}
+
+			questionNumber := esm.currentQuestion
+			if action == ActionNextQuestion {
+				if questionNumber >= esm.totalQuestions {
+					return nil, fmt.Errorf("no more questions (%d/%d)", questionNumber, esm.totalQuestions)
+				}
+				questionNumber++
+			}
+
+			change := esm.newChange(TransitionAction, StateSnapshot{State: EventState(t.To), QuestionNumber: questionNumber})
+			change.Action = action
+			change.Transition = &t
+			return change, nil
+		}
 
-	for _, validState := range validStates {
-		if validState == targetState {
-			return true
+		return nil, fmt.Errorf("action %s is not available in state %s", action, esm.currentState)
+	}, func(change *StateChange) {
+		esm.currentState = change.To.State
+		esm.currentQuestion = change.To.QuestionNumber
+		// Self-loops (e.g. show_answer_stats) are not worth going back to
+		if change.To != change.From {
+			esm.pushHistory(change.From)
+		}
+	})
+}
+
+// GetAvailableActions returns the actions enabled in the current state
+func (esm *EventStateManager) GetAvailableActions() []string {
+	details := esm.GetAvailableActionDetails()
+	actions := make([]string, 0, len(details))
+	for _, detail := range details {
+		actions = append(actions, detail.Action)
+	}
+	return actions
+}
+
+// GetAvailableActionDetails returns the enabled actions with their labels and target states
+func (esm *EventStateManager) GetAvailableActionDetails() []AvailableAction {
+	esm.mu.RLock()
+	defer esm.mu.RUnlock()
+
+	actions := []AvailableAction{}
+	seen := make(map[string]bool)
+
+	for _, t := range esm.enabledTransitions() {
+		if seen[t.Action] {
+			continue
 		}
+		seen[t.Action] = true
+
+		label := t.Label
+		if label == "" {
+			label = GetActionLabel(t.Action)
+		}
+		actions = append(actions, AvailableAction{
+			Action: t.Action,
+			Label:  label,
+			To:     EventState(t.To),
+		})
 	}
 
-	return false
+	// Going back is always possible while there is history
+	if previous, ok := esm.previousSnapshot(); ok {
+		actions = append(actions, AvailableAction{
+			Action: ActionGoBack,
+			Label:  GetActionLabel(ActionGoBack),
+			To:     previous.State,
+		})
+	}
+
+	return actions
 }
 
-func (esm *EventStateManager) TransitionTo(targetState EventState) error {
-	if !esm.CanTransitionTo(targetState) {
-		return fmt.Errorf("invalid transition from %s to %s", esm.currentState, targetState)
+// GoBack restores the state and question number before the last change
+func (esm *EventStateManager) GoBack() (StateSnapshot, error) {
+	change, err := esm.GoBackContext(context.Background())
+	if err != nil {
+		return StateSnapshot{}, err
 	}
+	return change.To, nil
+}
 
-	esm.currentState = targetState
-	return nil
+// GoBackContext restores the state and question number before the last change
+func (esm *EventStateManager) GoBackContext(ctx context.Context) (*StateChange, error) {
+	return esm.apply(ctx, func() (*StateChange, error) {
+		previous, ok := esm.previousSnapshot()
+		if !ok {
+			return nil, errors.New("no previous state to go back to")
+		}
+		return esm.newChange(TransitionGoBack, previous), nil
+	}, func(change *StateChange) {
+		esm.history = esm.history[:len(esm.history)-1]
+		esm.currentState = change.To.State
+		esm.currentQuestion = change.To.QuestionNumber
+	})
 }
 
-// JumpToState allows jumping to any state without transition validation (for admin use)
-func (esm *EventStateManager) JumpToState(targetState EventState) error {
-	// Validate that the target state exists using the constants
-	if !IsValidState(targetState) {
-		return fmt.Errorf("invalid state: %s", targetState)
+// GetHistory returns a copy of the states GoBack can return to, oldest first
+func (esm *EventStateManager) GetHistory() []StateSnapshot {
+	esm.mu.RLock()
+	defer esm.mu.RUnlock()
+
+	history := make([]StateSnapshot, len(esm.history))
+	copy(history, esm.history)
+	return history
+}
+
+// Restore puts the manager back to a persisted position. Hooks are not run:
+// the position was already seen by them before it was saved.
+func (esm *EventStateManager) Restore(state EventState, questionNumber int, history []StateSnapshot) error {
+	if !IsValidState(state) {
+		return fmt.Errorf("invalid state: %s", state)
+	}
+	if err := esm.validateQuestionNumber(questionNumber); err != nil {
+		return err
 	}
 
-	esm.currentState = targetState
+	esm.transitionMu.Lock()
+	defer esm.transitionMu.Unlock()
+	esm.mu.Lock()
+	defer esm.mu.Unlock()
+
+	esm.currentState = state
+	esm.currentQuestion = questionNumber
+	esm.history = nil
+	for _, snapshot := range history {
+		if IsValidState(snapshot.State) && snapshot.QuestionNumber >= 0 && snapshot.QuestionNumber <= esm.totalQuestions {
+			esm.pushHistory(snapshot)
+		}
+	}
 	return nil
 }
 
-func (esm *EventStateManager) NextQuestion() error {
-	if esm.currentState != StateAnswerReveal && esm.currentState != StateTeamAssignment {
-		return fmt.Errorf("cannot advance question from state %s", esm.currentState)
+// PreviousSnapshot returns the state GoBack would return to
+func (esm *EventStateManager) PreviousSnapshot() (StateSnapshot, bool) {
+	esm.mu.RLock()
+	defer esm.mu.RUnlock()
+	return esm.previousSnapshot()
+}
+
+// The helpers below expect the caller to hold mu
+
+func (esm *EventStateManager) previousSnapshot() (StateSnapshot, bool) {
+	if len(esm.history) == 0 {
+		return StateSnapshot{}, false
+	}
+	return esm.history[len(esm.history)-1], true
+}
+
+func (esm *EventStateManager) snapshot() StateSnapshot {
+	return StateSnapshot{
+		State:          esm.currentState,
+		QuestionNumber: esm.currentQuestion,
 	}
+}
 
-	if esm.currentQuestion >= esm.totalQuestions {
-		// 最後の問題なので結果発表へ
-		return esm.TransitionTo(StateResults)
+func (esm *EventStateManager) newChange(kind TransitionKind, to StateSnapshot) *StateChange {
+	return &StateChange{
+		Kind: kind,
+		From: esm.snapshot(),
+		To:   to,
 	}
+}
 
-	esm.currentQuestion++
-	return esm.TransitionTo(StateQuestionActive)
+// commitWithHistory moves to the change's target, remembering where it came from
+func (esm *EventStateManager) commitWithHistory(change *StateChange) {
+	esm.pushHistory(change.From)
+	esm.currentState = change.To.State
+	esm.currentQuestion = change.To.QuestionNumber
 }
 
-func (esm *EventStateManager) GetAvailableActions() []string {
-	switch esm.currentState {
-	case StateStarted:
-		return []string{"show_title"}
-	case StateTitleDisplay:
+func (esm *EventStateManager) pushHistory(snapshot StateSnapshot) {
+	esm.history = append(esm.history, snapshot)
+	if len(esm.history) > maxStateHistory {
+		esm.history = esm.history[len(esm.history)-maxStateHistory:]
+	}
+}
+
+func (esm *EventStateManager) validateQuestionNumber(questionNumber int) error {
+	if questionNumber < 0 || questionNumber > esm.totalQuestions {
+		return fmt.Errorf("invalid question number: %d (valid range: 0-%d)", questionNumber, esm.totalQuestions)
+	}
+	return nil
+}
+
+func (esm *EventStateManager) canTransitionTo(targetState EventState) bool {
+	for _, t := range esm.enabledTransitions() {
+		if EventState(t.To) == targetState {
+			return true
+		}
+	}
+	return false
+}
+
+// enabledTransitions returns the transitions from the current state whose conditions hold
+func (esm *EventStateManager) enabledTransitions() []FlowTransition {
+	transitions := []FlowTransition{}
+	for _, t := range esm.flow.Transitions {
+		if EventState(t.From) != esm.currentState {
+			continue
+		}
+		if !esm.conditionHolds(t) {
+			continue
+		}
+		transitions = append(transitions, t)
+	}
+	return transitions
+}
+
+func (esm *EventStateManager) conditionHolds(t FlowTransition) bool {
+	switch t.When {
+	case FlowWhenTeamMode:
+		if !esm.teamMode {
+			return false
+		}
+	case FlowWhenIndividualMode:
 		if esm.teamMode {
-			return []string{"assign_teams"}
+			return false
 		}
-		return []string{"next_question"}
-	case StateTeamAssignment:
-		return []string{"next_question"}
-	case StateQuestionActive:
-		return []string{"countdown_alert"}
-	case StateCountdownActive:
-		return []string{"show_answer_stats"}
-	case StateAnswerStats:
-		return []string{"reveal_answer"}
-	case StateAnswerReveal:
+	case FlowWhenHasNextQuestion:
 		if esm.currentQuestion >= esm.totalQuestions {
-			return []string{"show_results"}
+			return false
+		}
+	case FlowWhenLastQuestion:
+		if esm.currentQuestion < esm.totalQuestions {
+			return false
 		}
-		return []string{"next_question"}
-	case StateResults:
-		return []string{"celebration"}
-	case StateCelebration:
-		return []string{} // 自動遷移
-	case StateFinished:
-		return []string{}
-	default:
-		return []string{}
 	}
+
+	if t.Every > 0 && (esm.currentQuestion == 0 || esm.currentQuestion%t.Every != 0) {
+		return false
+	}
+
+	return true
 }
@@ -1,7 +1,13 @@
 package models
 
 import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
 )
 
 func TestTeamSeparationLogic(t *testing.T) {
@@ -105,3 +111,60 @@ func containsString(text, pattern string) bool {
 		(text[:len(pattern)] == pattern ||
 			text[len(text)-len(pattern):] == pattern)
 }
+
+func TestSaveAnswerScoresOnce(t *testing.T) {
+	// A database file, so that concurrent submissions use separate connections
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "quiz.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	schema, err := os.ReadFile("../database/init.sql")
+	if err != nil {
+		t.Fatalf("Failed to read schema: %v", err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatalf("Failed to execute schema: %v", err)
+	}
+
+	userRepo := NewUserRepository(db, 1)
+	answerRepo := NewAnswerRepository(db, 1)
+	user, err := userRepo.CreateUser("session", "Alice")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	// The same answer arrives over the WebSocket and the HTTP retry at once
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := answerRepo.SaveAnswer(user.ID, 1, 2, true, 10); err != nil {
+				t.Errorf("Failed to save answer: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM answers WHERE user_id = ?`, user.ID).Scan(&count); err != nil {
+		t.Fatalf("Failed to count answers: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 answer, got %d", count)
+	}
+	if saved, _ := userRepo.GetUserByID(user.ID); saved == nil || saved.Score != 10 {
+		t.Errorf("Expected score 10, got %+v", saved)
+	}
+
+	// Changing to a wrong answer takes the points back
+	saved, err := answerRepo.SaveAnswer(user.ID, 1, 3, false, 10)
+	if err != nil {
+		t.Fatalf("Failed to change answer: %v", err)
+	}
+	if saved.ScoreChange != -10 || saved.NewScore != 0 {
+		t.Errorf("Expected score change -10 to 0, got %+v", saved)
+	}
+}
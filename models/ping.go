@@ -0,0 +1,62 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Ping defaults used when the config leaves them empty
+const (
+	DefaultPingInterval    = 10 * time.Second
+	DefaultPingTimeout     = 8 * time.Second
+	DefaultPingSlowLatency = 500 * time.Millisecond
+)
+
+// PingConfig controls how the connections of participants are measured
+type PingConfig struct {
+	Interval    string `toml:"interval"`     // Between two pings to every participant (default 10s)
+	Timeout     string `toml:"timeout"`      // A later pong counts as lost; at most the interval (default 8s)
+	SlowLatency string `toml:"slow_latency"` // p95 latency above this flags a connection as slow (default 500ms)
+}
+
+// Validate checks that the durations parse and that a ping times out before the next one
+func (p *PingConfig) Validate() error {
+	for name, value := range map[string]string{"interval": p.Interval, "timeout": p.Timeout, "slow_latency": p.SlowLatency} {
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s: invalid duration: %v", name, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("%s: duration must be positive", name)
+		}
+	}
+	if p.Deadline() > p.Period() {
+		return fmt.Errorf("timeout %v must not be longer than interval %v", p.Deadline(), p.Period())
+	}
+	return nil
+}
+
+// Period returns the time between two ping rounds
+func (p *PingConfig) Period() time.Duration {
+	return parsePositiveDuration(p.Interval, DefaultPingInterval)
+}
+
+// Deadline returns how long a ping waits for its pong
+func (p *PingConfig) Deadline() time.Duration {
+	return parsePositiveDuration(p.Timeout, DefaultPingTimeout)
+}
+
+// SlowThreshold returns the p95 latency above which a connection is slow
+func (p *PingConfig) SlowThreshold() time.Duration {
+	return parsePositiveDuration(p.SlowLatency, DefaultPingSlowLatency)
+}
+
+func parsePositiveDuration(value string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d
+	}
+	return fallback
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPingConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  PingConfig
+		wantErr bool
+	}{
+		{"defaults", PingConfig{}, false},
+		{"custom", PingConfig{Interval: "5s", Timeout: "3s", SlowLatency: "800ms"}, false},
+		{"invalid duration", PingConfig{Interval: "often"}, true},
+		{"negative", PingConfig{SlowLatency: "-1s"}, true},
+		{"timeout longer than interval", PingConfig{Interval: "5s"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	defaults := PingConfig{}
+	if defaults.Period() != DefaultPingInterval || defaults.Deadline() != DefaultPingTimeout || defaults.SlowThreshold() != DefaultPingSlowLatency {
+		t.Errorf("Expected the defaults, got %v %v %v", defaults.Period(), defaults.Deadline(), defaults.SlowThreshold())
+	}
+	custom := PingConfig{Interval: "5s", Timeout: "3s"}
+	if custom.Period() != 5*time.Second || custom.Deadline() != 3*time.Second {
+		t.Errorf("Expected 5s and 3s, got %v %v", custom.Period(), custom.Deadline())
+	}
+}
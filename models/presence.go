@@ -0,0 +1,150 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PresenceStatus tells whether a participant is following the event
+type PresenceStatus string
+
+const (
+	PresenceOnline  PresenceStatus = "online"  // Connected, or reconnecting within the grace period
+	PresenceAway    PresenceStatus = "away"    // Disconnected for longer than the grace period
+	PresenceOffline PresenceStatus = "offline" // Away for longer than offline_after, or never connected
+)
+
+// Presence defaults used when the config leaves them empty
+const (
+	DefaultPresenceGracePeriod  = 15 * time.Second
+	DefaultPresenceOfflineAfter = 5 * time.Minute
+)
+
+// PresenceConfig controls how long a participant may be disconnected before
+// counting as away (no longer in team assignment and answer rates) and offline
+type PresenceConfig struct {
+	GracePeriod  string `toml:"grace_period"`  // Reconnects within this time go unnoticed (default 15s)
+	OfflineAfter string `toml:"offline_after"` // Away for this long counts as offline (default 5m)
+}
+
+// Validate checks that both durations parse and are positive
+func (p *PresenceConfig) Validate() error {
+	for name, value := range map[string]string{"grace_period": p.GracePeriod, "offline_after": p.OfflineAfter} {
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s: invalid duration: %v", name, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("%s: duration must be positive", name)
+		}
+	}
+	return nil
+}
+
+// Grace returns how long a disconnected participant still counts as online
+func (p *PresenceConfig) Grace() time.Duration {
+	if d, err := time.ParseDuration(p.GracePeriod); err == nil && d > 0 {
+		return d
+	}
+	return DefaultPresenceGracePeriod
+}
+
+// OfflineDelay returns how long an away participant takes to count as offline
+func (p *PresenceConfig) OfflineDelay() time.Duration {
+	if d, err := time.ParseDuration(p.OfflineAfter); err == nil && d > 0 {
+		return d
+	}
+	return DefaultPresenceOfflineAfter
+}
+
+// ConnectionRecord is one connection of a participant
+type ConnectionRecord struct {
+	ID             int64      `json:"id"`
+	UserID         int        `json:"user_id"`
+	Transport      string     `json:"transport"`
+	ConnectedAt    time.Time  `json:"connected_at"`
+	DisconnectedAt *time.Time `json:"disconnected_at"` // nil while connected
+}
+
+// ConnectionRepository keeps the connection history of the event's participants
+type ConnectionRepository struct {
+	db      *sql.DB
+	eventID int
+}
+
+func NewConnectionRepository(db *sql.DB, eventID int) *ConnectionRepository {
+	return &ConnectionRepository{db: db, eventID: eventID}
+}
+
+// OpenConnection records a new connection and returns its ID
+func (r *ConnectionRepository) OpenConnection(userID int, transport string, at time.Time) (int64, error) {
+	query := `INSERT INTO user_connections (event_id, user_id, transport, connected_at) VALUES (?, ?, ?, ?)`
+	result, err := r.db.Exec(query, r.eventID, userID, transport, at.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// CloseConnection records the end of a connection
+func (r *ConnectionRepository) CloseConnection(id int64, at time.Time) error {
+	query := `UPDATE user_connections SET disconnected_at = ? WHERE id = ? AND event_id = ?`
+	_, err := r.db.Exec(query, at.UTC(), id, r.eventID)
+	return err
+}
+
+// CloseOpenConnections ends connections left open by a crash or restart
+func (r *ConnectionRepository) CloseOpenConnections(at time.Time) error {
+	query := `UPDATE user_connections SET disconnected_at = ? WHERE disconnected_at IS NULL AND event_id = ?`
+	_, err := r.db.Exec(query, at.UTC(), r.eventID)
+	return err
+}
+
+// GetUserConnections returns the latest connections of a user, newest first
+func (r *ConnectionRepository) GetUserConnections(userID int, limit int) ([]ConnectionRecord, error) {
+	query := `SELECT id, user_id, transport, connected_at, disconnected_at FROM user_connections
+		WHERE user_id = ? AND event_id = ? ORDER BY connected_at DESC, id DESC LIMIT ?`
+	rows, err := r.db.Query(query, userID, r.eventID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []ConnectionRecord{}
+	for rows.Next() {
+		var record ConnectionRecord
+		var disconnectedAt sql.NullTime
+		if err := rows.Scan(&record.ID, &record.UserID, &record.Transport, &record.ConnectedAt, &disconnectedAt); err != nil {
+			return nil, err
+		}
+		if disconnectedAt.Valid {
+			record.DisconnectedAt = &disconnectedAt.Time
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// CountUserConnections returns how many times each user has connected
+func (r *ConnectionRepository) CountUserConnections() (map[int]int, error) {
+	query := `SELECT user_id, COUNT(*) FROM user_connections WHERE event_id = ? GROUP BY user_id`
+	rows, err := r.db.Query(query, r.eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var userID, count int
+		if err := rows.Scan(&userID, &count); err != nil {
+			return nil, err
+		}
+		counts[userID] = count
+	}
+	return counts, rows.Err()
+}
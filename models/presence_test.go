@@ -0,0 +1,77 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPresenceConfig(t *testing.T) {
+	var empty PresenceConfig
+	if empty.Grace() != DefaultPresenceGracePeriod || empty.OfflineDelay() != DefaultPresenceOfflineAfter {
+		t.Error("Expected defaults for an empty config")
+	}
+	if err := empty.Validate(); err != nil {
+		t.Errorf("Expected an empty config to be valid, got %v", err)
+	}
+
+	config := PresenceConfig{GracePeriod: "30s", OfflineAfter: "10m"}
+	if config.Grace() != 30*time.Second || config.OfflineDelay() != 10*time.Minute {
+		t.Errorf("Expected 30s and 10m, got %v and %v", config.Grace(), config.OfflineDelay())
+	}
+
+	for _, invalid := range []PresenceConfig{{GracePeriod: "soon"}, {OfflineAfter: "-1m"}, {GracePeriod: "0s"}} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", invalid)
+		}
+	}
+}
+
+func TestConnectionHistory(t *testing.T) {
+	db := setupProgressDB(t)
+	event, err := NewEventRepository(db).CreateEvent("main", "Test Quiz", false, 1, "")
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	user, err := NewUserRepository(db, event.ID).CreateUser("session-1", "alice")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	repo := NewConnectionRepository(db, event.ID)
+
+	start := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	first, err := repo.OpenConnection(user.ID, "websocket", start)
+	if err != nil {
+		t.Fatalf("Failed to open connection: %v", err)
+	}
+	if err := repo.CloseConnection(first, start.Add(time.Minute)); err != nil {
+		t.Fatalf("Failed to close connection: %v", err)
+	}
+	if _, err := repo.OpenConnection(user.ID, "sse", start.Add(2*time.Minute)); err != nil {
+		t.Fatalf("Failed to open connection: %v", err)
+	}
+
+	history, err := repo.GetUserConnections(user.ID, 10)
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if len(history) != 2 || history[0].Transport != "sse" || history[0].DisconnectedAt != nil {
+		t.Fatalf("Expected the open sse connection first, got %+v", history)
+	}
+	if history[1].DisconnectedAt == nil || !history[1].DisconnectedAt.Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected the first connection to end after a minute, got %+v", history[1])
+	}
+
+	// A restart closes whatever was left open
+	if err := repo.CloseOpenConnections(start.Add(3 * time.Minute)); err != nil {
+		t.Fatalf("Failed to close open connections: %v", err)
+	}
+	history, _ = repo.GetUserConnections(user.ID, 1)
+	if len(history) != 1 || history[0].DisconnectedAt == nil {
+		t.Errorf("Expected the latest connection to be closed, got %+v", history)
+	}
+
+	counts, err := repo.CountUserConnections()
+	if err != nil || counts[user.ID] != 2 {
+		t.Errorf("Expected 2 connections, got %v (%v)", counts, err)
+	}
+}
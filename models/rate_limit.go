@@ -0,0 +1,279 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Endpoints with rate limits; HTTP and WebSocket requests share the buckets
+const (
+	RateLimitJoin   = "join"
+	RateLimitAnswer = "answer"
+	RateLimitEmoji  = "emoji"
+)
+
+// rateLimitSweepInterval is how often idle buckets are dropped
+const rateLimitSweepInterval = time.Minute
+
+// RateLimit is a token bucket: up to Burst requests at once, refilled with one
+// request per Every
+type RateLimit struct {
+	Burst int    `toml:"burst"`
+	Every string `toml:"every"`
+}
+
+// EndpointRateLimits limits one endpoint per participant session and per client IP
+type EndpointRateLimits struct {
+	PerSession RateLimit `toml:"per_session"`
+	PerIP      RateLimit `toml:"per_ip"`
+}
+
+// RateLimitsConfig limits the participant endpoints; empty values use the
+// defaults. The limiter is shared by all rooms (main config only).
+type RateLimitsConfig struct {
+	Join   EndpointRateLimits `toml:"join"`
+	Answer EndpointRateLimits `toml:"answer"`
+	Emoji  EndpointRateLimits `toml:"emoji"`
+}
+
+// defaultRateLimits leave room for a whole venue behind one NAT address while
+// stopping scripts and emoji spam from a single phone
+var defaultRateLimits = map[string]EndpointRateLimits{
+	RateLimitJoin: {
+		PerSession: RateLimit{Burst: 5, Every: "10s"},
+		PerIP:      RateLimit{Burst: 60, Every: "500ms"},
+	},
+	RateLimitAnswer: {
+		PerSession: RateLimit{Burst: 5, Every: "1s"},
+		PerIP:      RateLimit{Burst: 200, Every: "10ms"},
+	},
+	RateLimitEmoji: {
+		PerSession: RateLimit{Burst: 5, Every: "1s"},
+		PerIP:      RateLimit{Burst: 100, Every: "50ms"},
+	},
+}
+
+// Validate checks the bursts and durations of every limit
+func (r *RateLimitsConfig) Validate() error {
+	for endpoint, limits := range r.endpoints() {
+		for scope, limit := range map[string]RateLimit{"per_session": limits.PerSession, "per_ip": limits.PerIP} {
+			if err := limit.validate(); err != nil {
+				return fmt.Errorf("%s.%s: %v", endpoint, scope, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *RateLimitsConfig) endpoints() map[string]EndpointRateLimits {
+	return map[string]EndpointRateLimits{
+		RateLimitJoin:   r.Join,
+		RateLimitAnswer: r.Answer,
+		RateLimitEmoji:  r.Emoji,
+	}
+}
+
+func (l RateLimit) validate() error {
+	if l.Burst < 0 {
+		return fmt.Errorf("burst must not be negative")
+	}
+	if l.Every != "" {
+		d, err := time.ParseDuration(l.Every)
+		if err != nil {
+			return fmt.Errorf("every: invalid duration: %v", err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("every: duration must be positive")
+		}
+	}
+	return nil
+}
+
+// bucketLimit is a RateLimit with the defaults applied
+type bucketLimit struct {
+	burst float64
+	every time.Duration
+}
+
+func (l RateLimit) resolve(fallback RateLimit) bucketLimit {
+	burst := l.Burst
+	if burst == 0 {
+		burst = fallback.Burst
+	}
+	defaultEvery, _ := time.ParseDuration(fallback.Every)
+	return bucketLimit{burst: float64(burst), every: parsePositiveDuration(l.Every, defaultEvery)}
+}
+
+type endpointLimits struct {
+	session bucketLimit
+	ip      bucketLimit
+}
+
+type bucketKey struct {
+	endpoint string
+	scope    string // "session" or "ip"
+	key      string
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// refill adds the tokens earned since the last update
+func (b *tokenBucket) refill(limit bucketLimit, now time.Time) {
+	earned := float64(now.Sub(b.updated)) / float64(limit.every)
+	b.tokens = math.Min(limit.burst, b.tokens+earned)
+	b.updated = now
+}
+
+// wait returns how long until the bucket holds a whole token
+func (b *tokenBucket) wait(limit bucketLimit) time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) * float64(limit.every))
+}
+
+// RateLimitCounters count the requests of an endpoint since the server started
+type RateLimitCounters struct {
+	Allowed          int64 `json:"allowed"`
+	LimitedBySession int64 `json:"limited_by_session"`
+	LimitedByIP      int64 `json:"limited_by_ip"`
+}
+
+// RateLimitStats is the limiter state shown in the debug API
+type RateLimitStats struct {
+	Endpoints map[string]RateLimitCounters `json:"endpoints"`
+	Buckets   int                          `json:"buckets"` // Sessions and IPs currently tracked
+}
+
+// RateLimiter keeps in-memory token buckets per endpoint, session and IP
+type RateLimiter struct {
+	mu        sync.Mutex
+	limits    map[string]endpointLimits
+	buckets   map[bucketKey]*tokenBucket
+	counters  map[string]*RateLimitCounters
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewRateLimiter creates a limiter from the config
+func NewRateLimiter(config RateLimitsConfig) *RateLimiter {
+	rl := &RateLimiter{
+		limits:   make(map[string]endpointLimits),
+		buckets:  make(map[bucketKey]*tokenBucket),
+		counters: make(map[string]*RateLimitCounters),
+		now:      time.Now,
+	}
+	for endpoint, limits := range config.endpoints() {
+		defaults := defaultRateLimits[endpoint]
+		rl.limits[endpoint] = endpointLimits{
+			session: limits.PerSession.resolve(defaults.PerSession),
+			ip:      limits.PerIP.resolve(defaults.PerIP),
+		}
+		rl.counters[endpoint] = &RateLimitCounters{}
+	}
+	rl.lastSweep = rl.now()
+	return rl
+}
+
+// Allow takes one request from the buckets of the session and the IP; an
+// empty key skips its bucket. A refused request takes nothing, and the
+// returned duration is how long until it would be allowed.
+func (rl *RateLimiter) Allow(endpoint, sessionID, ip string) (bool, time.Duration) {
+	limits, ok := rl.limits[endpoint]
+	if !ok {
+		return true, 0
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	rl.sweep(now)
+	counters := rl.counters[endpoint]
+
+	sessionBucket := rl.bucket(bucketKey{endpoint, "session", sessionID}, limits.session, now)
+	ipBucket := rl.bucket(bucketKey{endpoint, "ip", ip}, limits.ip, now)
+
+	var retryAfter time.Duration
+	if sessionBucket != nil {
+		retryAfter = sessionBucket.wait(limits.session)
+	}
+	if retryAfter > 0 {
+		counters.LimitedBySession++
+	}
+	if ipBucket != nil {
+		if wait := ipBucket.wait(limits.ip); wait > 0 {
+			if retryAfter == 0 {
+				counters.LimitedByIP++
+			}
+			retryAfter = max(retryAfter, wait)
+		}
+	}
+	if retryAfter > 0 {
+		return false, retryAfter
+	}
+
+	if sessionBucket != nil {
+		sessionBucket.tokens--
+	}
+	if ipBucket != nil {
+		ipBucket.tokens--
+	}
+	counters.Allowed++
+	return true, 0
+}
+
+// bucket returns the refilled bucket of the key, or nil for an empty key
+func (rl *RateLimiter) bucket(key bucketKey, limit bucketLimit, now time.Time) *tokenBucket {
+	if key.key == "" {
+		return nil
+	}
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: limit.burst, updated: now}
+		rl.buckets[key] = b
+	}
+	b.refill(limit, now)
+	return b
+}
+
+// sweep drops buckets that have refilled completely, since a new bucket
+// would start out the same
+func (rl *RateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	rl.lastSweep = now
+	for key, b := range rl.buckets {
+		limit := rl.limits[key.endpoint].ip
+		if key.scope == "session" {
+			limit = rl.limits[key.endpoint].session
+		}
+		b.refill(limit, now)
+		if b.tokens >= limit.burst {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+// Stats returns the counters of every endpoint
+func (rl *RateLimiter) Stats() RateLimitStats {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	stats := RateLimitStats{Endpoints: make(map[string]RateLimitCounters), Buckets: len(rl.buckets)}
+	for endpoint, counters := range rl.counters {
+		stats.Endpoints[endpoint] = *counters
+	}
+	return stats
+}
+
+// RetryAfterSeconds rounds a wait up to whole seconds for Retry-After, at least one
+func RetryAfterSeconds(wait time.Duration) int {
+	return max(1, int(math.Ceil(wait.Seconds())))
+}
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimitsConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  RateLimitsConfig
+		wantErr bool
+	}{
+		{"defaults", RateLimitsConfig{}, false},
+		{"custom", RateLimitsConfig{Emoji: EndpointRateLimits{PerSession: RateLimit{Burst: 3, Every: "2s"}}}, false},
+		{"negative burst", RateLimitsConfig{Join: EndpointRateLimits{PerIP: RateLimit{Burst: -1}}}, true},
+		{"invalid duration", RateLimitsConfig{Answer: EndpointRateLimits{PerSession: RateLimit{Every: "often"}}}, true},
+		{"zero duration", RateLimitsConfig{Answer: EndpointRateLimits{PerIP: RateLimit{Every: "0s"}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// newTestRateLimiter returns a limiter on a clock the test moves
+func newTestRateLimiter(config RateLimitsConfig) (*RateLimiter, *time.Time) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	rl := NewRateLimiter(config)
+	rl.now = func() time.Time { return now }
+	rl.lastSweep = now
+	return rl, &now
+}
+
+func TestRateLimiterPerSession(t *testing.T) {
+	rl, now := newTestRateLimiter(RateLimitsConfig{
+		Emoji: EndpointRateLimits{PerSession: RateLimit{Burst: 3, Every: "1s"}},
+	})
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := rl.Allow(RateLimitEmoji, "s1", "10.0.0.1"); !ok {
+			t.Fatalf("Request %d within the burst was refused", i+1)
+		}
+	}
+	ok, retryAfter := rl.Allow(RateLimitEmoji, "s1", "10.0.0.1")
+	if ok {
+		t.Fatal("Request over the burst was allowed")
+	}
+	if retryAfter != time.Second {
+		t.Errorf("Expected retry after 1s, got %v", retryAfter)
+	}
+
+	// Another session has its own bucket
+	if ok, _ := rl.Allow(RateLimitEmoji, "s2", "10.0.0.1"); !ok {
+		t.Error("Other session was refused")
+	}
+
+	// One request is refilled per interval
+	*now = now.Add(time.Second)
+	if ok, _ := rl.Allow(RateLimitEmoji, "s1", "10.0.0.1"); !ok {
+		t.Error("Refilled request was refused")
+	}
+	if ok, _ := rl.Allow(RateLimitEmoji, "s1", "10.0.0.1"); ok {
+		t.Error("Only one request should have been refilled")
+	}
+
+	stats := rl.Stats()
+	if got := stats.Endpoints[RateLimitEmoji]; got.Allowed != 5 || got.LimitedBySession != 2 || got.LimitedByIP != 0 {
+		t.Errorf("Unexpected counters %+v", got)
+	}
+}
+
+func TestRateLimiterPerIP(t *testing.T) {
+	rl, _ := newTestRateLimiter(RateLimitsConfig{
+		Join: EndpointRateLimits{PerIP: RateLimit{Burst: 2, Every: "1m"}},
+	})
+
+	// Joining without a session only has the IP bucket
+	for i := 0; i < 2; i++ {
+		if ok, _ := rl.Allow(RateLimitJoin, "", "10.0.0.1"); !ok {
+			t.Fatalf("Join %d within the burst was refused", i+1)
+		}
+	}
+	if ok, retryAfter := rl.Allow(RateLimitJoin, "", "10.0.0.1"); ok || retryAfter != time.Minute {
+		t.Errorf("Expected refusal for 1m, got %v %v", ok, retryAfter)
+	}
+	if ok, _ := rl.Allow(RateLimitJoin, "", "10.0.0.2"); !ok {
+		t.Error("Other IP was refused")
+	}
+
+	if got := rl.Stats().Endpoints[RateLimitJoin]; got.Allowed != 3 || got.LimitedByIP != 1 {
+		t.Errorf("Unexpected counters %+v", got)
+	}
+}
+
+func TestRateLimiterRefusalTakesNothing(t *testing.T) {
+	rl, now := newTestRateLimiter(RateLimitsConfig{
+		Answer: EndpointRateLimits{
+			PerSession: RateLimit{Burst: 1, Every: "1s"},
+			PerIP:      RateLimit{Burst: 2, Every: "1s"},
+		},
+	})
+
+	rl.Allow(RateLimitAnswer, "s1", "10.0.0.1")
+	// Refused by the session bucket, so the IP bucket keeps its token
+	if ok, _ := rl.Allow(RateLimitAnswer, "s1", "10.0.0.1"); ok {
+		t.Fatal("Session over its burst was allowed")
+	}
+	if ok, _ := rl.Allow(RateLimitAnswer, "s2", "10.0.0.1"); !ok {
+		t.Error("IP bucket lost a token to a refused request")
+	}
+	// The IP is now empty; s3 still has its whole burst afterwards
+	if ok, _ := rl.Allow(RateLimitAnswer, "s3", "10.0.0.1"); ok {
+		t.Fatal("IP over its burst was allowed")
+	}
+	*now = now.Add(time.Second)
+	if ok, _ := rl.Allow(RateLimitAnswer, "s3", "10.0.0.1"); !ok {
+		t.Error("Session refused by the IP bucket lost a token")
+	}
+}
+
+func TestRateLimiterSweepsIdleBuckets(t *testing.T) {
+	rl, now := newTestRateLimiter(RateLimitsConfig{})
+
+	rl.Allow(RateLimitEmoji, "s1", "10.0.0.1")
+	if got := rl.Stats().Buckets; got != 2 {
+		t.Fatalf("Expected 2 buckets, got %d", got)
+	}
+
+	*now = now.Add(2 * rateLimitSweepInterval)
+	rl.Allow(RateLimitEmoji, "", "10.0.0.2")
+	if got := rl.Stats().Buckets; got != 1 {
+		t.Errorf("Expected the refilled buckets to be dropped, got %d buckets", got)
+	}
+}
+
+func TestRateLimiterUnknownEndpoint(t *testing.T) {
+	rl := NewRateLimiter(RateLimitsConfig{})
+	if ok, _ := rl.Allow("unknown", "s1", "10.0.0.1"); !ok {
+		t.Error("Endpoints without limits should be allowed")
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	for wait, want := range map[time.Duration]int{0: 1, 10 * time.Millisecond: 1, time.Second: 1, 1500 * time.Millisecond: 2, time.Minute: 60} {
+		if got := RetryAfterSeconds(wait); got != want {
+			t.Errorf("RetryAfterSeconds(%v) = %d, want %d", wait, got, want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// PlayerRatings maps a normalized nickname to a strength rating (0-100)
+type PlayerRatings map[string]float64
+
+// PlayerResult is one participant's result in a finished event
+type PlayerResult struct {
+	ID            int    `json:"id" db:"id"`
+	Nickname      string `json:"nickname" db:"nickname"`
+	EventTitle    string `json:"event_title" db:"event_title"`
+	CorrectCount  int    `json:"correct_count" db:"correct_count"`
+	QuestionCount int    `json:"question_count" db:"question_count"`
+}
+
+type ratingsFile struct {
+	Players []struct {
+		Nickname string  `toml:"nickname"`
+		Rating   float64 `toml:"rating"`
+	} `toml:"players"`
+}
+
+// RatingRepository records the results of one event; ratings are read across all events
+type RatingRepository struct {
+	db      *sql.DB
+	eventID int
+}
+
+func NewRatingRepository(db *sql.DB, eventID int) *RatingRepository {
+	return &RatingRepository{db: db, eventID: eventID}
+}
+
+// NormalizeNickname returns the key used to match nicknames across events
+func NormalizeNickname(nickname string) string {
+	return strings.ToLower(strings.TrimSpace(nickname))
+}
+
+// Get returns the rating for a nickname
+func (pr PlayerRatings) Get(nickname string) (float64, bool) {
+	rating, ok := pr[NormalizeNickname(nickname)]
+	return rating, ok
+}
+
+// Average returns the mean of all known ratings, or 50 when no rating is known
+func (pr PlayerRatings) Average() float64 {
+	if len(pr) == 0 {
+		return 50
+	}
+
+	total := 0.0
+	for _, rating := range pr {
+		total += rating
+	}
+	return total / float64(len(pr))
+}
+
+// LoadPlayerRatings loads ratings from an imported history file
+func LoadPlayerRatings(path string) (PlayerRatings, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, fmt.Errorf("ratings file not found: %s", path)
+	}
+
+	var file ratingsFile
+	if _, err := toml.DecodeFile(path, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode ratings file: %v", err)
+	}
+
+	if len(file.Players) == 0 {
+		return nil, errors.New("players is empty in ratings file")
+	}
+
+	ratings := make(PlayerRatings, len(file.Players))
+	for i, player := range file.Players {
+		if strings.TrimSpace(player.Nickname) == "" {
+			return nil, fmt.Errorf("player %d: nickname is required", i+1)
+		}
+		if player.Rating < 0 || player.Rating > 100 {
+			return nil, fmt.Errorf("player %d: rating must be between 0 and 100", i+1)
+		}
+		ratings[NormalizeNickname(player.Nickname)] = player.Rating
+	}
+
+	return ratings, nil
+}
+
+// RecordResults stores the correct answer counts of all users for the finished
+// event. Recording the same event again replaces its results, so each event
+// counts once in the ratings.
+func (r *RatingRepository) RecordResults(eventTitle string, questionCount int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM player_results WHERE event_id = ?", r.eventID); err != nil {
+		return fmt.Errorf("failed to delete previous results: %v", err)
+	}
+
+	query := `
+		INSERT INTO player_results (event_id, nickname, event_title, correct_count, question_count, created_at)
+		SELECT ?, u.nickname, ?, COUNT(a.id), ?, CURRENT_TIMESTAMP
+		FROM users u
+		LEFT JOIN answers a ON a.user_id = u.id AND a.is_correct = 1
+		WHERE u.event_id = ?
+		GROUP BY u.id
+	`
+	if _, err := tx.Exec(query, r.eventID, eventTitle, questionCount, r.eventID); err != nil {
+		return fmt.Errorf("failed to record results: %v", err)
+	}
+	return tx.Commit()
+}
+
+// GetRatings returns the correct answer rate (0-100) of each nickname across all prior events
+func (r *RatingRepository) GetRatings() (PlayerRatings, error) {
+	query := `
+		SELECT nickname, SUM(correct_count), SUM(question_count)
+		FROM player_results
+		GROUP BY nickname
+	`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Merge rows whose nicknames only differ in case or surrounding spaces
+	correctByKey := make(map[string]int)
+	totalByKey := make(map[string]int)
+	for rows.Next() {
+		var nickname string
+		var correct, total int
+		if err := rows.Scan(&nickname, &correct, &total); err != nil {
+			return nil, err
+		}
+		key := NormalizeNickname(nickname)
+		correctByKey[key] += correct
+		totalByKey[key] += total
+	}
+
+	ratings := make(PlayerRatings)
+	for key, total := range totalByKey {
+		if total <= 0 {
+			continue
+		}
+		ratings[key] = float64(correctByKey[key]) * 100 / float64(total)
+	}
+
+	return ratings, nil
+}
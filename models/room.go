@@ -0,0 +1,92 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DefaultRoomCode is used for the main config when it does not set [event] room_code
+const DefaultRoomCode = "MAIN"
+
+var roomCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{1,32}$`)
+
+// RoomEntry declares an additional room in the main config
+type RoomEntry struct {
+	Code   string `toml:"code"`
+	Config string `toml:"config"` // Quiz config of the room (relative to config dir)
+}
+
+// RoomConfig is a room code together with the quiz config it runs
+type RoomConfig struct {
+	Code   string
+	Config *Config
+}
+
+// NormalizeRoomCode returns the key used to match room codes typed by participants
+func NormalizeRoomCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// ValidateRoomCode checks that a room code can be typed and used in URLs
+func ValidateRoomCode(code string) error {
+	if !roomCodePattern.MatchString(code) {
+		return fmt.Errorf("invalid room code %q: use up to 32 letters, digits, '-' or '_'", code)
+	}
+	return nil
+}
+
+// LoadRoomConfigs loads the main config and the config of every room it declares.
+// The main config is always the first room.
+func LoadRoomConfigs(configPath string) ([]RoomConfig, error) {
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	mainCode := DefaultRoomCode
+	if config.Event.RoomCode != "" {
+		mainCode = NormalizeRoomCode(config.Event.RoomCode)
+	}
+	if err := ValidateRoomCode(mainCode); err != nil {
+		return nil, err
+	}
+
+	rooms := []RoomConfig{{Code: mainCode, Config: config}}
+	seen := map[string]bool{mainCode: true}
+
+	for i, entry := range config.Rooms {
+		code := NormalizeRoomCode(entry.Code)
+		if err := ValidateRoomCode(code); err != nil {
+			return nil, fmt.Errorf("room %d: %v", i+1, err)
+		}
+		if seen[code] {
+			return nil, fmt.Errorf("room %d: duplicate room code %s", i+1, code)
+		}
+		if entry.Config == "" {
+			return nil, fmt.Errorf("room %s: config is required", code)
+		}
+
+		path := entry.Config
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(configPath), path)
+		}
+		roomConfig, err := LoadConfig(path)
+		if err != nil {
+			return nil, fmt.Errorf("room %s: %v", code, err)
+		}
+		if len(roomConfig.Rooms) > 0 {
+			return nil, errors.New("rooms can only be declared in the main config")
+		}
+		if roomConfig.RateLimits != (RateLimitsConfig{}) {
+			return nil, fmt.Errorf("room %s: rate_limits can only be set in the main config", code)
+		}
+
+		seen[code] = true
+		rooms = append(rooms, RoomConfig{Code: code, Config: roomConfig})
+	}
+
+	return rooms, nil
+}
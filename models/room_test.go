@@ -0,0 +1,126 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const roomTestQuestions = `
+[[questions]]
+type = "text"
+text = "What is 2+2?"
+choices = ["2", "3", "4", "5"]
+correct = 3
+`
+
+func writeRoomConfig(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoadRoomConfigs(t *testing.T) {
+	dir := t.TempDir()
+	writeRoomConfig(t, dir, "room_b.toml", `
+[event]
+title = "Room B Quiz"
+`+roomTestQuestions)
+	mainPath := writeRoomConfig(t, dir, "quiz.toml", `
+[event]
+title = "Main Quiz"
+room_code = "hall"
+
+[[rooms]]
+code = " b2 "
+config = "room_b.toml"
+`+roomTestQuestions)
+
+	rooms, err := LoadRoomConfigs(mainPath)
+	if err != nil {
+		t.Fatalf("Failed to load rooms: %v", err)
+	}
+	if len(rooms) != 2 {
+		t.Fatalf("Expected 2 rooms, got %d", len(rooms))
+	}
+	if rooms[0].Code != "HALL" || rooms[0].Config.Event.Title != "Main Quiz" {
+		t.Errorf("Expected main room HALL, got %s (%s)", rooms[0].Code, rooms[0].Config.Event.Title)
+	}
+	if rooms[1].Code != "B2" || rooms[1].Config.Event.Title != "Room B Quiz" {
+		t.Errorf("Expected room B2, got %s (%s)", rooms[1].Code, rooms[1].Config.Event.Title)
+	}
+}
+
+func TestLoadRoomConfigsDefaultCode(t *testing.T) {
+	dir := t.TempDir()
+	mainPath := writeRoomConfig(t, dir, "quiz.toml", `
+[event]
+title = "Main Quiz"
+`+roomTestQuestions)
+
+	rooms, err := LoadRoomConfigs(mainPath)
+	if err != nil {
+		t.Fatalf("Failed to load rooms: %v", err)
+	}
+	if len(rooms) != 1 || rooms[0].Code != DefaultRoomCode {
+		t.Errorf("Expected only the %s room, got %v", DefaultRoomCode, rooms)
+	}
+}
+
+func TestLoadRoomConfigsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rooms string
+	}{
+		{"duplicate code", "[[rooms]]\ncode = \"main\"\nconfig = \"quiz.toml\"\n"},
+		{"invalid code", "[[rooms]]\ncode = \"a b\"\nconfig = \"quiz.toml\"\n"},
+		{"missing config", "[[rooms]]\ncode = \"B\"\n"},
+		{"unknown config", "[[rooms]]\ncode = \"B\"\nconfig = \"nope.toml\"\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			mainPath := writeRoomConfig(t, dir, "quiz.toml", "[event]\ntitle = \"Main Quiz\"\n"+roomTestQuestions+tt.rooms)
+			if _, err := LoadRoomConfigs(mainPath); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestLoadRoomConfigsRateLimitsInMainConfigOnly(t *testing.T) {
+	dir := t.TempDir()
+	writeRoomConfig(t, dir, "room_b.toml", "[event]\ntitle = \"Room B Quiz\"\n"+roomTestQuestions+`
+[rate_limits.emoji]
+per_session = { burst = 1, every = "1s" }
+`)
+	mainPath := writeRoomConfig(t, dir, "quiz.toml", "[event]\ntitle = \"Main Quiz\"\n"+roomTestQuestions+`
+[rate_limits.emoji]
+per_session = { burst = 3, every = "2s" }
+`)
+
+	rooms, err := LoadRoomConfigs(mainPath)
+	if err != nil {
+		t.Fatalf("Failed to load rooms: %v", err)
+	}
+	if got := rooms[0].Config.RateLimits.Emoji.PerSession; got.Burst != 3 || got.Every != "2s" {
+		t.Errorf("Expected the emoji limit of the main config, got %+v", got)
+	}
+
+	writeRoomConfig(t, dir, "quiz.toml", "[event]\ntitle = \"Main Quiz\"\n"+roomTestQuestions+"[[rooms]]\ncode = \"B\"\nconfig = \"room_b.toml\"\n")
+	if _, err := LoadRoomConfigs(mainPath); err == nil {
+		t.Error("Expected an error for rate_limits in a room config")
+	}
+}
+
+func TestNormalizeRoomCode(t *testing.T) {
+	if got := NormalizeRoomCode("  ab-1 "); got != "AB-1" {
+		t.Errorf("Expected AB-1, got %s", got)
+	}
+	if err := ValidateRoomCode(""); err == nil {
+		t.Error("Expected empty code to be rejected")
+	}
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultScreenChannel is the channel of screens that do not ask for one
+const DefaultScreenChannel = "main"
+
+var screenChannelPattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// ScreenContent is a part of the event a screen channel can show
+type ScreenContent string
+
+const (
+	ScreenQuestions    ScreenContent = "questions"    // Title, questions, countdown, answer stats and reveal
+	ScreenLeaderboard  ScreenContent = "leaderboard"  // Scores after each reveal, leaderboard and final results
+	ScreenEmoji        ScreenContent = "emoji"        // Emoji reactions
+	ScreenParticipants ScreenContent = "participants" // Joins and team members
+	ScreenSlides       ScreenContent = "slides"       // Announcement slides
+)
+
+// AllScreenContents returns every content in display order; channels without
+// a setting show all of them
+func AllScreenContents() []ScreenContent {
+	return []ScreenContent{ScreenQuestions, ScreenLeaderboard, ScreenEmoji, ScreenParticipants, ScreenSlides}
+}
+
+// ScreenChannelConfig sets what the screens of a channel show
+type ScreenChannelConfig struct {
+	Channel string          `toml:"channel"`
+	Shows   []ScreenContent `toml:"shows"`
+}
+
+// NormalizeScreenChannel returns the channel name used for routing; empty is the default channel
+func NormalizeScreenChannel(channel string) string {
+	channel = strings.ToLower(strings.TrimSpace(channel))
+	if channel == "" {
+		return DefaultScreenChannel
+	}
+	return channel
+}
+
+// ValidateScreenChannel checks that a channel name can be used in URLs
+func ValidateScreenChannel(channel string) error {
+	if !screenChannelPattern.MatchString(channel) {
+		return fmt.Errorf("invalid screen channel %q: use up to 32 lowercase letters, digits, '-' or '_'", channel)
+	}
+	return nil
+}
+
+// ValidateScreenContents checks that a channel shows known contents, each once
+func ValidateScreenContents(shows []ScreenContent) error {
+	if len(shows) == 0 {
+		return fmt.Errorf("shows must list at least one of %v", AllScreenContents())
+	}
+
+	seen := make(map[ScreenContent]bool)
+	for _, content := range shows {
+		known := false
+		for _, valid := range AllScreenContents() {
+			known = known || content == valid
+		}
+		if !known {
+			return fmt.Errorf("unknown screen content %q", content)
+		}
+		if seen[content] {
+			return fmt.Errorf("screen content %q is listed twice", content)
+		}
+		seen[content] = true
+	}
+	return nil
+}
+
+func (c *Config) validateScreens() error {
+	seen := make(map[string]bool)
+	for i, screen := range c.Screens {
+		channel := NormalizeScreenChannel(screen.Channel)
+		if err := ValidateScreenChannel(channel); err != nil {
+			return fmt.Errorf("screen %d: %v", i+1, err)
+		}
+		if seen[channel] {
+			return fmt.Errorf("screen %d: duplicate channel %s", i+1, channel)
+		}
+		seen[channel] = true
+
+		if err := ValidateScreenContents(screen.Shows); err != nil {
+			return fmt.Errorf("screen %s: %v", channel, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package models
+
+import "testing"
+
+func TestValidateScreenContents(t *testing.T) {
+	tests := []struct {
+		name    string
+		shows   []ScreenContent
+		wantErr bool
+	}{
+		{"leaderboard and emoji", []ScreenContent{ScreenLeaderboard, ScreenEmoji}, false},
+		{"everything", AllScreenContents(), false},
+		{"nothing", nil, true},
+		{"unknown", []ScreenContent{"video"}, true},
+		{"duplicate", []ScreenContent{ScreenEmoji, ScreenEmoji}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateScreenContents(tt.shows)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateScreenContents() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigScreens(t *testing.T) {
+	config := &Config{Screens: []ScreenChannelConfig{
+		{Channel: "Leaderboard", Shows: []ScreenContent{ScreenLeaderboard}},
+		{Channel: "lobby", Shows: []ScreenContent{ScreenParticipants, ScreenSlides}},
+	}}
+	if err := config.validateScreens(); err != nil {
+		t.Fatalf("Expected screens to be valid, got %v", err)
+	}
+
+	config.Screens = append(config.Screens, ScreenChannelConfig{Channel: "leaderboard", Shows: []ScreenContent{ScreenEmoji}})
+	if err := config.validateScreens(); err == nil {
+		t.Error("Expected duplicate channel to be rejected")
+	}
+
+	config.Screens = []ScreenChannelConfig{{Channel: "big screen", Shows: []ScreenContent{ScreenEmoji}}}
+	if err := config.validateScreens(); err == nil {
+		t.Error("Expected invalid channel name to be rejected")
+	}
+}
@@ -0,0 +1,172 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSessionTTL is how long a session token stays valid without a refresh
+const DefaultSessionTTL = 12 * time.Hour
+
+// sessionSecretName is the row of the generated signing key in server_secrets
+const sessionSecretName = "session_token_key"
+
+var (
+	ErrInvalidSessionToken = errors.New("invalid session token")
+	ErrExpiredSessionToken = errors.New("session token expired")
+)
+
+// SessionClaims is what a valid session token vouches for
+type SessionClaims struct {
+	SessionID string
+	ExpiresAt time.Time
+}
+
+// SessionTokens issues and verifies the signed session tokens participants
+// authenticate with, so knowing a session ID is not enough to act as someone.
+// Tokens are signed with the first key and accepted with any key: putting a
+// new key in front rotates the secret while issued tokens stay valid.
+type SessionTokens struct {
+	keys [][]byte
+	ttl  time.Duration
+	now  func() time.Time
+}
+
+// NewSessionTokens creates a signer; keys must not be empty
+func NewSessionTokens(keys [][]byte, ttl time.Duration) (*SessionTokens, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one session key is required")
+	}
+	for i, key := range keys {
+		if len(key) < 16 {
+			return nil, fmt.Errorf("session key %d: at least 16 bytes are required", i+1)
+		}
+	}
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	return &SessionTokens{keys: keys, ttl: ttl, now: time.Now}, nil
+}
+
+// LoadSessionTokens creates the signer from comma-separated secrets (the
+// first one signs) and a TTL such as "12h". Without secrets a random key is
+// generated once and kept in the database so tokens survive restarts.
+func LoadSessionTokens(db *sql.DB, secrets, ttl string) (*SessionTokens, error) {
+	duration := DefaultSessionTTL
+	if ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid session TTL: %v", err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("session TTL must be positive")
+		}
+		duration = d
+	}
+
+	var keys [][]byte
+	for _, secret := range strings.Split(secrets, ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			keys = append(keys, []byte(secret))
+		}
+	}
+	if len(keys) == 0 {
+		key, err := storedSessionKey(db)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return NewSessionTokens(keys, duration)
+}
+
+// storedSessionKey returns the generated signing key, creating it on first use
+func storedSessionKey(db *sql.DB) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate session key: %v", err)
+	}
+	// Keeps the existing key when another process created it first
+	if _, err := db.Exec(`INSERT OR IGNORE INTO server_secrets (name, value) VALUES (?, ?)`, sessionSecretName, hex.EncodeToString(key)); err != nil {
+		return nil, fmt.Errorf("failed to store session key: %v", err)
+	}
+
+	var value string
+	if err := db.QueryRow(`SELECT value FROM server_secrets WHERE name = ?`, sessionSecretName).Scan(&value); err != nil {
+		return nil, fmt.Errorf("failed to load session key: %v", err)
+	}
+	stored, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode session key: %v", err)
+	}
+	return stored, nil
+}
+
+// TTL returns how long issued tokens are valid
+func (st *SessionTokens) TTL() time.Duration {
+	return st.ttl
+}
+
+// Issue signs a token for the session that expires after the TTL
+func (st *SessionTokens) Issue(sessionID string) (string, time.Time) {
+	expiresAt := st.now().Add(st.ttl).Truncate(time.Second)
+	payload := sessionID + "|" + strconv.FormatInt(expiresAt.Unix(), 10)
+	return encodeTokenPart([]byte(payload)) + "." + encodeTokenPart(sign(st.keys[0], payload)), expiresAt
+}
+
+// Verify checks the signature with every key and the expiry of a token
+func (st *SessionTokens) Verify(token string) (SessionClaims, error) {
+	encodedPayload, encodedMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return SessionClaims{}, ErrInvalidSessionToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return SessionClaims{}, ErrInvalidSessionToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil {
+		return SessionClaims{}, ErrInvalidSessionToken
+	}
+
+	signed := false
+	for _, key := range st.keys {
+		if hmac.Equal(mac, sign(key, string(payload))) {
+			signed = true
+			break
+		}
+	}
+	if !signed {
+		return SessionClaims{}, ErrInvalidSessionToken
+	}
+
+	sessionID, expiry, ok := strings.Cut(string(payload), "|")
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if !ok || sessionID == "" || err != nil {
+		return SessionClaims{}, ErrInvalidSessionToken
+	}
+	claims := SessionClaims{SessionID: sessionID, ExpiresAt: time.Unix(unix, 0)}
+	if !st.now().Before(claims.ExpiresAt) {
+		return claims, ErrExpiredSessionToken
+	}
+	return claims, nil
+}
+
+func sign(key []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func encodeTokenPart(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
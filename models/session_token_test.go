@@ -0,0 +1,117 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSessionTokens(t *testing.T, keys ...string) *SessionTokens {
+	var raw [][]byte
+	for _, key := range keys {
+		raw = append(raw, []byte(key))
+	}
+	tokens, err := NewSessionTokens(raw, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create session tokens: %v", err)
+	}
+	return tokens
+}
+
+func TestSessionTokenRoundTrip(t *testing.T) {
+	tokens := newTestSessionTokens(t, "first-secret-0123456789")
+
+	token, expiresAt := tokens.Issue("session-1")
+	claims, err := tokens.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if claims.SessionID != "session-1" || !claims.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Got claims %+v, want session-1 expiring at %v", claims, expiresAt)
+	}
+}
+
+func TestSessionTokenRejectsTampering(t *testing.T) {
+	tokens := newTestSessionTokens(t, "first-secret-0123456789")
+	token, _ := tokens.Issue("session-1")
+	payload, mac, _ := strings.Cut(token, ".")
+
+	forged := encodeTokenPart([]byte("session-2|9999999999")) + "." + mac
+	otherKey, _ := newTestSessionTokens(t, "other-secret-0123456789").Issue("session-1")
+	for name, candidate := range map[string]string{
+		"empty":          "",
+		"no signature":   payload,
+		"bare uuid":      "123e4567-e89b-12d3-a456-426614174000",
+		"forged payload": forged,
+		"other key":      otherKey,
+	} {
+		if _, err := tokens.Verify(candidate); !errors.Is(err, ErrInvalidSessionToken) {
+			t.Errorf("%s: got %v, want ErrInvalidSessionToken", name, err)
+		}
+	}
+}
+
+func TestSessionTokenExpiry(t *testing.T) {
+	tokens := newTestSessionTokens(t, "first-secret-0123456789")
+	now := time.Now()
+	tokens.now = func() time.Time { return now }
+	token, _ := tokens.Issue("session-1")
+
+	tokens.now = func() time.Time { return now.Add(time.Hour + time.Second) }
+	claims, err := tokens.Verify(token)
+	if !errors.Is(err, ErrExpiredSessionToken) {
+		t.Fatalf("Got %v, want ErrExpiredSessionToken", err)
+	}
+	if claims.SessionID != "session-1" {
+		t.Errorf("Expired claims should still name the session, got %q", claims.SessionID)
+	}
+}
+
+func TestSessionKeyRotation(t *testing.T) {
+	old := newTestSessionTokens(t, "old-secret-0123456789")
+	oldToken, _ := old.Issue("session-1")
+
+	rotated := newTestSessionTokens(t, "new-secret-0123456789", "old-secret-0123456789")
+	if _, err := rotated.Verify(oldToken); err != nil {
+		t.Errorf("Token signed with the previous key was rejected: %v", err)
+	}
+	newToken, _ := rotated.Issue("session-1")
+	if _, err := old.Verify(newToken); err == nil {
+		t.Error("New tokens should be signed with the first key")
+	}
+}
+
+func TestLoadSessionTokens(t *testing.T) {
+	db := setupProgressDB(t)
+
+	first, err := LoadSessionTokens(db, "", "")
+	if err != nil {
+		t.Fatalf("LoadSessionTokens failed: %v", err)
+	}
+	if first.TTL() != DefaultSessionTTL {
+		t.Errorf("Got TTL %v, want %v", first.TTL(), DefaultSessionTTL)
+	}
+	second, err := LoadSessionTokens(db, "", "30m")
+	if err != nil {
+		t.Fatalf("LoadSessionTokens failed: %v", err)
+	}
+	if !bytes.Equal(first.keys[0], second.keys[0]) {
+		t.Error("Generated key should be kept in the database")
+	}
+
+	configured, err := LoadSessionTokens(db, " new-secret-0123456789 , old-secret-0123456789", "")
+	if err != nil {
+		t.Fatalf("LoadSessionTokens failed: %v", err)
+	}
+	if len(configured.keys) != 2 || string(configured.keys[0]) != "new-secret-0123456789" {
+		t.Errorf("Got keys %q, want the configured secrets in order", configured.keys)
+	}
+
+	for _, invalid := range []struct{ secrets, ttl string }{{"short", ""}, {"", "soon"}, {"", "-1h"}} {
+		if _, err := LoadSessionTokens(db, invalid.secrets, invalid.ttl); err == nil {
+			t.Errorf("LoadSessionTokens(%q, %q) should fail", invalid.secrets, invalid.ttl)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Slide layouts
+const (
+	SlideTypeText     = "text"     // Title and plain text
+	SlideTypeImage    = "image"    // Full-screen image with an optional caption
+	SlideTypeMarkdown = "markdown" // Headings, lists, bold text and images
+)
+
+// Slide is an announcement the admin shows over the current state (e.g. "dinner
+// is served", sponsor logos, the prize table). Dismissing it returns the clients
+// to the state underneath.
+type Slide struct {
+	ID    string `toml:"id" json:"id,omitempty"`
+	Type  string `toml:"type" json:"type"`
+	Label string `toml:"label" json:"label,omitempty"` // Admin button name (defaults to the title or ID)
+	Title string `toml:"title" json:"title,omitempty"`
+	Text  string `toml:"text" json:"text,omitempty"`   // Body (markdown for markdown slides)
+	Image string `toml:"image" json:"image,omitempty"` // File in static/images
+}
+
+// Validate checks that a slide has the content its layout needs
+func (s *Slide) Validate() error {
+	switch s.Type {
+	case SlideTypeText:
+		if s.Title == "" && s.Text == "" {
+			return errors.New("title or text is required")
+		}
+	case SlideTypeImage:
+		if s.Image == "" {
+			return errors.New("image is required for image slides")
+		}
+	case SlideTypeMarkdown:
+		if s.Text == "" {
+			return errors.New("text is required for markdown slides")
+		}
+	default:
+		return fmt.Errorf("slide type must be '%s', '%s' or '%s'", SlideTypeText, SlideTypeImage, SlideTypeMarkdown)
+	}
+
+	if s.Image != "" {
+		imagePath := filepath.Join("static", "images", s.Image)
+		if _, err := os.Stat(imagePath); os.IsNotExist(err) {
+			return fmt.Errorf("image file not found: %s", imagePath)
+		}
+	}
+
+	return nil
+}
+
+// DisplayLabel returns the name of the slide on the admin screen
+func (s *Slide) DisplayLabel() string {
+	if s.Label != "" {
+		return s.Label
+	}
+	if s.Title != "" {
+		return s.Title
+	}
+	if s.ID != "" {
+		return s.ID
+	}
+	return s.Text
+}
+
+// FindSlide returns the configured slide with the given ID
+func (c *Config) FindSlide(id string) (*Slide, bool) {
+	for i := range c.Slides {
+		if c.Slides[i].ID == id {
+			slide := c.Slides[i]
+			return &slide, true
+		}
+	}
+	return nil, false
+}
+
+func (c *Config) validateSlides() error {
+	seen := make(map[string]bool)
+	for i, slide := range c.Slides {
+		if slide.ID == "" {
+			return fmt.Errorf("slide %d: id is required", i+1)
+		}
+		if seen[slide.ID] {
+			return fmt.Errorf("slide %d: duplicate id %s", i+1, slide.ID)
+		}
+		seen[slide.ID] = true
+
+		if err := slide.Validate(); err != nil {
+			return fmt.Errorf("slide %s: %v", slide.ID, err)
+		}
+	}
+	return nil
+}
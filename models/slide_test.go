@@ -0,0 +1,56 @@
+package models
+
+import "testing"
+
+func TestSlideValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		slide   Slide
+		wantErr bool
+	}{
+		{"text", Slide{Type: SlideTypeText, Title: "休憩"}, false},
+		{"text without content", Slide{Type: SlideTypeText}, true},
+		{"markdown", Slide{Type: SlideTypeMarkdown, Text: "# 景品\n- 1位: 温泉旅行"}, false},
+		{"markdown without text", Slide{Type: SlideTypeMarkdown, Title: "景品"}, true},
+		{"image without file", Slide{Type: SlideTypeImage}, true},
+		{"image not found", Slide{Type: SlideTypeImage, Image: "missing.png"}, true},
+		{"unknown type", Slide{Type: "video", Text: "x"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.slide.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigSlides(t *testing.T) {
+	config := &Config{Slides: []Slide{
+		{ID: "dinner", Type: SlideTypeText, Title: "お食事をどうぞ"},
+		{ID: "prizes", Type: SlideTypeMarkdown, Label: "景品", Text: "- 1位: 温泉旅行"},
+	}}
+	if err := config.validateSlides(); err != nil {
+		t.Fatalf("Expected slides to be valid, got %v", err)
+	}
+
+	slide, ok := config.FindSlide("prizes")
+	if !ok || slide.DisplayLabel() != "景品" {
+		t.Errorf("Expected prizes slide labelled 景品, got %v", slide)
+	}
+	if _, ok := config.FindSlide("missing"); ok {
+		t.Error("Expected unknown slide not to be found")
+	}
+
+	config.Slides = append(config.Slides, Slide{ID: "dinner", Type: SlideTypeText, Text: "again"})
+	if err := config.validateSlides(); err == nil {
+		t.Error("Expected duplicate slide id to be rejected")
+	}
+
+	config.Slides = []Slide{{Type: SlideTypeText, Text: "no id"}}
+	if err := config.validateSlides(); err == nil {
+		t.Error("Expected slide without id to be rejected")
+	}
+}
@@ -0,0 +1,175 @@
+package models
+
+import "context"
+
+// TransitionKind tells how a state change was made
+type TransitionKind string
+
+const (
+	TransitionAction      TransitionKind = "action"       // Flow action (PerformAction)
+	TransitionDirect      TransitionKind = "transition"   // Validated move to a state (TransitionTo)
+	TransitionJump        TransitionKind = "jump"         // Unvalidated admin jump
+	TransitionGoBack      TransitionKind = "go_back"      // Return to the previous position
+	TransitionSetQuestion TransitionKind = "set_question" // Question number edit
+)
+
+// StateChange describes one change of the state machine's position
+type StateChange struct {
+	Kind       TransitionKind
+	Action     string          // Action performed (TransitionAction only)
+	Transition *FlowTransition // Flow transition followed (TransitionAction only)
+	From       StateSnapshot
+	To         StateSnapshot
+}
+
+// StateChanged reports whether the state itself changed (not just the question number)
+func (c StateChange) StateChanged() bool {
+	return c.From.State != c.To.State
+}
+
+// BeforeTransitionHook runs before a change is applied; returning an error vetoes it
+type BeforeTransitionHook func(ctx context.Context, change StateChange) error
+
+// TransitionListener runs after a change is applied. Listeners see one change at
+// a time, in the order the changes were made, in registration order. They run
+// without the transition lock, so a listener may change the state itself by
+// passing on its context: that change is delivered once the current one has
+// been seen by every listener.
+type TransitionListener func(ctx context.Context, change StateChange)
+
+// OnBeforeTransition registers a hook that can veto changes
+func (esm *EventStateManager) OnBeforeTransition(hook BeforeTransitionHook) {
+	esm.hookMu.Lock()
+	defer esm.hookMu.Unlock()
+	esm.beforeHooks = append(esm.beforeHooks, hook)
+}
+
+// OnTransition registers a listener for applied changes
+func (esm *EventStateManager) OnTransition(listener TransitionListener) {
+	esm.hookMu.Lock()
+	defer esm.hookMu.Unlock()
+	esm.afterHooks = append(esm.afterHooks, listener)
+}
+
+// appliedChange is a change waiting to be delivered to the listeners
+type appliedChange struct {
+	ctx       context.Context
+	change    StateChange
+	listeners []TransitionListener
+	done      bool // Every listener has seen the change
+}
+
+// deliveringKey marks the context given to listeners, so that a change made by
+// a listener is not waited for by the goroutine that has to deliver it
+type deliveringKey struct{}
+
+// apply makes one change: plan works out the target from the current position,
+// the before hooks may veto it, commit applies it and the listeners are told.
+// Changes are serialized and delivered to the listeners in the order they were
+// made; readers are only blocked while commit runs. apply returns once the
+// listeners have seen the change, except for a change made by a listener, which
+// is delivered after the change the listener is handling.
+func (esm *EventStateManager) apply(ctx context.Context, plan func() (*StateChange, error), commit func(*StateChange)) (*StateChange, error) {
+	applied, err := esm.commitChange(ctx, plan, commit)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Value(deliveringKey{}) != esm {
+		esm.waitDelivered(applied)
+	}
+	change := applied.change
+	return &change, nil
+}
+
+// commitChange applies a change under the transition lock and queues it for the
+// listeners before the lock is released, so the queue keeps the commit order
+func (esm *EventStateManager) commitChange(ctx context.Context, plan func() (*StateChange, error), commit func(*StateChange)) (*appliedChange, error) {
+	esm.transitionMu.Lock()
+	defer esm.transitionMu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	esm.mu.RLock()
+	change, err := plan()
+	esm.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	esm.hookMu.RLock()
+	beforeHooks := esm.beforeHooks
+	afterHooks := esm.afterHooks
+	esm.hookMu.RUnlock()
+
+	for _, hook := range beforeHooks {
+		if err := hook(ctx, *change); err != nil {
+			return nil, err
+		}
+	}
+
+	esm.mu.Lock()
+	commit(change)
+	esm.mu.Unlock()
+
+	applied := &appliedChange{ctx: ctx, change: *change, listeners: afterHooks}
+	esm.notifyMu.Lock()
+	esm.pending = append(esm.pending, applied)
+	esm.notifyMu.Unlock()
+	return applied, nil
+}
+
+// waitDelivered returns once the listeners have seen the change. One goroutine
+// delivers at a time, the queued changes of the others included; the others
+// wait for it and take over if it stops before their change was delivered.
+func (esm *EventStateManager) waitDelivered(applied *appliedChange) {
+	esm.notifyMu.Lock()
+	defer esm.notifyMu.Unlock()
+
+	for !applied.done {
+		if esm.notifying {
+			esm.delivered.Wait()
+			continue
+		}
+		esm.deliverPending()
+	}
+}
+
+// deliverPending runs the listeners of the queued changes. It is called, and
+// returns, with notifyMu held, but releases it while the listeners run.
+func (esm *EventStateManager) deliverPending() {
+	esm.notifying = true
+	defer func() {
+		esm.notifying = false
+		esm.delivered.Broadcast()
+	}()
+
+	for len(esm.pending) > 0 {
+		next := esm.pending[0]
+		esm.pending = esm.pending[1:]
+		esm.deliver(next)
+	}
+}
+
+// deliver runs the listeners of one change without holding notifyMu. Even if a
+// listener panics the lock is taken back and the change counts as delivered, so
+// the goroutines waiting for it don't hang.
+func (esm *EventStateManager) deliver(applied *appliedChange) {
+	esm.notifyMu.Unlock()
+	defer func() {
+		esm.notifyMu.Lock()
+		applied.done = true
+		esm.delivered.Broadcast()
+	}()
+	esm.runListeners(applied)
+}
+
+// runListeners tells the listeners about one change, marking their context so
+// that changes they make are queued behind it
+func (esm *EventStateManager) runListeners(applied *appliedChange) {
+	ctx := context.WithValue(applied.ctx, deliveringKey{}, esm)
+	for _, listener := range applied.listeners {
+		listener(ctx, applied.change)
+	}
+}
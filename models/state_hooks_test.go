@@ -0,0 +1,209 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTransitionHooks(t *testing.T) {
+	esm := NewEventStateManager(false, 2, FlowConfig{})
+
+	var changes []StateChange
+	esm.OnTransition(func(ctx context.Context, change StateChange) {
+		// Listeners see the applied position
+		if esm.GetSnapshot() != change.To {
+			t.Errorf("Expected listener to see %v, got %v", change.To, esm.GetSnapshot())
+		}
+		changes = append(changes, change)
+	})
+
+	esm.PerformAction(ActionShowTitle)
+	esm.PerformAction(ActionNextQuestion)
+	esm.GoBack()
+
+	if len(changes) != 3 {
+		t.Fatalf("Expected 3 changes, got %d", len(changes))
+	}
+	if changes[1].Kind != TransitionAction || changes[1].Action != ActionNextQuestion || changes[1].Transition == nil {
+		t.Errorf("Expected next_question action change, got %+v", changes[1])
+	}
+	if changes[1].To != (StateSnapshot{State: StateQuestionActive, QuestionNumber: 1}) {
+		t.Errorf("Expected question 1 to be active, got %v", changes[1].To)
+	}
+	if changes[2].Kind != TransitionGoBack || changes[2].To.State != StateTitleDisplay {
+		t.Errorf("Expected go back to title, got %+v", changes[2])
+	}
+}
+
+func TestBeforeTransitionHookVeto(t *testing.T) {
+	esm := NewEventStateManager(false, 2, FlowConfig{})
+
+	held := true
+	esm.OnBeforeTransition(func(ctx context.Context, change StateChange) error {
+		if held {
+			return errors.New("held")
+		}
+		return nil
+	})
+	notified := 0
+	esm.OnTransition(func(ctx context.Context, change StateChange) { notified++ })
+
+	if _, err := esm.PerformAction(ActionShowTitle); err == nil {
+		t.Error("Expected the hook to veto the action")
+	}
+	if err := esm.JumpToState(StateResults); err == nil {
+		t.Error("Expected the hook to veto the jump")
+	}
+	if esm.GetCurrentState() != StateStarted || notified != 0 {
+		t.Errorf("Expected vetoed changes to leave the state alone, got %s with %d notifications", esm.GetCurrentState(), notified)
+	}
+
+	held = false
+	if _, err := esm.PerformAction(ActionShowTitle); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if notified != 1 {
+		t.Errorf("Expected 1 notification, got %d", notified)
+	}
+}
+
+func TestTransitionCanceledContext(t *testing.T) {
+	esm := NewEventStateManager(false, 2, FlowConfig{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := esm.PerformActionContext(ctx, ActionShowTitle); err == nil {
+		t.Error("Expected a canceled context to stop the action")
+	}
+	if esm.GetCurrentState() != StateStarted {
+		t.Errorf("Expected state to stay started, got %s", esm.GetCurrentState())
+	}
+}
+
+func TestListenerCanChangeState(t *testing.T) {
+	esm := NewEventStateManager(false, 2, FlowConfig{})
+
+	// The first listener moves on from the title; both must see the changes in order
+	var first, second []EventState
+	esm.OnTransition(func(ctx context.Context, change StateChange) {
+		first = append(first, change.To.State)
+		if change.To.State == StateTitleDisplay {
+			if _, err := esm.PerformActionContext(ctx, ActionNextQuestion); err != nil {
+				t.Errorf("Unexpected error from the listener: %v", err)
+			}
+		}
+	})
+	esm.OnTransition(func(ctx context.Context, change StateChange) {
+		second = append(second, change.To.State)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		esm.PerformAction(ActionShowTitle)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Transition from a listener deadlocked")
+	}
+
+	expected := []EventState{StateTitleDisplay, StateQuestionActive}
+	for name, seen := range map[string][]EventState{"first": first, "second": second} {
+		if len(seen) != len(expected) || seen[0] != expected[0] || seen[1] != expected[1] {
+			t.Errorf("Expected the %s listener to see %v, got %v", name, expected, seen)
+		}
+	}
+	if esm.GetSnapshot() != (StateSnapshot{State: StateQuestionActive, QuestionNumber: 1}) {
+		t.Errorf("Expected question 1 to be active, got %v", esm.GetSnapshot())
+	}
+}
+
+func TestTransitionWaitsForItsListeners(t *testing.T) {
+	esm := NewEventStateManager(false, 2, FlowConfig{})
+
+	// The listener holds the title change until released
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var seen []EventState
+	esm.OnTransition(func(ctx context.Context, change StateChange) {
+		if change.To.State == StateTitleDisplay {
+			close(entered)
+			<-release
+		}
+		mu.Lock()
+		seen = append(seen, change.To.State)
+		mu.Unlock()
+	})
+
+	go esm.PerformAction(ActionShowTitle)
+	<-entered
+
+	// The next change is delivered by the goroutine still handling the title,
+	// but PerformAction must not return before its listeners ran
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		esm.PerformAction(ActionNextQuestion)
+		mu.Lock()
+		defer mu.Unlock()
+		if len(seen) != 2 || seen[1] != StateQuestionActive {
+			t.Errorf("Expected the listeners to have seen question 1 start, got %v", seen)
+		}
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Expected PerformAction to wait for the title change to be delivered first")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("PerformAction did not return after its change was delivered")
+	}
+}
+
+func TestConcurrentTransitions(t *testing.T) {
+	esm := NewEventStateManager(false, 50, FlowConfig{})
+	esm.JumpToState(StateAnswerReveal)
+
+	var mu sync.Mutex
+	seen := map[int]bool{}
+	esm.OnTransition(func(ctx context.Context, change StateChange) {
+		mu.Lock()
+		defer mu.Unlock()
+		if change.Action == ActionNextQuestion {
+			if seen[change.To.QuestionNumber] {
+				t.Errorf("Question %d started twice", change.To.QuestionNumber)
+			}
+			seen[change.To.QuestionNumber] = true
+		}
+	})
+
+	// Every worker races to start the next question and reveal it again
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for esm.GetQuestionNumber() < 50 {
+				esm.PerformAction(ActionNextQuestion)
+				esm.JumpToState(StateAnswerReveal)
+				esm.GetAvailableActionDetails()
+				esm.GetHistory()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if esm.GetQuestionNumber() != 50 || len(seen) != 50 {
+		t.Errorf("Expected all 50 questions to start once, got question %d and %d starts", esm.GetQuestionNumber(), len(seen))
+	}
+}
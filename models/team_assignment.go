@@ -3,25 +3,31 @@ package models
 import (
 	"fmt"
 	"math/rand"
+	"sort"
 	"strings"
 	"time"
 )
 
 type TeamAssignmentService struct {
-	userRepo *UserRepository
-	teamRepo *TeamRepository
-	config   *Config
+	userRepo   *UserRepository
+	teamRepo   *TeamRepository
+	ratingRepo *RatingRepository
+	config     *Config
 }
 
-func NewTeamAssignmentService(userRepo *UserRepository, teamRepo *TeamRepository, config *Config) *TeamAssignmentService {
+func NewTeamAssignmentService(userRepo *UserRepository, teamRepo *TeamRepository, ratingRepo *RatingRepository, config *Config) *TeamAssignmentService {
 	return &TeamAssignmentService{
-		userRepo: userRepo,
-		teamRepo: teamRepo,
-		config:   config,
+		userRepo:   userRepo,
+		teamRepo:   teamRepo,
+		ratingRepo: ratingRepo,
+		config:     config,
 	}
 }
 
-func (s *TeamAssignmentService) CreateTeamsAndAssignUsers() ([]Team, error) {
+// CreateTeamsAndAssignUsers creates teams and assigns all users without a team.
+// When ratings is nil, users are shuffled and assigned round-robin.
+// Otherwise users are distributed so that every team has a similar total rating.
+func (s *TeamAssignmentService) CreateTeamsAndAssignUsers(ratings PlayerRatings) ([]Team, error) {
 	// Get all users without team assignment
 	users, err := s.userRepo.GetUsersWithoutTeam()
 	if err != nil {
@@ -38,14 +44,14 @@ func (s *TeamAssignmentService) CreateTeamsAndAssignUsers() ([]Team, error) {
 		return nil, fmt.Errorf("failed to clear existing teams: %v", err)
 	}
 
-	// Shuffle users for random assignment
+	// Shuffle users for random assignment (also breaks rating ties randomly)
 	rand.Seed(time.Now().UnixNano())
 	rand.Shuffle(len(users), func(i, j int) {
 		users[i], users[j] = users[j], users[i]
 	})
 
 	// Apply separation constraints
-	if len(s.config.TeamSeparation.AvoidGroups) > 0 {
+	if ratings == nil && len(s.config.TeamSeparation.AvoidGroups) > 0 {
 		users = s.applySeparationConstraints(users)
 	}
 
@@ -73,11 +79,22 @@ func (s *TeamAssignmentService) CreateTeamsAndAssignUsers() ([]Team, error) {
 	}
 
 	// Assign users to teams
-	for i, user := range users {
-		teamIndex := i % len(teams)
-		err = s.userRepo.AssignUserToTeam(user.ID, teams[teamIndex].ID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to assign user %d to team: %v", user.ID, err)
+	if ratings != nil {
+		for teamIndex, members := range s.distributeByRating(users, ratings, len(teams)) {
+			for _, user := range members {
+				err = s.userRepo.AssignUserToTeam(user.ID, teams[teamIndex].ID)
+				if err != nil {
+					return nil, fmt.Errorf("failed to assign user %d to team: %v", user.ID, err)
+				}
+			}
+		}
+	} else {
+		for i, user := range users {
+			teamIndex := i % len(teams)
+			err = s.userRepo.AssignUserToTeam(user.ID, teams[teamIndex].ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to assign user %d to team: %v", user.ID, err)
+			}
 		}
 	}
 
@@ -137,6 +154,101 @@ func (s *TeamAssignmentService) applySeparationConstraints(users []User) []User
 	return result
 }
 
+// distributeByRating assigns the strongest remaining user to the weakest team that still has room.
+// Users without a rating are treated as average players.
+func (s *TeamAssignmentService) distributeByRating(users []User, ratings PlayerRatings, numTeams int) [][]User {
+	defaultRating := ratings.Average()
+	userRating := func(user User) float64 {
+		if rating, ok := ratings.Get(user.Nickname); ok {
+			return rating
+		}
+		return defaultRating
+	}
+
+	sorted := make([]User, len(users))
+	copy(sorted, users)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return userRating(sorted[i]) > userRating(sorted[j])
+	})
+
+	capacity := (len(users) + numTeams - 1) / numTeams
+	teams := make([][]User, numTeams)
+	totals := make([]float64, numTeams)
+
+	for _, user := range sorted {
+		best := -1
+		bestConflict := false
+		for i := range teams {
+			if len(teams[i]) >= capacity {
+				continue
+			}
+			conflict := s.hasSeparationConflict(user, teams[i])
+			if best == -1 ||
+				(bestConflict && !conflict) ||
+				(bestConflict == conflict && totals[i] < totals[best]) {
+				best = i
+				bestConflict = conflict
+			}
+		}
+
+		teams[best] = append(teams[best], user)
+		totals[best] += userRating(user)
+	}
+
+	return teams
+}
+
+// hasSeparationConflict reports whether user shares an avoid group with any of members
+func (s *TeamAssignmentService) hasSeparationConflict(user User, members []User) bool {
+	for _, avoidGroup := range s.config.TeamSeparation.AvoidGroups {
+		if !s.isNameMatch(user.Nickname, avoidGroup) {
+			continue
+		}
+		for _, member := range members {
+			if s.isNameMatch(member.Nickname, avoidGroup) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// LoadRatings collects ratings from the configured history file and prior events in the DB.
+// Ratings from the file take precedence over ratings computed from the DB.
+func (s *TeamAssignmentService) LoadRatings() (PlayerRatings, error) {
+	ratings := make(PlayerRatings)
+
+	if s.config.TeamBalance.UsePastResults && s.ratingRepo != nil {
+		pastRatings, err := s.ratingRepo.GetRatings()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get past results: %v", err)
+		}
+		for nickname, rating := range pastRatings {
+			ratings[nickname] = rating
+		}
+	}
+
+	if s.config.TeamBalance.RatingsFile != "" {
+		fileRatings, err := LoadPlayerRatings(s.config.TeamBalance.RatingsFile)
+		if err != nil {
+			return nil, err
+		}
+		for nickname, rating := range fileRatings {
+			ratings[nickname] = rating
+		}
+	}
+
+	return ratings, nil
+}
+
+// RecordEventResults stores the event's results so that later events can balance teams with them
+func (s *TeamAssignmentService) RecordEventResults() error {
+	if s.ratingRepo == nil {
+		return nil
+	}
+	return s.ratingRepo.RecordResults(s.config.Event.Title, len(s.config.Questions))
+}
+
 func (s *TeamAssignmentService) isNameMatch(nickname, avoidGroup string) bool {
 	// Convert to lowercase for case-insensitive matching
 	nicknameLower := strings.ToLower(nickname)
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestDistributeByRatingBalancesTotals(t *testing.T) {
+	service := NewTeamAssignmentService(nil, nil, nil, &Config{})
+
+	users := []User{
+		{ID: 1, Nickname: "A"}, {ID: 2, Nickname: "B"}, {ID: 3, Nickname: "C"},
+		{ID: 4, Nickname: "D"}, {ID: 5, Nickname: "E"}, {ID: 6, Nickname: "F"},
+	}
+	ratings := PlayerRatings{"a": 90, "b": 80, "c": 70, "d": 30, "e": 20, "f": 10}
+
+	teams := service.distributeByRating(users, ratings, 2)
+
+	if len(teams) != 2 {
+		t.Fatalf("Expected 2 teams, got %d", len(teams))
+	}
+
+	totals := make([]float64, len(teams))
+	for i, members := range teams {
+		if len(members) != 3 {
+			t.Errorf("Expected 3 members in team %d, got %d", i+1, len(members))
+		}
+		for _, member := range members {
+			rating, _ := ratings.Get(member.Nickname)
+			totals[i] += rating
+		}
+	}
+
+	diff := totals[0] - totals[1]
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff > 20 {
+		t.Errorf("Expected balanced team totals, got %v", totals)
+	}
+}
+
+func TestDistributeByRatingUnknownPlayersAreAverage(t *testing.T) {
+	service := NewTeamAssignmentService(nil, nil, nil, &Config{})
+
+	users := []User{
+		{ID: 1, Nickname: "Strong"}, {ID: 2, Nickname: "Weak"},
+		{ID: 3, Nickname: "New1"}, {ID: 4, Nickname: "New2"},
+	}
+	ratings := PlayerRatings{"strong": 100, "weak": 0}
+
+	teams := service.distributeByRating(users, ratings, 2)
+
+	for i, members := range teams {
+		names := map[string]bool{}
+		for _, member := range members {
+			names[member.Nickname] = true
+		}
+		if names["Strong"] && names["Weak"] {
+			continue
+		}
+		if names["Strong"] || names["Weak"] {
+			t.Errorf("Expected strong and weak players in the same team, team %d has %v", i+1, names)
+		}
+	}
+}
+
+func TestDistributeByRatingRespectsAvoidGroups(t *testing.T) {
+	service := NewTeamAssignmentService(nil, nil, nil, &Config{
+		TeamSeparation: TeamSeparationConfig{AvoidGroups: []string{"田中"}},
+	})
+
+	users := []User{
+		{ID: 1, Nickname: "田中太郎"}, {ID: 2, Nickname: "田中花子"},
+		{ID: 3, Nickname: "鈴木"}, {ID: 4, Nickname: "高橋"},
+	}
+	ratings := PlayerRatings{"田中太郎": 90, "田中花子": 85, "鈴木": 20, "高橋": 10}
+
+	teams := service.distributeByRating(users, ratings, 2)
+
+	for i, members := range teams {
+		count := 0
+		for _, member := range members {
+			if service.isNameMatch(member.Nickname, "田中") {
+				count++
+			}
+		}
+		if count > 1 {
+			t.Errorf("Expected avoid group members to be separated, team %d has %d", i+1, count)
+		}
+	}
+}
+
+func TestLoadPlayerRatings(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "ratings.toml")
+
+	content := `
+[[players]]
+nickname = " Alice "
+rating = 75.5
+
+[[players]]
+nickname = "Bob"
+rating = 40
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write ratings file: %v", err)
+	}
+
+	ratings, err := LoadPlayerRatings(path)
+	if err != nil {
+		t.Fatalf("Failed to load ratings: %v", err)
+	}
+
+	if rating, ok := ratings.Get("alice"); !ok || rating != 75.5 {
+		t.Errorf("Expected rating 75.5 for alice, got %v (found: %v)", rating, ok)
+	}
+
+	if rating, ok := ratings.Get("BOB"); !ok || rating != 40 {
+		t.Errorf("Expected rating 40 for BOB, got %v (found: %v)", rating, ok)
+	}
+
+	invalid := filepath.Join(tempDir, "invalid.toml")
+	if err := os.WriteFile(invalid, []byte("[[players]]\nnickname = \"X\"\nrating = 150\n"), 0644); err != nil {
+		t.Fatalf("Failed to write ratings file: %v", err)
+	}
+	if _, err := LoadPlayerRatings(invalid); err == nil {
+		t.Error("Expected error for out-of-range rating")
+	}
+}
+
+func TestRecordResultsOncePerEvent(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	schema, err := os.ReadFile("../database/init.sql")
+	if err != nil {
+		t.Fatalf("Failed to read schema: %v", err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatalf("Failed to execute schema: %v", err)
+	}
+
+	// Alice answered all 4 questions of an earlier event and 1 of 2 in this one
+	if _, err := db.Exec(`INSERT INTO player_results (event_id, nickname, event_title, correct_count, question_count) VALUES (1, 'Alice', 'Earlier Quiz', 4, 4)`); err != nil {
+		t.Fatalf("Failed to insert earlier result: %v", err)
+	}
+	result, err := db.Exec(`INSERT INTO users (event_id, session_id, nickname) VALUES (2, 's1', 'Alice')`)
+	if err != nil {
+		t.Fatalf("Failed to insert user: %v", err)
+	}
+	userID, _ := result.LastInsertId()
+	if _, err := db.Exec(`INSERT INTO answers (user_id, question_number, answer_index, is_correct) VALUES (?, 1, 1, 1)`, userID); err != nil {
+		t.Fatalf("Failed to insert answer: %v", err)
+	}
+
+	repo := NewRatingRepository(db, 2)
+	// Showing the results again must not count the event twice
+	for range 2 {
+		if err := repo.RecordResults("Test Quiz", 2); err != nil {
+			t.Fatalf("Failed to record results: %v", err)
+		}
+	}
+
+	ratings, err := repo.GetRatings()
+	if err != nil {
+		t.Fatalf("Failed to get ratings: %v", err)
+	}
+	if rating, ok := ratings.Get("alice"); !ok || rating < 83.3 || rating > 83.4 {
+		t.Errorf("Expected rating 83.33 for alice (5 of 6), got %v (found: %v)", rating, ok)
+	}
+}
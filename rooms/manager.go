@@ -0,0 +1,163 @@
+package rooms
+
+import (
+	"database/sql"
+	"net/http"
+
+	quizerrors "quiz100/errors"
+	"quiz100/handlers"
+	"quiz100/middleware"
+	"quiz100/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Manager routes requests to the room they belong to
+type Manager struct {
+	db      *sql.DB
+	tokens  *models.SessionTokens
+	rooms   []*Room
+	byCode  map[string]*Room
+	byEvent map[int]*Room
+}
+
+// NewManager creates an empty room manager; participant sessions are
+// resolved from tokens verified with the given signer
+func NewManager(db *sql.DB, tokens *models.SessionTokens) *Manager {
+	return &Manager{
+		db:      db,
+		tokens:  tokens,
+		byCode:  make(map[string]*Room),
+		byEvent: make(map[int]*Room),
+	}
+}
+
+// Add registers a room; rooms are kept in the order they were added
+func (m *Manager) Add(room *Room) {
+	m.rooms = append(m.rooms, room)
+	m.byCode[room.Code] = room
+	m.byEvent[room.Event.ID] = room
+}
+
+// Get returns the room with the given join code
+func (m *Manager) Get(code string) *Room {
+	return m.byCode[models.NormalizeRoomCode(code)]
+}
+
+// Rooms returns all rooms
+func (m *Manager) Rooms() []*Room {
+	return m.rooms
+}
+
+// CodeRequired reports whether participants have to enter a room code
+func (m *Manager) CodeRequired() bool {
+	return len(m.rooms) > 1
+}
+
+// Resolve finds the room of a request from the room code (query "room" or
+// header X-Room-Code), then from the participant's session token. With a
+// single room the code is optional.
+func (m *Manager) Resolve(c *gin.Context) (*Room, *quizerrors.QuizError) {
+	code := c.Query("room")
+	if code == "" {
+		code = c.GetHeader("X-Room-Code")
+	}
+	if code != "" {
+		if room := m.Get(code); room != nil {
+			return room, nil
+		}
+		return nil, m.roomNotFound()
+	}
+
+	// An invalid token is rejected by the handler; here it only finds no room
+	if claims, err := m.tokens.Verify(handlers.SessionToken(c)); err == nil {
+		eventID, err := models.FindEventIDBySession(m.db, claims.SessionID)
+		if err != nil {
+			return nil, quizerrors.ErrDatabaseError.WithCause(err)
+		}
+		if room, ok := m.byEvent[eventID]; ok {
+			return room, nil
+		}
+	}
+
+	if len(m.rooms) == 1 {
+		return m.rooms[0], nil
+	}
+	return nil, quizerrors.ErrMissingParameter.WithDetails("Room code required").WithData("code_required", true)
+}
+
+// roomNotFound tells the participant page whether it should ask for another code
+func (m *Manager) roomNotFound() *quizerrors.QuizError {
+	return quizerrors.ErrRoomNotFound.WithData("code_required", m.CodeRequired())
+}
+
+func (m *Manager) dispatch(handle func(*Room, *gin.Context)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		room, err := m.Resolve(c)
+		if err != nil {
+			middleware.RespondError(c, err)
+			return
+		}
+		// Lets the participant page keep the room in its URL
+		c.Header("X-Room-Code", room.Code)
+		handle(room, c)
+	}
+}
+
+// Participant dispatches to a participant handler of the request's room
+func (m *Manager) Participant(h func(*handlers.ParticipantHandlers, *gin.Context)) gin.HandlerFunc {
+	return m.dispatch(func(room *Room, c *gin.Context) { h(room.Participant, c) })
+}
+
+// Admin dispatches to an admin handler of the request's room
+func (m *Manager) Admin(h func(*handlers.AdminHandlers, *gin.Context)) gin.HandlerFunc {
+	return m.dispatch(func(room *Room, c *gin.Context) { h(room.Admin, c) })
+}
+
+// WebSocket dispatches to a WebSocket or utility handler of the request's room
+func (m *Manager) WebSocket(h func(*handlers.WebSocketHandlers, *gin.Context)) gin.HandlerFunc {
+	return m.dispatch(func(room *Room, c *gin.Context) { h(room.WebSocket, c) })
+}
+
+// HealthCheck reports the health of the requested room, or of the first room
+func (m *Manager) HealthCheck(c *gin.Context) {
+	room, _ := m.Resolve(c)
+	if room == nil {
+		room = m.rooms[0]
+	}
+	room.WebSocket.HealthCheck(c)
+}
+
+// RoomInfo tells the participant page whether a room code is needed and
+// whether the given one exists
+func (m *Manager) RoomInfo(c *gin.Context) {
+	response := gin.H{"code_required": m.CodeRequired()}
+
+	if code := c.Query("room"); code != "" {
+		room := m.Get(code)
+		if room == nil {
+			middleware.RespondError(c, m.roomNotFound())
+			return
+		}
+		response["room"] = gin.H{"code": room.Code, "title": room.Config.Event.Title}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ListRooms returns every room with its progress for the admin room selector
+func (m *Manager) ListRooms(c *gin.Context) {
+	rooms := make([]gin.H, 0, len(m.rooms))
+	for _, room := range m.rooms {
+		rooms = append(rooms, gin.H{
+			"code":            room.Code,
+			"title":           room.Config.Event.Title,
+			"event_id":        room.Event.ID,
+			"state":           room.StateService.GetCurrentState(),
+			"question_number": room.StateService.GetQuestionNumber(),
+			"client_counts":   room.HubManager.GetClientCount(),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rooms": rooms})
+}
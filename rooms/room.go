@@ -0,0 +1,133 @@
+package rooms
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"quiz100/handlers"
+	"quiz100/models"
+	"quiz100/services"
+	"quiz100/websocket"
+)
+
+// Room is one event running on the server with its own config, state machine,
+// WebSocket hub and handlers. Repositories only see the data of the room's event.
+type Room struct {
+	Code         string
+	Config       *models.Config
+	Event        *models.Event
+	Hub          *websocket.Hub
+	HubManager   *websocket.HubManager
+	StateService *services.StateService
+	Participant  *handlers.ParticipantHandlers
+	Admin        *handlers.AdminHandlers
+	WebSocket    *handlers.WebSocketHandlers
+}
+
+// NewRoom resumes the latest unfinished event of the room (crash or restart
+// mid-event), otherwise creates a new one, and wires the room's services to it.
+// Session tokens and the rate limiter are shared by all rooms.
+func NewRoom(db *sql.DB, logger *models.QuizLogger, sessionTokens *models.SessionTokens, rateLimiter *models.RateLimiter, roomConfig models.RoomConfig) (*Room, error) {
+	config := roomConfig.Config
+	eventRepo := models.NewEventRepository(db)
+
+	event, err := eventRepo.GetUnfinishedEvent(roomConfig.Code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load current event: %v", err)
+	}
+	var progress *models.EventProgress
+	if event != nil {
+		progress, err = eventRepo.LoadProgress(event.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load event progress: %v", err)
+		}
+	} else {
+		event, err = eventRepo.CreateEvent(roomConfig.Code, config.Event.Title, config.Event.TeamMode, config.Event.TeamSize, config.Event.QrCode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create event: %v", err)
+		}
+	}
+
+	// Initialize repositories scoped to the room's event
+	userRepo := models.NewUserRepository(db, event.ID)
+	answerRepo := models.NewAnswerRepository(db, event.ID)
+	emojiReactionRepo := models.NewEmojiReactionRepository(db, event.ID)
+	teamRepo := models.NewTeamRepository(db, event.ID)
+	ratingRepo := models.NewRatingRepository(db, event.ID)
+	connectionRepo := models.NewConnectionRepository(db, event.ID)
+
+	teamAssignmentSvc := models.NewTeamAssignmentService(userRepo, teamRepo, ratingRepo, config)
+
+	// Each room has its own hub so broadcasts never reach another room
+	hub := websocket.NewHub(answerRepo)
+	hubManager := websocket.NewHubManager(hub)
+	for _, screen := range config.Screens {
+		hub.SetScreenChannel(models.NormalizeScreenChannel(screen.Channel), screen.Shows)
+	}
+
+	userRepoAdapter := websocket.NewUserRepositoryAdapter(userRepo)
+	pingManager := websocket.NewPingManager(hubManager, userRepoAdapter, config.Ping)
+	messageHandler := websocket.NewMessageHandler(pingManager)
+
+	go hub.Run()
+	go pingManager.Start()
+
+	stateManager := models.NewEventStateManager(config.Event.TeamMode, len(config.Questions), config.Flow)
+	stateService := services.NewStateService(stateManager, hubManager, hub, logger, config, userRepo, teamRepo, answerRepo)
+
+	// Answers and emoji are graded the same way over HTTP and WebSocket and
+	// counted as they arrive
+	answerTally := services.NewAnswerTally(userRepo, answerRepo, hubManager, logger, config)
+	answerService := services.NewAnswerService(userRepo, answerRepo, emojiReactionRepo, hubManager, stateService, answerTally, logger, config)
+	messageHandler.SetAnswerService(answerService)
+	messageHandler.SetRateLimiter(rateLimiter)
+
+	// Presence follows hub registrations, so it starts before anyone can connect
+	presenceService := services.NewPresenceService(userRepo, connectionRepo, hubManager, logger, config)
+	if err := presenceService.Start(hub); err != nil {
+		return nil, fmt.Errorf("failed to start presence tracking: %v", err)
+	}
+
+	participantHandlers := handlers.NewParticipantHandlers(userRepo, teamRepo, answerRepo, emojiReactionRepo, hubManager, stateService, answerService, sessionTokens, *logger, config)
+	adminHandlers := handlers.NewAdminHandlers(eventRepo, userRepo, answerRepo, teamRepo, teamAssignmentSvc, hubManager, stateService, *logger, config)
+	websocketHandlers := handlers.NewWebSocketHandlers(hub, hubManager, messageHandler, userRepo, teamRepo, eventRepo, *logger, config, stateService, sessionTokens, rateLimiter)
+	participantHandlers.SetPresence(presenceService)
+	adminHandlers.SetPresence(presenceService)
+	adminHandlers.SetAnswerTally(answerTally)
+	adminHandlers.SetPingManager(pingManager)
+	answerTally.SetPresence(presenceService)
+	stateManager.OnTransition(func(ctx context.Context, change models.StateChange) {
+		answerTally.Follow(change.To.State, change.To.QuestionNumber)
+	})
+
+	// Flow timers run actions through the admin handlers so they broadcast like manual ones
+	stateService.SetActionExecutor(adminHandlers.ExecuteAction)
+
+	if progress != nil {
+		if err := stateService.RestoreProgress(progress); err != nil {
+			return nil, fmt.Errorf("failed to restore event %d: %v", event.ID, err)
+		}
+		answerTally.Follow(progress.State, progress.QuestionNumber)
+		log.Printf("Room %s: resumed event %d in state %s (question %d)", roomConfig.Code, event.ID, progress.State, progress.QuestionNumber)
+	}
+	adminHandlers.SetCurrentEvent(event)
+	websocketHandlers.SetCurrentEvent(event)
+	stateService.AttachEvent(eventRepo, event.ID)
+
+	// Run the event from the run-sheet when [autopilot] is enabled
+	stateService.StartAutopilot()
+
+	return &Room{
+		Code:         roomConfig.Code,
+		Config:       config,
+		Event:        event,
+		Hub:          hub,
+		HubManager:   hubManager,
+		StateService: stateService,
+		Participant:  participantHandlers,
+		Admin:        adminHandlers,
+		WebSocket:    websocketHandlers,
+	}, nil
+}
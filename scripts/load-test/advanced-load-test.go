@@ -78,11 +78,11 @@ type UserProfile struct {
 }
 
 type UserSession struct {
-	ID        int
-	Nickname  string
-	SessionID string
-	Conn      *websocket.Conn
-	Profile   UserProfile
+	ID           int
+	Nickname     string
+	SessionToken string
+	Conn         *websocket.Conn
+	Profile      UserProfile
 
 	JoinTime       time.Time
 	MessagesSent   int64
@@ -353,7 +353,7 @@ func (alt *AdvancedLoadTester) joinAdvancedUser(user *UserSession) bool {
 		return false
 	}
 
-	user.SessionID = joinResp["session_id"].(string)
+	user.SessionToken = joinResp["session_token"].(string)
 	user.ResponseTimes = append(user.ResponseTimes, responseTime)
 	user.LastActivity = time.Now()
 
@@ -367,7 +367,7 @@ func (alt *AdvancedLoadTester) joinAdvancedUser(user *UserSession) bool {
 
 func (alt *AdvancedLoadTester) connectAdvancedWebSocket(user *UserSession) bool {
 	networkProfile := alt.NetworkProfiles[user.Profile.NetworkType]
-	wsURL := fmt.Sprintf("%s/ws/participant?session_id=%s", alt.WebSocketURL, user.SessionID)
+	wsURL := fmt.Sprintf("%s/ws/participant?session_token=%s", alt.WebSocketURL, user.SessionToken)
 
 	// ネットワーク遅延をシミュレート
 	if networkProfile.Latency > 0 {
@@ -563,7 +563,7 @@ func (alt *AdvancedLoadTester) sendAdvancedAnswer(user *UserSession) {
 	reqBody, _ := json.Marshal(answerReq)
 	req, _ := http.NewRequest("POST", alt.ServerURL+"/api/answer", bytes.NewBuffer(reqBody))
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Session-ID", user.SessionID)
+	req.Header.Set("X-Session-Token", user.SessionToken)
 
 	startTime := time.Now()
 	client := &http.Client{Timeout: 10 * time.Second}
@@ -603,7 +603,7 @@ func (alt *AdvancedLoadTester) sendAdvancedEmoji(user *UserSession) {
 	reqBody, _ := json.Marshal(emojiReq)
 	req, _ := http.NewRequest("POST", alt.ServerURL+"/api/emoji", bytes.NewBuffer(reqBody))
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Session-ID", user.SessionID)
+	req.Header.Set("X-Session-Token", user.SessionToken)
 
 	startTime := time.Now()
 	client := &http.Client{Timeout: 5 * time.Second}
@@ -44,7 +44,7 @@ type UserProfile struct {
 	Pattern         AnswerPattern
 	ExpectedScore   int // 期待される獲得点数
 	ActualScore     int // 実際の獲得点数
-	SessionID       string
+	SessionToken    string
 	Conn            *websocket.Conn
 	CurrentQuestion int
 	Answers         map[int]int // questionNumber -> answerIndex
@@ -278,13 +278,13 @@ func (slt *SequenceLoadTester) joinUser(user *UserProfile) bool {
 		return false
 	}
 
-	user.SessionID = joinResp["session_id"].(string)
+	user.SessionToken = joinResp["session_token"].(string)
 	return true
 }
 
 // connectWebSocket はWebSocketに接続
 func (slt *SequenceLoadTester) connectWebSocket(user *UserProfile) bool {
-	wsURL := fmt.Sprintf("%s/ws/participant?session_id=%s", WebSocketURL, user.SessionID)
+	wsURL := fmt.Sprintf("%s/ws/participant?session_token=%s", WebSocketURL, user.SessionToken)
 
 	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
 	if err != nil {
@@ -432,7 +432,7 @@ func (slt *SequenceLoadTester) sendAnswer(user *UserProfile, questionNumber, ans
 
 	req, _ := http.NewRequest("POST", ServerURL+"/api/answer", bytes.NewBuffer(reqBody))
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Session-ID", user.SessionID)
+	req.Header.Set("X-Session-Token", user.SessionToken)
 
 	client := &http.Client{Timeout: 10 * time.Second}
 	resp, err := client.Do(req)
@@ -465,7 +465,7 @@ func (slt *SequenceLoadTester) sendReactions() {
 
 		req, _ := http.NewRequest("POST", ServerURL+"/api/emoji", bytes.NewBuffer(reqBody))
 		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-Session-ID", user.SessionID)
+		req.Header.Set("X-Session-Token", user.SessionToken)
 
 		client := &http.Client{Timeout: 5 * time.Second}
 		resp, err := client.Do(req)
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"fmt"
+	quizerrors "quiz100/errors"
+	"quiz100/models"
+	"quiz100/validation"
+	"quiz100/websocket"
+)
+
+// AnswerService grades answers and relays emoji reactions. The HTTP endpoints
+// and the participant WebSocket both go through it.
+type AnswerService struct {
+	userRepo          *models.UserRepository
+	answerRepo        *models.AnswerRepository
+	emojiReactionRepo *models.EmojiReactionRepository
+	hubManager        *websocket.HubManager
+	stateService      *StateService
+	tally             *AnswerTally
+	validator         *validation.RequestValidator
+	logger            *models.QuizLogger
+	config            *models.Config
+}
+
+// NewAnswerService creates a new AnswerService instance
+func NewAnswerService(userRepo *models.UserRepository, answerRepo *models.AnswerRepository, emojiReactionRepo *models.EmojiReactionRepository, hubManager *websocket.HubManager, stateService *StateService, tally *AnswerTally, logger *models.QuizLogger, config *models.Config) *AnswerService {
+	return &AnswerService{
+		userRepo:          userRepo,
+		answerRepo:        answerRepo,
+		emojiReactionRepo: emojiReactionRepo,
+		hubManager:        hubManager,
+		stateService:      stateService,
+		tally:             tally,
+		validator:         validation.NewRequestValidator(config),
+		logger:            logger,
+		config:            config,
+	}
+}
+
+// SubmitAnswer records (or changes) the participant's answer to the current
+// question and updates their score. answerIndex is 1-based. Errors are
+// *errors.QuizError.
+func (as *AnswerService) SubmitAnswer(sessionID string, questionNumber, answerIndex int) (*websocket.AnswerResult, error) {
+	user, err := as.findUser(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if result := as.validator.ValidateAnswerRequest(user.ID, questionNumber, answerIndex, sessionID); !result.Valid {
+		return nil, result.GetCombinedError()
+	}
+
+	// 現在回答受付中かどうか判断する
+	currentState := as.stateService.GetCurrentState()
+	currentQuestion := as.stateService.GetQuestionNumber()
+	if currentState != models.StateQuestionActive && currentState != models.StateCountdownActive {
+		return nil, quizerrors.ErrStateNotAllowed.WithDetails("Not currently accepting answers")
+	}
+	if as.stateService.IsPaused() {
+		return nil, quizerrors.ErrStateNotAllowed.WithDetails("Event is paused")
+	}
+	if currentQuestion != questionNumber {
+		return nil, quizerrors.ErrInvalidQuestionNumber.WithDetails(fmt.Sprintf("Question %d is open, not %d", currentQuestion, questionNumber))
+	}
+
+	question := as.config.Questions[questionNumber-1]
+
+	// Both answer indexes are 1-based
+	isCorrect := answerIndex == question.Correct
+
+	saved, err := as.answerRepo.SaveAnswer(user.ID, questionNumber, answerIndex, isCorrect, question.Point)
+	if err != nil {
+		as.logger.LogError("saving answer", err)
+		return nil, quizerrors.ErrDatabaseError.WithCause(err)
+	}
+
+	as.logger.LogAnswer(user.Nickname, questionNumber, answerIndex, isCorrect)
+	as.tally.Record(user.ID, questionNumber, answerIndex)
+	if saved.ScoreChange != 0 {
+		as.logger.Info("User %s score updated: %d -> %d (change: %+d)", user.Nickname, saved.NewScore-saved.ScoreChange, saved.NewScore, saved.ScoreChange)
+	}
+
+	// Broadcast answer received notification
+	answerData := websocket.AnswerReceivedData{
+		UserID:         user.ID,
+		Nickname:       user.Nickname,
+		QuestionNumber: questionNumber,
+		Answer:         answerIndex,
+	}
+	if err := as.hubManager.BroadcastAnswerReceived(answerData); err != nil {
+		as.logger.LogError("broadcasting answer received", err)
+	}
+
+	return &websocket.AnswerResult{
+		AnswerIndex: answerIndex,
+		IsCorrect:   isCorrect,
+		NewScore:    saved.NewScore,
+		ScoreChange: saved.ScoreChange,
+	}, nil
+}
+
+// ForgetUser takes the answers of a participant who left out of the live counts
+func (as *AnswerService) ForgetUser(userID int) {
+	as.tally.Forget(userID)
+}
+
+// SendEmoji shows the participant's emoji reaction on the screen and saves it.
+// Errors are *errors.QuizError.
+func (as *AnswerService) SendEmoji(sessionID, emoji string) error {
+	user, err := as.findUser(sessionID)
+	if err != nil {
+		return err
+	}
+
+	if result := as.validator.ValidateEmojiRequest(user.ID, sessionID, emoji); !result.Valid {
+		return result.GetCombinedError()
+	}
+
+	as.logger.LogEmojiReaction(user.Nickname, emoji)
+
+	// Broadcast emoji reaction
+	emojiData := websocket.EmojiData{
+		Nickname: user.Nickname,
+		Emoji:    emoji,
+	}
+	if err := as.hubManager.BroadcastEmojiReaction(emojiData); err != nil {
+		as.logger.LogError("broadcasting emoji reaction", err)
+	}
+
+	if err := as.emojiReactionRepo.CreateReaction(user.ID, emoji); err != nil {
+		as.logger.LogError("Emoji create failed", err)
+	}
+
+	return nil
+}
+
+// findUser returns the participant of a session
+func (as *AnswerService) findUser(sessionID string) (*models.User, error) {
+	user, err := as.userRepo.GetUserBySessionID(sessionID)
+	if err != nil {
+		as.logger.LogError("getting user by session", err)
+		return nil, quizerrors.ErrDatabaseError.WithCause(err)
+	}
+	if user == nil {
+		return nil, quizerrors.ErrUserNotFound
+	}
+	return user, nil
+}
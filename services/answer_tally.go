@@ -0,0 +1,221 @@
+package services
+
+import (
+	"fmt"
+	"quiz100/models"
+	"quiz100/websocket"
+	"sync"
+	"time"
+)
+
+// AnswerTally counts the answers to the current question as they arrive, so
+// the live counts and the answer stats never query every participant. Counts
+// are pushed to admins and screens at most once per configured interval.
+type AnswerTally struct {
+	userRepo   *models.UserRepository
+	answerRepo *models.AnswerRepository
+	hubManager *websocket.HubManager
+	logger     *models.QuizLogger
+	config     *models.Config
+	presence   *PresenceService
+	interval   time.Duration
+
+	mu             sync.Mutex
+	questionNumber int         // Question the tally holds; 0 before the first answer
+	answers        map[int]int // User ID -> 1-based answer index
+	counts         []int       // Answers per choice
+	open           bool        // Answers are accepted; counts are only sent while they are
+	scheduled      bool        // An update is waiting for the interval
+	lastSent       time.Time
+}
+
+// NewAnswerTally creates a new AnswerTally instance
+func NewAnswerTally(userRepo *models.UserRepository, answerRepo *models.AnswerRepository, hubManager *websocket.HubManager, logger *models.QuizLogger, config *models.Config) *AnswerTally {
+	return &AnswerTally{
+		userRepo:   userRepo,
+		answerRepo: answerRepo,
+		hubManager: hubManager,
+		logger:     logger,
+		config:     config,
+		interval:   config.LiveAnswers.UpdateInterval(),
+		answers:    make(map[int]int),
+	}
+}
+
+// SetPresence sets the presence service; participants who are not online
+// and did not answer are left out of the total
+func (t *AnswerTally) SetPresence(presence *PresenceService) {
+	t.presence = presence
+}
+
+// Open starts streaming the counts of a question that just opened
+func (t *AnswerTally) Open(questionNumber int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.load(questionNumber); err != nil {
+		t.logger.LogError("loading answer counts", err)
+		return
+	}
+	t.open = true
+	t.schedule()
+}
+
+// Follow keeps the tally open while the event accepts answers (also after a
+// jump or a restart) and closes it when answers close
+func (t *AnswerTally) Follow(state models.EventState, questionNumber int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if state != models.StateQuestionActive && state != models.StateCountdownActive {
+		t.open = false
+		return
+	}
+	if err := t.load(questionNumber); err != nil {
+		t.logger.LogError("loading answer counts", err)
+		t.open = false
+		return
+	}
+	t.open = true
+}
+
+// Record counts a new or changed answer of a participant
+func (t *AnswerTally) Record(userID, questionNumber, answerIndex int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.load(questionNumber); err != nil {
+		t.logger.LogError("loading answer counts", err)
+		return
+	}
+	t.set(userID, answerIndex)
+	if t.open {
+		t.schedule()
+	}
+}
+
+// Forget removes the answer of a participant who left the event
+func (t *AnswerTally) Forget(userID int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.answers[userID]; !ok {
+		return
+	}
+	t.set(userID, 0)
+	if t.open {
+		t.schedule()
+	}
+}
+
+// Counts returns the counts of a question with the distribution
+func (t *AnswerTally) Counts(questionNumber int) (websocket.AnswerCountData, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.load(questionNumber); err != nil {
+		return websocket.AnswerCountData{}, err
+	}
+	return t.snapshot(), nil
+}
+
+// load reads the answers to a question once, when the tally moves to it
+// (first answer, going back, restart); the caller must hold mu
+func (t *AnswerTally) load(questionNumber int) error {
+	if questionNumber == t.questionNumber {
+		return nil
+	}
+	if questionNumber < 1 || questionNumber > len(t.config.Questions) {
+		return fmt.Errorf("invalid question number %d", questionNumber)
+	}
+
+	answers, err := t.answerRepo.GetAnswersByQuestion(questionNumber)
+	if err != nil {
+		return fmt.Errorf("failed to load answers to question %d: %v", questionNumber, err)
+	}
+
+	t.questionNumber = questionNumber
+	t.answers = make(map[int]int, len(answers))
+	t.counts = make([]int, len(t.config.Questions[questionNumber-1].Choices))
+	for _, answer := range answers {
+		t.set(answer.UserID, answer.AnswerIndex)
+	}
+	return nil
+}
+
+// set moves a participant's answer to another choice, or removes it when
+// answerIndex is 0; the caller must hold mu
+func (t *AnswerTally) set(userID, answerIndex int) {
+	if previous, ok := t.answers[userID]; ok {
+		if previous >= 1 && previous <= len(t.counts) {
+			t.counts[previous-1]--
+		}
+		delete(t.answers, userID)
+	}
+	if answerIndex == 0 {
+		return
+	}
+	t.answers[userID] = answerIndex
+	if answerIndex <= len(t.counts) {
+		t.counts[answerIndex-1]++
+	}
+}
+
+// snapshot copies the counts; the caller must hold mu
+func (t *AnswerTally) snapshot() websocket.AnswerCountData {
+	return websocket.AnswerCountData{
+		QuestionNumber:    t.questionNumber,
+		TotalParticipants: t.participants(),
+		AnsweredCount:     len(t.answers),
+		ChoicesCounts:     append([]int(nil), t.counts...),
+	}
+}
+
+// participants counts the online participants and those who answered, or
+// every participant without presence tracking; the caller must hold mu
+func (t *AnswerTally) participants() int {
+	if t.presence == nil {
+		users, err := t.userRepo.GetAllUsers()
+		if err != nil {
+			t.logger.LogError("counting participants", err)
+		}
+		return max(len(users), len(t.answers))
+	}
+
+	online := t.presence.OnlineUserIDs()
+	total := len(online)
+	for userID := range t.answers {
+		if !online[userID] {
+			total++
+		}
+	}
+	return total
+}
+
+// schedule sends the counts now, or once the interval since the last update
+// has passed; the caller must hold mu
+func (t *AnswerTally) schedule() {
+	if t.scheduled {
+		return // The pending update will carry this change too
+	}
+	t.scheduled = true
+	time.AfterFunc(max(0, t.interval-time.Since(t.lastSent)), t.send)
+}
+
+func (t *AnswerTally) send() {
+	t.mu.Lock()
+	t.scheduled = false
+	if !t.open {
+		t.mu.Unlock()
+		return // Answers closed while the update was waiting
+	}
+	t.lastSent = time.Now()
+	adminData := t.snapshot()
+	t.mu.Unlock()
+
+	screenData := adminData
+	if !t.config.LiveAnswers.ShowsDistribution(true) {
+		adminData.ChoicesCounts = nil
+	}
+	if !t.config.LiveAnswers.ShowsDistribution(false) {
+		screenData.ChoicesCounts = nil
+	}
+	if err := t.hubManager.BroadcastAnswerCount(adminData, screenData); err != nil {
+		t.logger.LogError("broadcasting answer count", err)
+	}
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"quiz100/models"
+	"quiz100/websocket"
+	"testing"
+	"time"
+)
+
+func setupTally(t *testing.T) *AnswerTally {
+	db, eventID, logger := setupEvent(t)
+	answerRepo := models.NewAnswerRepository(db, eventID)
+	hub := websocket.NewHub(answerRepo)
+	go hub.Run()
+
+	config := &models.Config{
+		Questions: []models.Question{
+			{Text: "Q1", Choices: []string{"A", "B", "C", "D"}, Correct: 1, Point: 10},
+		},
+		// Once the first update is sent, the next one waits, so a scheduled update stays visible
+		LiveAnswers: models.LiveAnswersConfig{Interval: "1h"},
+	}
+	return NewAnswerTally(models.NewUserRepository(db, eventID), answerRepo, websocket.NewHubManager(hub), logger, config)
+}
+
+// isScheduled reports whether an update is waiting to be sent
+func isScheduled(tally *AnswerTally) bool {
+	tally.mu.Lock()
+	defer tally.mu.Unlock()
+	return tally.scheduled
+}
+
+func TestAnswerTallySendsOnlyWhileOpen(t *testing.T) {
+	tally := setupTally(t)
+
+	tally.Open(1)
+	deadline := time.Now().Add(2 * time.Second)
+	for isScheduled(tally) {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the counts to be sent when the question opens")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// After answers close, changes are counted but not broadcast
+	tally.Follow(models.StateAnswerStats, 1)
+	tally.Record(1, 1, 2)
+	tally.Forget(1)
+	tally.Record(2, 1, 3)
+	if isScheduled(tally) {
+		t.Error("Expected no update while answers are closed")
+	}
+	counts, err := tally.Counts(1)
+	if err != nil {
+		t.Fatalf("Failed to get counts: %v", err)
+	}
+	if counts.AnsweredCount != 1 || counts.ChoicesCounts[2] != 1 {
+		t.Errorf("Expected one answer for choice 3, got %+v", counts)
+	}
+
+	// Going back to the question opens the tally again
+	tally.Follow(models.StateQuestionActive, 1)
+	tally.Forget(2)
+	if !isScheduled(tally) {
+		t.Error("Expected an update once answers are accepted again")
+	}
+}
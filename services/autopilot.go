@@ -0,0 +1,316 @@
+package services
+
+import (
+	"fmt"
+	"quiz100/models"
+	"time"
+)
+
+// AutopilotStatus describes what the autopilot will do next
+type AutopilotStatus struct {
+	Enabled     bool              `json:"enabled"` // A run-sheet is configured
+	Active      bool              `json:"active"`  // The autopilot is driving (not in manual mode)
+	State       models.EventState `json:"state"`
+	NextAction  string            `json:"next_action,omitempty"`
+	RemainingMs int64             `json:"remaining_ms,omitempty"`
+}
+
+// StartAutopilot starts the scheduler goroutine when the run-sheet is enabled
+func (ss *StateService) StartAutopilot() {
+	if ss.config == nil || !ss.config.Autopilot.Enabled {
+		return
+	}
+
+	ss.timerMu.Lock()
+	if ss.autopilotWake != nil {
+		ss.timerMu.Unlock()
+		return
+	}
+	ss.autopilotWake = make(chan struct{}, 1)
+	ss.autopilotOn = true
+	ss.timerMu.Unlock()
+
+	ss.logger.LogAlert("Autopilot started")
+	go ss.runAutopilot()
+}
+
+// runAutopilot waits for the current run-sheet step and advances the event.
+// Any state change, pause or admin intervention wakes it up to recompute the wait.
+func (ss *StateService) runAutopilot() {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+
+	for {
+		if wait, ok := ss.autopilotWait(); ok {
+			timer.Reset(wait)
+		} else {
+			timer.Stop()
+		}
+
+		select {
+		case <-ss.autopilotWake:
+		case <-timer.C:
+			ss.autopilotStep()
+		}
+	}
+}
+
+// autopilotHeld reports whether the run-sheet clock is stopped by a pause or an
+// announcement slide; timerMu must be held
+func (ss *StateService) autopilotHeld() bool {
+	return ss.paused || ss.slide != nil
+}
+
+// holdAutopilot keeps the time left on the current step; call with timerMu held
+// before the pause or slide is set
+func (ss *StateService) holdAutopilot() {
+	if !ss.autopilotHeld() && ss.autopilotScheduled {
+		ss.autopilotRemaining = max(time.Until(ss.autopilotDeadline), 0)
+	}
+}
+
+// releaseAutopilot continues the current step with the time that was left; call
+// with timerMu held after the pause or slide is cleared
+func (ss *StateService) releaseAutopilot() {
+	if !ss.autopilotHeld() && ss.autopilotScheduled {
+		ss.autopilotDeadline = time.Now().Add(ss.autopilotRemaining)
+	}
+}
+
+// wakeAutopilot makes the scheduler look at the current state again
+func (ss *StateService) wakeAutopilot() {
+	ss.timerMu.Lock()
+	wake := ss.autopilotWake
+	ss.timerMu.Unlock()
+
+	if wake == nil {
+		return
+	}
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// autopilotWait returns how long the scheduler sleeps before the next step
+func (ss *StateService) autopilotWait() (time.Duration, bool) {
+	ss.timerMu.Lock()
+	defer ss.timerMu.Unlock()
+
+	if !ss.autopilotOn || ss.autopilotHeld() {
+		return 0, false
+	}
+
+	current := ss.stateManager.GetSnapshot()
+	if current != ss.autopilotVisit || !ss.autopilotScheduled {
+		// Entered a new state (or question): start its run-sheet step
+		ss.autopilotVisit = current
+		ss.autopilotLoopDone = false
+		duration, ok := ss.autopilotDuration(current.State)
+		ss.autopilotScheduled = ok
+		ss.autopilotDeadline = time.Now().Add(duration)
+	}
+
+	if !ss.autopilotScheduled {
+		return 0, false
+	}
+	if selfLoop, _ := ss.autopilotActions(); selfLoop != "" && !ss.autopilotLoopDone {
+		return 0, true
+	}
+	return max(time.Until(ss.autopilotDeadline), 0), true
+}
+
+// autopilotStep runs the self-loop action of a new visit right away, and the
+// action that moves on once the step's time is up
+func (ss *StateService) autopilotStep() {
+	ss.timerMu.Lock()
+	current := ss.stateManager.GetSnapshot()
+	if !ss.autopilotOn || ss.autopilotHeld() || !ss.autopilotScheduled || current != ss.autopilotVisit {
+		ss.timerMu.Unlock()
+		return
+	}
+
+	selfLoop, forward := ss.autopilotActions()
+	action := ""
+	if selfLoop != "" && !ss.autopilotLoopDone {
+		ss.autopilotLoopDone = true
+		action = selfLoop
+	} else if !time.Now().Before(ss.autopilotDeadline) {
+		// Retried after a full step if the action fails
+		ss.autopilotScheduled = false
+		action = forward
+	}
+	ss.timerMu.Unlock()
+
+	if action == "" {
+		return
+	}
+
+	ss.logger.LogAlert(fmt.Sprintf("Autopilot in state %s: %s", current.State, action))
+	ss.runAutomaticAction(action, "autopilot")
+}
+
+// autopilotDuration returns how long the run-sheet keeps the event in a state.
+// States with a flow timer are left to the timer.
+func (ss *StateService) autopilotDuration(state models.EventState) (time.Duration, bool) {
+	flow := ss.stateManager.GetFlow()
+	if _, _, hasTimer := flow.TimerFor(state); hasTimer {
+		return 0, false
+	}
+
+	duration, ok := ss.config.Autopilot.DurationFor(state)
+	if ok && state == models.StateQuestionActive {
+		// The final countdown is part of the time limit
+		if _, countdown, hasTimer := flow.TimerFor(models.StateCountdownActive); hasTimer {
+			duration = max(duration-countdown, 0)
+		}
+	}
+	return duration, ok
+}
+
+// autopilotActions picks from the enabled flow actions: one that stays in the
+// state (run once on entering, e.g. show_answer_stats) and the first one in flow
+// order that moves on. Re-opening answers is never chosen automatically.
+func (ss *StateService) autopilotActions() (selfLoop, forward string) {
+	current := ss.stateManager.GetCurrentState()
+	for _, available := range ss.stateManager.GetAvailableActionDetails() {
+		switch available.Action {
+		case models.ActionGoBack, models.ActionReopenAnswers:
+			continue
+		}
+
+		if available.To == current {
+			if selfLoop == "" {
+				selfLoop = available.Action
+			}
+		} else if forward == "" {
+			forward = available.Action
+		}
+	}
+	return selfLoop, forward
+}
+
+// AutopilotSkip advances to the next step now
+func (ss *StateService) AutopilotSkip() error {
+	ss.timerMu.Lock()
+	if !ss.autopilotOn || ss.autopilotHeld() {
+		ss.timerMu.Unlock()
+		return fmt.Errorf("autopilot is not running")
+	}
+	ss.autopilotVisit = ss.stateManager.GetSnapshot()
+	ss.autopilotLoopDone = true
+	ss.autopilotScheduled = true
+	ss.autopilotDeadline = time.Now()
+	ss.timerMu.Unlock()
+
+	ss.logger.LogAlert("Autopilot step skipped by admin")
+	ss.wakeAutopilot()
+	return nil
+}
+
+// AutopilotExtend gives the current step more time
+func (ss *StateService) AutopilotExtend() (time.Duration, error) {
+	step := ss.config.Autopilot.ExtendStep()
+
+	ss.timerMu.Lock()
+	if !ss.autopilotOn || !ss.autopilotScheduled {
+		ss.timerMu.Unlock()
+		return 0, fmt.Errorf("no autopilot step to extend")
+	}
+	if ss.autopilotHeld() {
+		ss.autopilotRemaining += step
+	} else {
+		ss.autopilotDeadline = ss.autopilotDeadline.Add(step)
+	}
+	ss.timerMu.Unlock()
+
+	ss.logger.LogAlert(fmt.Sprintf("Autopilot step extended by %s", step))
+	ss.wakeAutopilot()
+	return step, nil
+}
+
+// SetAutopilotActive switches between autopilot and manual control
+func (ss *StateService) SetAutopilotActive(active bool) error {
+	ss.timerMu.Lock()
+	if ss.autopilotWake == nil {
+		ss.timerMu.Unlock()
+		return fmt.Errorf("autopilot is not enabled")
+	}
+	ss.autopilotOn = active
+	// Start the current state's step from the beginning
+	ss.autopilotScheduled = false
+	ss.timerMu.Unlock()
+
+	if active {
+		ss.logger.LogAlert("Autopilot resumed control")
+	} else {
+		ss.logger.LogAlert("Admin took manual control from the autopilot")
+	}
+	ss.wakeAutopilot()
+	return nil
+}
+
+// GetAutopilotStatus returns the mode and the next automatic action
+func (ss *StateService) GetAutopilotStatus() *AutopilotStatus {
+	ss.timerMu.Lock()
+	defer ss.timerMu.Unlock()
+
+	status := &AutopilotStatus{
+		Enabled: ss.autopilotWake != nil,
+		Active:  ss.autopilotOn,
+		State:   ss.stateManager.GetCurrentState(),
+	}
+	if !ss.autopilotOn || !ss.autopilotScheduled {
+		return status
+	}
+
+	selfLoop, forward := ss.autopilotActions()
+	if selfLoop != "" && !ss.autopilotLoopDone {
+		status.NextAction = selfLoop
+		return status
+	}
+	status.NextAction = forward
+	if ss.autopilotHeld() {
+		status.RemainingMs = ss.autopilotRemaining.Milliseconds()
+	} else {
+		status.RemainingMs = max(time.Until(ss.autopilotDeadline), 0).Milliseconds()
+	}
+	return status
+}
+
+// autopilotActionDetails returns the admin buttons for steering the autopilot
+func (ss *StateService) autopilotActionDetails() []models.AvailableAction {
+	status := ss.GetAutopilotStatus()
+	if !status.Enabled {
+		return nil
+	}
+
+	if !status.Active {
+		return []models.AvailableAction{{
+			Action: models.ActionAutopilotAuto,
+			Label:  models.GetActionLabel(models.ActionAutopilotAuto),
+			To:     status.State,
+		}}
+	}
+
+	var actions []models.AvailableAction
+	if status.NextAction != "" {
+		actions = append(actions,
+			models.AvailableAction{
+				Action: models.ActionAutopilotSkip,
+				Label:  models.GetActionLabel(models.ActionAutopilotSkip),
+				To:     status.State,
+			},
+			models.AvailableAction{
+				Action: models.ActionAutopilotExtend,
+				Label:  fmt.Sprintf("%s (+%s)", models.GetActionLabel(models.ActionAutopilotExtend), ss.config.Autopilot.ExtendStep()),
+				To:     status.State,
+			},
+		)
+	}
+	return append(actions, models.AvailableAction{
+		Action: models.ActionAutopilotManual,
+		Label:  models.GetActionLabel(models.ActionAutopilotManual),
+		To:     status.State,
+	})
+}
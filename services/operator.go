@@ -0,0 +1,152 @@
+package services
+
+import (
+	"quiz100/models"
+	"quiz100/websocket"
+	"sync"
+	"time"
+)
+
+// idempotencyTTL is how long the result of an action is kept for retries
+const idempotencyTTL = 10 * time.Minute
+
+// Operator identifies the admin console that sent a request
+type Operator = websocket.OperatorInfo
+
+// OperatorLock is held by the console that may drive the event
+type OperatorLock struct {
+	Holder Operator  `json:"holder"`
+	Since  time.Time `json:"since"`
+}
+
+// ActionResult is the response to an action, kept to answer retries
+type ActionResult struct {
+	Action string
+	Status int
+	Body   any
+	at     time.Time
+}
+
+// OperatorService coordinates several admin consoles: the optional operator
+// lock, the results of actions by idempotency key, and telling the other
+// consoles what an operator did
+type OperatorService struct {
+	hubManager *websocket.HubManager
+
+	mu      sync.Mutex
+	lock    *OperatorLock
+	results map[string]ActionResult
+}
+
+// NewOperatorService creates a new OperatorService instance
+func NewOperatorService(hubManager *websocket.HubManager) *OperatorService {
+	return &OperatorService{
+		hubManager: hubManager,
+		results:    make(map[string]ActionResult),
+	}
+}
+
+// Lock returns the operator lock, or nil when any console may drive the event
+func (ops *OperatorService) Lock() *OperatorLock {
+	ops.mu.Lock()
+	defer ops.mu.Unlock()
+	if ops.lock == nil {
+		return nil
+	}
+	lock := *ops.lock
+	return &lock
+}
+
+// Allows reports whether the operator may drive the event. Otherwise it
+// returns the lock held by another console.
+func (ops *OperatorService) Allows(operator Operator) (*OperatorLock, bool) {
+	lock := ops.Lock()
+	if lock == nil || (operator.ID != "" && lock.Holder.ID == operator.ID) {
+		return nil, true
+	}
+	return lock, false
+}
+
+// Acquire gives the lock to the operator. A lock held by another console is
+// only taken over with force; then the current lock is returned with false.
+func (ops *OperatorService) Acquire(operator Operator, force bool) (*OperatorLock, bool, error) {
+	ops.mu.Lock()
+	if ops.lock != nil && ops.lock.Holder.ID != operator.ID && !force {
+		lock := *ops.lock
+		ops.mu.Unlock()
+		return &lock, false, nil
+	}
+	if ops.lock == nil || ops.lock.Holder.ID != operator.ID {
+		ops.lock = &OperatorLock{Holder: operator, Since: time.Now()}
+	} else {
+		ops.lock.Holder.Name = operator.Name // Renamed console keeps its lock
+	}
+	lock := *ops.lock
+	ops.mu.Unlock()
+
+	return &lock, true, ops.announceLock(&lock, operator)
+}
+
+// Release frees the lock held by the operator, or by anyone with force
+func (ops *OperatorService) Release(operator Operator, force bool) (*OperatorLock, bool, error) {
+	ops.mu.Lock()
+	if ops.lock == nil {
+		ops.mu.Unlock()
+		return nil, true, nil
+	}
+	if ops.lock.Holder.ID != operator.ID && !force {
+		lock := *ops.lock
+		ops.mu.Unlock()
+		return &lock, false, nil
+	}
+	ops.lock = nil
+	ops.mu.Unlock()
+
+	return nil, true, ops.announceLock(nil, operator)
+}
+
+func (ops *OperatorService) announceLock(lock *OperatorLock, by Operator) error {
+	data := websocket.OperatorLockData{By: by}
+	if lock != nil {
+		data.Holder = &lock.Holder
+		data.Since = &lock.Since
+	}
+	return ops.hubManager.BroadcastOperatorLock(data)
+}
+
+// Recall returns the result kept for an idempotency key
+func (ops *OperatorService) Recall(key string) (ActionResult, bool) {
+	ops.mu.Lock()
+	defer ops.mu.Unlock()
+	result, ok := ops.results[key]
+	if !ok || time.Since(result.at) > idempotencyTTL {
+		return ActionResult{}, false
+	}
+	return result, true
+}
+
+// Remember keeps the result of an action for retries with the same key
+func (ops *OperatorService) Remember(key string, result ActionResult) {
+	ops.mu.Lock()
+	defer ops.mu.Unlock()
+	now := time.Now()
+	for k, kept := range ops.results {
+		if now.Sub(kept.at) > idempotencyTTL {
+			delete(ops.results, k)
+		}
+	}
+	result.at = now
+	ops.results[key] = result
+}
+
+// AnnounceAction tells the admin consoles which operator ran an action
+func (ops *OperatorService) AnnounceAction(operator Operator, action string, from, to models.EventState, questionNumber int) error {
+	return ops.hubManager.BroadcastAdminAction(websocket.AdminActionData{
+		Action:         action,
+		Operator:       operator,
+		FromState:      from,
+		State:          to,
+		QuestionNumber: questionNumber,
+		Time:           time.Now(),
+	})
+}
@@ -0,0 +1,344 @@
+package services
+
+import (
+	"fmt"
+	"quiz100/models"
+	"quiz100/websocket"
+	"sort"
+	"sync"
+	"time"
+)
+
+// connectionHistoryLimit is how many connections of a user the admin sees
+const connectionHistoryLimit = 50
+
+// PresenceService tracks which participants follow the event. It listens to
+// clients registering with and leaving the hub; a participant whose last
+// connection drops stays online for the grace period, so reloads and network
+// switches go unnoticed, then counts as away and later as offline. The
+// users.connected flag that team assignment reads follows the online status.
+type PresenceService struct {
+	userRepo       *models.UserRepository
+	connectionRepo *models.ConnectionRepository
+	hubManager     *websocket.HubManager
+	logger         *models.QuizLogger
+	gracePeriod    time.Duration
+	offlineAfter   time.Duration
+
+	// Hub events and timer expiries wait here for the worker, so the hub's
+	// run loop never waits for the database
+	queueMu sync.Mutex
+	queue   []presenceUpdate
+	wake    chan struct{}
+
+	mu    sync.RWMutex // Guards users; written by the worker and by Joined (the join handler)
+	users map[int]*userPresence
+}
+
+// presenceUpdate is a connection event, or an expired timer when event is nil
+type presenceUpdate struct {
+	event      *websocket.ConnectionEvent
+	userID     int
+	generation int
+}
+
+// userPresence is the presence of one participant
+type userPresence struct {
+	status      models.PresenceStatus
+	since       time.Time
+	connections map[*websocket.Client]int64 // Open connection -> connection log ID
+	generation  int                         // Bumped to cancel the pending timer
+}
+
+// UserPresence is a participant's presence as the admin sees it
+type UserPresence struct {
+	UserID           int                   `json:"user_id"`
+	Nickname         string                `json:"nickname"`
+	TeamID           *int                  `json:"team_id"`
+	Status           models.PresenceStatus `json:"status"`
+	Since            *time.Time            `json:"since,omitempty"`
+	Connections      int                   `json:"connections"`       // Open right now
+	TotalConnections int                   `json:"total_connections"` // Since joining, including reconnects
+}
+
+// NewPresenceService creates a new PresenceService instance
+func NewPresenceService(userRepo *models.UserRepository, connectionRepo *models.ConnectionRepository, hubManager *websocket.HubManager, logger *models.QuizLogger, config *models.Config) *PresenceService {
+	return &PresenceService{
+		userRepo:       userRepo,
+		connectionRepo: connectionRepo,
+		hubManager:     hubManager,
+		logger:         logger,
+		gracePeriod:    config.Presence.Grace(),
+		offlineAfter:   config.Presence.OfflineDelay(),
+		wake:           make(chan struct{}, 1),
+		users:          make(map[int]*userPresence),
+	}
+}
+
+// Start restores the presence left by a restart and begins listening to the
+// hub. Participants marked connected get the grace period to reconnect.
+func (ps *PresenceService) Start(hub *websocket.Hub) error {
+	now := time.Now()
+	if err := ps.connectionRepo.CloseOpenConnections(now); err != nil {
+		return fmt.Errorf("failed to close stale connections: %v", err)
+	}
+
+	users, err := ps.userRepo.GetAllUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %v", err)
+	}
+	for _, user := range users {
+		if user.Connected {
+			ps.expectConnection(user.ID, now)
+		}
+	}
+
+	hub.AddConnectionListener(ps.onConnection)
+	go ps.run()
+	return nil
+}
+
+// Joined gives a participant who just joined the grace period to connect
+func (ps *PresenceService) Joined(userID int) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if p, ok := ps.users[userID]; ok && len(p.connections) > 0 {
+		return
+	}
+	ps.expectConnection(userID, time.Now())
+}
+
+// expectConnection marks a participant online without a connection and
+// starts the grace timer; the caller must hold mu (or be the only user)
+func (ps *PresenceService) expectConnection(userID int, now time.Time) {
+	p := ps.presenceOf(userID)
+	p.status = models.PresenceOnline
+	p.since = now
+	ps.schedule(userID, p, ps.gracePeriod)
+}
+
+// onConnection queues a hub event; it runs in the hub's run loop
+func (ps *PresenceService) onConnection(event websocket.ConnectionEvent) {
+	if event.Client.Type != websocket.ClientTypeParticipant || event.Client.UserID == 0 {
+		return
+	}
+	ps.enqueue(presenceUpdate{event: &event, userID: event.Client.UserID})
+}
+
+func (ps *PresenceService) enqueue(update presenceUpdate) {
+	ps.queueMu.Lock()
+	ps.queue = append(ps.queue, update)
+	ps.queueMu.Unlock()
+
+	select {
+	case ps.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run applies queued updates one at a time
+func (ps *PresenceService) run() {
+	for range ps.wake {
+		ps.queueMu.Lock()
+		updates := ps.queue
+		ps.queue = nil
+		ps.queueMu.Unlock()
+
+		for _, update := range updates {
+			var changed *websocket.PresenceData
+			if update.event != nil {
+				changed = ps.applyConnection(*update.event)
+			} else {
+				changed = ps.applyTimeout(update.userID, update.generation)
+			}
+			if changed != nil {
+				ps.announce(*changed)
+			}
+		}
+	}
+}
+
+// applyConnection records a connection opening or closing and returns the
+// presence change to announce, if any
+func (ps *PresenceService) applyConnection(event websocket.ConnectionEvent) *websocket.PresenceData {
+	client := event.Client
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	p := ps.presenceOf(client.UserID)
+
+	if event.Connected {
+		id, err := ps.connectionRepo.OpenConnection(client.UserID, string(client.Transport), event.Time)
+		if err != nil {
+			ps.logger.LogError("recording connection", err)
+		}
+		p.connections[client] = id
+		p.generation++ // Cancel the grace or offline timer
+
+		if p.status == models.PresenceOnline {
+			return nil // Connected in time, or another tab
+		}
+		return ps.setStatus(client.UserID, p, models.PresenceOnline, event.Time)
+	}
+
+	id, ok := p.connections[client]
+	if !ok {
+		return nil
+	}
+	delete(p.connections, client)
+	if err := ps.connectionRepo.CloseConnection(id, event.Time); err != nil {
+		ps.logger.LogError("recording disconnection", err)
+	}
+	if len(p.connections) == 0 {
+		ps.schedule(client.UserID, p, ps.gracePeriod)
+	}
+	return nil
+}
+
+// applyTimeout moves a disconnected participant from online to away, or
+// from away to offline
+func (ps *PresenceService) applyTimeout(userID int, generation int) *websocket.PresenceData {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	p, ok := ps.users[userID]
+	if !ok || p.generation != generation || len(p.connections) > 0 {
+		return nil // Reconnected or rescheduled in the meantime
+	}
+
+	switch p.status {
+	case models.PresenceOnline:
+		ps.schedule(userID, p, ps.offlineAfter)
+		return ps.setStatus(userID, p, models.PresenceAway, time.Now())
+	case models.PresenceAway:
+		return ps.setStatus(userID, p, models.PresenceOffline, time.Now())
+	}
+	return nil
+}
+
+// setStatus changes the status and the connected flag; the caller must hold mu
+func (ps *PresenceService) setStatus(userID int, p *userPresence, status models.PresenceStatus, now time.Time) *websocket.PresenceData {
+	wasOnline := p.status == models.PresenceOnline
+	p.status = status
+	p.since = now
+
+	if isOnline := status == models.PresenceOnline; isOnline != wasOnline {
+		if err := ps.userRepo.SetUserConnected(userID, isOnline); err != nil {
+			ps.logger.LogError("updating user connection", err)
+		}
+	}
+
+	return &websocket.PresenceData{
+		UserID:      userID,
+		Status:      status,
+		Since:       now,
+		Connections: len(p.connections),
+	}
+}
+
+// announce logs a presence change and tells the admins
+func (ps *PresenceService) announce(data websocket.PresenceData) {
+	user, err := ps.userRepo.GetUserByID(data.UserID)
+	if err != nil || user == nil {
+		// Removed by a session reset; nothing to track any more
+		ps.mu.Lock()
+		delete(ps.users, data.UserID)
+		ps.mu.Unlock()
+		return
+	}
+	data.Nickname = user.Nickname
+
+	ps.logger.Info("Presence: %s is %s", user.Nickname, data.Status)
+	if err := ps.hubManager.BroadcastPresence(data); err != nil {
+		ps.logger.LogError("broadcasting presence", err)
+	}
+}
+
+// schedule arms the next timeout of a participant; the caller must hold mu
+func (ps *PresenceService) schedule(userID int, p *userPresence, after time.Duration) {
+	p.generation++
+	generation := p.generation
+	time.AfterFunc(after, func() {
+		ps.enqueue(presenceUpdate{userID: userID, generation: generation})
+	})
+}
+
+// presenceOf returns the entry of a participant, creating an offline one;
+// the caller must hold mu
+func (ps *PresenceService) presenceOf(userID int) *userPresence {
+	p, ok := ps.users[userID]
+	if !ok {
+		p = &userPresence{
+			status:      models.PresenceOffline,
+			connections: make(map[*websocket.Client]int64),
+		}
+		ps.users[userID] = p
+	}
+	return p
+}
+
+// Status returns the presence of a participant. Participants never seen
+// since the server started are offline.
+func (ps *PresenceService) Status(userID int) models.PresenceStatus {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	if p, ok := ps.users[userID]; ok {
+		return p.status
+	}
+	return models.PresenceOffline
+}
+
+// OnlineUserIDs returns the participants currently online, including those
+// reconnecting within the grace period
+func (ps *PresenceService) OnlineUserIDs() map[int]bool {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	online := make(map[int]bool)
+	for userID, p := range ps.users {
+		if p.status == models.PresenceOnline {
+			online[userID] = true
+		}
+	}
+	return online
+}
+
+// GetPresence returns every participant's presence, online ones first
+func (ps *PresenceService) GetPresence() ([]UserPresence, error) {
+	users, err := ps.userRepo.GetAllUsers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load users: %v", err)
+	}
+	counts, err := ps.connectionRepo.CountUserConnections()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count connections: %v", err)
+	}
+
+	ps.mu.RLock()
+	result := make([]UserPresence, 0, len(users))
+	for _, user := range users {
+		entry := UserPresence{
+			UserID:           user.ID,
+			Nickname:         user.Nickname,
+			TeamID:           user.TeamID,
+			Status:           models.PresenceOffline,
+			TotalConnections: counts[user.ID],
+		}
+		if p, ok := ps.users[user.ID]; ok {
+			since := p.since
+			entry.Status = p.status
+			entry.Since = &since
+			entry.Connections = len(p.connections)
+		}
+		result = append(result, entry)
+	}
+	ps.mu.RUnlock()
+
+	order := map[models.PresenceStatus]int{models.PresenceOnline: 0, models.PresenceAway: 1, models.PresenceOffline: 2}
+	sort.SliceStable(result, func(i, j int) bool {
+		return order[result[i].Status] < order[result[j].Status]
+	})
+	return result, nil
+}
+
+// GetConnectionHistory returns the latest connections of a participant, newest first
+func (ps *PresenceService) GetConnectionHistory(userID int) ([]models.ConnectionRecord, error) {
+	return ps.connectionRepo.GetUserConnections(userID, connectionHistoryLimit)
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"database/sql"
+	"os"
+	"quiz100/models"
+	"quiz100/websocket"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	testGracePeriod  = 50 * time.Millisecond
+	testOfflineAfter = 100 * time.Millisecond
+)
+
+// setupEvent creates an event on an in-memory database with the schema
+func setupEvent(t *testing.T) (*sql.DB, int, *models.QuizLogger) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.SetMaxOpenConns(1) // Every connection to :memory: is a new database
+	t.Cleanup(func() { db.Close() })
+	schema, err := os.ReadFile("../database/init.sql")
+	if err != nil {
+		t.Fatalf("Failed to read schema: %v", err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatalf("Failed to execute schema: %v", err)
+	}
+
+	event, err := models.NewEventRepository(db).CreateEvent("main", "Test Quiz", false, 1, "")
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	logger, err := models.NewQuizLogger(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	return db, event.ID, logger
+}
+
+// setupPresence starts a presence service with short timers on an in-memory
+// database and returns it with the user repository
+func setupPresence(t *testing.T) (*PresenceService, *models.UserRepository) {
+	db, eventID, logger := setupEvent(t)
+
+	userRepo := models.NewUserRepository(db, eventID)
+	answerRepo := models.NewAnswerRepository(db, eventID)
+	hub := websocket.NewHub(answerRepo)
+	go hub.Run()
+
+	config := &models.Config{Presence: models.PresenceConfig{
+		GracePeriod:  testGracePeriod.String(),
+		OfflineAfter: testOfflineAfter.String(),
+	}}
+	ps := NewPresenceService(userRepo, models.NewConnectionRepository(db, eventID), websocket.NewHubManager(hub), logger, config)
+	if err := ps.Start(hub); err != nil {
+		t.Fatalf("Failed to start presence: %v", err)
+	}
+	return ps, userRepo
+}
+
+func createPresenceUser(t *testing.T, userRepo *models.UserRepository, nickname string) int {
+	user, err := userRepo.CreateUser(nickname+"-session", nickname)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	return user.ID
+}
+
+// connect opens a participant connection the way the hub reports it
+func connect(ps *PresenceService, userID int) *websocket.Client {
+	client := &websocket.Client{Type: websocket.ClientTypeParticipant, Transport: websocket.TransportWebSocket, UserID: userID}
+	ps.onConnection(websocket.ConnectionEvent{Client: client, Connected: true, Time: time.Now()})
+	return client
+}
+
+func disconnect(ps *PresenceService, client *websocket.Client) {
+	ps.onConnection(websocket.ConnectionEvent{Client: client, Connected: false, Time: time.Now()})
+}
+
+// waitForStatus waits for the worker to apply the queued updates and timers
+func waitForStatus(t *testing.T, ps *PresenceService, userID int, want models.PresenceStatus) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for ps.Status(userID) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("User %d: status %s, want %s", userID, ps.Status(userID), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// expectStatusFor checks that the status holds for the duration
+func expectStatusFor(t *testing.T, ps *PresenceService, userID int, want models.PresenceStatus, d time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) {
+		if got := ps.Status(userID); got != want {
+			t.Fatalf("User %d: status changed to %s, want %s", userID, got, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPresenceGraceAwayOffline(t *testing.T) {
+	ps, userRepo := setupPresence(t)
+	userID := createPresenceUser(t, userRepo, "alice")
+
+	// Joined but never connected: online for the grace period, then away, then offline
+	ps.Joined(userID)
+	if got := ps.Status(userID); got != models.PresenceOnline {
+		t.Fatalf("Expected online right after joining, got %s", got)
+	}
+	waitForStatus(t, ps, userID, models.PresenceAway)
+	if user, _ := userRepo.GetUserByID(userID); user.Connected {
+		t.Error("Expected the connected flag to be cleared when away")
+	}
+	waitForStatus(t, ps, userID, models.PresenceOffline)
+
+	// Connecting again brings the participant back online
+	connect(ps, userID)
+	waitForStatus(t, ps, userID, models.PresenceOnline)
+	if user, _ := userRepo.GetUserByID(userID); !user.Connected {
+		t.Error("Expected the connected flag to be set when back online")
+	}
+}
+
+func TestPresenceReconnectWithinGracePeriod(t *testing.T) {
+	ps, userRepo := setupPresence(t)
+	userID := createPresenceUser(t, userRepo, "alice")
+
+	client := connect(ps, userID)
+	waitForStatus(t, ps, userID, models.PresenceOnline)
+
+	// A reload drops the connection and opens a new one before the grace period ends
+	disconnect(ps, client)
+	connect(ps, userID)
+	expectStatusFor(t, ps, userID, models.PresenceOnline, 3*testGracePeriod)
+
+	history, err := ps.GetConnectionHistory(userID)
+	if err != nil {
+		t.Fatalf("Failed to get connection history: %v", err)
+	}
+	if len(history) != 2 {
+		t.Errorf("Expected 2 connections in the history, got %d", len(history))
+	}
+}
+
+func TestPresenceMultipleTabs(t *testing.T) {
+	ps, userRepo := setupPresence(t)
+	userID := createPresenceUser(t, userRepo, "alice")
+
+	first := connect(ps, userID)
+	second := connect(ps, userID)
+	waitForStatus(t, ps, userID, models.PresenceOnline)
+
+	// Closing one tab keeps the participant online
+	disconnect(ps, first)
+	expectStatusFor(t, ps, userID, models.PresenceOnline, 3*testGracePeriod)
+
+	// Closing the last one starts the grace period
+	disconnect(ps, second)
+	waitForStatus(t, ps, userID, models.PresenceAway)
+	waitForStatus(t, ps, userID, models.PresenceOffline)
+}
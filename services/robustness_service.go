@@ -334,11 +334,12 @@ func (rs *RobustnessService) syncStateAcrossClients(data map[string]interface{})
 		return fmt.Errorf("invalid question_number in sync data")
 	}
 
-	syncData := map[string]interface{}{
-		"state":           state,
-		"question_number": questionNumber,
-		"sync_type":       "robustness_recovery",
-		"timestamp":       time.Now().Unix(),
+	timestamp := time.Now().UTC()
+	syncData := websocket.StateChangedData{
+		NewState:       state,
+		QuestionNumber: questionNumber,
+		Reason:         "robustness_recovery",
+		Timestamp:      &timestamp,
 	}
 
 	return rs.hubManager.BroadcastStateChanged(syncData)
@@ -438,7 +439,6 @@ func (rs *RobustnessService) RecoverFromWebSocketDisconnection(clientType websoc
 		Data: map[string]interface{}{
 			"client_type": string(clientType),
 			"user_id":     userID,
-			"session_id":  sessionID,
 		},
 		Timestamp: time.Now(),
 	}
@@ -485,11 +485,12 @@ func (rs *RobustnessService) EmergencyReset() error {
 	}
 
 	// Broadcast reset to all clients
-	resetData := map[string]interface{}{
-		"state":           models.StateWaiting,
-		"question_number": 0,
-		"reset_type":      "emergency",
-		"timestamp":       time.Now().Unix(),
+	timestamp := time.Now().UTC()
+	resetData := websocket.StateChangedData{
+		NewState:       models.StateWaiting,
+		QuestionNumber: 0,
+		Reason:         "emergency_reset",
+		Timestamp:      &timestamp,
 	}
 
 	err = rs.hubManager.BroadcastStateChanged(resetData)
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"fmt"
+	"quiz100/models"
+	"quiz100/websocket"
+)
+
+// ShowSlide puts an announcement over the current state. The state machine and
+// flow timers keep going underneath; the autopilot waits until it is dismissed.
+// Showing another slide replaces the current one.
+func (ss *StateService) ShowSlide(slide models.Slide) error {
+	if err := slide.Validate(); err != nil {
+		return fmt.Errorf("invalid slide: %v", err)
+	}
+
+	ss.timerMu.Lock()
+	ss.holdAutopilot()
+	ss.slide = &slide
+	ss.timerMu.Unlock()
+
+	ss.logger.LogAlert(fmt.Sprintf("Slide shown: %s", slide.DisplayLabel()))
+	ss.UpdateEventState()
+	ss.saveProgress()
+	ss.wakeAutopilot()
+
+	if err := ss.hubManager.BroadcastSlideShow(&slide); err != nil {
+		ss.logger.LogError("broadcasting slide show", err)
+	}
+	return nil
+}
+
+// DismissSlide removes the announcement so clients return to the current state
+func (ss *StateService) DismissSlide() (*models.Slide, error) {
+	ss.timerMu.Lock()
+	slide := ss.slide
+	if slide == nil {
+		ss.timerMu.Unlock()
+		return nil, fmt.Errorf("no slide is shown")
+	}
+	ss.slide = nil
+	ss.releaseAutopilot()
+	ss.timerMu.Unlock()
+
+	ss.logger.LogAlert(fmt.Sprintf("Slide dismissed: %s", slide.DisplayLabel()))
+	ss.UpdateEventState()
+	ss.saveProgress()
+	ss.wakeAutopilot()
+
+	hideData := websocket.SlideHideData{
+		Slide:          slide,
+		State:          ss.stateManager.GetCurrentState(),
+		QuestionNumber: ss.stateManager.GetQuestionNumber(),
+	}
+	if err := ss.hubManager.BroadcastSlideHide(hideData); err != nil {
+		ss.logger.LogError("broadcasting slide hide", err)
+	}
+	return slide, nil
+}
+
+// GetSlide returns the announcement currently shown, or nil
+func (ss *StateService) GetSlide() *models.Slide {
+	ss.timerMu.Lock()
+	defer ss.timerMu.Unlock()
+	return ss.slide
+}
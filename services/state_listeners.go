@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"quiz100/models"
+	"quiz100/websocket"
+	"time"
+)
+
+// registerStateListeners hooks the side effects of state changes onto the state
+// machine, so every change is handled the same way whether it came from the
+// admin, a flow timer or the autopilot
+func (ss *StateService) registerStateListeners() {
+	ss.stateManager.OnBeforeTransition(ss.rejectWhilePaused)
+
+	ss.stateManager.OnTransition(ss.logStateChange)
+	ss.stateManager.OnTransition(ss.syncStateChange)
+	ss.stateManager.OnTransition(ss.broadcastStateChange)
+	ss.stateManager.OnTransition(ss.rescheduleStateTimer)
+	ss.stateManager.OnTransition(ss.persistStateChange)
+	ss.stateManager.OnTransition(ss.notifyAutopilot)
+}
+
+// rejectWhilePaused keeps the event where it is until it is resumed
+func (ss *StateService) rejectWhilePaused(ctx context.Context, change models.StateChange) error {
+	if ss.IsPaused() {
+		return fmt.Errorf("event is paused")
+	}
+	return nil
+}
+
+func (ss *StateService) logStateChange(ctx context.Context, change models.StateChange) {
+	switch change.Kind {
+	case models.TransitionJump:
+		ss.logger.LogAlert(fmt.Sprintf("Admin jumped from state %s to %s (question %d)", change.From.State, change.To.State, change.To.QuestionNumber))
+	case models.TransitionGoBack:
+		ss.logger.LogAlert(fmt.Sprintf("Admin went back from state %s to %s (question %d)", change.From.State, change.To.State, change.To.QuestionNumber))
+	case models.TransitionSetQuestion:
+		ss.logger.LogAlert(fmt.Sprintf("Question number set from %d to %d", change.From.QuestionNumber, change.To.QuestionNumber))
+	default:
+		if change.StateChanged() {
+			ss.logger.LogStateTransition(change.From.State, change.To.State)
+		}
+	}
+}
+
+// syncStateChange updates the Hub's event state (state or question number changed)
+func (ss *StateService) syncStateChange(ctx context.Context, change models.StateChange) {
+	ss.UpdateEventState()
+}
+
+// broadcastStateChange tells clients about changes that no action message covers.
+// Flow actions broadcast their own messages from the admin handlers.
+func (ss *StateService) broadcastStateChange(ctx context.Context, change models.StateChange) {
+	switch change.Kind {
+	case models.TransitionJump:
+		timestamp := time.Now().UTC()
+		stateData := websocket.StateChangedData{
+			PreviousState:  change.From.State,
+			NewState:       change.To.State,
+			QuestionNumber: change.To.QuestionNumber,
+			Jumped:         true,
+			Timestamp:      &timestamp,
+		}
+
+		// Clients showing a question need its content
+		if question, ok := ss.questionFor(change.To); ok {
+			stateData.Question = &question
+			stateData.TotalQuestions = len(ss.config.Questions)
+		}
+
+		if err := ss.hubManager.BroadcastStateChanged(stateData); err != nil {
+			ss.logger.LogError("broadcasting state change", err)
+		}
+	case models.TransitionGoBack:
+		ss.ResyncAllClients()
+	}
+}
+
+// rescheduleStateTimer restarts the flow timer when a new state is entered.
+// Self-loop actions and question number edits keep the running timer.
+func (ss *StateService) rescheduleStateTimer(ctx context.Context, change models.StateChange) {
+	if !change.StateChanged() && (change.Kind == models.TransitionAction || change.Kind == models.TransitionSetQuestion) {
+		return
+	}
+	ss.scheduleStateTimer()
+}
+
+func (ss *StateService) persistStateChange(ctx context.Context, change models.StateChange) {
+	ss.saveProgress()
+}
+
+func (ss *StateService) notifyAutopilot(ctx context.Context, change models.StateChange) {
+	ss.wakeAutopilot()
+}
+
+// questionFor returns the question of the position without the answer, unless
+// the answer is being revealed
+func (ss *StateService) questionFor(position models.StateSnapshot) (models.Question, bool) {
+	if ss.config == nil || position.QuestionNumber <= 0 || position.QuestionNumber > len(ss.config.Questions) {
+		return models.Question{}, false
+	}
+
+	question := ss.config.Questions[position.QuestionNumber-1]
+	if position.State != models.StateAnswerReveal {
+		question.Correct = 0 // invalid value
+	}
+	return question, true
+}
@@ -1,12 +1,12 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"quiz100/models"
 	"quiz100/websocket"
+	"sync"
 	"time"
-
-	"github.com/gin-gonic/gin"
 )
 
 // StateService provides high-level state management operations
@@ -19,8 +19,37 @@ type StateService struct {
 	userRepo     *models.UserRepository
 	teamRepo     *models.TeamRepository
 	answerRepo   *models.AnswerRepository
+
+	actionExecutor ActionExecutor
+	timerMu        sync.Mutex
+	stateTimer     *time.Timer
+	timerSeq       int
+	timerAction    string        // Action of the pending flow timer ("" when none)
+	timerDeadline  time.Time     // When the running flow timer fires
+	timerRemaining time.Duration // Time left on the flow timer while paused
+	paused         bool
+	pausedAt       time.Time
+	slide          *models.Slide // Announcement shown over the current state
+
+	eventRepo *models.EventRepository // Where progress is saved (nil until an event is attached)
+	eventID   int
+
+	autopilotOn        bool
+	autopilotWake      chan struct{}
+	autopilotVisit     models.StateSnapshot // State and question the current step belongs to
+	autopilotScheduled bool                 // Whether the run-sheet has a step for the visit
+	autopilotDeadline  time.Time            // When the autopilot moves on
+	autopilotRemaining time.Duration        // Time left on the step while paused or a slide is shown
+	autopilotLoopDone  bool                 // Self-loop action (e.g. show_answer_stats) already run in this visit
 }
 
+// PauseStatus describes whether the event is paused. It is also the payload
+// of event_paused and event_resumed.
+type PauseStatus = websocket.PauseData
+
+// ActionExecutor performs an admin action including its broadcasts
+type ActionExecutor func(action string) error
+
 // Logger interface for logging operations
 type Logger interface {
 	LogStateTransition(from, to models.EventState)
@@ -35,6 +64,9 @@ type StateTransitionResult struct {
 	Success       bool              `json:"success"`
 	Message       string            `json:"message,omitempty"`
 	Error         error             `json:"-"`
+
+	// Transition is the flow transition that was followed (PerformAction only)
+	Transition *models.FlowTransition `json:"-"`
 }
 
 // NewStateService creates a new StateService instance
@@ -49,6 +81,7 @@ func NewStateService(stateManager *models.EventStateManager, hubManager *websock
 		teamRepo:     teamRepo,
 		answerRepo:   answerRepo,
 	}
+	ss.registerStateListeners()
 
 	// // Start periodic sync with 15-second interval
 	// if hub != nil {
@@ -70,7 +103,51 @@ func (ss *StateService) GetQuestionNumber() int {
 
 // GetAvailableActions returns available actions for the current state
 func (ss *StateService) GetAvailableActions() []string {
-	return ss.stateManager.GetAvailableActions()
+	details := ss.GetAvailableActionDetails()
+	actions := make([]string, 0, len(details))
+	for _, detail := range details {
+		actions = append(actions, detail.Action)
+	}
+	return actions
+}
+
+// GetAvailableActionDetails returns available actions with their labels.
+// While paused, resuming is the only thing the admin can do.
+func (ss *StateService) GetAvailableActionDetails() []models.AvailableAction {
+	currentState := ss.stateManager.GetCurrentState()
+
+	if ss.IsPaused() {
+		return []models.AvailableAction{{
+			Action: models.ActionResume,
+			Label:  models.GetActionLabel(models.ActionResume),
+			To:     currentState,
+		}}
+	}
+
+	actions := ss.stateManager.GetAvailableActionDetails()
+	if currentState != models.StateFinished {
+		actions = append(actions, models.AvailableAction{
+			Action: models.ActionPause,
+			Label:  models.GetActionLabel(models.ActionPause),
+			To:     currentState,
+		})
+	}
+	return append(actions, ss.autopilotActionDetails()...)
+}
+
+// SetActionExecutor sets the function used to run actions fired by flow timers
+func (ss *StateService) SetActionExecutor(executor ActionExecutor) {
+	ss.actionExecutor = executor
+}
+
+// AttachEvent saves every state change of the service to the given event from now on
+func (ss *StateService) AttachEvent(eventRepo *models.EventRepository, eventID int) {
+	ss.timerMu.Lock()
+	ss.eventRepo = eventRepo
+	ss.eventID = eventID
+	ss.timerMu.Unlock()
+
+	ss.saveProgress()
 }
 
 // CanTransitionTo checks if transition to target state is allowed
@@ -78,83 +155,44 @@ func (ss *StateService) CanTransitionTo(targetState models.EventState) bool {
 	return ss.stateManager.CanTransitionTo(targetState)
 }
 
-// TransitionTo performs a state transition with validation and logging
+// TransitionTo performs a state transition with validation; the registered
+// listeners log, sync and save it
 func (ss *StateService) TransitionTo(targetState models.EventState) *StateTransitionResult {
-	previousState := ss.stateManager.GetCurrentState()
-
-	// Validate transition
-	if !ss.stateManager.CanTransitionTo(targetState) {
-		result := &StateTransitionResult{
-			PreviousState: previousState,
-			NewState:      previousState, // No change
-			Success:       false,
-			Message:       fmt.Sprintf("Invalid transition from %s to %s", previousState, targetState),
-			Error:         fmt.Errorf("invalid transition from %s to %s", previousState, targetState),
-		}
-		ss.logger.LogError("state transition", result.Error)
-		return result
-	}
-
-	// Perform transition
-	if err := ss.stateManager.TransitionTo(targetState); err != nil {
-		result := &StateTransitionResult{
-			PreviousState: previousState,
-			NewState:      previousState, // No change
-			Success:       false,
-			Message:       fmt.Sprintf("Transition failed: %v", err),
-			Error:         err,
-		}
-		ss.logger.LogError("state transition", err)
-		return result
+	change, err := ss.stateManager.TransitionToContext(context.Background(), targetState)
+	if err != nil {
+		return ss.failedResult("state transition", fmt.Sprintf("Transition failed: %v", err), err)
 	}
 
-	// Log successful transition
-	ss.logger.LogStateTransition(previousState, targetState)
-
-	// // Broadcast state change
-	// ss.broadcastStateChange(previousState, targetState)
-
-	// Update Hub event state for synchronization
-	ss.UpdateEventState()
-
 	return &StateTransitionResult{
-		PreviousState: previousState,
-		NewState:      targetState,
+		PreviousState: change.From.State,
+		NewState:      change.To.State,
 		Success:       true,
-		Message:       fmt.Sprintf("Successfully transitioned from %s to %s", previousState, targetState),
+		Message:       fmt.Sprintf("Successfully transitioned from %s to %s", change.From.State, change.To.State),
 	}
 }
 
 // JumpToState performs an unrestricted state jump (for admin use)
 func (ss *StateService) JumpToState(targetState models.EventState) *StateTransitionResult {
-	previousState := ss.stateManager.GetCurrentState()
-
-	if err := ss.stateManager.JumpToState(targetState); err != nil {
-		result := &StateTransitionResult{
-			PreviousState: previousState,
-			NewState:      previousState, // No change
-			Success:       false,
-			Message:       fmt.Sprintf("State jump failed: %v", err),
-			Error:         err,
-		}
-		ss.logger.LogError("state jump", err)
-		return result
-	}
-
-	// Log state jump
-	ss.logger.LogAlert(fmt.Sprintf("Admin jumped from state %s to %s", previousState, targetState))
+	change, err := ss.stateManager.JumpToStateContext(context.Background(), targetState)
+	return ss.jumpResult(change, err)
+}
 
-	// Broadcast state change
-	ss.broadcastStateChange(previousState, targetState)
+// JumpToQuestion jumps to a state and question number in one change (for admin use)
+func (ss *StateService) JumpToQuestion(targetState models.EventState, questionNumber int) *StateTransitionResult {
+	change, err := ss.stateManager.JumpToQuestionContext(context.Background(), targetState, questionNumber)
+	return ss.jumpResult(change, err)
+}
 
-	// Update Hub event state for synchronization
-	ss.UpdateEventState()
+func (ss *StateService) jumpResult(change *models.StateChange, err error) *StateTransitionResult {
+	if err != nil {
+		return ss.failedResult("state jump", fmt.Sprintf("State jump failed: %v", err), err)
+	}
 
 	return &StateTransitionResult{
-		PreviousState: previousState,
-		NewState:      targetState,
+		PreviousState: change.From.State,
+		NewState:      change.To.State,
 		Success:       true,
-		Message:       fmt.Sprintf("Successfully jumped from %s to %s", previousState, targetState),
+		Message:       fmt.Sprintf("Successfully jumped from %s to %s", change.From.State, change.To.State),
 	}
 }
 
@@ -163,64 +201,62 @@ func (ss *StateService) SetQuestionNumber(questionNumber int) error {
 	return ss.stateManager.SetQuestionNumber(questionNumber)
 }
 
-// NextQuestion advances to the next question
-func (ss *StateService) NextQuestion() *StateTransitionResult {
-	previousState := ss.stateManager.GetCurrentState()
-
-	if err := ss.stateManager.NextQuestion(); err != nil {
-		result := &StateTransitionResult{
-			PreviousState: previousState,
-			NewState:      previousState, // No change
-			Success:       false,
-			Message:       fmt.Sprintf("Cannot advance to next question: %v", err),
-			Error:         err,
-		}
-		ss.logger.LogError("next question", err)
-		return result
+// PerformAction follows the flow transition for the given admin action
+func (ss *StateService) PerformAction(action string) *StateTransitionResult {
+	change, err := ss.stateManager.PerformActionContext(context.Background(), action)
+	if err != nil {
+		return ss.failedResult("perform action", fmt.Sprintf("Cannot perform %s: %v", action, err), err)
 	}
 
-	newState := ss.stateManager.GetCurrentState()
-
-	// // Log transition
-	// if newState != previousState {
-	// 	ss.logger.LogStateTransition(previousState, newState)
-
-	// 	// Broadcast state change if state actually changed
-	// 	ss.broadcastStateChange(previousState, newState)
-	// }
-
-	// Update Hub event state for synchronization (question number changed)
-	ss.UpdateEventState()
-
 	return &StateTransitionResult{
-		PreviousState: previousState,
-		NewState:      newState,
+		PreviousState: change.From.State,
+		NewState:      change.To.State,
 		Success:       true,
-		Message:       "Successfully advanced to next question",
+		Message:       fmt.Sprintf("Performed %s (%s -> %s)", action, change.From.State, change.To.State),
+		Transition:    change.Transition,
 	}
 }
 
-// StartCountdownWithAutoTransition starts countdown and automatically transitions to answer stats
-func (ss *StateService) StartCountdownWithAutoTransition() *StateTransitionResult {
-	// First transition to countdown state
-	result := ss.TransitionTo(models.StateCountdownActive)
-	if !result.Success {
-		return result
+// GoBack returns to the previous state and question number; the listeners
+// resynchronize all clients
+func (ss *StateService) GoBack() *StateTransitionResult {
+	change, err := ss.stateManager.GoBackContext(context.Background())
+	if err != nil {
+		return ss.failedResult("go back", fmt.Sprintf("Cannot go back: %v", err), err)
 	}
 
-	// Start countdown in a separate goroutine
-	go ss.executeCountdownSequence()
+	return &StateTransitionResult{
+		PreviousState: change.From.State,
+		NewState:      change.To.State,
+		Success:       true,
+		Message:       fmt.Sprintf("Went back from %s to %s", change.From.State, change.To.State),
+	}
+}
 
-	return result
+// failedResult logs a rejected change and reports that the state stayed where it was
+func (ss *StateService) failedResult(operation, message string, err error) *StateTransitionResult {
+	ss.logger.LogError(operation, err)
+
+	currentState := ss.stateManager.GetCurrentState()
+	return &StateTransitionResult{
+		PreviousState: currentState,
+		NewState:      currentState, // No change
+		Success:       false,
+		Message:       message,
+		Error:         err,
+	}
 }
 
 // GetStateInfo returns comprehensive state information
 func (ss *StateService) GetStateInfo() map[string]any {
+	position := ss.stateManager.GetSnapshot()
 	return map[string]any{
-		"current_state":     ss.stateManager.GetCurrentState(),
-		"question_number":   ss.stateManager.GetQuestionNumber(),
-		"available_actions": ss.stateManager.GetAvailableActions(),
-		"state_label":       models.GetStateLabel(ss.stateManager.GetCurrentState()),
+		"current_state":     position.State,
+		"question_number":   position.QuestionNumber,
+		"available_actions": ss.GetAvailableActionDetails(),
+		"paused":            ss.IsPaused(),
+		"slide":             ss.GetSlide(),
+		"state_label":       models.GetStateLabel(position.State),
 		"timestamp":         time.Now().UTC(),
 	}
 }
@@ -248,67 +284,283 @@ func (ss *StateService) ValidateStateTransition(from, to models.EventState) erro
 
 // Private helper methods
 
-// broadcastStateChange broadcasts state change notification to all clients
-func (ss *StateService) broadcastStateChange(previousState, newState models.EventState) {
-	stateData := map[string]any{
-		"previous_state":  previousState,
-		"new_state":       newState,
-		"question_number": ss.stateManager.GetQuestionNumber(),
-		"jumped":          false, // This could be parameterized if needed
-		"timestamp":       time.Now().UTC(),
+// scheduleStateTimer (re)arms the flow timer declared for the current state
+func (ss *StateService) scheduleStateTimer() {
+	ss.timerMu.Lock()
+	defer ss.timerMu.Unlock()
+
+	if ss.stateTimer != nil {
+		ss.stateTimer.Stop()
+		ss.stateTimer = nil
+	}
+	ss.timerSeq++
+	ss.timerAction = ""
+
+	state := ss.stateManager.GetCurrentState()
+	flow := ss.stateManager.GetFlow()
+	action, delay, ok := flow.TimerFor(state)
+	if !ok {
+		return
+	}
+
+	ss.timerAction = action
+	if ss.paused {
+		// Started on resume
+		ss.timerRemaining = delay
+		return
+	}
+	ss.armStateTimer(state, action, delay)
+}
+
+// armStateTimer starts the flow timer; the caller must hold timerMu
+func (ss *StateService) armStateTimer(state models.EventState, action string, delay time.Duration) {
+	seq := ss.timerSeq
+	ss.timerDeadline = time.Now().Add(delay)
+	ss.stateTimer = time.AfterFunc(delay, func() {
+		ss.fireStateTimer(seq, state, action)
+	})
+}
+
+// fireStateTimer runs a timer action unless the state has moved on in the meantime
+func (ss *StateService) fireStateTimer(seq int, state models.EventState, action string) {
+	ss.timerMu.Lock()
+	stale := seq != ss.timerSeq
+	if !stale && ss.paused {
+		// Paused right as the timer fired; run it immediately on resume
+		ss.timerRemaining = 0
+		ss.timerMu.Unlock()
+		return
+	}
+	if !stale {
+		ss.timerAction = ""
+		ss.stateTimer = nil
+	}
+	ss.timerMu.Unlock()
+
+	if stale || ss.stateManager.GetCurrentState() != state {
+		return
+	}
+
+	ss.logger.LogAlert(fmt.Sprintf("Flow timer fired in state %s: %s", state, action))
+	ss.runAutomaticAction(action, "flow timer")
+}
+
+// runAutomaticAction performs an action nobody clicked (flow timers, autopilot)
+func (ss *StateService) runAutomaticAction(action, source string) {
+	if ss.actionExecutor == nil {
+		if result := ss.PerformAction(action); !result.Success {
+			ss.logger.LogError(source+" action", result.Error)
+		}
+		return
+	}
+
+	if err := ss.actionExecutor(action); err != nil {
+		ss.logger.LogError(source+" action", err)
+		return
 	}
 
-	if err := ss.hubManager.BroadcastStateChanged(stateData); err != nil {
+	// Let admin screens refresh their action buttons
+	stateData := websocket.StateChangedData{
+		NewState:       ss.stateManager.GetCurrentState(),
+		QuestionNumber: ss.stateManager.GetQuestionNumber(),
+		Action:         action,
+		Automatic:      true,
+		Source:         source,
+	}
+	if err := ss.hubManager.BroadcastToType(websocket.MessageStateChanged, stateData, websocket.ClientTypeAdmin); err != nil {
 		ss.logger.LogError("broadcasting state change", err)
 	}
 }
 
-// executeCountdownSequence executes the 5-second countdown and auto-transitions
-func (ss *StateService) executeCountdownSequence() {
-	countdownData := gin.H{
-		"seconds_left": 5,
+// Pause freezes the pending flow timer and blocks actions and answers until Resume
+func (ss *StateService) Pause() (*PauseStatus, error) {
+	ss.timerMu.Lock()
+	if ss.paused {
+		ss.timerMu.Unlock()
+		return nil, fmt.Errorf("event is already paused")
+	}
+
+	ss.holdAutopilot()
+	ss.paused = true
+	ss.pausedAt = time.Now()
+	if ss.stateTimer != nil && ss.stateTimer.Stop() {
+		ss.timerRemaining = max(time.Until(ss.timerDeadline), 0)
+	}
+	ss.stateTimer = nil
+	ss.timerMu.Unlock()
+
+	status := ss.GetPauseStatus()
+	ss.logger.LogAlert(fmt.Sprintf("Event paused in state %s", status.State))
+	ss.UpdateEventState()
+	ss.saveProgress()
+	ss.wakeAutopilot()
+
+	if err := ss.hubManager.BroadcastEventPaused(*status); err != nil {
+		ss.logger.LogError("broadcasting event paused", err)
+	}
+
+	return status, nil
+}
+
+// Resume restarts the flow timer with the time that was left when pausing
+func (ss *StateService) Resume() (*PauseStatus, error) {
+	ss.timerMu.Lock()
+	if !ss.paused {
+		ss.timerMu.Unlock()
+		return nil, fmt.Errorf("event is not paused")
+	}
+
+	pausedFor := time.Since(ss.pausedAt)
+	remaining := ss.timerRemaining
+	ss.paused = false
+	if ss.timerAction != "" {
+		ss.armStateTimer(ss.stateManager.GetCurrentState(), ss.timerAction, remaining)
 	}
-	if err := ss.hubManager.BroadcastCountdown(countdownData); err != nil {
-		ss.logger.LogError("broadcasting countdown", err)
+	ss.releaseAutopilot()
+	ss.timerMu.Unlock()
+
+	status := ss.GetPauseStatus()
+	status.RemainingMs = remaining.Milliseconds()
+	ss.logger.LogAlert(fmt.Sprintf("Event resumed after %s", pausedFor.Round(time.Second)))
+	ss.UpdateEventState()
+	ss.saveProgress()
+	ss.wakeAutopilot()
+
+	if err := ss.hubManager.BroadcastEventResumed(*status); err != nil {
+		ss.logger.LogError("broadcasting event resumed", err)
 	}
 
-	time.Sleep(5500 * time.Millisecond)
+	return status, nil
+}
 
-	// Send question end message
-	endData := map[string]any{}
+// saveProgress persists the current position so the event survives a restart
+func (ss *StateService) saveProgress() {
+	ss.timerMu.Lock()
+	if ss.eventRepo == nil {
+		ss.timerMu.Unlock()
+		return
+	}
 
-	if err := ss.hubManager.BroadcastQuestionEnd(endData); err != nil {
-		ss.logger.LogError("broadcasting question end", err)
+	eventRepo := ss.eventRepo
+	position := ss.stateManager.GetSnapshot()
+	progress := &models.EventProgress{
+		EventID:        ss.eventID,
+		State:          position.State,
+		QuestionNumber: position.QuestionNumber,
+		History:        ss.stateManager.GetHistory(),
+		Paused:         ss.paused,
+		TimerAction:    ss.timerAction,
+		Slide:          ss.slide,
 	}
+	if ss.paused {
+		progress.PausedAt = ss.pausedAt
+		progress.TimerRemaining = ss.timerRemaining
+	} else if ss.stateTimer != nil {
+		progress.TimerDeadline = ss.timerDeadline
+	}
+	ss.timerMu.Unlock()
 
-	// Auto-transition to answer stats
-	if result := ss.TransitionTo(models.StateAnswerStats); !result.Success {
-		ss.logger.LogError("auto-transition to answer stats", result.Error)
+	if err := eventRepo.SaveProgress(progress); err != nil {
+		ss.logger.LogError("saving event progress", err)
 	}
 }
 
-// AutoTransitionToCelebration automatically transitions to celebration after delay
-func (ss *StateService) AutoTransitionToCelebration(delay time.Duration) {
-	go func() {
-		time.Sleep(delay)
-		if result := ss.TransitionTo(models.StateFinished); !result.Success {
-			ss.logger.LogError("auto-transition to finished", result.Error)
+// restoreTimerGrace is the minimum delay before a restored timer fires, so clients
+// reconnecting after a restart see the state before it moves on
+const restoreTimerGrace = 3 * time.Second
+
+// RestoreProgress resumes a persisted event: state, question, go-back history,
+// pause and the pending flow timer. A timer whose deadline passed while the
+// server was down fires after restoreTimerGrace.
+func (ss *StateService) RestoreProgress(progress *models.EventProgress) error {
+	if err := ss.stateManager.Restore(progress.State, progress.QuestionNumber, progress.History); err != nil {
+		return fmt.Errorf("failed to restore state: %v", err)
+	}
+
+	state := ss.stateManager.GetCurrentState()
+	flow := ss.stateManager.GetFlow()
+
+	ss.timerMu.Lock()
+	if ss.stateTimer != nil {
+		ss.stateTimer.Stop()
+		ss.stateTimer = nil
+	}
+	ss.timerSeq++
+	ss.timerAction = ""
+	ss.paused = progress.Paused
+	ss.pausedAt = progress.PausedAt
+	ss.slide = progress.Slide
+
+	if action, delay, ok := flow.TimerFor(state); ok {
+		remaining := delay
+		if progress.TimerAction == action {
+			if progress.Paused {
+				remaining = progress.TimerRemaining
+			} else if !progress.TimerDeadline.IsZero() {
+				remaining = max(time.Until(progress.TimerDeadline), 0)
+			}
 		}
-	}()
+
+		ss.timerAction = action
+		if ss.paused {
+			ss.timerRemaining = remaining
+		} else {
+			ss.armStateTimer(state, action, max(remaining, restoreTimerGrace))
+		}
+	}
+	ss.timerMu.Unlock()
+
+	ss.logger.LogAlert(fmt.Sprintf("Restored event %d in state %s (question %d, paused: %v)",
+		progress.EventID, state, progress.QuestionNumber, progress.Paused))
+	ss.UpdateEventState()
+
+	return nil
+}
+
+// IsPaused reports whether the event is paused
+func (ss *StateService) IsPaused() bool {
+	ss.timerMu.Lock()
+	defer ss.timerMu.Unlock()
+	return ss.paused
+}
+
+// GetPauseStatus returns the pause state and the time left on the pending flow timer
+func (ss *StateService) GetPauseStatus() *PauseStatus {
+	ss.timerMu.Lock()
+	defer ss.timerMu.Unlock()
+
+	status := &PauseStatus{
+		Paused:      ss.paused,
+		State:       ss.stateManager.GetCurrentState(),
+		TimerAction: ss.timerAction,
+	}
+
+	if ss.paused {
+		pausedAt := ss.pausedAt
+		status.PausedAt = &pausedAt
+		if ss.timerAction != "" {
+			status.RemainingMs = ss.timerRemaining.Milliseconds()
+		}
+	} else if ss.stateTimer != nil {
+		status.RemainingMs = max(time.Until(ss.timerDeadline), 0).Milliseconds()
+	}
+
+	return status
 }
 
 // State Synchronization Methods
 
 // GenerateEventSyncData creates comprehensive synchronization data for the current state
 func (ss *StateService) GenerateEventSyncData() *websocket.EventSyncData {
-	currentState := ss.stateManager.GetCurrentState()
-	currentQuestion := ss.stateManager.GetQuestionNumber()
+	position := ss.stateManager.GetSnapshot()
+	currentState := position.State
+	currentQuestion := position.QuestionNumber
 
 	syncData := &websocket.EventSyncData{
 		EventState:     string(currentState),
 		QuestionNumber: currentQuestion,
-		// SyncVersion:     0, // Will be set by Hub
-		// Timestamp:       time.Now(),
+		Paused:         ss.IsPaused(),
+		Slide:          ss.GetSlide(),
 	}
 
 	// Add question data if in question-related state
@@ -370,12 +622,9 @@ func (ss *StateService) GenerateEventSyncData() *websocket.EventSyncData {
 			currentState == models.StateAnswerReveal) {
 
 		answerData := make(map[string]any)
-		if users, err := ss.userRepo.GetAllUsers(); err == nil {
-			for _, user := range users {
-				answer, err := ss.answerRepo.GetAnswerByUserAndQuestion(user.ID, currentQuestion)
-				if err == nil && answer != nil {
-					answerData[fmt.Sprintf("%d", user.ID)] = answer.AnswerIndex
-				}
+		if answers, err := ss.answerRepo.GetAnswersByQuestion(currentQuestion); err == nil {
+			for _, answer := range answers {
+				answerData[fmt.Sprintf("%d", answer.UserID)] = answer.AnswerIndex
 			}
 		}
 		syncData.AnswerData = answerData
@@ -439,6 +688,23 @@ func (ss *StateService) SyncAllClients() {
 	ss.logger.LogAlert(fmt.Sprintf("Manual sync requested for %d participants", count))
 }
 
+// ResyncAllClients sends a full state sync to every connected client (participants, screens and admins)
+func (ss *StateService) ResyncAllClients() {
+	if ss.hub == nil {
+		return
+	}
+
+	count := 0
+	for _, clientType := range []websocket.ClientType{websocket.ClientTypeParticipant, websocket.ClientTypeScreen, websocket.ClientTypeAdmin} {
+		for _, client := range ss.hub.GetClientsByType(clientType) {
+			ss.hub.RequestStateSync(client, "initial")
+			count++
+		}
+	}
+
+	ss.logger.LogAlert(fmt.Sprintf("Full resync requested for %d clients", count))
+}
+
 // IsClientSynchronized checks if a specific client is synchronized with current state
 func (ss *StateService) IsClientSynchronized(userID int) bool {
 	if ss.hub == nil {
@@ -1,22 +1,73 @@
+// アクションごとのボタン色 (未定義のアクションは btn-secondary)
+const ACTION_BUTTON_STYLES = {
+  start_event: 'btn-success',
+  show_title: 'btn-secondary',
+  assign_teams: 'btn-info',
+  next_question: 'btn-success',
+  countdown_alert: 'btn-warning',
+  close_answers: 'btn-warning',
+  reopen_answers: 'btn-warning',
+  show_answer_stats: 'btn-info',
+  reveal_answer: 'btn-primary',
+  show_leaderboard: 'btn-info',
+  show_intermission: 'btn-secondary',
+  show_results: 'btn-success',
+  celebration: 'btn-warning',
+  finish: 'btn-secondary',
+  go_back: 'btn-back',
+  pause: 'btn-danger',
+  resume: 'btn-success',
+  autopilot_skip: 'btn-info',
+  autopilot_extend: 'btn-secondary',
+  autopilot_manual: 'btn-warning',
+  autopilot_auto: 'btn-success',
+};
+
 class QuizAdmin {
   constructor() {
     this.ws = null;
+    this.messageSequence = new QuizUtils.MessageSequence();
     this.currentEvent = null;
     this.participants = new Map();
     this.teams = new Map();
     this.currentQuestion = null;
     this.answers = new Map();
+    this.liveAnswerCount = null; // answer_count で届く回答数
     this.teamMode = false;
     this.pingResults = new Map(); // Store ping results per user
+    this.degradedConnections = new Map(); // user_id -> 通信が不安定な参加者の統計 (connection_quality)
+    this.presence = new Map(); // user_id -> online / away / offline
+    this.operator = QuizUtils.OperatorUtils.getOperator();
+    this.operatorLock = null; // null なら全員が操作できる
+    this.identity = null; // ログイン中のスタッフ (/api/auth/me)
+    this.readOnly = false; // 閲覧者 (viewer) は操作できない
+    this.currentState = null; // expected_state として送る
+    this.currentQuestionNumber = null;
+    // action -> ボタンの表示名 (スライドはアクションボタンにないので固定)
+    this.actionLabels = new Map([
+      ['show_slide', 'スライド表示'],
+      ['dismiss_slide', 'スライドを閉じる'],
+      ['set_screen_channel', 'スクリーン設定'],
+    ]);
+    this.pendingAction = null; // 失敗した送信の再試行に同じ idempotency_key を使う
     this.syncStatus = new Map(); // Store sync status for users
     this.sortMode = 'name'; // 'name', 'score', 'ping'
+    this.autopilotTimer = null;
 
     this.initializeElements();
     this.setupEventListeners();
-
-    this.connectWebSocket();
-    this.loadStatus();
-    this.loadAvailableStates();
+    this.loadIdentity();
+
+    // 複数ルームのときは操作するルームを決めてから接続する
+    this.loadRooms().then(() => {
+      this.connectWebSocket();
+      this.loadStatus();
+      this.loadAvailableStates();
+      this.loadSlides();
+      this.loadScreenChannels();
+      this.loadConnectionQuality();
+      this.loadOperatorLock();
+    });
 
     // Start periodic sync status monitoring
     this.startSyncStatusMonitoring();
@@ -27,20 +78,26 @@ class QuizAdmin {
       // 接続状況
       connectionStatus: document.getElementById('connection-status'),
       connectionText: document.getElementById('connection-text'),
+      roomSelect: document.getElementById('room-select'),
+      adminUser: document.getElementById('admin-user'),
+      logoutBtn: document.getElementById('logout-btn'),
       connectionStatusDisplay: document.getElementById(
         'connection-status-display'
       ),
 
-      // 制御ボタン
-      startEventBtn: document.getElementById('btn-start-event'),
-      showTitleBtn: document.getElementById('btn-show-title'),
-      assignTeamsBtn: document.getElementById('btn-assign-teams'),
-      nextQuestionBtn: document.getElementById('btn-next-question'),
-      countdownAlertBtn: document.getElementById('btn-countdown-alert'),
-      showAnswerStatsBtn: document.getElementById('btn-show-answer-stats'),
-      revealAnswerBtn: document.getElementById('btn-reveal-answer'),
-      showResultsBtn: document.getElementById('btn-show-results'),
-      celebrationBtn: document.getElementById('btn-celebration'),
+      // 制御ボタン (利用可能なアクションから動的に生成)
+      actionButtons: document.getElementById('action-buttons'),
+      connectionWarning: document.getElementById('connection-warning'),
+      autopilotStatus: document.getElementById('autopilot-status'),
+
+      // お知らせスライド
+      slideActive: document.getElementById('slide-active'),
+      slideActiveLabel: document.getElementById('slide-active-label'),
+      slideDismissBtn: document.getElementById('slide-dismiss-btn'),
+      slideButtons: document.getElementById('slide-buttons'),
+      screenChannels: document.getElementById('screen-channels'),
+      slideCustomText: document.getElementById('slide-custom-text'),
+      slideCustomBtn: document.getElementById('slide-custom-btn'),
 
       // イベント状況
       // eventStatus: document.getElementById('event-status'),
@@ -59,6 +116,11 @@ class QuizAdmin {
       questionDisplay: document.getElementById('question-display'),
       answersDisplay: document.getElementById('answers-display'),
 
+      // 操作権
+      operatorName: document.getElementById('operator-name'),
+      operatorLockStatus: document.getElementById('operator-lock-status'),
+      operatorLockBtn: document.getElementById('operator-lock-btn'),
+
       // デバッグ
       jumpStateSelect: document.getElementById('jump-state-select'),
       jumpQuestionInput: document.getElementById('jump-question-input'),
@@ -82,33 +144,24 @@ class QuizAdmin {
   }
 
   setupEventListeners() {
-    // アクションボタンのイベントリスナー
-    this.elements.startEventBtn?.addEventListener('click', () =>
-      this.executeAction('start_event')
-    );
-    this.elements.showTitleBtn?.addEventListener('click', () =>
-      this.executeAction('show_title')
-    );
-    this.elements.assignTeamsBtn?.addEventListener('click', () =>
-      this.executeAction('assign_teams')
-    );
-    this.elements.nextQuestionBtn?.addEventListener('click', () =>
-      this.executeAction('next_question')
-    );
-    this.elements.countdownAlertBtn?.addEventListener('click', () =>
-      this.executeAction('countdown_alert')
-    );
-    this.elements.showAnswerStatsBtn?.addEventListener('click', () =>
-      this.executeAction('show_answer_stats')
-    );
-    this.elements.revealAnswerBtn?.addEventListener('click', () =>
-      this.executeAction('reveal_answer')
-    );
-    this.elements.showResultsBtn?.addEventListener('click', () =>
-      this.executeAction('show_results')
-    );
-    this.elements.celebrationBtn?.addEventListener('click', () =>
-      this.executeAction('celebration')
+    // ルーム切替 (ページごと切り替える)
+    this.elements.roomSelect?.addEventListener('change', (e) => {
+      QuizUtils.RoomUtils.setRoomCode(e.target.value);
+      window.location.reload();
+    });
+
+    this.elements.logoutBtn?.addEventListener('click', () => this.logout());
+
+    // オペレーター名と操作権
+    if (this.elements.operatorName) {
+      this.elements.operatorName.value = this.operator.name;
+      this.elements.operatorName.addEventListener('change', (e) => {
+        QuizUtils.OperatorUtils.setName(e.target.value);
+        this.operator = QuizUtils.OperatorUtils.getOperator();
+      });
+    }
+    this.elements.operatorLockBtn?.addEventListener('click', () =>
+      this.toggleOperatorLock()
     );
 
     // デバッグ ステートジャンプ
@@ -127,6 +180,17 @@ class QuizAdmin {
       this.setSortMode('ping')
     );
 
+    // お知らせスライド
+    this.elements.slideDismissBtn?.addEventListener('click', () =>
+      this.dismissSlide()
+    );
+    this.elements.slideCustomBtn?.addEventListener('click', () => {
+      const text = this.elements.slideCustomText.value.trim();
+      if (text) {
+        this.showSlide({ text: text });
+      }
+    });
+
     // DB削除
     this.elements.resetDatabaseBtn?.addEventListener('click', () =>
       this.showResetDatabaseModal()
@@ -151,9 +215,9 @@ class QuizAdmin {
 
   connectWebSocket() {
     const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-    const wsUrl = `${protocol}//${window.location.host}/ws/admin`;
+    const wsUrl = QuizUtils.RoomUtils.withRoom(`${protocol}//${window.location.host}/ws/admin`);
 
-    this.ws = new WebSocket(wsUrl);
+    this.ws = new WebSocket(this.messageSequence.connectURL(wsUrl));
 
     this.ws.onopen = () => {
       console.log('Admin WebSocket connected');
@@ -163,7 +227,8 @@ class QuizAdmin {
 
     this.ws.onmessage = (event) => {
       const message = JSON.parse(event.data);
-      this.handleWebSocketMessage(message);
+      this.messageSequence.track(message);
+      QuizUtils.MessageUtils.unbatch(message).forEach(item => this.handleWebSocketMessage(item));
     };
 
     this.ws.onclose = () => {
@@ -199,6 +264,10 @@ class QuizAdmin {
         this.handleAnswerReceived(message.data);
         break;
 
+      case 'answer_count':
+        this.handleAnswerCount(message.data);
+        break;
+
       case 'event_started':
         this.handleEventStarted(message.data);
         break;
@@ -211,6 +280,10 @@ class QuizAdmin {
         break;
 
       case 'answer_stats':
+        this.addLog(
+          `回答率 ${Math.round(message.data.answer_rate)}% (${message.data.answered_count}/${message.data.total_participants}人)`,
+          'info'
+        );
         break;
 
       case 'answer_reveal':
@@ -232,6 +305,10 @@ class QuizAdmin {
         this.handleEmojiReceived(message.data);
         break;
 
+      case 'connection_quality':
+        this.handleConnectionQuality(message.data);
+        break;
+
       case 'ping_result':
         this.handlePingResult(message.data);
         break;
@@ -240,6 +317,42 @@ class QuizAdmin {
         this.handleDatabaseReset(message.data);
         break;
 
+      case 'state_changed':
+        // タイマーによる自動遷移などでボタンを更新
+        this.loadAvailableActions();
+        break;
+
+      case 'event_paused':
+        this.addLog('⏸️ イベントを一時停止中です', 'warning');
+        this.loadAvailableActions();
+        break;
+
+      case 'event_resumed':
+        this.addLog('▶️ イベントを再開しました', 'info');
+        this.loadAvailableActions();
+        break;
+
+      case 'presence':
+        this.handlePresence(message.data);
+        break;
+
+      case 'admin_action':
+        this.handleAdminAction(message.data);
+        break;
+
+      case 'operator_lock':
+        this.handleOperatorLock(message.data);
+        break;
+
+      case 'slide_show':
+      case 'slide_hide':
+        this.updateActiveSlide(message.type === 'slide_show' ? message.data : null);
+        break;
+
+      case 'screen_channel':
+        this.loadScreenChannels();
+        break;
+
       default:
         console.log('Unknown message type:', message.type);
     }
@@ -269,6 +382,7 @@ class QuizAdmin {
 
     // Reload available actions to update button states
     this.loadAvailableActions();
+    this.updateActiveSlide(data.slide || null);
 
     this.addLog('サーバー状態と同期しました', 'success');
   }
@@ -301,6 +415,15 @@ class QuizAdmin {
     this.loadStatus();
   }
 
+  // 回答受付中の回答数 (設定によっては選択肢ごとの人数付き)
+  handleAnswerCount(data) {
+    if (!this.currentQuestion || data.question_number !== this.currentQuestion.question_number) {
+      return;
+    }
+    this.liveAnswerCount = data;
+    this.updateAnswersDisplay();
+  }
+
   handleEventStarted(data) {
     this.currentEvent = data.event;
     this.updateEventStatus();
@@ -309,6 +432,7 @@ class QuizAdmin {
 
   handleQuestionStart(data) {
     this.currentQuestion = data;
+    this.liveAnswerCount = null;
     this.answers.clear();
     this.updateQuestionDisplay();
     this.updateAnswersDisplay();
@@ -338,260 +462,592 @@ class QuizAdmin {
     return;
   }
 
-  async startEvent() {
-    this.elements.startEventBtn.disabled = true;
-
+  async loadRooms() {
     try {
-      const response = await fetch('/api/admin/start', {
-        method: 'POST',
-        headers: {
-          'Content-Type': 'application/json',
-        },
+      const response = await fetch('/api/admin/rooms');
+      const data = await response.json();
+      const rooms = data.rooms || [];
+      if (!response.ok || rooms.length <= 1) {
+        return;
+      }
+
+      const current = QuizUtils.RoomUtils.getRoomCode();
+      if (!rooms.some((room) => room.code === current)) {
+        QuizUtils.RoomUtils.setRoomCode(rooms[0].code);
+      }
+
+      const select = this.elements.roomSelect;
+      select.innerHTML = '';
+      rooms.forEach((room) => {
+        const option = document.createElement('option');
+        option.value = room.code;
+        option.textContent = `${room.code} - ${room.title} (${QuizUtils.StateUtils.getStateLabel(room.state)})`;
+        select.appendChild(option);
       });
+      select.value = QuizUtils.RoomUtils.getRoomCode();
+      select.classList.remove('hidden');
+    } catch (error) {
+      console.error('Error loading rooms:', error);
+    }
+  }
 
+  async loadStatus() {
+    try {
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/status'));
       const data = await response.json();
 
       if (response.ok) {
-        this.currentEvent = data.event;
-        this.updateEventStatus();
-        this.addLog('イベントを開始しました', 'success');
-      } else {
-        throw new Error(data.error || 'Failed to start event');
+        this.updateParticipants(data.users || []);
+        this.loadPresence();
+
+        if (data.teams) {
+          this.teams.clear();
+          data.teams.forEach((team) => {
+            this.teams.set(team.id, team);
+          });
+          this.updateTeamsDisplay();
+        }
+
+        if (data.event) {
+          this.currentEvent = data.event;
+          this.updateEventStatus();
+        }
+
+        if (data.config) {
+          this.teamMode = data.config.team_mode || false;
+          this.updateTeamModeDisplay();
+          // Total questions count - can be displayed in console or elsewhere if needed
+          console.log(`Total questions: ${data.config.questions?.length || 0}`);
+        }
+
+        // Load available actions and update button states
+        this.loadAvailableActions();
+      }
+    } catch (error) {
+      console.error('Error loading status:', error);
+    }
+  }
+
+  async loadOperatorLock() {
+    try {
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/admin/lock'));
+      const data = await response.json();
+      if (response.ok) {
+        this.updateOperatorLock(data.lock);
       }
     } catch (error) {
-      console.error('Error starting event:', error);
-      alert('イベント開始に失敗しました: ' + error.message);
-      this.addLog(`イベント開始エラー: ${error.message}`, 'error');
-    } finally {
-      this.elements.startEventBtn.disabled = false;
+      console.error('Error loading operator lock:', error);
     }
   }
 
-  async nextQuestion() {
-    this.elements.nextQuestionBtn.disabled = true;
+  // 操作権がなければ取得、自分が持っていれば解放、他の管理画面なら確認して引き継ぐ
+  async toggleOperatorLock() {
+    const holder = this.operatorLock?.holder;
+    const mine = holder && holder.id === this.operator.id;
+    const force = !!holder && !mine;
+    if (
+      force &&
+      !confirm(`${QuizUtils.OperatorUtils.displayName(holder)} から操作権を引き継ぎますか？`)
+    ) {
+      return;
+    }
 
+    const url = mine ? '/api/admin/lock/release' : '/api/admin/lock';
     try {
-      const response = await fetch('/api/admin/next', {
+      const response = await fetch(QuizUtils.RoomUtils.withRoom(url), {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
+          ...QuizUtils.OperatorUtils.headers(),
         },
+        body: JSON.stringify({ force }),
       });
+      const data = await response.json();
+
+      this.updateOperatorLock(
+        response.ok ? data.lock : QuizUtils.ErrorUtils.data(data).lock
+      );
+      if (!response.ok) {
+        throw new Error(QuizUtils.ErrorUtils.describe(data, 'Failed to change operator lock'));
+      }
+      this.addLog(mine ? '操作権を解放しました' : '操作権を取得しました', 'success');
+    } catch (error) {
+      console.error('Error changing operator lock:', error);
+      this.addLog(`操作権を変更できませんでした: ${error.message}`, 'error');
+    }
+  }
+
+  handleOperatorLock(data) {
+    this.updateOperatorLock(data.holder ? { holder: data.holder, since: data.since } : null);
+
+    if (data.by && data.by.id !== this.operator.id) {
+      const by = QuizUtils.OperatorUtils.displayName(data.by);
+      this.addLog(
+        data.holder ? `🔒 ${by} が操作権を取得しました` : `🔓 ${by} が操作権を解放しました`,
+        'info'
+      );
+    }
+  }
+
+  updateOperatorLock(lock) {
+    this.operatorLock = lock || null;
+
+    const holder = this.operatorLock?.holder;
+    const mine = holder && holder.id === this.operator.id;
+    const status = this.elements.operatorLockStatus;
+    if (status) {
+      status.classList.toggle('mine', !!mine);
+      status.classList.toggle('other', !!holder && !mine);
+      status.textContent = !holder
+        ? '操作権: 全員'
+        : mine
+        ? '操作権: この画面'
+        : `操作権: ${QuizUtils.OperatorUtils.displayName(holder)}`;
+    }
+    if (this.elements.operatorLockBtn) {
+      this.elements.operatorLockBtn.textContent = !holder
+        ? '🔒 操作権を取得'
+        : mine
+        ? '🔓 操作権を解放'
+        : '⚠️ 操作権を引き継ぐ';
+    }
+
+    this.loadAvailableActions();
+    this.elements.screenChannels
+      ?.querySelectorAll('input')
+      .forEach((checkbox) => (checkbox.disabled = this.isLockedOut()));
+  }
+
+  // ログイン中のスタッフを表示する。トークンやIPで入った場合はユーザー名がない
+  async loadIdentity() {
+    try {
+      const response = await fetch('/api/auth/me');
+      if (!response.ok) return;
+      this.identity = await response.json();
+    } catch (error) {
+      console.error('Error loading identity:', error);
+      return;
+    }
+
+    this.readOnly = this.identity.role === 'viewer';
+    document.body.classList.toggle('read-only', this.readOnly);
+    if (this.identity.username) {
+      this.elements.adminUser.textContent = `👤 ${this.identity.username}${this.readOnly ? ' (閲覧のみ)' : ''}`;
+      this.elements.adminUser.classList.remove('hidden');
+      this.elements.logoutBtn.classList.remove('hidden');
+    }
+    this.updateOperatorLock(this.operatorLock);
+  }
+
+  async logout() {
+    try {
+      await fetch('/api/auth/logout', { method: 'POST' });
+    } catch (error) {
+      console.error('Logout error:', error);
+    }
+    window.location.href = '/login';
+  }
+
+  // 他の管理画面が操作権を持っているか (閲覧者は常に操作できない)
+  isLockedOut() {
+    if (this.readOnly) return true;
+    const holder = this.operatorLock?.holder;
+    return !!holder && holder.id !== this.operator.id;
+  }
 
+  // 他の管理画面で実行されたアクション
+  handleAdminAction(data) {
+    if (data.operator && data.operator.id && data.operator.id === this.operator.id) {
+      return;
+    }
+    const by = QuizUtils.OperatorUtils.displayName(data.operator) || '他の管理画面';
+    this.addLog(
+      `👤 ${by} が ${this.actionLabels.get(data.action) || data.action} を実行しました`,
+      'info'
+    );
+    this.loadAvailableActions();
+  }
+
+  // 参加者ごとの接続状態 (切断しても猶予時間内は online のまま)
+  async loadPresence() {
+    try {
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/admin/presence'));
       const data = await response.json();
 
       if (response.ok) {
-        this.currentQuestion = data;
-        this.answers.clear();
-        this.updateQuestionDisplay();
-        this.updateAnswersDisplay();
-        this.addLog(`問題 ${data.question_number} を開始しました`, 'info');
-
-        if (this.currentEvent) {
-          this.currentEvent.question_number = data.question_number;
-          this.updateEventStatus();
-        }
-      } else {
-        throw new Error(data.error || 'Failed to start next question');
+        this.presence.clear();
+        (data.participants || []).forEach((participant) => {
+          this.presence.set(participant.user_id, participant.status);
+        });
+        this.updateParticipantsDisplay();
+        this.updateTeamsDisplay();
       }
     } catch (error) {
-      console.error('Error starting next question:', error);
-      alert('次の問題の開始に失敗しました: ' + error.message);
-      this.addLog(`次の問題開始エラー: ${error.message}`, 'error');
-    } finally {
-      this.elements.nextQuestionBtn.disabled = false;
+      console.error('Error loading presence:', error);
     }
   }
 
-  async sendAlert() {
-    this.elements.countdownAlertBtn.disabled = true;
+  handlePresence(data) {
+    const previous = this.presence.get(data.user_id);
+    this.presence.set(data.user_id, data.status);
 
-    try {
-      const response = await fetch('/api/admin/alert', {
-        method: 'POST',
-        headers: {
-          'Content-Type': 'application/json',
-        },
-      });
+    const user = this.participants.get(data.user_id);
+    if (user) {
+      user.connected = data.status === 'online';
+    }
+    this.updateParticipantsDisplay();
+    this.updateTeamsDisplay();
+
+    switch (data.status) {
+      case 'away':
+        this.addLog(`${data.nickname} の接続が切れました`, 'warning');
+        break;
+      case 'offline':
+        this.addLog(`${data.nickname} がオフラインになりました`, 'info');
+        break;
+      case 'online':
+        if (previous && previous !== 'online') {
+          this.addLog(`${data.nickname} が再接続しました`, 'success');
+        }
+        break;
+    }
+  }
+
+  // connection-status のクラス (online: なし, away: away, offline: disconnected)
+  presenceClass(user) {
+    const status =
+      this.presence.get(user.id) || (user.connected ? 'online' : 'offline');
+    switch (status) {
+      case 'online':
+        return '';
+      case 'away':
+        return 'away';
+      default:
+        return 'disconnected';
+    }
+  }
 
+  async loadAvailableActions() {
+    try {
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/admin/actions'));
       const data = await response.json();
 
       if (response.ok) {
-        this.addLog('5秒アラートを送信しました', 'warning');
-      } else {
-        throw new Error(data.error || 'Failed to send alert');
+        this.currentState = data.current_state;
+        this.currentQuestionNumber = data.question_number;
+        this.updateButtonStates(data.actions || []);
+        this.updateAutopilotStatus(data.autopilot, data.actions || []);
+      }
+    } catch (error) {
+      console.error('Error loading available actions:', error);
+    }
+  }
+
+  updateButtonStates(availableActions) {
+    const container = this.elements.actionButtons;
+    if (!container) return;
+
+    // ボタンはすべてサーバーのフロー定義から生成する
+    container.innerHTML = '';
+
+    if (availableActions.length === 0) {
+      const note = document.createElement('div');
+      note.className = 'no-actions';
+      note.textContent = '実行可能なアクションはありません';
+      container.appendChild(note);
+      return;
+    }
+
+    const lockedOut = this.isLockedOut();
+    availableActions.forEach((available) => {
+      this.actionLabels.set(available.action, available.label || available.action);
+
+      const button = document.createElement('button');
+      button.className = `btn ${
+        ACTION_BUTTON_STYLES[available.action] || 'btn-secondary'
+      }`;
+      button.dataset.action = available.action;
+      button.textContent = available.label || available.action;
+      button.disabled = lockedOut;
+      if (lockedOut) {
+        button.title = this.readOnly
+          ? '閲覧専用のアカウントです'
+          : '他の管理画面が操作権を持っています';
       }
+      button.addEventListener('click', () => {
+        button.disabled = true;
+        this.executeAction(available.action);
+      });
+      container.appendChild(button);
+    });
+  }
+
+  updateAutopilotStatus(autopilot, availableActions) {
+    const element = this.elements.autopilotStatus;
+    if (!element) return;
+
+    clearInterval(this.autopilotTimer);
+    this.autopilotTimer = null;
+
+    if (!autopilot || !autopilot.enabled) {
+      element.classList.add('hidden');
+      return;
+    }
+    element.classList.remove('hidden');
+
+    if (!autopilot.active) {
+      element.textContent = '✋ 手動操作中 (自動進行は停止しています)';
+      return;
+    }
+    if (!autopilot.next_action) {
+      element.textContent = '🤖 自動進行中: 管理者の操作を待っています';
+      return;
+    }
+
+    const next = availableActions.find((a) => a.action === autopilot.next_action);
+    const label = next ? next.label : autopilot.next_action;
+    const deadline = Date.now() + (autopilot.remaining_ms || 0);
+    const render = () => {
+      const seconds = Math.max(0, Math.ceil((deadline - Date.now()) / 1000));
+      element.textContent = `🤖 自動進行中: 「${label}」まで残り${seconds}秒`;
+    };
+    render();
+    this.autopilotTimer = setInterval(render, 1000);
+  }
+
+  async loadSlides() {
+    try {
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/admin/slides'));
+      const data = await response.json();
+      if (!response.ok) return;
+
+      const container = this.elements.slideButtons;
+      container.innerHTML = '';
+      (data.slides || []).forEach((slide) => {
+        const button = document.createElement('button');
+        button.className = 'btn btn-secondary';
+        button.textContent = slide.label;
+        button.addEventListener('click', () => this.showSlide({ slide_id: slide.id }));
+        container.appendChild(button);
+      });
+
+      this.updateActiveSlide(data.active || null);
     } catch (error) {
-      console.error('Error sending alert:', error);
-      alert('アラート送信に失敗しました: ' + error.message);
-      this.addLog(`アラート送信エラー: ${error.message}`, 'error');
-    } finally {
-      setTimeout(() => {
-        this.elements.countdownAlertBtn.disabled = false;
-      }, 2000); // 2秒間無効にして連打を防ぐ
+      console.error('Error loading slides:', error);
+    }
+  }
+
+  updateActiveSlide(slide) {
+    this.elements.slideActive.classList.toggle('hidden', !slide);
+    if (slide) {
+      const label = slide.label || slide.title || slide.id || slide.text;
+      this.elements.slideActiveLabel.textContent = `📢 表示中: ${label}`;
     }
   }
 
-  async stopEvent() {
-    this.elements.stopBtn.disabled = true;
+  async showSlide(request) {
+    const guard = this.guardFor('show_slide');
 
     try {
-      const response = await fetch('/api/admin/stop', {
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/admin/slide'), {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
+          ...QuizUtils.OperatorUtils.headers(),
         },
+        body: JSON.stringify({ ...request, ...guard }),
       });
-
+      this.pendingAction = null;
       const data = await response.json();
 
-      if (response.ok) {
-        this.currentEvent = data.event;
-        this.updateEventStatus();
-        this.addLog('イベントを終了しました', 'success');
-        this.displayFinalResults(data.results);
-      } else {
-        throw new Error(data.error || 'Failed to stop event');
+      if (this.reportRejectedChange('show_slide', response, data)) {
+        return;
       }
+      if (!response.ok) {
+        throw new Error(QuizUtils.ErrorUtils.describe(data, 'Failed to show slide'));
+      }
+      this.addLog(data.message, 'success');
+      this.elements.slideCustomText.value = '';
+      this.updateActiveSlide(data.slide);
     } catch (error) {
-      console.error('Error stopping event:', error);
-      alert('イベント終了に失敗しました: ' + error.message);
-      this.addLog(`イベント終了エラー: ${error.message}`, 'error');
-    } finally {
-      this.elements.stopBtn.disabled = false;
+      console.error('Error showing slide:', error);
+      this.addLog(`スライド表示エラー: ${error.message}`, 'error');
     }
   }
 
-  async createTeams() {
-    this.elements.createTeamsBtn.disabled = true;
+  async dismissSlide() {
+    const guard = this.guardFor('dismiss_slide');
 
     try {
-      const response = await fetch('/api/admin/teams', {
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/admin/slide/dismiss'), {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
+          ...QuizUtils.OperatorUtils.headers(),
         },
+        body: JSON.stringify(guard),
       });
-
+      this.pendingAction = null;
       const data = await response.json();
 
-      if (response.ok) {
-        this.teams.clear();
-        data.teams.forEach((team) => {
-          this.teams.set(team.id, team);
-        });
-        this.updateTeamsDisplay();
-        this.addLog(
-          `チーム分けが完了しました (${data.teams.length}チーム)`,
-          'success'
-        );
-      } else {
-        throw new Error(data.error || 'Failed to create teams');
+      if (this.reportRejectedChange('dismiss_slide', response, data)) {
+        return;
+      }
+      if (!response.ok) {
+        throw new Error(QuizUtils.ErrorUtils.describe(data, 'Failed to dismiss slide'));
       }
+      this.addLog(data.message, 'success');
+      this.updateActiveSlide(null);
     } catch (error) {
-      console.error('Error creating teams:', error);
-      alert('チーム分けに失敗しました: ' + error.message);
-      this.addLog(`チーム分けエラー: ${error.message}`, 'error');
-    } finally {
-      this.elements.createTeamsBtn.disabled = false;
+      console.error('Error dismissing slide:', error);
+      this.addLog(`スライドを閉じられませんでした: ${error.message}`, 'error');
     }
   }
 
-  async loadStatus() {
+  async loadScreenChannels() {
     try {
-      const response = await fetch('/api/status');
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/admin/screens'));
       const data = await response.json();
+      if (!response.ok) return;
 
-      if (response.ok) {
-        this.updateParticipants(data.users || []);
-
-        if (data.teams) {
-          this.teams.clear();
-          data.teams.forEach((team) => {
-            this.teams.set(team.id, team);
-          });
-          this.updateTeamsDisplay();
-        }
-
-        if (data.event) {
-          this.currentEvent = data.event;
-          this.updateEventStatus();
-        }
-
-        if (data.config) {
-          this.teamMode = data.config.team_mode || false;
-          this.updateTeamModeDisplay();
-          // Total questions count - can be displayed in console or elsewhere if needed
-          console.log(`Total questions: ${data.config.questions?.length || 0}`);
-        }
-
-        // Load available actions and update button states
-        this.loadAvailableActions();
-      }
+      this.renderScreenChannels(data.channels || [], data.contents || []);
     } catch (error) {
-      console.error('Error loading status:', error);
+      console.error('Error loading screen channels:', error);
     }
   }
 
-  async loadAvailableActions() {
+  renderScreenChannels(channels, contents) {
+    const labels = {
+      questions: '問題',
+      leaderboard: 'ランキング',
+      emoji: '絵文字',
+      participants: '参加者',
+      slides: 'スライド',
+    };
+
+    const container = this.elements.screenChannels;
+    container.innerHTML = '';
+    channels.forEach((channel) => {
+      const row = document.createElement('div');
+      row.className = 'screen-channel';
+
+      const name = document.createElement('div');
+      name.className = 'screen-channel-name';
+      name.textContent = channel.channel;
+      const count = document.createElement('small');
+      count.textContent = ` (${channel.screens}台接続中)`;
+      name.appendChild(count);
+      row.appendChild(name);
+
+      const shows = document.createElement('div');
+      shows.className = 'screen-channel-shows';
+      contents.forEach((content) => {
+        const label = document.createElement('label');
+        const checkbox = document.createElement('input');
+        checkbox.type = 'checkbox';
+        checkbox.value = content;
+        checkbox.checked = channel.shows.includes(content);
+        checkbox.disabled = this.isLockedOut();
+        checkbox.addEventListener('change', () => {
+          const selected = [...shows.querySelectorAll('input:checked')].map((input) => input.value);
+          this.setScreenChannel(channel.channel, selected);
+        });
+        label.appendChild(checkbox);
+        label.appendChild(document.createTextNode(` ${labels[content] || content}`));
+        shows.appendChild(label);
+      });
+      row.appendChild(shows);
+      container.appendChild(row);
+    });
+  }
+
+  async setScreenChannel(channel, shows) {
+    const guard = this.guardFor('set_screen_channel');
+
     try {
-      const response = await fetch('/api/admin/actions');
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/admin/screens'), {
+        method: 'POST',
+        headers: {
+          'Content-Type': 'application/json',
+          ...QuizUtils.OperatorUtils.headers(),
+        },
+        body: JSON.stringify({ channel, shows, ...guard }),
+      });
+      this.pendingAction = null;
       const data = await response.json();
 
-      if (response.ok) {
-        this.updateButtonStates(data.available_actions || []);
+      if (!this.reportRejectedChange('set_screen_channel', response, data)) {
+        if (!response.ok) {
+          throw new Error(QuizUtils.ErrorUtils.describe(data, 'Failed to update screen channel'));
+        }
+        this.addLog(data.message, 'success');
       }
     } catch (error) {
-      console.error('Error loading available actions:', error);
+      console.error('Error updating screen channel:', error);
+      this.addLog(`スクリーン設定エラー: ${error.message}`, 'error');
     }
+    this.loadScreenChannels(); // 失敗したときはチェックを元に戻す
   }
 
-  updateButtonStates(availableActions) {
-    const buttonMap = {
-      show_title: this.elements.showTitleBtn,
-      assign_teams: this.elements.assignTeamsBtn,
-      next_question: this.elements.nextQuestionBtn,
-      countdown_alert: this.elements.countdownAlertBtn,
-      show_answer_stats: this.elements.showAnswerStatsBtn,
-      reveal_answer: this.elements.revealAnswerBtn,
-      show_results: this.elements.showResultsBtn,
-      celebration: this.elements.celebrationBtn,
+  // 状態を変える操作に付ける expected_state と idempotency_key
+  guardFor(action) {
+    // 送信に失敗した操作の再実行は同じキーで送り、二重実行を防ぐ
+    if (!this.pendingAction || this.pendingAction.action !== action) {
+      this.pendingAction = {
+        action,
+        key: `${this.operator.id}-${Date.now()}-${Math.random().toString(36).slice(2, 8)}`,
+      };
+    }
+    return {
+      expected_state: this.currentState,
+      expected_question_number: this.currentQuestionNumber,
+      idempotency_key: this.pendingAction.key,
     };
+  }
 
-    // すべてのボタンを無効にし、利用可能なもののみ有効にする
-    Object.values(buttonMap).forEach((button) => {
-      if (button) button.disabled = true;
-    });
-
-    availableActions.forEach((action) => {
-      const button = buttonMap[action];
-      if (button) {
-        button.disabled = false;
-      }
-    });
-
-    // チーム分けボタンの表示制御
-    if (this.elements.assignTeamsBtn) {
-      this.elements.assignTeamsBtn.style.display = availableActions.includes(
-        'assign_teams'
-      )
-        ? 'inline-block'
-        : 'none';
+  // 409 (状態が変わった) と 423 (操作権がない) を知らせる。知らせたら true
+  reportRejectedChange(action, response, data) {
+    if (response.status === 409) {
+      // 他の管理画面やタイマーが先に進めた
+      this.addLog(
+        `${this.actionLabels.get(action) || action} は実行しませんでした (状態が変わっています)`,
+        'warning'
+      );
+      this.loadAvailableActions();
+      return true;
+    }
+    if (response.status === 423) {
+      const lock = QuizUtils.ErrorUtils.data(data).lock;
+      this.updateOperatorLock(lock);
+      this.addLog(
+        `${QuizUtils.OperatorUtils.displayName(lock?.holder)} が操作権を持っているため実行できません`,
+        'warning'
+      );
+      return true;
     }
+    return false;
   }
 
   async executeAction(action) {
+    const guard = this.guardFor(action);
+
     try {
-      const response = await fetch('/api/admin/action', {
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/admin/action'), {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
+          ...QuizUtils.OperatorUtils.headers(),
         },
-        body: JSON.stringify({ action: action }),
+        body: JSON.stringify({ action: action, ...guard }),
       });
+      this.pendingAction = null;
 
       const data = await response.json();
 
+      if (this.reportRejectedChange(action, response, data)) {
+        return;
+      }
+
       if (response.ok) {
         this.addLog(data.message || `${action} を実行しました`, 'success');
 
@@ -619,6 +1075,10 @@ class QuizAdmin {
               this.displayFinalResults(data.results);
             }
             break;
+          case 'go_back':
+            // 戻った先の問題・回答状況は initial_sync で再構築される
+            this.loadStatus();
+            break;
         }
 
         if (data.event) {
@@ -626,7 +1086,7 @@ class QuizAdmin {
           this.updateEventStatus();
         }
       } else {
-        throw new Error(data.error || `Failed to execute ${action}`);
+        throw new Error(QuizUtils.ErrorUtils.describe(data, `Failed to execute ${action}`));
       }
     } catch (error) {
       console.error(`Error executing ${action}:`, error);
@@ -639,9 +1099,6 @@ class QuizAdmin {
     if (!this.currentEvent) {
       // this.elements.eventStatus.textContent = '待機中';
       this.elements.currentQuestion.textContent = '-';
-      this.elements.startEventBtn.disabled = false;
-      this.elements.nextQuestionBtn.disabled = true;
-      this.elements.countdownAlertBtn.disabled = true;
       return;
     }
 
@@ -651,13 +1108,6 @@ class QuizAdmin {
 
     this.elements.currentQuestion.textContent =
       this.currentEvent.question_number - 1 || 0; // FIXME: 0問目スタートのための暫定対応
-
-    this.elements.startEventBtn.disabled =
-      this.currentEvent.status === 'started';
-    this.elements.nextQuestionBtn.disabled =
-      this.currentEvent.status !== 'started';
-    this.elements.countdownAlertBtn.disabled =
-      this.currentEvent.status !== 'started';
   }
 
   updateParticipants(users) {
@@ -711,6 +1161,11 @@ class QuizAdmin {
         pingStatusHtml = '<span class="ping-status unknown">--ms</span>';
       }
 
+      const degraded = this.degradedConnections.get(user.id);
+      if (degraded) {
+        pingStatusHtml += `<span class="ping-status bad" title="p50 ${degraded.latency.p50}ms / p95 ${degraded.latency.p95}ms">⚠️ ${this.connectionProblem(degraded)}</span>`;
+      }
+
       // // Get sync status for this user
       // const syncData = this.syncStatus.get(user.nickname);
       // let syncStatusHtml = '<span class="sync-status unknown">🔄 未知</span>';
@@ -740,9 +1195,9 @@ class QuizAdmin {
 
       item.innerHTML = `
                 <div class="participant-info">
-                    <div class="connection-status ${
-                      user.connected ? '' : 'disconnected'
-                    }"></div>
+                    <div class="connection-status ${this.presenceClass(
+                      user
+                    )}"></div>
                     <span class="participant-name">${user.nickname}</span>
                     ${pingStatusHtml}
                 </div>
@@ -850,11 +1305,14 @@ class QuizAdmin {
       return;
     }
 
-    const totalParticipants = this.participants.size;
-    const answeredCount = this.answers.size;
-    const correctCount = Array.from(this.answers.values()).filter(
-      (answer) => answer.is_correct
-    ).length;
+    // サーバーが数えた回答数があればそちらを使う
+    const live = this.liveAnswerCount;
+    const totalParticipants = live ? live.total_participants : this.participants.size;
+    const answeredCount = live ? live.answered_count : this.answers.size;
+    const correct = this.currentQuestion.correct;
+    const correctCount = live?.choices_counts && correct
+      ? live.choices_counts[correct - 1] || 0
+      : Array.from(this.answers.values()).filter((answer) => answer.is_correct).length;
 
     let html = `
             <div class="answer-stats">
@@ -881,6 +1339,21 @@ class QuizAdmin {
             </div>
         `;
 
+    if (live?.choices_counts) {
+      html += '<div class="answer-distribution">';
+      live.choices_counts.forEach((count, index) => {
+        const percent = Math.round((count / Math.max(answeredCount, 1)) * 100);
+        html += `
+            <div class="answer-distribution-row${index + 1 === correct ? ' correct' : ''}">
+                <span class="answer-distribution-label">${String.fromCharCode(65 + index)}</span>
+                <div class="answer-distribution-bar"><div style="width: ${percent}%"></div></div>
+                <span class="answer-distribution-count">${count}人</span>
+            </div>
+        `;
+      });
+      html += '</div>';
+    }
+
     this.elements.answersDisplay.innerHTML = html;
   }
 
@@ -928,9 +1401,9 @@ class QuizAdmin {
             .map(
               (member) => `
                 <div class="team-member">
-                    <div class="connection-status ${
-                      member.connected ? '' : 'disconnected'
-                    }"></div>
+                    <div class="connection-status ${this.presenceClass(
+                      member
+                    )}"></div>
                     <span>${member.nickname}</span>
                     <span class="member-score">${member.score}点</span>
                 </div>
@@ -1022,6 +1495,47 @@ class QuizAdmin {
     );
   }
 
+  async loadConnectionQuality() {
+    try {
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/admin/connections'));
+      const data = await response.json();
+      if (response.ok) {
+        this.handleConnectionQuality(data);
+      }
+    } catch (error) {
+      console.error('Error loading connection quality:', error);
+    }
+  }
+
+  // 通信が不安定になった参加者を問題の前に呼びかけられるよう表示する
+  handleConnectionQuality(data) {
+    const previous = this.degradedConnections;
+    this.degradedConnections = new Map((data.degraded || []).map((stats) => [stats.user_id, stats]));
+
+    this.degradedConnections.forEach((stats, userId) => {
+      if (!previous.has(userId)) {
+        this.addLog(`${stats.nickname} の通信が不安定です (${this.connectionProblem(stats)})`, 'warning');
+      }
+    });
+
+    const element = this.elements.connectionWarning;
+    if (element) {
+      const names = [...this.degradedConnections.values()].map(
+        (stats) => `${stats.nickname} (${this.connectionProblem(stats)})`
+      );
+      element.classList.toggle('hidden', names.length === 0);
+      element.textContent = `⚠️ 通信が不安定な参加者: ${names.join(', ')}`;
+    }
+
+    this.updateParticipantsDisplay();
+  }
+
+  connectionProblem(stats) {
+    return stats.quality === 'unstable'
+      ? `応答なし ${stats.latency.timeouts}回`
+      : `遅延 ${stats.latency.p95}ms`;
+  }
+
   handlePingResult(data) {
     // Store ping result for the user
     this.pingResults.set(data.nickname, {
@@ -1090,7 +1604,7 @@ class QuizAdmin {
 
   async loadAvailableStates() {
     try {
-      const response = await fetch('/api/admin/available-states');
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/admin/available-states'));
       if (response.ok) {
         const data = await response.json();
         this.populateStateOptions(data.available_states);
@@ -1130,7 +1644,7 @@ class QuizAdmin {
 
   async loadSyncStatus() {
     try {
-      const response = await fetch('/api/ws/sync-status');
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/ws/sync-status'));
       if (response.ok) {
         const data = await response.json();
         this.updateSyncStatusDisplay(data);
@@ -1165,7 +1679,7 @@ class QuizAdmin {
 
   async requestClientSync(userID) {
     try {
-      const response = await fetch('/api/ws/sync-client', {
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/ws/sync-client'), {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
@@ -1184,7 +1698,7 @@ class QuizAdmin {
         setTimeout(() => this.loadSyncStatus(), 1000);
       } else {
         const error = await response.json();
-        this.addLog(`同期要求エラー: ${error.error}`, 'error');
+        this.addLog(`同期要求エラー: ${QuizUtils.ErrorUtils.describe(error)}`, 'error');
       }
     } catch (error) {
       console.error('Client sync request failed:', error);
@@ -1194,7 +1708,7 @@ class QuizAdmin {
 
   async syncAllClients() {
     try {
-      const response = await fetch('/api/ws/sync-all', {
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/ws/sync-all'), {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
@@ -1209,7 +1723,7 @@ class QuizAdmin {
         setTimeout(() => this.loadSyncStatus(), 1000);
       } else {
         const error = await response.json();
-        this.addLog(`全体同期要求エラー: ${error.error}`, 'error');
+        this.addLog(`全体同期要求エラー: ${QuizUtils.ErrorUtils.describe(error)}`, 'error');
       }
     } catch (error) {
       console.error('Sync all clients failed:', error);
@@ -1241,10 +1755,11 @@ class QuizAdmin {
         requestBody.question_number = parseInt(questionNumber);
       }
 
-      const response = await fetch('/api/admin/jump-state', {
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/admin/jump-state'), {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
+          ...QuizUtils.OperatorUtils.headers(),
         },
         body: JSON.stringify(requestBody),
       });
@@ -1260,7 +1775,7 @@ class QuizAdmin {
         this.elements.jumpQuestionInput.value = '';
       } else {
         const error = await response.json();
-        this.addLog(`ステートジャンプエラー: ${error.error}`, 'error');
+        this.addLog(`ステートジャンプエラー: ${QuizUtils.ErrorUtils.describe(error)}`, 'error');
       }
     } catch (error) {
       console.error('State jump failed:', error);
@@ -1287,10 +1802,11 @@ class QuizAdmin {
     try {
       this.addLog('データベースをリセット中...', 'warning');
 
-      const response = await fetch('/api/admin/reset-database', {
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/admin/reset-database'), {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
+          ...QuizUtils.OperatorUtils.headers(),
         },
       });
 
@@ -1309,7 +1825,7 @@ class QuizAdmin {
         }, 3000);
       } else {
         const error = await response.json();
-        this.addLog(`DBリセットエラー: ${error.error}`, 'error');
+        this.addLog(`DBリセットエラー: ${QuizUtils.ErrorUtils.describe(error)}`, 'error');
       }
     } catch (error) {
       console.error('Database reset failed:', error);
@@ -13,6 +13,8 @@ const EVENT_STATES = {
     COUNTDOWN_ACTIVE: 'countdown_active',
     ANSWER_STATS: 'answer_stats',
     ANSWER_REVEAL: 'answer_reveal',
+    LEADERBOARD: 'leaderboard',
+    INTERMISSION: 'intermission',
     RESULTS: 'results',
     CELEBRATION: 'celebration',
     FINISHED: 'finished'
@@ -28,6 +30,8 @@ const STATE_LABELS = {
     [EVENT_STATES.COUNTDOWN_ACTIVE]: 'カウントダウン中',
     [EVENT_STATES.ANSWER_STATS]: '回答状況表示',
     [EVENT_STATES.ANSWER_REVEAL]: '回答発表',
+    [EVENT_STATES.LEADERBOARD]: '途中経過発表',
+    [EVENT_STATES.INTERMISSION]: '休憩',
     [EVENT_STATES.RESULTS]: '結果発表',
     [EVENT_STATES.CELEBRATION]: 'お疲れ様画面',
     [EVENT_STATES.FINISHED]: '終了'
@@ -50,12 +54,32 @@ const MESSAGE_TYPES = {
     ANSWER_RECEIVED: 'answer_received',
     EMOJI_REACTION: 'emoji',
     TEAM_MEMBER_ADDED: 'team_member_added',
+    PRESENCE: 'presence',
     
     // Quiz progress messages
     COUNTDOWN: 'countdown',
     ANSWER_STATS: 'answer_stats',
+    ANSWER_COUNT: 'answer_count',
     ANSWER_REVEAL: 'answer_reveal',
+    LEADERBOARD: 'leaderboard',
+    INTERMISSION: 'intermission',
     STATE_CHANGED: 'state_changed',
+    EVENT_PAUSED: 'event_paused',
+    EVENT_RESUMED: 'event_resumed',
+    
+    // Admin console coordination
+    ADMIN_ACTION: 'admin_action',
+    OPERATOR_LOCK: 'operator_lock',
+    
+    SLIDE_SHOW: 'slide_show',
+    SLIDE_HIDE: 'slide_hide',
+    SCREEN_CHANNEL: 'screen_channel',
+    
+    // Connectivity
+    CONNECTION_QUALITY: 'connection_quality',
+    
+    // Several high-frequency messages of one type sent together
+    BATCH: 'batch',
     
     // Legacy/deprecated
     TIME_ALERT: 'time_alert' // DEPRECATED: use countdown instead
@@ -68,12 +92,27 @@ const ADMIN_ACTIONS = {
     ASSIGN_TEAMS: 'assign_teams',
     NEXT_QUESTION: 'next_question',
     COUNTDOWN_ALERT: 'countdown_alert',
+    CLOSE_ANSWERS: 'close_answers',
+    REOPEN_ANSWERS: 'reopen_answers',
     SHOW_ANSWER_STATS: 'show_answer_stats',
     REVEAL_ANSWER: 'reveal_answer',
+    SHOW_LEADERBOARD: 'show_leaderboard',
+    SHOW_INTERMISSION: 'show_intermission',
     SHOW_RESULTS: 'show_results',
-    CELEBRATION: 'celebration'
+    CELEBRATION: 'celebration',
+    FINISH: 'finish',
+    GO_BACK: 'go_back',
+    PAUSE: 'pause',
+    RESUME: 'resume',
+    AUTOPILOT_SKIP: 'autopilot_skip',
+    AUTOPILOT_EXTEND: 'autopilot_extend',
+    AUTOPILOT_MANUAL: 'autopilot_manual',
+    AUTOPILOT_AUTO: 'autopilot_auto'
 };
 
+// WebSocket protocol version this page speaks - matches websocket/payloads.go ProtocolVersion
+const PROTOCOL_VERSION = 1;
+
 // Client Types - matches websocket/websocket.go ClientType
 const CLIENT_TYPES = {
     PARTICIPANT: 'participant',
@@ -100,6 +139,7 @@ const LOG_TYPES = {
 if (typeof module !== 'undefined' && module.exports) {
     // Node.js environment
     module.exports = {
+        PROTOCOL_VERSION,
         EVENT_STATES,
         STATE_LABELS,
         MESSAGE_TYPES,
@@ -111,6 +151,7 @@ if (typeof module !== 'undefined' && module.exports) {
 } else {
     // Browser environment - make constants available globally
     window.QuizConstants = {
+        PROTOCOL_VERSION,
         EVENT_STATES,
         STATE_LABELS,
         MESSAGE_TYPES,
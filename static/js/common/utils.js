@@ -83,11 +83,67 @@ const MessageUtils = {
         }
         
         return message;
+    },
+
+    /**
+     * Split a batch message into the messages it carries
+     * @param {Object} message - Parsed WebSocket message
+     * @returns {Object[]} The items as messages of their own type, or the message itself
+     */
+    unbatch(message) {
+        if (message.type !== 'batch') {
+            return [message];
+        }
+        return message.data.items.map(data => ({ type: message.data.type, data }));
     }
 };
 
 // Error handling utilities
 const ErrorUtils = {
+    /**
+     * Message of an API error response ({ error: { code, message, details, data } })
+     * @param {object} body - Parsed response body
+     * @param {string} fallback - Message when the body carries no error
+     * @returns {string} Error message
+     */
+    message(body, fallback = 'Unknown error') {
+        const error = body && body.error;
+        if (!error) {
+            return (body && body.message) || fallback;
+        }
+        return typeof error === 'string' ? error : (error.message || fallback);
+    },
+
+    /**
+     * Message of an API error response with its details, for operator logs
+     * @param {object} body - Parsed response body
+     * @param {string} fallback - Message when the body carries no error
+     * @returns {string} Error message
+     */
+    describe(body, fallback) {
+        const message = this.message(body, fallback);
+        const details = body && body.error && body.error.details;
+        return details ? `${message} (${details})` : message;
+    },
+
+    /**
+     * Code of an API error response (e.g. 'ALREADY_ANSWERED'), or '' without one
+     * @param {object} body - Parsed response body
+     * @returns {string} Error code
+     */
+    code(body) {
+        return (body && body.error && body.error.code) || '';
+    },
+
+    /**
+     * Extra values of an API error response (e.g. retry_after, lock)
+     * @param {object} body - Parsed response body
+     * @returns {object} Error data, empty without one
+     */
+    data(body) {
+        return (body && body.error && body.error.data) || {};
+    },
+
     /**
      * Handle API errors with consistent error messages
      * @param {Error|Response} error - Error object or fetch response
@@ -100,7 +156,7 @@ const ErrorUtils = {
         try {
             if (error instanceof Response) {
                 const data = await error.json();
-                message += `: ${data.error || data.message || 'Unknown error'}`;
+                message += `: ${this.message(data)}`;
             } else if (error instanceof Error) {
                 message += `: ${error.message}`;
             } else {
@@ -246,6 +302,219 @@ const TimeUtils = {
     }
 };
 
+// Room utilities
+const RoomUtils = {
+    /**
+     * Get the room code from the page URL (?room=)
+     * @returns {string} Room code or empty string when none is given
+     */
+    getRoomCode() {
+        const code = new URLSearchParams(window.location.search).get('room');
+        return code ? code.trim().toUpperCase() : '';
+    },
+
+    /**
+     * Put the room code into the page URL so reloads stay in the same room
+     * @param {string} code - Room code, or empty to remove it
+     */
+    setRoomCode(code) {
+        const url = new URL(window.location.href);
+        if (code) {
+            url.searchParams.set('room', code.trim().toUpperCase());
+        } else {
+            url.searchParams.delete('room');
+        }
+        window.history.replaceState(null, '', url);
+    },
+
+    /**
+     * Add the room code to an API or WebSocket URL
+     * @param {string} url - URL to extend
+     * @returns {string} URL with the room query parameter
+     */
+    withRoom(url) {
+        const code = this.getRoomCode();
+        if (!code) {
+            return url;
+        }
+        const separator = url.includes('?') ? '&' : '?';
+        return `${url}${separator}room=${encodeURIComponent(code)}`;
+    }
+};
+
+// Operator (admin console) identity
+const OperatorUtils = {
+    /**
+     * Get this console's operator ID and name, creating the ID on first use
+     * @returns {{id: string, name: string}} Operator
+     */
+    getOperator() {
+        let id = localStorage.getItem('quiz_operator_id');
+        if (!id) {
+            id = `${Date.now().toString(36)}-${Math.random().toString(36).slice(2, 10)}`;
+            localStorage.setItem('quiz_operator_id', id);
+        }
+        return { id, name: localStorage.getItem('quiz_operator_name') || '' };
+    },
+
+    /**
+     * Remember the name other consoles see for this operator
+     * @param {string} name - Operator name
+     */
+    setName(name) {
+        localStorage.setItem('quiz_operator_name', name.trim());
+    },
+
+    /**
+     * Headers that identify this console to admin APIs
+     * @returns {Object} X-Operator-ID and X-Operator-Name (URL-encoded)
+     */
+    headers() {
+        const operator = this.getOperator();
+        return {
+            'X-Operator-ID': operator.id,
+            'X-Operator-Name': encodeURIComponent(operator.name),
+        };
+    },
+
+    /**
+     * Name of an operator for display
+     * @param {{id: string, name: string}} operator - Operator
+     * @returns {string} Name, or the start of the ID when unnamed
+     */
+    displayName(operator) {
+        if (!operator || (!operator.name && !operator.id)) {
+            return '';
+        }
+        return operator.name || `管理画面 ${operator.id.slice(-4)}`;
+    }
+};
+
+// Message sequence tracking for gap-free reconnects
+class MessageSequence {
+    constructor() {
+        this.epoch = null;
+        this.lastSeq = 0;
+        this.protocol = null; // Version the server chose
+    }
+
+    /**
+     * Remember the sequence number of a received message.
+     * initial_sync starts over (the server may have restarted).
+     * @param {Object} message - Parsed WebSocket message
+     */
+    track(message) {
+        if (message.type === 'initial_sync' && message.epoch) {
+            this.epoch = message.epoch;
+            this.lastSeq = message.seq || 0;
+            this.protocol = message.protocol || 1;
+        } else if (message.seq) {
+            this.lastSeq = Math.max(this.lastSeq, message.seq);
+        }
+    }
+
+    /**
+     * Add the protocol version and the resume point to a WebSocket (or SSE) URL
+     * so the server replays only the missed messages (or sends a full
+     * initial_sync when it cannot)
+     * @param {string} url - WebSocket URL
+     * @returns {string} URL with protocol, last_seq and epoch
+     */
+    connectURL(url) {
+        const constants = window.QuizConstants || {};
+        const separator = url.includes('?') ? '&' : '?';
+        let connectURL = `${url}${separator}protocol=${constants.PROTOCOL_VERSION || 1}`;
+        if (this.epoch) {
+            connectURL += `&last_seq=${this.lastSeq}&epoch=${encodeURIComponent(this.epoch)}`;
+        }
+        return connectURL;
+    }
+}
+
+// Slide utilities
+const SlideUtils = {
+    /**
+     * Escape text for use in HTML
+     * @param {string} text - Raw text
+     * @returns {string} Escaped text
+     */
+    escapeHtml(text) {
+        const div = document.createElement('div');
+        div.textContent = text ?? '';
+        return div.innerHTML;
+    },
+
+    /**
+     * Render inline markdown: **bold** and images ![alt](file in /images)
+     * @param {string} text - One line of markdown
+     * @returns {string} HTML
+     */
+    renderInline(text) {
+        return this.escapeHtml(text)
+            .replace(/!\[([^\]]*)\]\(([^)\s]+)\)/g, (match, alt, src) =>
+                `<img class="slide-inline-image" src="/images/${encodeURI(src)}" alt="${alt.replace(/"/g, '&quot;')}">`)
+            .replace(/\*\*([^*]+)\*\*/g, '<strong>$1</strong>');
+    },
+
+    /**
+     * Render the markdown subset used by slides: # headings, - lists, paragraphs
+     * @param {string} markdown - Slide body
+     * @returns {string} HTML
+     */
+    renderMarkdown(markdown) {
+        const html = [];
+        let list = false;
+
+        (markdown || '').split('\n').forEach((rawLine) => {
+            const line = rawLine.trim();
+            const item = line.match(/^[-*]\s+(.*)$/);
+            if (list && !item) {
+                html.push('</ul>');
+                list = false;
+            }
+
+            const heading = line.match(/^(#{1,3})\s+(.*)$/);
+            if (heading) {
+                const level = heading[1].length;
+                html.push(`<h${level}>${this.renderInline(heading[2])}</h${level}>`);
+            } else if (item) {
+                if (!list) {
+                    html.push('<ul>');
+                    list = true;
+                }
+                html.push(`<li>${this.renderInline(item[1])}</li>`);
+            } else if (line) {
+                html.push(`<p>${this.renderInline(line)}</p>`);
+            }
+        });
+
+        if (list) {
+            html.push('</ul>');
+        }
+        return html.join('');
+    },
+
+    /**
+     * Render the content of an announcement slide
+     * @param {Object} slide - Slide from slide_show or initial_sync
+     * @returns {string} HTML
+     */
+    render(slide) {
+        const title = slide.title ? `<h1 class="slide-title">${this.escapeHtml(slide.title)}</h1>` : '';
+
+        switch (slide.type) {
+            case 'image':
+                return `<img class="slide-image" src="/images/${encodeURI(slide.image)}" alt="">${title}`;
+            case 'markdown':
+                return `${title}<div class="slide-markdown">${this.renderMarkdown(slide.text)}</div>`;
+            default: {
+                const text = this.escapeHtml(slide.text).replace(/\n/g, '<br>');
+                return `${title}<p class="slide-text">${text}</p>`;
+            }
+        }
+    }
+};
+
 // Export utilities
 if (typeof module !== 'undefined' && module.exports) {
     // Node.js environment
@@ -255,7 +524,11 @@ if (typeof module !== 'undefined' && module.exports) {
         ErrorUtils,
         ValidationUtils,
         DOMUtils,
-        TimeUtils
+        TimeUtils,
+        RoomUtils,
+        OperatorUtils,
+        MessageSequence,
+        SlideUtils
     };
 } else {
     // Browser environment - make utilities available globally
@@ -265,6 +538,10 @@ if (typeof module !== 'undefined' && module.exports) {
         ErrorUtils,
         ValidationUtils,
         DOMUtils,
-        TimeUtils
+        TimeUtils,
+        RoomUtils,
+        OperatorUtils,
+        MessageSequence,
+        SlideUtils
     };
 }
\ No newline at end of file
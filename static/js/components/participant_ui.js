@@ -9,7 +9,7 @@ class ParticipantUI {
         this.currentScreen = 'join';
         
         // 参加者データ
-        this.sessionId = localStorage.getItem('quiz_session_id');
+        this.sessionId = localStorage.getItem('quiz_session_token');
         this.userInfo = null;
         this.currentQuestion = null;
         this.selectedAnswer = null;
@@ -535,11 +535,11 @@ class ParticipantUI {
         console.log('[ParticipantUI] Join successful:', userData);
         
         this.userInfo = userData.user;
-        this.sessionId = userData.session_id;
+        this.sessionId = userData.session_token;
         this.isJoined = true;
         
-        // セッションIDを保存
-        localStorage.setItem('quiz_session_id', this.sessionId);
+        // セッショントークンを保存
+        localStorage.setItem('quiz_session_token', this.sessionId);
         
         // 待機画面に遷移
         this.showWaitingScreen({});
@@ -706,7 +706,7 @@ class ParticipantUI {
     }
 
     clearLocalData() {
-        localStorage.removeItem('quiz_session_id');
+        localStorage.removeItem('quiz_session_token');
         this.sessionId = null;
         this.userInfo = null;
         this.isJoined = false;
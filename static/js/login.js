@@ -0,0 +1,71 @@
+// スタッフ (オペレーター・閲覧者・スクリーン) のログイン
+class QuizLogin {
+  constructor() {
+    this.form = document.getElementById('login-form');
+    this.username = document.getElementById('username');
+    this.password = document.getElementById('password');
+    this.error = document.getElementById('login-error');
+    this.button = document.getElementById('login-btn');
+
+    this.form.addEventListener('submit', (e) => {
+      e.preventDefault();
+      this.login();
+    });
+    this.username.focus();
+  }
+
+  async login() {
+    this.button.disabled = true;
+    this.error.classList.add('hidden');
+
+    try {
+      const response = await fetch('/api/auth/login', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({
+          username: this.username.value.trim(),
+          password: this.password.value,
+        }),
+      });
+      const data = await response.json();
+
+      if (!response.ok) {
+        if (response.status === 401) {
+          this.showError('ユーザー名またはパスワードが違います');
+        } else if (response.status === 429) {
+          this.showError(`ログインの失敗が続いたため、${response.headers.get('Retry-After')}秒後に再度お試しください`);
+        } else {
+          this.showError(`ログインに失敗しました: ${QuizUtils.ErrorUtils.message(data, response.status)}`);
+        }
+        return;
+      }
+      window.location.href = this.nextPage(data.role);
+    } catch (error) {
+      console.error('Login error:', error);
+      this.showError('サーバーに接続できません');
+    } finally {
+      this.button.disabled = false;
+    }
+  }
+
+  // ログイン前に開こうとしたページに戻る。なければ役割ごとのページへ
+  nextPage(role) {
+    const next = new URLSearchParams(window.location.search).get('next');
+    // 他のサイトへ飛ばされないよう、同じサイト内のパスだけを受け付ける
+    if (next && /^\/(?![/\\])/.test(next)) {
+      return next;
+    }
+    return role === 'screen' ? '/show' : '/admin';
+  }
+
+  showError(message) {
+    this.password.value = '';
+    this.error.textContent = message;
+    this.error.classList.remove('hidden');
+    this.password.focus();
+  }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+  new QuizLogin();
+});
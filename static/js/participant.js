@@ -1,7 +1,14 @@
 class QuizParticipant {
   constructor() {
     this.ws = null;
-    this.sessionID = localStorage.getItem('quiz_session_id') || null;
+    this.eventSource = null; // WebSocketが使えないネットワーク向けのSSE接続
+    this.useSSE = new URLSearchParams(window.location.search).get('transport') === 'sse';
+    this.wsFailures = 0;
+    this.messageSequence = new QuizUtils.MessageSequence();
+    // 署名付きのセッショントークン。期限の半分が過ぎたら更新する
+    this.sessionToken = localStorage.getItem('quiz_session_token') || null;
+    this.sessionRefreshTimer = null;
+    localStorage.removeItem('quiz_session_id'); // 旧形式のセッションIDは使えない
     this.user = null;
     this.currentQuestion = null;
     this.selectedAnswer = null;
@@ -9,14 +16,18 @@ class QuizParticipant {
     this.answersBlocked = false;
     this.answerRevealed = false;
     this.currentEventState = null;
+    this.paused = false;
+    this.pendingRequests = new Map(); // request_id -> WebSocket応答待ち
+    this.requestCounter = 0;
 
     this.initializeElements();
     this.setupEventListeners();
     this.preventZoom();
 
-    if (this.sessionID) {
+    if (this.sessionToken) {
       this.rejoinSession();
     }
+    this.loadRoomInfo();
   }
 
   initializeElements() {
@@ -24,6 +35,9 @@ class QuizParticipant {
       connectionStatus: document.getElementById('connection-status'),
       connectionText: document.getElementById('connection-text'),
       connectionUsername: document.getElementById('connection-username'),
+      pausedBanner: document.getElementById('paused-banner'),
+      slideOverlay: document.getElementById('slide-overlay'),
+      slideContent: document.getElementById('slide-content'),
 
       resetSessionBtn: document.getElementById('reset-session-btn'),
       resetModal: document.getElementById('reset-modal'),
@@ -31,6 +45,7 @@ class QuizParticipant {
       resetConfirmBtn: document.getElementById('reset-confirm-btn'),
 
       joinSection: document.getElementById('join-section'),
+      roomCode: document.getElementById('room-code'),
       nickname: document.getElementById('nickname'),
       joinBtn: document.getElementById('join-btn'),
 
@@ -117,26 +132,38 @@ class QuizParticipant {
   }
 
   connectWebSocket() {
-    if (!this.sessionID) return;
+    if (!this.sessionToken) return;
+    if (this.useSSE) {
+      this.connectEventSource();
+      return;
+    }
 
     const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-    const wsUrl = `${protocol}//${window.location.host}/ws/participant?session_id=${this.sessionID}`;
+    const wsUrl = QuizUtils.RoomUtils.withRoom(`${protocol}//${window.location.host}/ws/participant?session_token=${encodeURIComponent(this.sessionToken)}`);
 
-    this.ws = new WebSocket(wsUrl);
+    this.ws = new WebSocket(this.messageSequence.connectURL(wsUrl));
+    let opened = false;
 
     this.ws.onopen = () => {
       console.log('WebSocket connected');
+      opened = true;
+      this.wsFailures = 0;
       this.updateConnectionStatus(true);
     };
 
-    this.ws.onmessage = (event) => {
-      const message = JSON.parse(event.data);
-      this.handleWebSocketMessage(message);
-    };
+    this.ws.onmessage = (event) => this.handleRawMessage(event.data);
 
     this.ws.onclose = () => {
       console.log('WebSocket disconnected');
       this.updateConnectionStatus(false);
+      this.failPendingRequests();
+
+      // 一度も接続できないまま2回失敗したら、プロキシ等がWebSocketを通さないとみなしてSSEに切り替える
+      if (!opened && ++this.wsFailures >= 2) {
+        console.warn('WebSocket unavailable, falling back to SSE');
+        this.ws = null;
+        this.useSSE = true;
+      }
       setTimeout(() => this.connectWebSocket(), 3000);
     };
 
@@ -146,6 +173,36 @@ class QuizParticipant {
     };
   }
 
+  // サーバーからの受信だけをSSEで行う。回答・絵文字はHTTPで送る
+  connectEventSource() {
+    if (this.eventSource) return;
+
+    const url = QuizUtils.RoomUtils.withRoom(`/sse/participant?session_token=${encodeURIComponent(this.sessionToken)}`);
+    this.eventSource = new EventSource(this.messageSequence.connectURL(url));
+
+    this.eventSource.onopen = () => {
+      console.log('SSE connected');
+      this.updateConnectionStatus(true);
+    };
+
+    this.eventSource.onmessage = (event) => this.handleRawMessage(event.data);
+
+    this.eventSource.onerror = () => {
+      this.updateConnectionStatus(false);
+      // CONNECTINGの間はブラウザが自動で再接続する (Last-Event-IDで取りこぼしを再送)
+      if (this.eventSource.readyState === EventSource.CLOSED) {
+        this.eventSource = null;
+        setTimeout(() => this.connectWebSocket(), 3000);
+      }
+    };
+  }
+
+  handleRawMessage(raw) {
+    const message = JSON.parse(raw);
+    this.messageSequence.track(message);
+    QuizUtils.MessageUtils.unbatch(message).forEach(item => this.handleWebSocketMessage(item));
+  }
+
   handleWebSocketMessage(message) {
     console.log('Received message:', message);
 
@@ -172,6 +229,11 @@ class QuizParticipant {
         }
         break;
 
+      case 'leaderboard':
+      case 'intermission':
+        this.showWaiting();
+        break;
+
       case 'final_results':
         this.showResults(message.data);
         break;
@@ -187,10 +249,31 @@ class QuizParticipant {
         this.handleStateChanged(message.data);
         break;
 
+      case 'event_paused':
+        this.setPaused(true);
+        break;
+
+      case 'event_resumed':
+        this.setPaused(false);
+        break;
+
+      case 'slide_show':
+        this.showSlide(message.data);
+        break;
+
+      case 'slide_hide':
+        this.showSlide(null);
+        break;
+
       case 'ping':
         this.handlePing(message.data);
         break;
 
+      case 'ack':
+      case 'error':
+        this.handleRequestReply(message);
+        break;
+
       case 'initial_sync':
         this.handleInitialSync(message.data);
         break;
@@ -204,6 +287,26 @@ class QuizParticipant {
     }
   }
 
+  // 複数ルームで運用中で、URLに有効なルームコードがない場合だけ入力欄を表示する
+  async loadRoomInfo() {
+    try {
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/rooms'));
+      const data = await response.json();
+
+      if (!response.ok) {
+        QuizUtils.RoomUtils.setRoomCode('');
+      }
+      // ルームが見つからない場合は error.data に code_required が入る
+      const codeRequired = response.ok
+        ? data.code_required
+        : QuizUtils.ErrorUtils.data(data).code_required;
+      const needsCode = codeRequired && !data.room;
+      this.elements.roomCode.classList.toggle('hidden', !needsCode);
+    } catch (error) {
+      console.error('Error loading room info:', error);
+    }
+  }
+
   async joinQuiz() {
     const nickname = this.elements.nickname.value.trim();
     if (!nickname) {
@@ -211,6 +314,15 @@ class QuizParticipant {
       return;
     }
 
+    if (!this.elements.roomCode.classList.contains('hidden')) {
+      const roomCode = this.elements.roomCode.value.trim();
+      if (!roomCode) {
+        this.showMessage('ルームコードを入力してください');
+        return;
+      }
+      QuizUtils.RoomUtils.setRoomCode(roomCode);
+    }
+
     if (nickname.length > 20) {
       this.showMessage('ニックネームは20文字以内で入力してください');
       return;
@@ -227,11 +339,11 @@ class QuizParticipant {
         'Content-Type': 'application/json',
       };
 
-      if (this.sessionID) {
-        headers['X-Session-ID'] = this.sessionID;
+      if (this.sessionToken) {
+        headers['X-Session-Token'] = this.sessionToken;
       }
 
-      const response = await fetch('/api/join', {
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/join'), {
         method: 'POST',
         headers: headers,
         body: JSON.stringify({ nickname: nickname }),
@@ -241,8 +353,9 @@ class QuizParticipant {
 
       if (response.ok) {
         this.user = data.user;
-        this.sessionID = data.session_id;
-        localStorage.setItem('quiz_session_id', this.sessionID);
+        this.saveSession(data);
+        QuizUtils.RoomUtils.setRoomCode(response.headers.get('X-Room-Code'));
+        this.elements.roomCode.classList.add('hidden');
 
         this.elements.userNickname.textContent = this.user.nickname;
         this.elements.userScore.textContent = this.user.score;
@@ -250,8 +363,13 @@ class QuizParticipant {
 
         this.showWaiting();
         this.connectWebSocket();
+      } else if (response.status === 404) {
+        QuizUtils.RoomUtils.setRoomCode('');
+        throw new Error('ルームコードが見つかりません');
+      } else if (response.status === 429) {
+        throw new Error(this.rateLimitMessage(data));
       } else {
-        throw new Error(data.error || 'Failed to join quiz');
+        throw new Error(QuizUtils.ErrorUtils.message(data, 'Failed to join quiz'));
       }
     } catch (error) {
       console.error('Error joining quiz:', error);
@@ -264,20 +382,28 @@ class QuizParticipant {
 
   async rejoinSession() {
     try {
-      const response = await fetch('/api/join', {
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/join'), {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
-          'X-Session-ID': this.sessionID,
+          'X-Session-Token': this.sessionToken,
         },
         body: JSON.stringify({ nickname: 'Rejoining...' }),
       });
 
+      // 混雑で制限された場合はセッションを残して待ってから再試行する
+      if (response.status === 429) {
+        const data = await response.json();
+        const retryAfter = QuizUtils.ErrorUtils.data(data).retry_after || 1;
+        console.warn(`Rejoin rate limited, retrying in ${retryAfter}s`);
+        setTimeout(() => this.rejoinSession(), retryAfter * 1000);
+        return;
+      }
+
       // Check response status first
       if (!response.ok) {
         console.warn('Rejoin failed with status:', response.status);
-        localStorage.removeItem('quiz_session_id');
-        this.sessionID = null;
+        this.clearSession();
         this.showJoinScreen();
         return;
       }
@@ -292,14 +418,16 @@ class QuizParticipant {
       } catch (parseError) {
         console.error('JSON parse error:', parseError);
         console.error('Response text was:', responseText);
-        localStorage.removeItem('quiz_session_id');
-        this.sessionID = null;
+        this.clearSession();
         this.showJoinScreen();
         return;
       }
 
       if (data.user) {
         this.user = data.user;
+        this.saveSession(data);
+        QuizUtils.RoomUtils.setRoomCode(response.headers.get('X-Room-Code'));
+        this.elements.roomCode.classList.add('hidden');
         this.elements.userNickname.textContent = this.user.nickname;
         this.elements.userScore.textContent = this.user.score;
         this.elements.currentScore.textContent = this.user.score;
@@ -309,8 +437,7 @@ class QuizParticipant {
       } else {
         // No user data, clear session and show join screen
         console.warn('No user data in rejoin response');
-        localStorage.removeItem('quiz_session_id');
-        this.sessionID = null;
+        this.clearSession();
         this.showJoinScreen();
       }
 
@@ -320,12 +447,55 @@ class QuizParticipant {
       }
     } catch (error) {
       console.error('Error rejoining:', error);
-      localStorage.removeItem('quiz_session_id');
-      this.sessionID = null;
+      this.clearSession();
       this.showJoinScreen();
     }
   }
 
+  saveSession(data) {
+    this.sessionToken = data.session_token;
+    localStorage.setItem('quiz_session_token', this.sessionToken);
+    this.scheduleSessionRefresh(data.expires_at);
+  }
+
+  clearSession() {
+    clearTimeout(this.sessionRefreshTimer);
+    localStorage.removeItem('quiz_session_token');
+    this.sessionToken = null;
+  }
+
+  // 有効期限の半分が過ぎたところでトークンを新しいものに取り替える
+  scheduleSessionRefresh(expiresAt) {
+    clearTimeout(this.sessionRefreshTimer);
+    const remaining = new Date(expiresAt).getTime() - Date.now();
+    const delay = Math.max(remaining / 2, 10000);
+    this.sessionRefreshTimer = setTimeout(() => this.refreshSession(), delay);
+  }
+
+  async refreshSession() {
+    if (!this.sessionToken) return;
+
+    try {
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/session/refresh'), {
+        method: 'POST',
+        headers: { 'X-Session-Token': this.sessionToken },
+      });
+
+      if (response.ok) {
+        this.saveSession(await response.json());
+      } else if (response.status === 401) {
+        // 期限切れやリセット済み。次の再接続は拒否されるので参加し直してもらう
+        console.warn('Session refresh rejected');
+        this.clearSession();
+      } else {
+        this.sessionRefreshTimer = setTimeout(() => this.refreshSession(), 30000);
+      }
+    } catch (error) {
+      console.error('Session refresh error:', error);
+      this.sessionRefreshTimer = setTimeout(() => this.refreshSession(), 30000);
+    }
+  }
+
   showJoinScreen() {
     this.hideAllSections();
     this.elements.joinSection.classList.remove('hidden');
@@ -402,7 +572,7 @@ class QuizParticipant {
   }
 
   async selectAnswer(answerIndex) {
-    if (this.answersBlocked) return;
+    if (this.answersBlocked || this.paused) return;
 
     // 正答発表後の回答は禁止
     if (this.answerRevealed) {
@@ -417,22 +587,19 @@ class QuizParticipant {
     this.selectedAnswer = answerIndex;
     this.highlightSelectedAnswer(answerIndex);
 
-    try {
-      const response = await fetch('/api/answer', {
-        method: 'POST',
-        headers: {
-          'Content-Type': 'application/json',
-          'X-Session-ID': this.sessionID,
-        },
-        body: JSON.stringify({
-          question_number: this.currentQuestion.question_number,
-          answer_index: answerIndex + 1, // Convert 0-based to 1-based
-        }),
-      });
+    const answer = {
+      question_number: this.currentQuestion.question_number,
+      answer_index: answerIndex + 1, // Convert 0-based to 1-based
+    };
 
-      const data = await response.json();
+    try {
+      // WebSocketで送信し、使えない場合はHTTPで送り直す（同じ回答の再送は変更なしとして扱われる）
+      const reply =
+        (await this.sendWsRequest('answer', answer)) ||
+        (await this.postJSON('/api/answer', answer));
+      const data = reply.data;
 
-      if (response.ok) {
+      if (reply.ok) {
         // サーバーから返された選択肢でハイライトを更新
         if (data.answer_index !== undefined) {
           this.selectedAnswer = data.answer_index - 1; // Convert 1-based to 0-based
@@ -440,10 +607,14 @@ class QuizParticipant {
           this.highlightSelectedAnswer(this.selectedAnswer);
         }
       } else {
-        // 「Already answered this question」エラーは無視（回答変更として扱う）
-        if (data.error && !data.error.includes('Already answered')) {
-          console.error('Error submitting answer:', data.error);
-          this.showMessage('回答の送信に失敗しました: ' + data.error);
+        // ALREADY_ANSWERED エラーは無視（回答変更として扱う）
+        const code = QuizUtils.ErrorUtils.code(data);
+        if (code === 'RATE_LIMITED') {
+          this.showMessage(this.rateLimitMessage(data));
+        } else if (code !== 'ALREADY_ANSWERED') {
+          const message = QuizUtils.ErrorUtils.message(data);
+          console.error('Error submitting answer:', code, message);
+          this.showMessage('回答の送信に失敗しました: ' + message);
         }
         // Already answered エラーの場合は何もしない（回答変更として正常動作）
       }
@@ -453,6 +624,75 @@ class QuizParticipant {
     }
   }
 
+  // WebSocketでリクエストを送り、ack/errorの返信を待つ。
+  // 未接続・タイムアウト時は null を返す（呼び出し側でHTTPにフォールバック）
+  sendWsRequest(type, data, timeoutMs = 3000) {
+    if (!this.ws || this.ws.readyState !== WebSocket.OPEN) {
+      return Promise.resolve(null);
+    }
+
+    const requestId = `${Date.now()}-${++this.requestCounter}`;
+    return new Promise((resolve) => {
+      const timer = setTimeout(() => {
+        this.pendingRequests.delete(requestId);
+        resolve(null);
+      }, timeoutMs);
+      this.pendingRequests.set(requestId, { resolve, timer });
+      this.ws.send(JSON.stringify({ type, request_id: requestId, data }));
+    });
+  }
+
+  handleRequestReply(message) {
+    const data = message.data || {};
+    const pending = this.pendingRequests.get(data.request_id);
+    if (!pending) return; // タイムアウト済み
+
+    clearTimeout(pending.timer);
+    this.pendingRequests.delete(data.request_id);
+    if (message.type === 'ack') {
+      pending.resolve({ ok: true, data: data.result || {} });
+    } else {
+      // HTTPのエラー応答と同じ形にそろえる
+      pending.resolve({
+        ok: false,
+        data: {
+          error: {
+            code: data.code,
+            message: data.error,
+            data: { retry_after: data.retry_after },
+          },
+        },
+      });
+    }
+  }
+
+  // 切断時は応答待ちをすべてHTTPフォールバックに回す
+  failPendingRequests() {
+    this.pendingRequests.forEach((pending) => {
+      clearTimeout(pending.timer);
+      pending.resolve(null);
+    });
+    this.pendingRequests.clear();
+  }
+
+  // 送信が多すぎて制限されたとき (429 または retry_after 付きのエラー) の案内
+  rateLimitMessage(data) {
+    const seconds = QuizUtils.ErrorUtils.data(data).retry_after || 1;
+    return `送信が多すぎます。${seconds}秒ほど待ってからもう一度お試しください`;
+  }
+
+  async postJSON(url, body) {
+    const response = await fetch(QuizUtils.RoomUtils.withRoom(url), {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+        'X-Session-Token': this.sessionToken,
+      },
+      body: JSON.stringify(body),
+    });
+    return { ok: response.ok, data: await response.json() };
+  }
+
   disableChoices() {
     const choices =
       this.elements.choicesContainer.querySelectorAll('.choice-btn');
@@ -470,6 +710,29 @@ class QuizParticipant {
     this.disableChoices();
   }
 
+  setPaused(paused) {
+    this.paused = paused;
+    this.elements.pausedBanner.classList.toggle('hidden', !paused);
+
+    if (paused) {
+      this.disableChoices();
+    } else if (!this.answersBlocked && !this.answerRevealed) {
+      this.enableChoices();
+    }
+  }
+
+  // お知らせは回答画面の上に重ねて表示し、閉じられると元の画面に戻る
+  showSlide(slide) {
+    if (!slide) {
+      this.elements.slideOverlay.classList.add('hidden');
+      this.elements.slideContent.innerHTML = '';
+      return;
+    }
+
+    this.elements.slideContent.innerHTML = QuizUtils.SlideUtils.render(slide);
+    this.elements.slideOverlay.classList.remove('hidden');
+  }
+
   clearSelectionHighlight() {
     const choices =
       this.elements.choicesContainer.querySelectorAll('.choice-btn');
@@ -618,17 +881,15 @@ class QuizParticipant {
   }
 
   async sendEmoji(emoji) {
-    if (!this.sessionID) return;
+    if (!this.sessionToken) return;
 
     try {
-      await fetch('/api/emoji', {
-        method: 'POST',
-        headers: {
-          'Content-Type': 'application/json',
-          'X-Session-ID': this.sessionID,
-        },
-        body: JSON.stringify({ emoji: emoji }),
-      });
+      // 二重送信を避けるため、HTTPに回すのはWebSocketが未接続のときだけ
+      if (this.ws && this.ws.readyState === WebSocket.OPEN) {
+        this.sendWsRequest('emoji', { emoji: emoji });
+      } else {
+        await this.postJSON('/api/emoji', { emoji: emoji });
+      }
     } catch (error) {
       console.error('Error sending emoji:', error);
     }
@@ -658,31 +919,35 @@ class QuizParticipant {
   }
 
   async resetSession() {
-    if (!this.sessionID) {
+    if (!this.sessionToken) {
       this.showMessage('セッションが見つかりません');
       return;
     }
 
     try {
-      const response = await fetch('/api/reset-session', {
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/reset-session'), {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
-          'X-Session-ID': this.sessionID,
+          'X-Session-Token': this.sessionToken,
         },
       });
 
       if (response.ok) {
         // セッション情報をクリア
-        localStorage.removeItem('quiz_session_id');
-        this.sessionID = null;
+        this.clearSession();
         this.user = null;
 
-        // WebSocket接続を切断
+        // WebSocket接続を切断（新しいセッションは initial_sync から始める）
         if (this.ws) {
           this.ws.close();
           this.ws = null;
         }
+        if (this.eventSource) {
+          this.eventSource.close();
+          this.eventSource = null;
+        }
+        this.messageSequence = new QuizUtils.MessageSequence();
 
         // モーダルを閉じる
         this.hideResetModal();
@@ -700,7 +965,9 @@ class QuizParticipant {
         );
       } else {
         const error = await response.json();
-        this.showMessage(`セッション破棄に失敗しました: ${error.error}`);
+        this.showMessage(
+          `セッション破棄に失敗しました: ${QuizUtils.ErrorUtils.message(error)}`
+        );
       }
     } catch (error) {
       console.error('Reset session error:', error);
@@ -817,6 +1084,8 @@ class QuizParticipant {
       case 'waiting':
       case 'started':
       case 'title_display':
+      case 'leaderboard':
+      case 'intermission':
         this.showWaiting();
         break;
 
@@ -924,6 +1193,9 @@ class QuizParticipant {
         this.showWaiting();
     }
 
+    this.setPaused(!!data.paused);
+    this.showSlide(data.slide || null);
+
     console.log('Initial sync completed for state:', data.event_state);
   }
 
@@ -1,13 +1,20 @@
 class QuizScreen {
   constructor() {
     this.ws = null;
+    this.messageSequence = new QuizUtils.MessageSequence();
     this.currentEvent = null;
     this.participants = new Map();
     this.currentQuestion = null;
     this.emojiAnimations = [];
     this.countdownInterval = null;
+    this.countdownSecondsLeft = null;
     this.timeUpTimeout = null;
     this.answersBlocked = false;
+    this.paused = false;
+    this.liveAnswerCount = null; // 回答受付中の回答数 (answer_count)
+    // ?channel= で開いたスクリーンは管理画面が決めた内容だけを表示する
+    this.channel = new URLSearchParams(window.location.search).get('channel') || '';
+    this.shows = null; // 表示する内容（未取得なら全部）
 
     this.initializeElements();
     this.connectWebSocket();
@@ -32,6 +39,8 @@ class QuizScreen {
       resultsScreen: document.getElementById('results-screen'),
 
       joinUrl: document.getElementById('join-url'),
+      roomCodeLine: document.getElementById('room-code-line'),
+      roomCode: document.getElementById('room-code'),
       participantsGrid: document.getElementById('participants-grid'),
 
       currentQuestionNum: document.getElementById('current-question-num'),
@@ -44,10 +53,14 @@ class QuizScreen {
       // progressFill: document.getElementById('progress-fill'),
       // answerCount: document.getElementById('answer-count'),
 
+      liveAnswerCount: document.getElementById('live-answer-count'),
       countdownDisplay: document.getElementById('countdown-display'),
       countdownNumber: document.getElementById('countdown-number'),
       timeUpDisplay: document.getElementById('time-up-display'),
       countdownBorder: document.getElementById('countdown-border'),
+      pausedOverlay: document.getElementById('paused-overlay'),
+      slideOverlay: document.getElementById('slide-overlay'),
+      slideContent: document.getElementById('slide-content'),
 
       rankingsDisplay: document.getElementById('rankings-display'),
       emojiReactions: document.getElementById('emoji-reactions'),
@@ -83,9 +96,13 @@ class QuizScreen {
 
   connectWebSocket() {
     const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-    const wsUrl = `${protocol}//${window.location.host}/ws/screen`;
+    let wsUrl = QuizUtils.RoomUtils.withRoom(`${protocol}//${window.location.host}/ws/screen`);
+    if (this.channel) {
+      const separator = wsUrl.includes('?') ? '&' : '?';
+      wsUrl = `${wsUrl}${separator}channel=${encodeURIComponent(this.channel)}`;
+    }
 
-    this.ws = new WebSocket(wsUrl);
+    this.ws = new WebSocket(this.messageSequence.connectURL(wsUrl));
 
     this.ws.onopen = () => {
       console.log('Screen WebSocket connected');
@@ -95,7 +112,8 @@ class QuizScreen {
 
     this.ws.onmessage = (event) => {
       const message = JSON.parse(event.data);
-      this.handleWebSocketMessage(message);
+      this.messageSequence.track(message);
+      QuizUtils.MessageUtils.unbatch(message).forEach(item => this.handleWebSocketMessage(item));
     };
 
     this.ws.onclose = () => {
@@ -160,10 +178,23 @@ class QuizScreen {
         this.handleAnswerStats(message.data);
         break;
 
+      case 'answer_count':
+        this.handleAnswerCount(message.data);
+        break;
+
       case 'answer_reveal':
         this.handleAnswerReveal(message.data);
         break;
 
+      case 'leaderboard':
+        // 途中経過は最終結果と同じ形式で表示
+        this.handleFinalResults(message.data);
+        break;
+
+      case 'intermission':
+        this.showIntermissionScreen(message.data);
+        break;
+
       case 'final_results':
         this.handleFinalResults(message.data);
         break;
@@ -180,6 +211,26 @@ class QuizScreen {
         this.handleStateChanged(message.data);
         break;
 
+      case 'event_paused':
+        this.handleEventPaused(message.data);
+        break;
+
+      case 'event_resumed':
+        this.handleEventResumed(message.data);
+        break;
+
+      case 'slide_show':
+        this.showSlide(message.data);
+        break;
+
+      case 'slide_hide':
+        this.showSlide(null);
+        break;
+
+      case 'screen_channel':
+        this.handleScreenChannel(message.data);
+        break;
+
       default:
         console.log('Unknown message type:', message.type);
     }
@@ -195,6 +246,17 @@ class QuizScreen {
 
     // Update participants
     this.loadStatus();
+    this.setPausedOverlay(!!data.paused);
+    if (data.channel) {
+      this.shows = data.channel.shows;
+    }
+    this.showSlide(this.shows && !this.shows.includes('slides') ? null : data.slide || null);
+
+    // 問題を表示しないチャンネルは状態に関係なく担当の内容を表示する
+    if (!this.showsQuestions()) {
+      this.showChannelView();
+      return;
+    }
 
     // Sync to current state with appropriate data
     const { EVENT_STATES } = QuizConstants;
@@ -296,6 +358,11 @@ class QuizScreen {
         }
         break;
 
+      case EVENT_STATES.INTERMISSION:
+        this.showIntermissionScreen({});
+        break;
+
+      case EVENT_STATES.LEADERBOARD:
       case EVENT_STATES.RESULTS:
       case EVENT_STATES.CELEBRATION:
         // 結果画面を表示
@@ -330,6 +397,54 @@ class QuizScreen {
     console.log('Screen synchronized with server state:', data.event_state);
   }
 
+  /**
+   * このスクリーンのチャンネルの表示内容が変わった
+   * @param {Object} data - チャンネル名と表示内容
+   */
+  handleScreenChannel(data) {
+    const channel = (this.channel || 'main').toLowerCase();
+    if (!data || data.channel !== channel) {
+      return; // 他のチャンネル宛て
+    }
+    const showedQuestions = this.showsQuestions();
+    this.shows = data.shows;
+    if (!this.shows.includes('slides')) {
+      this.showSlide(null);
+    }
+    if (!this.showsQuestions()) {
+      this.showChannelView();
+    } else if (!showedQuestions) {
+      this.showWaitingScreen();
+    }
+  }
+
+  showsQuestions() {
+    return !this.shows || this.shows.includes('questions');
+  }
+
+  /**
+   * 問題を表示しないチャンネルの画面: ランキングか参加者一覧
+   */
+  async showChannelView() {
+    if (!this.shows.includes('leaderboard')) {
+      this.showWaitingScreen();
+      return;
+    }
+    try {
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/status'));
+      const data = await response.json();
+      if (response.ok) {
+        this.handleFinalResults({
+          team_mode: !!data.config?.team_mode,
+          teams: data.teams || [],
+          results: data.users || [],
+        });
+      }
+    } catch (error) {
+      console.error('Error loading leaderboard:', error);
+    }
+  }
+
   handleUserJoined(data) {
     this.loadStatus();
   }
@@ -346,6 +461,7 @@ class QuizScreen {
 
   handleQuestionStart(data) {
     this.currentQuestion = data;
+    this.liveAnswerCount = null;
     this.answersBlocked = false;
     this.hideCountdown();
     this.elements.timeUpDisplay.classList.add('hidden');
@@ -389,7 +505,30 @@ class QuizScreen {
     this.showAnswerStatsScreen(data);
   }
 
+  /**
+   * 回答受付中の回答数。設定で許可されていれば選択肢ごとの人数も届く
+   * @param {Object} data - answered_count, total_participants, choices_counts
+   */
+  handleAnswerCount(data) {
+    if (!this.currentQuestion || data.question_number !== this.currentQuestion.question_number) {
+      return;
+    }
+    this.liveAnswerCount = data;
+    this.updateAnswerProgress();
+
+    if (data.choices_counts && !this.answersBlocked) {
+      this.elements.choicesDisplay.querySelectorAll('.choice-count').forEach((element, index) => {
+        element.textContent = `${data.choices_counts[index] || 0}人`;
+        element.style.visibility = 'visible';
+      });
+    }
+  }
+
   handleAnswerReveal(data) {
+    if (!this.showsQuestions()) {
+      this.showChannelView(); // 得点が変わったのでランキングを更新
+      return;
+    }
     this.showAnswerRevealScreen(data);
   }
 
@@ -403,12 +542,19 @@ class QuizScreen {
 
   async loadQuizInfo() {
     try {
-      const response = await fetch('/api/screen/info');
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/screen/info'));
       const data = await response.json();
 
       if (response.ok) {
         // this.elements.eventTitle.textContent = data.title || '';
         this.elements.qrcodeImage.src = data.qrcode || '';
+
+        // ルーム指定で開いたスクリーンには参加用のルームコードを表示する
+        const roomCode = data.current_event?.room_code;
+        if (roomCode && QuizUtils.RoomUtils.getRoomCode()) {
+          this.elements.roomCode.textContent = roomCode;
+          this.elements.roomCodeLine.classList.remove('hidden');
+        }
       }
     } catch (error) {
       console.error('Error loading quiz info:', error);
@@ -417,7 +563,7 @@ class QuizScreen {
 
   async loadStatus() {
     try {
-      const response = await fetch('/api/status');
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/status'));
       const data = await response.json();
 
       if (response.ok) {
@@ -491,8 +637,10 @@ class QuizScreen {
     // 動的に作成された画面も非表示
     const titleScreen = document.getElementById('title-screen');
     const teamScreen = document.getElementById('team-assignment-screen');
+    const intermissionScreen = document.getElementById('intermission-screen');
     if (titleScreen) titleScreen.classList.add('hidden');
     if (teamScreen) teamScreen.classList.add('hidden');
+    if (intermissionScreen) intermissionScreen.classList.add('hidden');
   }
 
   displayQuestion(questionData) {
@@ -558,16 +706,12 @@ class QuizScreen {
   }
 
   updateAnswerProgress() {
-    // if (!this.currentQuestion) return;
-    // const totalParticipants = this.participants.size;
-    // const answeredCount = this.getAnsweredCount();
-    // const progress = totalParticipants > 0 ? (answeredCount / totalParticipants) * 100 : 0;
-    // this.elements.progressFill.style.width = `${progress}%`;
-    // this.elements.answerCount.textContent = `${answeredCount} / ${totalParticipants} 回答済み`;
-  }
-
-  getAnsweredCount() {
-    return 5; // FIXME:
+    const element = this.elements.liveAnswerCount;
+    const count = this.liveAnswerCount;
+    element.classList.toggle('hidden', !count);
+    if (count) {
+      element.textContent = `${count.answered_count} / ${count.total_participants} 回答済み`;
+    }
   }
 
   displayFinalResults(results) {
@@ -731,33 +875,78 @@ class QuizScreen {
   }
 
   showCountdown(secondsLeft) {
+    clearTimeout(this.countdownInterval);
+    this.countdownInterval = null;
+
     if (secondsLeft > 10 || secondsLeft < 0) {
       this.hideCountdown();
       return;
     }
 
     // Show the countdown number
+    this.countdownSecondsLeft = secondsLeft;
     this.elements.countdownNumber.textContent = secondsLeft;
     this.elements.countdownNumber.classList.remove('hidden');
 
     // Show red border effect
     this.elements.countdownBorder.classList.remove('hidden');
 
+    // 一時停止中は数字を止めておき、再開時に続きから数える
+    if (this.paused) {
+      return;
+    }
+
     if (secondsLeft === 1) {
       // Show "Time Up!" after 1 second
-      setTimeout(() => {
+      this.countdownInterval = setTimeout(() => {
         this.hideCountdown();
         this.showTimeUp();
       }, 1300);
     } else {
       // Show count
-      setTimeout(() => {
+      this.countdownInterval = setTimeout(() => {
         this.showCountdown(secondsLeft - 1);
       }, 1000);
     }
   }
 
+  handleEventPaused(data) {
+    this.setPausedOverlay(true);
+    clearTimeout(this.countdownInterval);
+    this.countdownInterval = null;
+  }
+
+  handleEventResumed(data) {
+    this.setPausedOverlay(false);
+    if (this.countdownSecondsLeft !== null) {
+      this.showCountdown(this.countdownSecondsLeft);
+    }
+  }
+
+  setPausedOverlay(paused) {
+    this.paused = paused;
+    if (this.elements.pausedOverlay) {
+      this.elements.pausedOverlay.classList.toggle('hidden', !paused);
+    }
+  }
+
+  // お知らせスライドは現在の画面の上に重ねて表示し、閉じると元の画面に戻る
+  showSlide(slide) {
+    if (!slide) {
+      this.elements.slideOverlay.classList.add('hidden');
+      this.elements.slideContent.innerHTML = '';
+      return;
+    }
+
+    this.elements.slideContent.className = `slide-content slide-${slide.type}`;
+    this.elements.slideContent.innerHTML = QuizUtils.SlideUtils.render(slide);
+    this.elements.slideOverlay.classList.remove('hidden');
+  }
+
   hideCountdown() {
+    clearTimeout(this.countdownInterval);
+    this.countdownInterval = null;
+    this.countdownSecondsLeft = null;
     if (this.elements.countdownNumber) {
       this.elements.countdownNumber.classList.add('hidden');
     }
@@ -845,6 +1034,30 @@ class QuizScreen {
     teamScreen.classList.remove('hidden');
   }
 
+  showIntermissionScreen(data) {
+    this.hideAllScreens();
+    this.elements.questionHeader.classList.add('hidden');
+    this.elements.resultsScreen.classList.add('hidden');
+
+    let intermissionScreen = document.getElementById('intermission-screen');
+    if (!intermissionScreen) {
+      intermissionScreen = document.createElement('div');
+      intermissionScreen.id = 'intermission-screen';
+      intermissionScreen.className = 'screen-section';
+      intermissionScreen.innerHTML = `
+                <div class="intermission-display">
+                    <h2>☕ 休憩中</h2>
+                    <p id="intermission-message" class="intermission-message"></p>
+                </div>
+            `;
+      document.querySelector('.screen-content').appendChild(intermissionScreen);
+    }
+
+    intermissionScreen.querySelector('#intermission-message').textContent =
+      (data && data.message) || 'しばらくお待ちください';
+    intermissionScreen.classList.remove('hidden');
+  }
+
   showAnswerStatsScreen(data) {
     // この情報を問題画面に重ねて表示
     this.elements.questionScreen.classList.remove('hidden');
@@ -1118,6 +1331,10 @@ class QuizScreen {
   handleStateChanged(data) {
     console.log('State changed:', data.new_state);
 
+    if (!this.showsQuestions()) {
+      return;
+    }
+
     // Handle state-specific transitions using constants
     const { EVENT_STATES } = QuizConstants;
 
@@ -1185,7 +1402,7 @@ class QuizScreen {
 
   async loadQuestionFromAPI(questionNumber) {
     try {
-      const response = await fetch('/api/status');
+      const response = await fetch(QuizUtils.RoomUtils.withRoom('/api/status'));
       if (response.ok) {
         const data = await response.json();
         if (
@@ -11,7 +11,8 @@ import (
 
 // RequestValidator provides validation for all quiz application requests
 type RequestValidator struct {
-	config *models.Config
+	config      *models.Config
+	rateLimiter *models.RateLimiter
 }
 
 // NewRequestValidator creates a new request validator
@@ -21,6 +22,11 @@ func NewRequestValidator(config *models.Config) *RequestValidator {
 	}
 }
 
+// SetRateLimiter enables ValidateRateLimit with the limiter shared by all rooms
+func (rv *RequestValidator) SetRateLimiter(rateLimiter *models.RateLimiter) {
+	rv.rateLimiter = rateLimiter
+}
+
 // ValidationResult represents the result of a validation operation
 type ValidationResult struct {
 	Valid   bool
@@ -41,10 +47,8 @@ func (vr *ValidationResult) AddFieldError(field, message string) {
 	}
 	vr.Details[field] = message
 
-	err := errors.NewValidationError(
-		fmt.Sprintf("Field '%s': %s", field, message),
-		fmt.Sprintf("Field: %s, Message: %s", field, message),
-	)
+	// The message is shown to users as is; clients find the field in data
+	err := errors.NewValidationError(message, fmt.Sprintf("Field: %s", field)).WithData("field", field)
 	vr.AddError(err)
 }
 
@@ -85,7 +89,8 @@ func (rv *RequestValidator) ValidateNickname(nickname string) *ValidationResult
 	result := NewValidationResult()
 
 	// Required field check
-	if strings.TrimSpace(nickname) == "" {
+	nickname = strings.TrimSpace(nickname)
+	if nickname == "" {
 		result.AddFieldError("nickname", "ニックネームが入力されていません")
 		return result
 	}
@@ -114,23 +119,24 @@ func (rv *RequestValidator) ValidateNickname(nickname string) *ValidationResult
 
 // Answer validation
 
-// ValidateAnswer validates an answer submission
+// ValidateAnswer validates an answer submission; answerIndex is 1-based like the API
 func (rv *RequestValidator) ValidateAnswer(questionNumber, answerIndex int, userID int) *ValidationResult {
 	result := NewValidationResult()
 
 	// Question number validation
 	if questionNumber < 1 {
 		result.AddFieldError("question_number", "問題番号が無効です")
+		return result // Early return as other validations depend on this
 	}
 
 	if questionNumber > len(rv.config.Questions) {
 		result.AddFieldError("question_number", "存在しない問題番号です")
-		return result // Early return as other validations depend on this
+		return result
 	}
 
 	// Answer index validation
 	question := rv.config.Questions[questionNumber-1]
-	if answerIndex < 0 || answerIndex >= len(question.Choices) {
+	if answerIndex < 1 || answerIndex > len(question.Choices) {
 		result.AddFieldError("answer_index", "無効な選択肢です")
 	}
 
@@ -214,19 +220,8 @@ func (rv *RequestValidator) ValidateAdminAction(action string) *ValidationResult
 		return result
 	}
 
-	validActions := map[string]bool{
-		"start_event":       true,
-		"show_title":        true,
-		"assign_teams":      true,
-		"next_question":     true,
-		"countdown_alert":   true,
-		"show_answer_stats": true,
-		"reveal_answer":     true,
-		"show_results":      true,
-		"celebration":       true,
-	}
-
-	if !validActions[action] {
+	// Every action has a button label, including go_back, pause and the autopilot controls
+	if _, ok := models.ActionLabels[action]; !ok {
 		result.AddFieldError("action", "無効なアクションです")
 	}
 
@@ -244,11 +239,12 @@ func (rv *RequestValidator) ValidateEmoji(emoji string) *ValidationResult {
 		return result
 	}
 
-	// Allow common emojis (basic validation)
+	// Allow common emojis (basic validation), including the buttons of the participant page
 	allowedEmojis := map[string]bool{
 		"❤️": true, "👏": true, "😊": true, "😮": true, "🤔": true, "😅": true,
 		"👍": true, "👎": true, "🔥": true, "💯": true, "😄": true, "😢": true,
 		"😱": true, "🤯": true, "👌": true, "🙌": true, "💖": true, "⭐": true,
+		"😂": true, "🥳": true, "💪": true,
 	}
 
 	if !allowedEmojis[emoji] {
@@ -481,17 +477,20 @@ func (rv *RequestValidator) ValidateConfigIntegrity() *ValidationResult {
 	return result
 }
 
-// Rate limiting validation (basic implementation)
+// Rate limiting validation
 
-// ValidateRateLimit validates if an operation is within rate limits
-func (rv *RequestValidator) ValidateRateLimit(userID int, operation string) *ValidationResult {
+// ValidateRateLimit takes one request of the operation (models.RateLimitJoin,
+// ...) from the buckets of the session and the client IP; without a limiter
+// every request is valid
+func (rv *RequestValidator) ValidateRateLimit(sessionID, clientIP, operation string) *ValidationResult {
 	result := NewValidationResult()
+	if rv.rateLimiter == nil {
+		return result
+	}
 
-	// This is a placeholder for rate limiting logic
-	// In a real implementation, you might use Redis or in-memory storage
-	// to track request rates per user and operation
-
-	// For now, just return valid
+	if ok, retryAfter := rv.rateLimiter.Allow(operation, sessionID, clientIP); !ok {
+		result.AddError(errors.ErrRateLimited.WithDetails(fmt.Sprintf("retry after %ds", models.RetryAfterSeconds(retryAfter))))
+	}
 	return result
 }
 
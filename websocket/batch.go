@@ -0,0 +1,116 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// batchWindow is how long high-frequency messages are collected before they
+// are sent together
+const batchWindow = 200 * time.Millisecond
+
+// batchKeys lists the message types that are batched. The function returns
+// the coalescing key of an item: a later item with the same key replaces the
+// earlier one in the same window. A nil function keeps every item.
+var batchKeys = map[MessageType]func(data json.RawMessage) string{
+	// A participant changing their answer within the window only counts once.
+	// Nicknames are not unique, so participants are told apart by user ID.
+	MessageAnswerReceived: func(data json.RawMessage) string {
+		var answer struct {
+			UserID         int `json:"user_id"`
+			QuestionNumber int `json:"question_number"`
+		}
+		if err := json.Unmarshal(data, &answer); err != nil {
+			return ""
+		}
+		return fmt.Sprintf("%d/%d", answer.UserID, answer.QuestionNumber)
+	},
+	MessageEmojiReaction: nil,
+}
+
+// BatchData is the payload of a batch message: the items of several messages
+// of one type, in the order they were published
+type BatchData struct {
+	Type  MessageType       `json:"type"`
+	Items []json.RawMessage `json:"items"`
+}
+
+// pendingBatch collects the items of one message type for one audience
+type pendingBatch struct {
+	message     TypedMessage
+	clientTypes []ClientType
+	items       []json.RawMessage
+	keys        map[string]int // Coalescing key -> index in items
+}
+
+// IsBatchedMessageType checks if messages of the type are sent in batches
+func IsBatchedMessageType(msgType MessageType) bool {
+	_, ok := batchKeys[msgType]
+	return ok
+}
+
+// batchID identifies the batch a message joins: same type, same audience
+func batchID(msgType MessageType, clientTypes []ClientType) string {
+	return fmt.Sprintf("%s%v", msgType, clientTypes)
+}
+
+// addToBatch holds a message back until the batch window ends
+func (h *Hub) addToBatch(request publishRequest) {
+	message := request.message
+	data, ok := message.Data.(json.RawMessage)
+	if !ok {
+		var err error
+		if data, err = json.Marshal(message.Data); err != nil {
+			log.Printf("Error marshaling %s message: %v", message.Type, err)
+			return
+		}
+	}
+
+	id := batchID(message.Type, request.clientTypes)
+	batch, exists := h.batches[id]
+	if !exists {
+		batch = &pendingBatch{
+			message:     message,
+			clientTypes: request.clientTypes,
+			keys:        make(map[string]int),
+		}
+		h.batches[id] = batch
+		h.batchOrder = append(h.batchOrder, id)
+	}
+
+	if keyOf := batchKeys[message.Type]; keyOf != nil {
+		if key := keyOf(data); key != "" {
+			if index, seen := batch.keys[key]; seen {
+				batch.items[index] = data
+				return
+			}
+			batch.keys[key] = len(batch.items)
+		}
+	}
+	batch.items = append(batch.items, data)
+}
+
+// hasPendingBatches reports whether a batch is waiting for its window to end
+func (h *Hub) hasPendingBatches() bool {
+	return len(h.batchOrder) > 0
+}
+
+// flushBatches publishes the collected batches. A batch of one item goes out
+// as the plain message, so a quiet room sees no difference.
+func (h *Hub) flushBatches() {
+	for _, id := range h.batchOrder {
+		batch := h.batches[id]
+		message := batch.message
+		if len(batch.items) == 1 {
+			message.Data = batch.items[0]
+		} else {
+			message.Type = MessageBatch
+			message.Data = BatchData{Type: batch.message.Type, Items: batch.items}
+		}
+		h.publishMessage(publishRequest{message: message, clientTypes: batch.clientTypes})
+	}
+	clear(h.batches)
+	h.batchOrder = h.batchOrder[:0]
+}
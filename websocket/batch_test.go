@@ -0,0 +1,150 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// batch adds a message to the pending batches the way the run loop does
+func batch(hub *Hub, message TypedMessage, clientTypes ...ClientType) {
+	hub.addToBatch(publishRequest{message: message, clientTypes: clientTypes})
+}
+
+func answerReceived(userID int, nickname string, choice int) TypedMessage {
+	return NewTypedMessage(MessageAnswerReceived, map[string]any{"user_id": userID, "nickname": nickname, "question_number": 1, "answer": choice})
+}
+
+func receivedMessages(t *testing.T, client *Client) []Message {
+	t.Helper()
+	var messages []Message
+	for {
+		select {
+		case data := <-client.Send:
+			var message Message
+			if err := json.Unmarshal(data, &message); err != nil {
+				t.Fatalf("Failed to unmarshal message: %v", err)
+			}
+			messages = append(messages, message)
+		default:
+			return messages
+		}
+	}
+}
+
+func TestBatchCoalescesAnswers(t *testing.T) {
+	hub := NewHub(nil)
+	admin := newTestClient(ClientTypeAdmin)
+	hub.addClient(admin)
+
+	batch(hub, answerReceived(1, "alice", 1), ClientTypeAdmin)
+	batch(hub, answerReceived(2, "bob", 2), ClientTypeAdmin)
+	batch(hub, answerReceived(1, "alice", 3), ClientTypeAdmin) // Changed answer replaces the first one
+
+	if len(receivedMessages(t, admin)) != 0 {
+		t.Fatal("Expected nothing to be sent before the batch window ends")
+	}
+	hub.flushBatches()
+
+	messages := receivedMessages(t, admin)
+	if len(messages) != 1 || messages[0].Type != string(MessageBatch) || messages[0].Seq != 1 {
+		t.Fatalf("Expected one batch message with seq 1, got %+v", messages)
+	}
+	data := messages[0].Data.(map[string]any)
+	items := data["items"].([]any)
+	if data["type"] != string(MessageAnswerReceived) || len(items) != 2 {
+		t.Fatalf("Expected 2 answer_received items, got %v", data)
+	}
+	if first := items[0].(map[string]any); first["nickname"] != "alice" || first["answer"] != float64(3) {
+		t.Errorf("Expected alice's changed answer to keep her place, got %v", first)
+	}
+	if hub.hasPendingBatches() {
+		t.Error("Expected no pending batches after a flush")
+	}
+}
+
+func TestBatchKeepsAnswersOfUsersSharingANickname(t *testing.T) {
+	hub := NewHub(nil)
+	admin := newTestClient(ClientTypeAdmin)
+	hub.addClient(admin)
+
+	batch(hub, answerReceived(1, "alice", 1), ClientTypeAdmin)
+	batch(hub, answerReceived(2, "alice", 2), ClientTypeAdmin) // Another participant with the same nickname
+	hub.flushBatches()
+
+	messages := receivedMessages(t, admin)
+	if len(messages) != 1 {
+		t.Fatalf("Expected one batch message, got %+v", messages)
+	}
+	items := messages[0].Data.(map[string]any)["items"].([]any)
+	if len(items) != 2 {
+		t.Fatalf("Expected both answers to be kept, got %v", items)
+	}
+	for i, item := range items {
+		if userID := item.(map[string]any)["user_id"]; userID != float64(i+1) {
+			t.Errorf("Item %d: expected user %d, got %v", i, i+1, userID)
+		}
+	}
+}
+
+func TestBatchIsSentBeforeLaterMessages(t *testing.T) {
+	hub := NewHub(nil)
+	go hub.Run()
+
+	admin := newTestClient(ClientTypeAdmin)
+	hub.Register <- admin
+	for hub.GetClientCount()[ClientTypeAdmin] == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The question ends before the batch window does
+	hub.Publish(answerReceived(1, "alice", 1), ClientTypeAdmin)
+	hub.Publish(NewTypedMessage(MessageQuestionEnd, nil), ClientTypeAdmin)
+
+	var types []string
+	for range 2 {
+		select {
+		case data := <-admin.Send:
+			var message Message
+			if err := json.Unmarshal(data, &message); err != nil {
+				t.Fatalf("Failed to unmarshal message: %v", err)
+			}
+			types = append(types, message.Type)
+		case <-time.After(batchWindow / 2):
+			t.Fatalf("Expected the answer to be sent with the question end, got %v", types)
+		}
+	}
+	if types[0] != string(MessageAnswerReceived) || types[1] != string(MessageQuestionEnd) {
+		t.Errorf("Expected answer_received before question_end, got %v", types)
+	}
+}
+
+func TestBatchKeepsEveryEmoji(t *testing.T) {
+	hub := NewHub(nil)
+	screen := newTestClient(ClientTypeScreen)
+	hub.addClient(screen)
+
+	for range 3 {
+		batch(hub, NewTypedMessage(MessageEmojiReaction, map[string]any{"nickname": "alice", "emoji": "👏"}), ClientTypeScreen)
+	}
+	hub.flushBatches()
+
+	messages := receivedMessages(t, screen)
+	if len(messages) != 1 || len(messages[0].Data.(map[string]any)["items"].([]any)) != 3 {
+		t.Errorf("Expected a batch of 3 emoji, got %+v", messages)
+	}
+}
+
+func TestBatchOfOneIsPlainMessage(t *testing.T) {
+	hub := NewHub(nil)
+	screen := newTestClient(ClientTypeScreen)
+	hub.addClient(screen)
+
+	batch(hub, NewTypedMessage(MessageEmojiReaction, map[string]any{"emoji": "🎉"}), ClientTypeScreen)
+	hub.flushBatches()
+
+	messages := receivedMessages(t, screen)
+	if len(messages) != 1 || messages[0].Type != string(MessageEmojiReaction) || messages[0].Data.(map[string]any)["emoji"] != "🎉" {
+		t.Errorf("Expected a plain emoji message, got %+v", messages)
+	}
+}
@@ -0,0 +1,127 @@
+package websocket
+
+import (
+	"fmt"
+	"quiz100/models"
+	"slices"
+	"sort"
+)
+
+// screenMessageContents lists the contents a message belongs to on screens.
+// A screen channel receives the message when it shows one of them; messages
+// not listed here (state changes, pauses, syncs, resets) reach every screen.
+var screenMessageContents = map[MessageType][]models.ScreenContent{
+	MessageEventStarted:    {models.ScreenQuestions},
+	MessageTitleDisplay:    {models.ScreenQuestions},
+	MessageQuestionStart:   {models.ScreenQuestions},
+	MessageQuestionEnd:     {models.ScreenQuestions},
+	MessageCountdown:       {models.ScreenQuestions},
+	MessageTimeAlert:       {models.ScreenQuestions},
+	MessageAnswerStats:     {models.ScreenQuestions},
+	MessageAnswerCount:     {models.ScreenQuestions},
+	MessageIntermission:    {models.ScreenQuestions},
+	MessageAnswerReveal:    {models.ScreenQuestions, models.ScreenLeaderboard}, // Scores change
+	MessageCelebration:     {models.ScreenQuestions, models.ScreenLeaderboard},
+	MessageLeaderboard:     {models.ScreenLeaderboard},
+	MessageFinalResults:    {models.ScreenLeaderboard},
+	MessageTeamAssignment:  {models.ScreenQuestions, models.ScreenParticipants},
+	MessageUserJoined:      {models.ScreenParticipants},
+	MessageUserLeft:        {models.ScreenParticipants},
+	MessageTeamMemberAdded: {models.ScreenParticipants},
+	MessageEmojiReaction:   {models.ScreenEmoji},
+	MessageSlideShow:       {models.ScreenSlides},
+	MessageSlideHide:       {models.ScreenSlides},
+}
+
+// ScreenChannelData describes a screen channel: what it shows and how many
+// screens follow it
+type ScreenChannelData struct {
+	Channel string                 `json:"channel"`
+	Shows   []models.ScreenContent `json:"shows"`
+	Screens int                    `json:"screens"` // Connected screens
+}
+
+// channelReceives reports whether screens of the channel get messages of the
+// type; the caller must hold mutex
+func (h *Hub) channelReceives(channel string, msgType MessageType) bool {
+	contents, routed := screenMessageContents[msgType]
+	if !routed {
+		return true
+	}
+	shows, configured := h.channelShows[channel]
+	if !configured {
+		return true
+	}
+	for _, content := range contents {
+		if slices.Contains(shows, content) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetScreenChannel sets what the screens of a channel show; nil shows
+// everything again
+func (h *Hub) SetScreenChannel(channel string, shows []models.ScreenContent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if shows == nil {
+		delete(h.channelShows, channel)
+		return
+	}
+	h.channelShows[channel] = slices.Clone(shows)
+}
+
+// ScreenChannel describes one channel
+func (h *Hub) ScreenChannel(channel string) ScreenChannelData {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.screenChannel(channel)
+}
+
+// screenChannel describes one channel; the caller must hold mutex
+func (h *Hub) screenChannel(channel string) ScreenChannelData {
+	shows, configured := h.channelShows[channel]
+	if !configured {
+		shows = models.AllScreenContents()
+	}
+	return ScreenChannelData{
+		Channel: channel,
+		Shows:   slices.Clone(shows),
+		Screens: len(h.byChannel[channel]),
+	}
+}
+
+// ScreenChannels describes the default channel, the configured ones and the
+// ones screens are following, by name
+func (h *Hub) ScreenChannels() []ScreenChannelData {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	names := map[string]bool{models.DefaultScreenChannel: true}
+	for channel := range h.channelShows {
+		names[channel] = true
+	}
+	for channel, clients := range h.byChannel {
+		if len(clients) > 0 {
+			names[channel] = true
+		}
+	}
+
+	channels := make([]ScreenChannelData, 0, len(names))
+	for channel := range names {
+		channels = append(channels, h.screenChannel(channel))
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i].Channel < channels[j].Channel })
+	return channels
+}
+
+// ParseScreenChannel returns the channel a screen asked for in the channel
+// query parameter
+func ParseScreenChannel(value string) (string, error) {
+	channel := models.NormalizeScreenChannel(value)
+	if err := models.ValidateScreenChannel(channel); err != nil {
+		return "", fmt.Errorf("channel: %v", err)
+	}
+	return channel, nil
+}
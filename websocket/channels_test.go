@@ -0,0 +1,102 @@
+package websocket
+
+import (
+	"encoding/json"
+	"quiz100/models"
+	"testing"
+)
+
+func receivedTypes(t *testing.T, client *Client) []MessageType {
+	t.Helper()
+	var types []MessageType
+	for {
+		select {
+		case data := <-client.Send:
+			var message TypedMessage
+			if err := json.Unmarshal(data, &message); err != nil {
+				t.Fatalf("Failed to unmarshal message: %v", err)
+			}
+			types = append(types, message.Type)
+		default:
+			return types
+		}
+	}
+}
+
+func newScreen(channel string) *Client {
+	client := newTestClient(ClientTypeScreen)
+	client.Channel = channel
+	return client
+}
+
+func TestScreenChannelRouting(t *testing.T) {
+	hub := NewHub(nil)
+	hub.SetScreenChannel("leaderboard", []models.ScreenContent{models.ScreenLeaderboard, models.ScreenEmoji})
+	mainScreen := newScreen(models.DefaultScreenChannel)
+	leaderboard := newScreen("leaderboard")
+	hub.addClient(mainScreen)
+	hub.addClient(leaderboard)
+
+	publish(hub, NewTypedMessage(MessageQuestionStart, nil))
+	publish(hub, NewTypedMessage(MessageEmojiReaction, nil), ClientTypeScreen)
+	publish(hub, NewTypedMessage(MessageStateChanged, nil))
+	publish(hub, NewTypedMessage(MessageAnswerReveal, nil))
+	publish(hub, NewTypedMessage(MessageUserJoined, nil))
+
+	if types := receivedTypes(t, mainScreen); len(types) != 5 {
+		t.Errorf("Expected the main screen to receive everything, got %v", types)
+	}
+	want := []MessageType{MessageEmojiReaction, MessageStateChanged, MessageAnswerReveal}
+	types := receivedTypes(t, leaderboard)
+	if len(types) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want, types)
+		}
+	}
+}
+
+func TestReplaySkipsOtherChannels(t *testing.T) {
+	hub := NewHub(nil)
+	hub.SetScreenChannel("lobby", []models.ScreenContent{models.ScreenParticipants})
+	publish(hub, NewTypedMessage(MessageQuestionStart, nil))
+	publish(hub, NewTypedMessage(MessageUserJoined, nil))
+	publish(hub, NewTypedMessage(MessageCountdown, nil))
+
+	client := newScreen("lobby")
+	client.ResumeEpoch = hub.epoch
+	if !hub.addClient(client) {
+		t.Fatal("Expected the missed messages to be replayed")
+	}
+	if seqs := receivedSeqs(t, client); len(seqs) != 1 || seqs[0] != 2 {
+		t.Errorf("Expected only the user_joined message (seq 2), got %v", seqs)
+	}
+}
+
+func TestScreenChannels(t *testing.T) {
+	hub := NewHub(nil)
+	hub.SetScreenChannel("lobby", []models.ScreenContent{models.ScreenSlides})
+	hub.addClient(newScreen("stage"))
+
+	channels := hub.ScreenChannels()
+	if len(channels) != 3 || channels[0].Channel != "lobby" || channels[1].Channel != "main" || channels[2].Channel != "stage" {
+		t.Fatalf("Expected lobby, main and stage, got %+v", channels)
+	}
+	if channels[2].Screens != 1 || len(channels[2].Shows) != len(models.AllScreenContents()) {
+		t.Errorf("Expected the stage channel to have a screen and show everything, got %+v", channels[2])
+	}
+
+	hub.SetScreenChannel("lobby", nil)
+	if len(hub.ScreenChannels()) != 2 {
+		t.Error("Expected the cleared lobby channel to be forgotten")
+	}
+
+	if _, err := ParseScreenChannel("Big Screen"); err == nil {
+		t.Error("Expected a channel name with a space to be rejected")
+	}
+	if channel, err := ParseScreenChannel(""); err != nil || channel != models.DefaultScreenChannel {
+		t.Errorf("Expected an empty channel to be main, got %q, %v", channel, err)
+	}
+}
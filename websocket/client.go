@@ -0,0 +1,198 @@
+package websocket
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Connection timing, chosen for phones on congested party Wi-Fi
+const (
+	writeWait       = 10 * time.Second    // Time allowed to write one message
+	pongWait        = 60 * time.Second    // Time allowed between frames from the client
+	pingPeriod      = (pongWait * 9) / 10 // Protocol-level pings keep idle connections open
+	maxMessageSize  = 512                 // Largest message accepted from a client
+	clientQueueSize = 256                 // Messages queued per client before the slow-consumer policy applies
+)
+
+// Transport is how messages reach a client
+type Transport string
+
+const (
+	TransportWebSocket Transport = "websocket"
+	TransportSSE       Transport = "sse" // Server-Sent Events; the client sends requests over HTTP
+)
+
+type Client struct {
+	Conn        *websocket.Conn // nil for SSE clients
+	Type        ClientType
+	Transport   Transport
+	UserID      int
+	SessionID   string
+	Send        chan []byte // Queue written and closed only by the hub's run loop
+	ConnectedAt time.Time
+	Protocol    int    // Protocol version negotiated when connecting
+	Channel     string // Screen channel (from the channel query parameter); empty for other clients
+
+	// Where a reconnecting client left off (from the last_seq and epoch query parameters)
+	ResumeSeq   uint64
+	ResumeEpoch string
+
+	hub *Hub
+}
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: checkOrigin,
+}
+
+// allowedOrigins are the other origins whose pages may open connections
+// (set once at startup by AllowOrigins)
+var allowedOrigins = map[string]bool{}
+
+// AllowOrigins lets pages served from the comma-separated origins (such as
+// "https://quiz.example.com") connect besides the server's own host
+func AllowOrigins(origins string) {
+	allowedOrigins = map[string]bool{}
+	for _, origin := range strings.Split(origins, ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			allowedOrigins[strings.ToLower(origin)] = true
+		}
+	}
+}
+
+// checkOrigin rejects connections opened by pages of other sites, which
+// would otherwise ride on the staff's login cookie. Clients that are not
+// browsers send no Origin and are authenticated like any request.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if allowedOrigins[strings.ToLower(origin)] {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// ReadPump reads messages until the connection fails or stays silent for
+// longer than pongWait, then unregisters the client
+func (c *Client) ReadPump(hub *Hub, onMessage func(*Client, []byte)) {
+	defer func() {
+		hub.Unregister <- c
+		c.Conn.Close()
+	}()
+
+	c.Conn.SetReadLimit(maxMessageSize)
+	c.Conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.Conn.SetPongHandler(func(string) error {
+		return c.Conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, message, err := c.Conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("WebSocket error: %v", err)
+			}
+			break
+		}
+		c.Conn.SetReadDeadline(time.Now().Add(pongWait))
+
+		if onMessage != nil {
+			onMessage(c, message)
+		}
+	}
+}
+
+// WritePump writes queued messages and protocol pings. It stops when the hub
+// closes the queue or a write does not finish within writeWait.
+func (c *Client) WritePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.Conn.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-c.Send:
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+
+			if err := c.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				log.Printf("WebSocket write error: %v", err)
+				return
+			}
+
+		case <-ticker.C:
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// SendDirect queues a message for this client only (replies, pings). It is
+// not numbered and not kept for replay.
+func (c *Client) SendDirect(msgType MessageType, data []byte) {
+	c.hub.direct <- directMessage{client: c, msgType: msgType, data: data}
+}
+
+func ServeWS(hub *Hub, w http.ResponseWriter, r *http.Request, clientType ClientType, userID int, sessionID string, messageHandler *MessageHandler) {
+	protocol, ok := negotiateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	channel := ""
+	if clientType == ClientTypeScreen {
+		var err error
+		if channel, err = ParseScreenChannel(r.URL.Query().Get("channel")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	header := http.Header{protocolHeader: {strconv.Itoa(protocol)}}
+	conn, err := upgrader.Upgrade(w, r, header)
+	if err != nil {
+		log.Printf("WebSocket upgrade error: %v", err)
+		return
+	}
+
+	client := &Client{
+		Conn:        conn,
+		Type:        clientType,
+		Transport:   TransportWebSocket,
+		UserID:      userID,
+		SessionID:   sessionID,
+		Send:        make(chan []byte, clientQueueSize),
+		ConnectedAt: time.Now(),
+		Protocol:    protocol,
+		Channel:     channel,
+		ResumeEpoch: r.URL.Query().Get("epoch"),
+		hub:         hub,
+	}
+	client.ResumeSeq, _ = strconv.ParseUint(r.URL.Query().Get("last_seq"), 10, 64)
+
+	hub.Register <- client
+
+	// Set up message handling function
+	var onMessage func(*Client, []byte)
+	if messageHandler != nil {
+		onMessage = messageHandler.HandleMessage
+	}
+
+	go client.WritePump()
+	go client.ReadPump(hub, onMessage)
+}
@@ -0,0 +1,38 @@
+package websocket
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	AllowOrigins("https://Quiz.example.com/, https://screen.example.com")
+	t.Cleanup(func() { AllowOrigins("") })
+
+	tests := []struct {
+		name   string
+		origin string
+		want   bool
+	}{
+		{"no origin (not a browser)", "", true},
+		{"same host", "http://quiz.local:8080", true},
+		{"same host, other case", "http://QUIZ.local:8080", true},
+		{"other site", "https://evil.example.net", false},
+		{"same host name, other port", "http://quiz.local:9090", false},
+		{"allowed origin", "https://quiz.example.com", true},
+		{"allowed origin, other scheme", "http://quiz.example.com", false},
+		{"invalid origin", "://", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://quiz.local:8080/ws/admin", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if got := checkOrigin(req); got != tt.want {
+				t.Errorf("Origin %q: got %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
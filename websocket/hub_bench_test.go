@@ -0,0 +1,118 @@
+package websocket
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+// benchmarkHub registers fake clients whose queues are drained in the
+// background, like WritePumps on a fast network
+func benchmarkHub(b *testing.B, participants, screens int) *Hub {
+	b.Helper()
+	log.SetOutput(io.Discard)
+	hub := NewHub(nil)
+	add := func(clientType ClientType, count int) {
+		for i := range count {
+			client := &Client{Type: clientType, UserID: i, Send: make(chan []byte, clientQueueSize)}
+			hub.addClient(client)
+			go func() {
+				for range client.Send {
+				}
+			}()
+		}
+	}
+	add(ClientTypeParticipant, participants)
+	add(ClientTypeScreen, screens)
+	add(ClientTypeAdmin, 1)
+
+	b.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		hub.mutex.Lock()
+		defer hub.mutex.Unlock()
+		for client := range hub.clients {
+			hub.removeClient(client)
+		}
+	})
+	return hub
+}
+
+var questionData = map[string]any{
+	"question_number": 12,
+	"question": map[string]any{
+		"type":    "text",
+		"text":    "日本で一番高い山は？",
+		"choices": []string{"富士山", "北岳", "奥穂高岳", "槍ヶ岳"},
+	},
+	"total_questions": 30,
+}
+
+func BenchmarkPublishToAll(b *testing.B) {
+	for _, participants := range []int{100, 1000, 5000} {
+		b.Run(fmt.Sprintf("participants=%d", participants), func(b *testing.B) {
+			hub := benchmarkHub(b, participants, 2)
+			message := NewTypedMessage(MessageQuestionStart, questionData)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for b.Loop() {
+				hub.publishMessage(publishRequest{message: message})
+			}
+		})
+	}
+}
+
+// Publishing to the screens must not cost more with a full room
+func BenchmarkPublishToScreens(b *testing.B) {
+	for _, participants := range []int{100, 1000, 5000} {
+		b.Run(fmt.Sprintf("participants=%d", participants), func(b *testing.B) {
+			hub := benchmarkHub(b, participants, 2)
+			message := NewTypedMessageWithTarget(MessageCountdown, map[string]any{"seconds_left": 5}, ClientTypeScreen)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for b.Loop() {
+				hub.publishMessage(publishRequest{message: message, clientTypes: []ClientType{ClientTypeScreen}})
+			}
+		})
+	}
+}
+
+// Everyone in the room answers within one batch window
+func BenchmarkAnswerBurst(b *testing.B) {
+	for _, participants := range []int{100, 1000, 5000} {
+		b.Run(fmt.Sprintf("participants=%d", participants), func(b *testing.B) {
+			hub := benchmarkHub(b, participants, 2)
+			answers := make([]TypedMessage, participants)
+			for i := range answers {
+				answers[i] = NewTypedMessageWithTarget(MessageAnswerReceived, map[string]any{
+					"nickname":        fmt.Sprintf("player%d", i),
+					"question_number": 1,
+					"answer":          i%4 + 1,
+				}, ClientTypeAdmin)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for b.Loop() {
+				for _, answer := range answers {
+					hub.addToBatch(publishRequest{message: answer, clientTypes: []ClientType{ClientTypeAdmin}})
+				}
+				hub.flushBatches()
+			}
+		})
+	}
+}
+
+// Publish through the run loop; once the queue is full, every call waits for it
+func BenchmarkRunLoopPublish(b *testing.B) {
+	hub := benchmarkHub(b, 1000, 2)
+	go hub.Run()
+	message := NewTypedMessage(MessageStateChanged, map[string]any{"new_state": "answer_reveal"})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for b.Loop() {
+		if err := hub.Publish(message); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
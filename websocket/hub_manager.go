@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"quiz100/models"
 )
 
 // HubManager provides high-level WebSocket hub management operations
@@ -20,39 +21,48 @@ func NewHubManager(hub *Hub) *HubManager {
 
 // BroadcastMessage sends a message to all connected clients
 func (hm *HubManager) BroadcastMessage(msgType MessageType, data any) error {
+	if err := hm.ValidateMessage(msgType, data); err != nil {
+		return err
+	}
 	message := NewTypedMessage(msgType, data)
 	return hm.broadcastTypedMessage(message)
 }
 
 // BroadcastToType sends a message to clients of a specific type
 func (hm *HubManager) BroadcastToType(msgType MessageType, data any, clientType ClientType) error {
+	if err := hm.ValidateMessage(msgType, data); err != nil {
+		return err
+	}
 	message := NewTypedMessageWithTarget(msgType, data, clientType)
 	return hm.broadcastTypedMessageToType(message, clientType)
 }
 
 // BroadcastToUser sends a message to a specific user
 func (hm *HubManager) BroadcastToUser(msgType MessageType, data any, userID int) error {
+	if err := hm.ValidateMessage(msgType, data); err != nil {
+		return err
+	}
 	message := NewTypedMessage(msgType, data)
 	return hm.broadcastTypedMessageToUser(message, userID)
 }
 
 // BroadcastEventStarted sends event started message to all clients
-func (hm *HubManager) BroadcastEventStarted(eventData any) error {
+func (hm *HubManager) BroadcastEventStarted(eventData EventStartedData) error {
 	return hm.BroadcastMessage(MessageEventStarted, eventData)
 }
 
 // BroadcastTitleDisplay sends title display message to screen clients
-func (hm *HubManager) BroadcastTitleDisplay(titleData any) error {
+func (hm *HubManager) BroadcastTitleDisplay(titleData TitleDisplayData) error {
 	return hm.BroadcastToType(MessageTitleDisplay, titleData, ClientTypeScreen)
 }
 
 // BroadcastTeamAssignment sends team assignment message to all clients
-func (hm *HubManager) BroadcastTeamAssignment(teamsData any) error {
+func (hm *HubManager) BroadcastTeamAssignment(teamsData TeamAssignmentData) error {
 	return hm.BroadcastMessage(MessageTeamAssignment, teamsData)
 }
 
 // BroadcastQuestionStart sends question start message to all clients
-func (hm *HubManager) BroadcastQuestionStart(questionData any, questionAndAnswerData any) error {
+func (hm *HubManager) BroadcastQuestionStart(questionData QuestionStartData, questionAndAnswerData QuestionStartData) error {
 	if err := hm.BroadcastToType(MessageQuestionStart, questionAndAnswerData, ClientTypeAdmin); err != nil {
 		return err
 	}
@@ -63,12 +73,12 @@ func (hm *HubManager) BroadcastQuestionStart(questionData any, questionAndAnswer
 }
 
 // BroadcastQuestionEnd sends question end message to all clients
-func (hm *HubManager) BroadcastQuestionEnd(endData any) error {
+func (hm *HubManager) BroadcastQuestionEnd(endData QuestionEndData) error {
 	return hm.BroadcastMessage(MessageQuestionEnd, endData)
 }
 
 // BroadcastCountdown sends countdown message to all clients
-func (hm *HubManager) BroadcastCountdown(countdownData any) error {
+func (hm *HubManager) BroadcastCountdown(countdownData CountdownData) error {
 	// Send to admin clients
 	if err := hm.BroadcastToType(MessageCountdown, countdownData, ClientTypeAdmin); err != nil {
 		return err
@@ -78,7 +88,7 @@ func (hm *HubManager) BroadcastCountdown(countdownData any) error {
 }
 
 // BroadcastAnswerStats sends answer statistics to screen clients
-func (hm *HubManager) BroadcastAnswerStats(statsData any) error {
+func (hm *HubManager) BroadcastAnswerStats(statsData AnswerStatsData) error {
 	// Send to admin clients
 	if err := hm.BroadcastToType(MessageAnswerStats, statsData, ClientTypeAdmin); err != nil {
 		return err
@@ -88,22 +98,52 @@ func (hm *HubManager) BroadcastAnswerStats(statsData any) error {
 }
 
 // BroadcastAnswerReveal sends answer reveal to all clients
-func (hm *HubManager) BroadcastAnswerReveal(revealData any) error {
+func (hm *HubManager) BroadcastAnswerReveal(revealData AnswerRevealData) error {
 	return hm.BroadcastMessage(MessageAnswerReveal, revealData)
 }
 
+// BroadcastLeaderboard sends the mid-game standings to all clients
+func (hm *HubManager) BroadcastLeaderboard(leaderboardData LeaderboardData) error {
+	return hm.BroadcastMessage(MessageLeaderboard, leaderboardData)
+}
+
+// BroadcastIntermission sends an intermission notice to all clients
+func (hm *HubManager) BroadcastIntermission(intermissionData IntermissionData) error {
+	return hm.BroadcastMessage(MessageIntermission, intermissionData)
+}
+
+// BroadcastEventPaused notifies all clients that the event is paused
+func (hm *HubManager) BroadcastEventPaused(pauseData PauseData) error {
+	return hm.BroadcastMessage(MessageEventPaused, pauseData)
+}
+
+// BroadcastEventResumed notifies all clients that the event continues
+func (hm *HubManager) BroadcastEventResumed(pauseData PauseData) error {
+	return hm.BroadcastMessage(MessageEventResumed, pauseData)
+}
+
+// BroadcastSlideShow shows an announcement slide on all clients
+func (hm *HubManager) BroadcastSlideShow(slideData *models.Slide) error {
+	return hm.BroadcastMessage(MessageSlideShow, slideData)
+}
+
+// BroadcastSlideHide returns all clients from the announcement to the current state
+func (hm *HubManager) BroadcastSlideHide(slideData SlideHideData) error {
+	return hm.BroadcastMessage(MessageSlideHide, slideData)
+}
+
 // BroadcastFinalResults sends final results to all clients
-func (hm *HubManager) BroadcastFinalResults(resultsData any) error {
+func (hm *HubManager) BroadcastFinalResults(resultsData FinalResultsData) error {
 	return hm.BroadcastMessage(MessageFinalResults, resultsData)
 }
 
 // BroadcastCelebration sends celebration message to screen clients
-func (hm *HubManager) BroadcastCelebration(celebrationData any) error {
+func (hm *HubManager) BroadcastCelebration(celebrationData CelebrationData) error {
 	return hm.BroadcastToType(MessageCelebration, celebrationData, ClientTypeScreen)
 }
 
 // BroadcastUserJoined sends user joined notification to admin and screen
-func (hm *HubManager) BroadcastUserJoined(userData any) error {
+func (hm *HubManager) BroadcastUserJoined(userData UserJoinedData) error {
 	// Send to admin clients
 	if err := hm.BroadcastToType(MessageUserJoined, userData, ClientTypeAdmin); err != nil {
 		return err
@@ -113,7 +153,7 @@ func (hm *HubManager) BroadcastUserJoined(userData any) error {
 }
 
 // BroadcastUserLeft sends user left notification to admin and screen
-func (hm *HubManager) BroadcastUserLeft(userData any) error {
+func (hm *HubManager) BroadcastUserLeft(userData UserLeftData) error {
 	// Send to admin clients
 	if err := hm.BroadcastToType(MessageUserLeft, userData, ClientTypeAdmin); err != nil {
 		return err
@@ -123,17 +163,17 @@ func (hm *HubManager) BroadcastUserLeft(userData any) error {
 }
 
 // BroadcastAnswerReceived sends answer received notification to admin
-func (hm *HubManager) BroadcastAnswerReceived(answerData any) error {
+func (hm *HubManager) BroadcastAnswerReceived(answerData AnswerReceivedData) error {
 	return hm.BroadcastToType(MessageAnswerReceived, answerData, ClientTypeAdmin)
 }
 
 // BroadcastEmojiReaction sends emoji reaction to screen clients
-func (hm *HubManager) BroadcastEmojiReaction(emojiData any) error {
+func (hm *HubManager) BroadcastEmojiReaction(emojiData EmojiData) error {
 	return hm.BroadcastToType(MessageEmojiReaction, emojiData, ClientTypeScreen)
 }
 
 // BroadcastTeamMemberAdded sends team member added notification
-func (hm *HubManager) BroadcastTeamMemberAdded(teamData any) error {
+func (hm *HubManager) BroadcastTeamMemberAdded(teamData TeamMemberAddedData) error {
 	// Send to admin clients
 	if err := hm.BroadcastToType(MessageTeamMemberAdded, teamData, ClientTypeAdmin); err != nil {
 		return err
@@ -142,13 +182,66 @@ func (hm *HubManager) BroadcastTeamMemberAdded(teamData any) error {
 	return hm.BroadcastToType(MessageTeamMemberAdded, teamData, ClientTypeScreen)
 }
 
+// BroadcastAnswerCount sends the live answer counts to admins and screens;
+// screenData usually leaves out the distribution
+func (hm *HubManager) BroadcastAnswerCount(adminData, screenData AnswerCountData) error {
+	if err := hm.BroadcastToType(MessageAnswerCount, adminData, ClientTypeAdmin); err != nil {
+		return err
+	}
+	return hm.BroadcastToType(MessageAnswerCount, screenData, ClientTypeScreen)
+}
+
+// BroadcastConnectionQuality sends admins the latest connection statistics
+func (hm *HubManager) BroadcastConnectionQuality(qualityData ConnectionQualityData) error {
+	return hm.BroadcastToType(MessageConnectionQuality, qualityData, ClientTypeAdmin)
+}
+
+// BroadcastPresence tells admins that a participant went online, away or offline
+func (hm *HubManager) BroadcastPresence(presenceData PresenceData) error {
+	return hm.BroadcastToType(MessagePresence, presenceData, ClientTypeAdmin)
+}
+
+// BroadcastAdminAction tells admin consoles that an operator ran an action
+func (hm *HubManager) BroadcastAdminAction(actionData AdminActionData) error {
+	return hm.BroadcastToType(MessageAdminAction, actionData, ClientTypeAdmin)
+}
+
+// BroadcastOperatorLock tells admin consoles who holds the operator lock
+func (hm *HubManager) BroadcastOperatorLock(lockData OperatorLockData) error {
+	return hm.BroadcastToType(MessageOperatorLock, lockData, ClientTypeAdmin)
+}
+
+// SetScreenChannel sets what the screens of a channel show and tells them
+// and the admins
+func (hm *HubManager) SetScreenChannel(channel string, shows []models.ScreenContent) (ScreenChannelData, error) {
+	channel, err := ParseScreenChannel(channel)
+	if err != nil {
+		return ScreenChannelData{}, err
+	}
+	if err := models.ValidateScreenContents(shows); err != nil {
+		return ScreenChannelData{}, err
+	}
+
+	hm.hub.SetScreenChannel(channel, shows)
+	channelData := hm.hub.ScreenChannel(channel)
+	if err := hm.BroadcastToType(MessageScreenChannel, channelData, ClientTypeScreen); err != nil {
+		return channelData, err
+	}
+	return channelData, hm.BroadcastToType(MessageScreenChannel, channelData, ClientTypeAdmin)
+}
+
+// GetScreenChannels describes every known screen channel
+func (hm *HubManager) GetScreenChannels() []ScreenChannelData {
+	return hm.hub.ScreenChannels()
+}
+
 // BroadcastStateChanged sends state change notification to all clients
-func (hm *HubManager) BroadcastStateChanged(stateData any) error {
+func (hm *HubManager) BroadcastStateChanged(stateData StateChangedData) error {
 	return hm.BroadcastMessage(MessageStateChanged, stateData)
 }
 
 // BroadcastDatabaseReset sends database reset notification to all clients
-func (hm *HubManager) BroadcastDatabaseReset(resetData any) error {
+func (hm *HubManager) BroadcastDatabaseReset(resetData DatabaseResetData) error {
 	return hm.BroadcastMessage(MessageDatabaseReset, resetData)
 }
 
@@ -173,6 +266,8 @@ func (hm *HubManager) GetStatistics() map[string]any {
 	result["active_connections"] = stats.ActiveConnections
 	result["total_connected"] = stats.TotalConnected
 	result["messages_sent"] = stats.MessagesSent
+	result["messages_dropped"] = stats.MessagesDropped
+	result["clients_disconnected"] = stats.ClientsDisconnected
 	result["uptime_seconds"] = stats.UptimeSeconds
 	result["average_connections"] = stats.AverageConnections
 	result["client_details"] = stats.ClientDetails
@@ -189,25 +284,19 @@ func (hm *HubManager) GetClientsByType(clientType ClientType) []*Client {
 
 // broadcastTypedMessage broadcasts a typed message to all clients
 func (hm *HubManager) broadcastTypedMessage(message TypedMessage) error {
-	messageBytes, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Error marshaling typed message: %v", err)
+	if err := hm.hub.Publish(message); err != nil {
+		log.Printf("Error broadcasting typed message: %v", err)
 		return err
 	}
-
-	hm.hub.Broadcast <- messageBytes
 	return nil
 }
 
 // broadcastTypedMessageToType broadcasts a typed message to clients of a specific type
 func (hm *HubManager) broadcastTypedMessageToType(message TypedMessage, clientType ClientType) error {
-	messageBytes, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Error marshaling typed message: %v", err)
+	if err := hm.hub.Publish(message, clientType); err != nil {
+		log.Printf("Error broadcasting typed message: %v", err)
 		return err
 	}
-
-	hm.hub.BroadcastToType(messageBytes, clientType)
 	return nil
 }
 
@@ -219,7 +308,7 @@ func (hm *HubManager) broadcastTypedMessageToUser(message TypedMessage, userID i
 		return err
 	}
 
-	hm.hub.BroadcastToUser(messageBytes, userID)
+	hm.hub.SendToUser(message.Type, messageBytes, userID)
 	return nil
 }
 
@@ -241,3 +330,16 @@ func (hm *HubManager) ValidateMessageType(msgType MessageType) error {
 
 	return nil
 }
+
+// ValidateMessage checks that a message type is valid and that data has the
+// payload declared for it in messageSpecs
+func (hm *HubManager) ValidateMessage(msgType MessageType, data any) error {
+	if err := hm.ValidateMessageType(msgType); err != nil {
+		return err
+	}
+	if err := ValidatePayload(msgType, data); err != nil {
+		log.Printf("Refusing to broadcast: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlowClientDropsDroppableMessages(t *testing.T) {
+	hub := NewHub(nil)
+	client := &Client{Type: ClientTypeScreen, Send: make(chan []byte, 1)}
+	hub.addClient(client)
+
+	publish(hub, NewTypedMessage(MessageEmojiReaction, nil))
+	publish(hub, NewTypedMessage(MessageEmojiReaction, nil))
+
+	if hub.MessagesDropped != 1 {
+		t.Errorf("Expected 1 dropped message, got %d", hub.MessagesDropped)
+	}
+	if hub.GetClientCount()[ClientTypeScreen] != 1 {
+		t.Error("Expected the client to stay connected after a dropped emoji")
+	}
+}
+
+func TestSlowClientIsDisconnected(t *testing.T) {
+	hub := NewHub(nil)
+	client := &Client{Type: ClientTypeParticipant, Send: make(chan []byte, 1)}
+	hub.addClient(client)
+
+	publish(hub, NewTypedMessage(MessageQuestionStart, nil))
+	publish(hub, NewTypedMessage(MessageAnswerReveal, nil))
+
+	if hub.GetClientCount()[ClientTypeParticipant] != 0 || hub.ClientsDisconnected != 1 {
+		t.Fatal("Expected the slow client to be disconnected")
+	}
+	<-client.Send
+	if _, ok := <-client.Send; ok {
+		t.Error("Expected the queue to be closed")
+	}
+
+	// The ReadPump unregisters the client afterwards; that must not close the queue again
+	hub.mutex.Lock()
+	removed := hub.removeClient(client)
+	hub.mutex.Unlock()
+	if removed {
+		t.Error("Expected a second removal to be ignored")
+	}
+
+	// Later messages skip the removed client
+	publish(hub, NewTypedMessage(MessageLeaderboard, nil))
+}
+
+func TestRunLoopDelivers(t *testing.T) {
+	hub := NewHub(nil)
+	go hub.Run()
+
+	client := &Client{Type: ClientTypeParticipant, UserID: 7, Send: make(chan []byte, clientQueueSize), hub: hub}
+	hub.Register <- client
+	for hub.GetClientCount()[ClientTypeParticipant] == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := hub.Publish(NewTypedMessage(MessageStateChanged, map[string]any{"new_state": "waiting"})); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	client.SendDirect(MessageAck, []byte(`{"type":"ack"}`))
+	hub.SendToUser(MessagePing, []byte(`{"type":"ping"}`), 7)
+
+	// Broadcasts and direct messages arrive on separate channels, so only the set is fixed
+	received := map[string]bool{}
+	for range 3 {
+		received[string(<-client.Send)] = true
+	}
+	for _, want := range []string{`{"type":"state_changed","data":{"new_state":"waiting"},"seq":1}`, `{"type":"ack"}`, `{"type":"ping"}`} {
+		if !received[want] {
+			t.Errorf("Expected %s, got %v", want, received)
+		}
+	}
+
+	if err := hub.Publish(NewTypedMessage(MessageStateChanged, make(chan int))); err == nil {
+		t.Error("Expected an unmarshalable message to be rejected")
+	}
+
+	hub.Unregister <- client
+	hub.Unregister <- client
+	for range client.Send {
+	}
+}
+
+func TestConnectionListenerSeesRegistrations(t *testing.T) {
+	hub := NewHub(nil)
+	var events []ConnectionEvent
+	hub.AddConnectionListener(func(event ConnectionEvent) { events = append(events, event) })
+
+	client := &Client{Type: ClientTypeParticipant, UserID: 3, Send: make(chan []byte, 1)}
+	hub.addClient(client)
+	hub.mutex.Lock()
+	hub.removeClient(client)
+	hub.removeClient(client) // Already gone; no second event
+	hub.mutex.Unlock()
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	if !events[0].Connected || events[1].Connected {
+		t.Errorf("Expected connect then disconnect, got %+v", events)
+	}
+	if events[0].Client != client || events[1].Time.IsZero() {
+		t.Errorf("Expected events about the client with a time, got %+v", events)
+	}
+}
@@ -3,11 +3,30 @@ package websocket
 import (
 	"encoding/json"
 	"log"
+	quizerrors "quiz100/errors"
+	"quiz100/models"
 )
 
 // MessageHandler handles WebSocket message processing
 type MessageHandler struct {
-	pingManager *PingManager
+	pingManager   *PingManager
+	answerService AnswerService
+	rateLimiter   *models.RateLimiter
+}
+
+// AnswerService grades answers and relays emoji sent by participants.
+// The HTTP endpoints use the same implementation.
+type AnswerService interface {
+	SubmitAnswer(sessionID string, questionNumber, answerIndex int) (*AnswerResult, error)
+	SendEmoji(sessionID, emoji string) error
+}
+
+// AnswerResult is the outcome of a graded answer
+type AnswerResult struct {
+	AnswerIndex int  `json:"answer_index"`
+	IsCorrect   bool `json:"is_correct"`
+	NewScore    int  `json:"new_score"`
+	ScoreChange int  `json:"score_change"`
 }
 
 // NewMessageHandler creates a new message handler
@@ -17,11 +36,23 @@ func NewMessageHandler(pingManager *PingManager) *MessageHandler {
 	}
 }
 
+// SetAnswerService enables answer and emoji messages from participants
+func (mh *MessageHandler) SetAnswerService(answerService AnswerService) {
+	mh.answerService = answerService
+}
+
+// SetRateLimiter limits answers and emoji with the buckets the HTTP
+// endpoints use, so switching transports does not reset a participant's limit
+func (mh *MessageHandler) SetRateLimiter(rateLimiter *models.RateLimiter) {
+	mh.rateLimiter = rateLimiter
+}
+
 // HandleMessage processes incoming WebSocket messages
 func (mh *MessageHandler) HandleMessage(client *Client, message []byte) {
 	var msg struct {
-		Type string          `json:"type"`
-		Data json.RawMessage `json:"data"`
+		Type      string          `json:"type"`
+		RequestID string          `json:"request_id"`
+		Data      json.RawMessage `json:"data"`
 	}
 
 	if err := json.Unmarshal(message, &msg); err != nil {
@@ -30,8 +61,12 @@ func (mh *MessageHandler) HandleMessage(client *Client, message []byte) {
 	}
 
 	switch msg.Type {
-	case "pong":
+	case string(MessagePong):
 		mh.handlePongMessage(client, msg.Data)
+	case string(MessageAnswer):
+		mh.handleAnswerMessage(client, msg.RequestID, msg.Data)
+	case string(MessageEmojiReaction):
+		mh.handleEmojiMessage(client, msg.RequestID, msg.Data)
 	default:
 		// Other message types can be added here in the future
 		log.Printf("Unknown message type: %s", msg.Type)
@@ -40,10 +75,7 @@ func (mh *MessageHandler) HandleMessage(client *Client, message []byte) {
 
 // handlePongMessage processes pong responses from participants
 func (mh *MessageHandler) handlePongMessage(client *Client, data json.RawMessage) {
-	var pongData struct {
-		PingID string `json:"ping_id"`
-	}
-
+	var pongData PingData
 	if err := json.Unmarshal(data, &pongData); err != nil {
 		log.Printf("Error unmarshaling pong data: %v", err)
 		return
@@ -63,3 +95,92 @@ func (mh *MessageHandler) handlePongMessage(client *Client, data json.RawMessage
 	// Forward to ping manager for processing
 	mh.pingManager.HandlePong(pongData.PingID, client.UserID)
 }
+
+// handleAnswerMessage grades an answer and acknowledges it to the sender
+func (mh *MessageHandler) handleAnswerMessage(client *Client, requestID string, data json.RawMessage) {
+	if !mh.acceptsParticipantRequest(client, requestID, MessageAnswer) || !mh.allowRequest(client, requestID, MessageAnswer, models.RateLimitAnswer) {
+		return
+	}
+
+	var answerData AnswerRequest
+	if err := json.Unmarshal(data, &answerData); err != nil {
+		sendError(client, requestID, MessageAnswer, quizerrors.ErrInvalidRequest.WithDetails("Invalid answer data"))
+		return
+	}
+
+	result, err := mh.answerService.SubmitAnswer(client.SessionID, answerData.QuestionNumber, answerData.AnswerIndex)
+	if err != nil {
+		sendError(client, requestID, MessageAnswer, quizerrors.FromStandardError(err))
+		return
+	}
+	sendReply(client, MessageAck, ReplyData{RequestID: requestID, For: MessageAnswer, Result: result})
+}
+
+// handleEmojiMessage relays an emoji reaction and acknowledges it to the sender
+func (mh *MessageHandler) handleEmojiMessage(client *Client, requestID string, data json.RawMessage) {
+	if !mh.acceptsParticipantRequest(client, requestID, MessageEmojiReaction) || !mh.allowRequest(client, requestID, MessageEmojiReaction, models.RateLimitEmoji) {
+		return
+	}
+
+	var emojiData EmojiRequest
+	if err := json.Unmarshal(data, &emojiData); err != nil {
+		sendError(client, requestID, MessageEmojiReaction, quizerrors.ErrInvalidRequest.WithDetails("Invalid emoji data"))
+		return
+	}
+
+	if err := mh.answerService.SendEmoji(client.SessionID, emojiData.Emoji); err != nil {
+		sendError(client, requestID, MessageEmojiReaction, quizerrors.FromStandardError(err))
+		return
+	}
+	sendReply(client, MessageAck, ReplyData{RequestID: requestID, For: MessageEmojiReaction})
+}
+
+// acceptsParticipantRequest rejects requests from admin/screen clients and
+// requests arriving before the answer service is set
+func (mh *MessageHandler) acceptsParticipantRequest(client *Client, requestID string, requestType MessageType) bool {
+	if client.Type != ClientTypeParticipant {
+		log.Printf("Ignoring %s from non-participant client: %s", requestType, client.Type)
+		return false
+	}
+	if mh.answerService == nil {
+		sendError(client, requestID, requestType, quizerrors.ErrServiceUnavailable.WithDetails("Not available over WebSocket"))
+		return false
+	}
+	return true
+}
+
+// allowRequest refuses requests over the rate limit of the session. The
+// client IP is only limited over HTTP, where it is resolved behind proxies.
+func (mh *MessageHandler) allowRequest(client *Client, requestID string, requestType MessageType, endpoint string) bool {
+	if mh.rateLimiter == nil {
+		return true
+	}
+	ok, retryAfter := mh.rateLimiter.Allow(endpoint, client.SessionID, "")
+	if !ok {
+		reply := errorReply(requestID, requestType, quizerrors.ErrRateLimited)
+		reply.RetryAfter = models.RetryAfterSeconds(retryAfter)
+		sendReply(client, MessageError, reply)
+	}
+	return ok
+}
+
+// sendError answers a participant request with the message and code of err
+func sendError(client *Client, requestID string, requestType MessageType, err *quizerrors.QuizError) {
+	sendReply(client, MessageError, errorReply(requestID, requestType, err))
+}
+
+func errorReply(requestID string, requestType MessageType, err *quizerrors.QuizError) ReplyData {
+	return ReplyData{RequestID: requestID, For: requestType, Error: err.Message, Code: string(err.Code)}
+}
+
+// sendReply answers a participant request; request_id lets the client match
+// it with the request it sent
+func sendReply(client *Client, replyType MessageType, reply ReplyData) {
+	jsonData, err := json.Marshal(Message{Type: string(replyType), Data: reply})
+	if err != nil {
+		log.Printf("Error marshaling %s reply: %v", reply.For, err)
+		return
+	}
+
+	client.SendDirect(replyType, jsonData)
+}
@@ -20,17 +20,43 @@ const (
 	MessageAnswerReceived  MessageType = "answer_received"
 	MessageEmojiReaction   MessageType = "emoji"
 	MessageTeamMemberAdded MessageType = "team_member_added"
+	MessagePresence        MessageType = "presence" // Participant went online, away or offline
+
+	// Participant requests and their replies
+	MessageAnswer MessageType = "answer" // Answer submitted over WebSocket
+	MessageAck    MessageType = "ack"    // Request succeeded
+	MessageError  MessageType = "error"  // Request failed
 
 	// Quiz progress messages
 	MessageCountdown    MessageType = "countdown"
 	MessageAnswerStats  MessageType = "answer_stats"
+	MessageAnswerCount  MessageType = "answer_count" // Live answer counts while a question is open
 	MessageAnswerReveal MessageType = "answer_reveal"
+	MessageLeaderboard  MessageType = "leaderboard"
+	MessageIntermission MessageType = "intermission"
 	MessageStateChanged MessageType = "state_changed"
+	MessageEventPaused  MessageType = "event_paused"
+	MessageEventResumed MessageType = "event_resumed"
+
+	// Admin console coordination
+	MessageAdminAction  MessageType = "admin_action"  // An operator ran an action
+	MessageOperatorLock MessageType = "operator_lock" // The operator lock was taken or released
+
+	// Announcement messages
+	MessageSlideShow MessageType = "slide_show"
+	MessageSlideHide MessageType = "slide_hide"
+
+	// Screen channel layout changed by the admin
+	MessageScreenChannel MessageType = "screen_channel"
 
 	// Connectivity messages
-	MessagePing       MessageType = "ping"
-	MessagePong       MessageType = "pong"
-	MessagePingResult MessageType = "ping_result"
+	MessagePing              MessageType = "ping"
+	MessagePong              MessageType = "pong"
+	MessagePingResult        MessageType = "ping_result"
+	MessageConnectionQuality MessageType = "connection_quality" // Periodic latency summary for admins
+
+	// Several high-frequency messages of one type sent together
+	MessageBatch MessageType = "batch"
 
 	// State synchronization messages
 	MessageInitialSync  MessageType = "initial_sync"
@@ -60,13 +86,29 @@ func AllMessageTypes() []MessageType {
 		MessageAnswerReceived,
 		MessageEmojiReaction,
 		MessageTeamMemberAdded,
+		MessagePresence,
+		MessageAnswer,
+		MessageAck,
+		MessageError,
 		MessageCountdown,
 		MessageAnswerStats,
+		MessageAnswerCount,
 		MessageAnswerReveal,
+		MessageLeaderboard,
+		MessageIntermission,
 		MessageStateChanged,
+		MessageEventPaused,
+		MessageEventResumed,
+		MessageAdminAction,
+		MessageOperatorLock,
+		MessageSlideShow,
+		MessageSlideHide,
+		MessageScreenChannel,
 		MessagePing,
 		MessagePong,
 		MessagePingResult,
+		MessageConnectionQuality,
+		MessageBatch,
 		MessageInitialSync,
 		MessageStateSync,
 		MessageSyncRequest,
@@ -119,12 +161,24 @@ func IsDeprecatedMessageType(msgType MessageType) bool {
 	return false
 }
 
+// IsDroppableMessageType checks if a message may be skipped for a client whose
+// queue is full. Losing one of these costs a client nothing it needs to stay in
+// step; for any other type the client is disconnected instead and resyncs.
+func IsDroppableMessageType(msgType MessageType) bool {
+	switch msgType {
+	case MessageEmojiReaction, MessageAnswerReceived, MessageAnswerCount, MessagePing, MessagePingResult, MessageConnectionQuality:
+		return true
+	}
+	return false
+}
+
 // TypedMessage represents a WebSocket message with typed message type
 type TypedMessage struct {
 	Type MessageType `json:"type"`
 	Data any         `json:"data"`
 	// UserID *int        `json:"user_id,omitempty"` // Reserved for future user-specific messaging
 	Target ClientType `json:"target,omitempty"`
+	Seq    uint64     `json:"seq,omitempty"` // Per client type; set by Hub.Publish
 }
 
 // NewTypedMessage creates a new typed message
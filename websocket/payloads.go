@@ -0,0 +1,375 @@
+package websocket
+
+import (
+	"fmt"
+	"net/http"
+	"quiz100/models"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Protocol versions this server speaks. Clients ask for a version with the
+// protocol query parameter when connecting; the server answers with the
+// version it chose in the protocol field of initial_sync.
+const (
+	ProtocolVersion    = 1
+	MinProtocolVersion = 1
+)
+
+// MessageSpec declares a message type: who takes part in it and the shape
+// of its data in each direction
+type MessageSpec struct {
+	Type        MessageType
+	Audience    []ClientType // Receivers of server messages, senders of client messages
+	Description string
+	Payload     any // Zero value of the data the server sends; nil if the server never sends it
+	Request     any // Zero value of the data clients send; nil if clients never send it
+	Deprecated  bool
+}
+
+var (
+	everyone         = []ClientType{ClientTypeAdmin, ClientTypeScreen, ClientTypeParticipant}
+	adminAndScreen   = []ClientType{ClientTypeAdmin, ClientTypeScreen}
+	adminOnly        = []ClientType{ClientTypeAdmin}
+	screenOnly       = []ClientType{ClientTypeScreen}
+	participantsOnly = []ClientType{ClientTypeParticipant}
+)
+
+// messageSpecs declares every message type of the protocol. The JSON Schema
+// and the message list in WebSocket.md are generated from it.
+var messageSpecs = []MessageSpec{
+	{MessageEventStarted, everyone, "イベント開始", EventStartedData{}, nil, false},
+	{MessageTitleDisplay, screenOnly, "タイトル表示", TitleDisplayData{}, nil, false},
+	{MessageTeamAssignment, everyone, "チーム分け結果", TeamAssignmentData{}, nil, false},
+	{MessageQuestionStart, everyone, "問題開始 (正解は管理者にのみ送る)", QuestionStartData{}, nil, false},
+	{MessageQuestionEnd, everyone, "回答締め切り", QuestionEndData{}, nil, false},
+	{MessageFinalResults, everyone, "最終結果", FinalResultsData{}, nil, false},
+	{MessageCelebration, screenOnly, "エンディング", CelebrationData{}, nil, false},
+	{MessageUserJoined, adminAndScreen, "参加者の参加", UserJoinedData{}, nil, false},
+	{MessageUserLeft, adminAndScreen, "参加者の退出", UserLeftData{}, nil, false},
+	{MessageAnswerReceived, adminOnly, "回答の受信", AnswerReceivedData{}, nil, false},
+	{MessageEmojiReaction, screenOnly, "絵文字リアクション (参加者からも送れる)", EmojiData{}, EmojiRequest{}, false},
+	{MessageTeamMemberAdded, adminAndScreen, "チームへのメンバー追加", TeamMemberAddedData{}, nil, false},
+	{MessagePresence, adminOnly, "参加者の接続状態の変化 (猶予時間を過ぎた切断のみ)", PresenceData{}, nil, false},
+	{MessageAnswer, participantsOnly, "回答の送信 (ack/error が返る)", nil, AnswerRequest{}, false},
+	{MessageAck, participantsOnly, "リクエストの成功", ReplyData{}, nil, false},
+	{MessageError, participantsOnly, "リクエストの失敗", ReplyData{}, nil, false},
+	{MessageCountdown, adminAndScreen, "残り秒数のカウントダウン", CountdownData{}, nil, false},
+	{MessageAnswerStats, adminAndScreen, "回答状況", AnswerStatsData{}, nil, false},
+	{MessageAnswerCount, adminAndScreen, "回答受付中の回答数 (間隔を空けて送る。choices_counts は設定で許可された相手にのみ送る)", AnswerCountData{}, nil, false},
+	{MessageAnswerReveal, everyone, "正解発表", AnswerRevealData{}, nil, false},
+	{MessageLeaderboard, everyone, "途中経過", LeaderboardData{}, nil, false},
+	{MessageIntermission, everyone, "休憩", IntermissionData{}, nil, false},
+	{MessageStateChanged, everyone, "アクションのない状態変更 (ジャンプ・自動進行・復旧など)", StateChangedData{}, nil, false},
+	{MessageEventPaused, everyone, "一時停止", PauseData{}, nil, false},
+	{MessageEventResumed, everyone, "再開", PauseData{}, nil, false},
+	{MessageAdminAction, adminOnly, "管理画面から実行されたアクション (実行したオペレーター付き)", AdminActionData{}, nil, false},
+	{MessageOperatorLock, adminOnly, "操作権 (オペレーターロック) の取得・解放", OperatorLockData{}, nil, false},
+	{MessageSlideShow, everyone, "お知らせスライド表示", models.Slide{}, nil, false},
+	{MessageSlideHide, everyone, "お知らせスライドを閉じる", SlideHideData{}, nil, false},
+	{MessageScreenChannel, adminAndScreen, "スクリーンチャンネルの表示内容の変更 (全スクリーンに届くので channel で判別する)", ScreenChannelData{}, nil, false},
+	{MessagePing, participantsOnly, "接続遅延の計測", PingData{}, nil, false},
+	{MessagePong, participantsOnly, "ping への応答", nil, PingData{}, false},
+	{MessagePingResult, adminOnly, "接続遅延の計測結果 (result が -1 なら応答なし)", PingResultWebsocket{}, nil, false},
+	{MessageConnectionQuality, adminOnly, "接続品質のまとめ (ping の間隔ごと。不安定な参加者を degraded に挙げる)", ConnectionQualityData{}, nil, false},
+	{MessageBatch, adminAndScreen, "同じ種類の高頻度メッセージをまとめたもの", BatchData{}, nil, false},
+	{MessageInitialSync, everyone, "接続時の全体同期", EventSyncData{}, nil, false},
+	{MessageStateSync, everyone, "状態同期 (現在は送られない)", EventSyncData{}, nil, false},
+	{MessageSyncRequest, participantsOnly, "同期の要求 (現在は無視される)", nil, SyncRequestData{}, false},
+	{MessageSyncComplete, everyone, "同期完了 (現在は送られない)", struct{}{}, nil, false},
+	{MessageDatabaseReset, everyone, "データベースのリセット", DatabaseResetData{}, nil, false},
+	{MessageTimeAlert, adminAndScreen, "countdown を使う", CountdownData{}, nil, true},
+}
+
+// MessageSpecs returns the declarations of all message types
+func MessageSpecs() []MessageSpec {
+	return messageSpecs
+}
+
+// LookupMessageSpec returns the declaration of a message type
+func LookupMessageSpec(msgType MessageType) (MessageSpec, bool) {
+	for _, spec := range messageSpecs {
+		if spec.Type == msgType {
+			return spec, true
+		}
+	}
+	return MessageSpec{}, false
+}
+
+// ValidatePayload checks that data is the declared payload of a server
+// message of the type (or a pointer to it)
+func ValidatePayload(msgType MessageType, data any) error {
+	spec, ok := LookupMessageSpec(msgType)
+	if !ok {
+		return fmt.Errorf("undeclared message type: %s", msgType)
+	}
+	if spec.Payload == nil {
+		return fmt.Errorf("%s is not sent by the server", msgType)
+	}
+
+	want := reflect.TypeOf(spec.Payload)
+	got := reflect.TypeOf(data)
+	if got != nil && got.Kind() == reflect.Pointer {
+		got = got.Elem()
+	}
+	if got != want {
+		return fmt.Errorf("%s data must be %s, got %T", msgType, want, data)
+	}
+	return nil
+}
+
+// protocolHeader carries the negotiated version in the handshake response
+const protocolHeader = "X-Quiz-Protocol"
+
+// NegotiateProtocol picks the protocol version for a client that asked for
+// the given one. Clients that do not ask get version 1.
+func NegotiateProtocol(requested int) (int, error) {
+	if requested == 0 {
+		requested = 1
+	}
+	if requested < MinProtocolVersion {
+		return 0, fmt.Errorf("protocol version %d is no longer supported (supported: %d-%d)", requested, MinProtocolVersion, ProtocolVersion)
+	}
+	return min(requested, ProtocolVersion), nil
+}
+
+// negotiateRequest reads the protocol query parameter of a connection
+// request. Unsupported versions get 426 Upgrade Required with the supported
+// range, so the page can tell the user to reload.
+func negotiateRequest(w http.ResponseWriter, r *http.Request) (int, bool) {
+	requested := 0
+	if value := r.URL.Query().Get("protocol"); value != "" {
+		var err error
+		if requested, err = strconv.Atoi(value); err != nil || requested < 1 {
+			http.Error(w, "Invalid protocol version", http.StatusBadRequest)
+			return 0, false
+		}
+	}
+
+	protocol, err := NegotiateProtocol(requested)
+	if err != nil {
+		w.Header().Set(protocolHeader, fmt.Sprintf("%d-%d", MinProtocolVersion, ProtocolVersion))
+		http.Error(w, err.Error(), http.StatusUpgradeRequired)
+		return 0, false
+	}
+	return protocol, true
+}
+
+// Payloads of server messages
+
+type EventStartedData struct {
+	Event *models.Event     `json:"event"`
+	Title string            `json:"title"`
+	State models.EventState `json:"state"`
+}
+
+type TitleDisplayData struct {
+	Title string            `json:"title"`
+	State models.EventState `json:"state"`
+}
+
+type TeamAssignmentData struct {
+	Teams []models.Team     `json:"teams"`
+	State models.EventState `json:"state"`
+}
+
+// QuestionContent is a question as clients show it; the answer is only
+// filled in for admins
+type QuestionContent struct {
+	Type    string   `json:"type"`
+	Text    string   `json:"text"`
+	Image   string   `json:"image"`
+	Choices []string `json:"choices"`
+	Correct int      `json:"correct,omitempty"`
+	Point   int      `json:"point,omitempty"`
+}
+
+// NewQuestionContent returns the question without its answer
+func NewQuestionContent(question models.Question) QuestionContent {
+	return QuestionContent{
+		Type:    question.Type,
+		Text:    question.Text,
+		Image:   question.Image,
+		Choices: question.Choices,
+	}
+}
+
+type QuestionStartData struct {
+	QuestionNumber int             `json:"question_number"`
+	Question       QuestionContent `json:"question"`
+	TotalQuestions int             `json:"total_questions,omitempty"` // Admins only
+	Correct        int             `json:"correct,omitempty"`         // Admins only
+}
+
+type QuestionEndData struct{}
+
+type CountdownData struct {
+	SecondsLeft int `json:"seconds_left"`
+}
+
+type AnswerStatsData struct {
+	TotalParticipants int     `json:"total_participants"` // Online participants and those who answered
+	AnsweredCount     int     `json:"answered_count"`
+	AnswerRate        float64 `json:"answer_rate"` // Percent of total_participants
+	ChoicesCounts     []int   `json:"choices_counts"`
+}
+
+// AnswerCountData is the live count of answers to the open question
+type AnswerCountData struct {
+	QuestionNumber    int   `json:"question_number"`
+	TotalParticipants int   `json:"total_participants"` // Online participants and those who answered
+	AnsweredCount     int   `json:"answered_count"`
+	ChoicesCounts     []int `json:"choices_counts,omitempty"` // Only where the distribution is shown
+}
+
+type AnswerRevealData struct {
+	Correct int `json:"correct"`
+}
+
+type LeaderboardData struct {
+	Results        []models.User `json:"results"`
+	Teams          []models.Team `json:"teams"`
+	TeamMode       bool          `json:"team_mode"`
+	QuestionNumber int           `json:"question_number"`
+	TotalQuestions int           `json:"total_questions"`
+}
+
+type IntermissionData struct {
+	Message        string `json:"message"`
+	QuestionNumber int    `json:"question_number"`
+}
+
+type FinalResultsData struct {
+	Results  []models.User `json:"results"`
+	Teams    []models.Team `json:"teams"`
+	TeamMode bool          `json:"team_mode"`
+}
+
+type CelebrationData struct {
+	State models.EventState `json:"state"`
+}
+
+type UserJoinedData struct {
+	User     *models.User `json:"user"`
+	Nickname string       `json:"nickname"`
+	TeamName string       `json:"teamname"`
+}
+
+type UserLeftData struct {
+	UserID   int    `json:"user_id"`
+	Nickname string `json:"nickname"`
+}
+
+type AnswerReceivedData struct {
+	UserID         int    `json:"user_id"`
+	Nickname       string `json:"nickname"`
+	QuestionNumber int    `json:"question_number"`
+	Answer         int    `json:"answer"`
+}
+
+type EmojiData struct {
+	Nickname string `json:"nickname"`
+	Emoji    string `json:"emoji"`
+}
+
+type TeamMemberAddedData struct {
+	TeamID   int    `json:"team_id"`
+	Nickname string `json:"nickname"`
+}
+
+// PresenceData reports that a participant went online, away or offline
+type PresenceData struct {
+	UserID      int                   `json:"user_id"`
+	Nickname    string                `json:"nickname"`
+	Status      models.PresenceStatus `json:"status"`
+	Since       time.Time             `json:"since"`
+	Connections int                   `json:"connections"` // Open connections (tabs) right now
+}
+
+// StateChangedData reports a state change that no action message covers
+type StateChangedData struct {
+	PreviousState  models.EventState `json:"previous_state,omitempty"`
+	NewState       models.EventState `json:"new_state"`
+	QuestionNumber int               `json:"question_number"`
+	Question       *models.Question  `json:"question,omitempty"` // Jumps to a question; correct is 0 before the reveal
+	TotalQuestions int               `json:"total_questions,omitempty"`
+	Jumped         bool              `json:"jumped,omitempty"`
+	Action         string            `json:"action,omitempty"`    // Action run automatically
+	Automatic      bool              `json:"automatic,omitempty"` // Run by a timer or the autopilot
+	Source         string            `json:"source,omitempty"`
+	Reason         string            `json:"reason,omitempty"` // Recovery or reset
+	Timestamp      *time.Time        `json:"timestamp,omitempty"`
+}
+
+// PauseData is the pause state sent with event_paused and event_resumed
+type PauseData struct {
+	Paused      bool              `json:"paused"`
+	PausedAt    *time.Time        `json:"paused_at,omitempty"`
+	State       models.EventState `json:"state"`
+	TimerAction string            `json:"timer_action,omitempty"`
+	RemainingMs int64             `json:"remaining_ms,omitempty"`
+}
+
+// OperatorInfo identifies an admin console
+type OperatorInfo struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// AdminActionData tells the other consoles which operator ran an action
+type AdminActionData struct {
+	Action         string            `json:"action"`
+	Operator       OperatorInfo      `json:"operator"`
+	FromState      models.EventState `json:"from_state"`
+	State          models.EventState `json:"state"`
+	QuestionNumber int               `json:"question_number"`
+	Time           time.Time         `json:"time"`
+}
+
+// OperatorLockData is the operator lock after it changed; holder is null when released
+type OperatorLockData struct {
+	Holder *OperatorInfo `json:"holder"`
+	Since  *time.Time    `json:"since,omitempty"`
+	By     OperatorInfo  `json:"by"` // Who took, released or took over the lock
+}
+
+type SlideHideData struct {
+	Slide          *models.Slide     `json:"slide"`
+	State          models.EventState `json:"state"`
+	QuestionNumber int               `json:"question_number"`
+}
+
+type PingData struct {
+	PingID string `json:"ping_id"`
+}
+
+// ReplyData answers a participant request (ack or error)
+type ReplyData struct {
+	RequestID  string        `json:"request_id"`
+	For        MessageType   `json:"for"`
+	Result     *AnswerResult `json:"result,omitempty"`      // ack of an answer
+	Error      string        `json:"error,omitempty"`       // error only: message to show
+	Code       string        `json:"code,omitempty"`        // error only: code of the HTTP ErrorResponse
+	RetryAfter int           `json:"retry_after,omitempty"` // seconds, when rate limited
+}
+
+type DatabaseResetData struct {
+	Message string `json:"message"`
+}
+
+// Payloads of client messages
+
+type AnswerRequest struct {
+	QuestionNumber int `json:"question_number"`
+	AnswerIndex    int `json:"answer_index"` // 1-based
+}
+
+type EmojiRequest struct {
+	Emoji string `json:"emoji"`
+}
+
+type SyncRequestData struct {
+	UserID       *int   `json:"user_id"`
+	CurrentState string `json:"current_state"`
+}
@@ -4,6 +4,8 @@ import (
 	"crypto/rand"
 	"fmt"
 	"log"
+	"quiz100/models"
+	"sort"
 	"sync"
 	"time"
 )
@@ -40,34 +42,47 @@ type PingUser struct {
 	Nickname string `json:"nickname"`
 }
 
-// PingManager handles ping/pong operations
+// PingManager handles ping/pong operations and keeps rolling statistics of
+// the results, so admins see whose connection is degrading
 type PingManager struct {
 	hubManager   *HubManager
 	userRepo     PingUserRepository
+	interval     time.Duration
+	timeout      time.Duration
+	slow         int64 // p95 latency in milliseconds above which a connection is slow
 	pingTrackers map[string]*PingTracker
 	mutex        sync.RWMutex
 	ticker       *time.Ticker
 	stopCh       chan struct{}
+
+	historyMu sync.Mutex
+	history   map[int]*pingHistory // User ID -> latest results
 }
 
 // NewPingManager creates a new ping manager
-func NewPingManager(hubManager *HubManager, userRepo PingUserRepository) *PingManager {
+func NewPingManager(hubManager *HubManager, userRepo PingUserRepository, config models.PingConfig) *PingManager {
 	return &PingManager{
 		hubManager:   hubManager,
 		userRepo:     userRepo,
+		interval:     config.Period(),
+		timeout:      config.Deadline(),
+		slow:         config.SlowThreshold().Milliseconds(),
 		pingTrackers: make(map[string]*PingTracker),
 		stopCh:       make(chan struct{}),
+		history:      make(map[int]*pingHistory),
 	}
 }
 
-// Start begins the ping monitoring system (10-second intervals)
+// Start begins the ping monitoring system. Every round first sends admins
+// the statistics up to the previous round, then pings every participant.
 func (pm *PingManager) Start() {
-	pm.ticker = time.NewTicker(10 * time.Second)
+	pm.ticker = time.NewTicker(pm.interval)
 
 	go func() {
 		for {
 			select {
 			case <-pm.ticker.C:
+				pm.broadcastConnectionQuality()
 				pm.sendPingToAllParticipants()
 			case <-pm.stopCh:
 				return
@@ -75,7 +90,7 @@ func (pm *PingManager) Start() {
 		}
 	}()
 
-	log.Println("Ping manager started with 10-second intervals")
+	log.Printf("Ping manager started with %v intervals (timeout %v)", pm.interval, pm.timeout)
 }
 
 // Stop stops the ping monitoring system
@@ -92,6 +107,9 @@ func (pm *PingManager) sendPingToAllParticipants() {
 	participants := pm.hubManager.GetClientsByType(ClientTypeParticipant)
 
 	for _, client := range participants {
+		if client.Transport == TransportSSE {
+			continue // Cannot answer with a pong
+		}
 		pm.sendPingToClient(client)
 	}
 }
@@ -101,8 +119,7 @@ func (pm *PingManager) sendPingToClient(client *Client) {
 	pingID := pm.generateUniqueID()
 	sentTime := time.Now()
 
-	// Create timeout timer (8 second)
-	timeout := time.AfterFunc(8*time.Second, func() {
+	timeout := time.AfterFunc(pm.timeout, func() {
 		pm.handlePingTimeout(client.UserID, pingID)
 	})
 
@@ -117,9 +134,7 @@ func (pm *PingManager) sendPingToClient(client *Client) {
 	pm.mutex.Unlock()
 
 	// Send ping message
-	pingData := map[string]string{
-		"ping_id": pingID,
-	}
+	pingData := PingData{PingID: pingID}
 
 	err := pm.hubManager.BroadcastToUser(MessagePing, pingData, client.UserID)
 	if err != nil {
@@ -153,6 +168,7 @@ func (pm *PingManager) HandlePong(pingID string, userID int) {
 
 	// Calculate latency
 	latency := time.Since(tracker.SentTime).Milliseconds()
+	pm.record(userID, latency)
 
 	// Stop timeout timer
 	tracker.Timeout.Stop()
@@ -164,13 +180,17 @@ func (pm *PingManager) HandlePong(pingID string, userID int) {
 	pm.sendPingResultToAdmins(userID, latency)
 }
 
-// handlePingTimeout handles ping timeout (1 second)
+// handlePingTimeout handles a ping left without a pong for the timeout
 func (pm *PingManager) handlePingTimeout(userID int, pingID string) {
 	pm.mutex.Lock()
 	defer pm.mutex.Unlock()
 
+	if _, exists := pm.pingTrackers[pingID]; !exists {
+		return // The pong arrived just in time
+	}
 	// Remove tracker (responses after this point are ignored)
 	delete(pm.pingTrackers, pingID)
+	pm.record(userID, -1)
 
 	// Send timeout result to admin clients
 	pm.sendPingResultToAdmins(userID, -1)
@@ -178,16 +198,8 @@ func (pm *PingManager) handlePingTimeout(userID int, pingID string) {
 
 // sendPingResultToAdmins sends ping results to admin clients
 func (pm *PingManager) sendPingResultToAdmins(userID int, latency int64) {
-	// Get user nickname from repository
-	nickname := ""
-	if pm.userRepo != nil {
-		if user, err := pm.userRepo.GetUserByID(userID); err == nil && user != nil {
-			nickname = user.Nickname
-		}
-	}
-
 	pingResult := PingResultWebsocket{
-		Nickname: nickname,
+		Nickname: pm.nickname(userID),
 		Latency:  latency,
 	}
 
@@ -197,6 +209,90 @@ func (pm *PingManager) sendPingResultToAdmins(userID int, latency int64) {
 	}
 }
 
+// record adds a ping result (-1 for a timeout) to the participant's history
+func (pm *PingManager) record(userID int, latency int64) {
+	pm.historyMu.Lock()
+	defer pm.historyMu.Unlock()
+	history, ok := pm.history[userID]
+	if !ok {
+		history = &pingHistory{}
+		pm.history[userID] = history
+	}
+	history.add(latency, time.Now())
+}
+
+// ConnectionQuality returns the statistics of every connected participant
+// that can be pinged, and overall
+func (pm *PingManager) ConnectionQuality() ConnectionQualityData {
+	seen := make(map[int]bool)
+	var userIDs []int
+	for _, client := range pm.hubManager.GetClientsByType(ClientTypeParticipant) {
+		if client.Transport == TransportSSE || client.UserID == 0 || seen[client.UserID] {
+			continue
+		}
+		seen[client.UserID] = true
+		userIDs = append(userIDs, client.UserID)
+	}
+
+	data := ConnectionQualityData{
+		Participants: len(userIDs),
+		Degraded:     []UserConnectionStats{},
+		Users:        make([]UserConnectionStats, 0, len(userIDs)),
+		Time:         time.Now(),
+	}
+	var latencies []int64
+	timeouts := 0
+
+	pm.historyMu.Lock()
+	for _, userID := range userIDs {
+		stats := UserConnectionStats{Quality: QualityUnknown, LastLatency: -1}
+		if history, ok := pm.history[userID]; ok && len(history.results) > 0 {
+			stats = history.stats(pm.slow)
+			answered, lost := splitResults(history.results)
+			latencies = append(latencies, answered...)
+			timeouts += lost
+		}
+		stats.UserID = userID
+		data.Users = append(data.Users, stats)
+	}
+	pm.historyMu.Unlock()
+
+	for i := range data.Users {
+		data.Users[i].Nickname = pm.nickname(data.Users[i].UserID)
+		if quality := data.Users[i].Quality; quality == QualitySlow || quality == QualityUnstable {
+			data.Degraded = append(data.Degraded, data.Users[i])
+		}
+	}
+	sort.SliceStable(data.Degraded, func(i, j int) bool {
+		a, b := data.Degraded[i], data.Degraded[j]
+		if a.Quality != b.Quality {
+			return qualityOrder[a.Quality] < qualityOrder[b.Quality]
+		}
+		return a.Latency.P95 > b.Latency.P95
+	})
+	data.Overall = latencyStats(latencies, timeouts)
+	return data
+}
+
+// broadcastConnectionQuality sends admins the summary and the degraded connections
+func (pm *PingManager) broadcastConnectionQuality() {
+	data := pm.ConnectionQuality()
+	data.Users = nil
+	if err := pm.hubManager.BroadcastConnectionQuality(data); err != nil {
+		log.Printf("Failed to send connection quality to admin: %v", err)
+	}
+}
+
+func (pm *PingManager) nickname(userID int) string {
+	if pm.userRepo == nil {
+		return ""
+	}
+	if user, err := pm.userRepo.GetUserByID(userID); err == nil && user != nil {
+		return user.Nickname
+	}
+	return ""
+}
+
 // generateUniqueID generates a unique ID for ping requests
 func (pm *PingManager) generateUniqueID() string {
 	bytes := make([]byte, 8)
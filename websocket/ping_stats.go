@@ -0,0 +1,125 @@
+package websocket
+
+import (
+	"sort"
+	"time"
+)
+
+// Rolling windows of the connection statistics
+const (
+	pingWindow       = 20 // Latest pings the statistics of a participant cover
+	unstableWindow   = 5  // Latest pings checked for timeouts
+	unstableTimeouts = 2  // Timeouts among them that flag a connection as unstable
+	minSlowSamples   = 3  // Answered pings needed before a connection can be slow
+)
+
+// ConnectionQuality rates a participant's connection from the latest pings
+type ConnectionQuality string
+
+const (
+	QualityUnknown  ConnectionQuality = "unknown"  // Not measured yet
+	QualityGood     ConnectionQuality = "good"     // Answers pings in time
+	QualitySlow     ConnectionQuality = "slow"     // p95 latency above the slow threshold
+	QualityUnstable ConnectionQuality = "unstable" // Several of the latest pings timed out
+)
+
+// LatencyStats are rolling latency statistics in milliseconds
+type LatencyStats struct {
+	P50      int64 `json:"p50"`
+	P95      int64 `json:"p95"`
+	Samples  int   `json:"samples"`  // Answered pings in the window
+	Timeouts int   `json:"timeouts"` // Lost pings in the window
+}
+
+// UserConnectionStats is the connection of one participant
+type UserConnectionStats struct {
+	UserID      int               `json:"user_id"`
+	Nickname    string            `json:"nickname"`
+	Latency     LatencyStats      `json:"latency"`
+	LastLatency int64             `json:"last_latency"` // -1 when the last ping timed out
+	LastSeen    *time.Time        `json:"last_seen"`    // Last answered ping
+	Quality     ConnectionQuality `json:"quality"`
+}
+
+// ConnectionQualityData summarizes the connections of the connected participants
+type ConnectionQualityData struct {
+	Overall      LatencyStats          `json:"overall"`      // Over every participant's window
+	Participants int                   `json:"participants"` // Connected participants that can be pinged
+	Degraded     []UserConnectionStats `json:"degraded"`     // Slow or unstable, worst first
+	Users        []UserConnectionStats `json:"users,omitempty"`
+	Time         time.Time             `json:"time"`
+}
+
+// pingHistory holds the latest ping results of a participant
+type pingHistory struct {
+	results  []int64 // Latency in milliseconds, -1 for a timeout; oldest first
+	lastSeen time.Time
+}
+
+func (h *pingHistory) add(latency int64, at time.Time) {
+	h.results = append(h.results, latency)
+	if len(h.results) > pingWindow {
+		h.results = h.results[len(h.results)-pingWindow:]
+	}
+	if latency >= 0 {
+		h.lastSeen = at
+	}
+}
+
+// stats rates the history; slow is the p95 threshold in milliseconds
+func (h *pingHistory) stats(slow int64) UserConnectionStats {
+	latencies, timeouts := splitResults(h.results)
+	stats := UserConnectionStats{
+		Latency:     latencyStats(latencies, timeouts),
+		LastLatency: h.results[len(h.results)-1],
+		Quality:     QualityGood,
+	}
+	if !h.lastSeen.IsZero() {
+		lastSeen := h.lastSeen
+		stats.LastSeen = &lastSeen
+	}
+
+	_, recentTimeouts := splitResults(h.results[max(0, len(h.results)-unstableWindow):])
+	switch {
+	case recentTimeouts >= unstableTimeouts:
+		stats.Quality = QualityUnstable
+	case len(latencies) >= minSlowSamples && stats.Latency.P95 > slow:
+		stats.Quality = QualitySlow
+	}
+	return stats
+}
+
+// splitResults separates answered pings from timeouts
+func splitResults(results []int64) (latencies []int64, timeouts int) {
+	for _, latency := range results {
+		if latency < 0 {
+			timeouts++
+		} else {
+			latencies = append(latencies, latency)
+		}
+	}
+	return latencies, timeouts
+}
+
+func latencyStats(latencies []int64, timeouts int) LatencyStats {
+	sorted := append([]int64(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return LatencyStats{
+		P50:      percentile(sorted, 50),
+		P95:      percentile(sorted, 95),
+		Samples:  len(sorted),
+		Timeouts: timeouts,
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted values, 0 when empty
+func percentile(sorted []int64, p int) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100 // ceil(p/100 * n)
+	return sorted[max(rank, 1)-1]
+}
+
+// qualityOrder sorts degraded connections worst first
+var qualityOrder = map[ConnectionQuality]int{QualityUnstable: 0, QualitySlow: 1, QualityGood: 2, QualityUnknown: 3}
@@ -0,0 +1,101 @@
+package websocket
+
+import (
+	"quiz100/models"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := []int64{10, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+	if p := percentile(sorted, 50); p != 50 {
+		t.Errorf("Expected p50 50, got %d", p)
+	}
+	if p := percentile(sorted, 95); p != 100 {
+		t.Errorf("Expected p95 100, got %d", p)
+	}
+	if p := percentile([]int64{42}, 95); p != 42 {
+		t.Errorf("Expected a single sample to be every percentile, got %d", p)
+	}
+	if p := percentile(nil, 50); p != 0 {
+		t.Errorf("Expected 0 without samples, got %d", p)
+	}
+}
+
+func TestPingHistoryQuality(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []int64
+		want    ConnectionQuality
+	}{
+		{"fast", []int64{40, 50, 60, 45}, QualityGood},
+		{"slow", []int64{700, 800, 650, 900}, QualitySlow},
+		{"too few samples to be slow", []int64{900, 800}, QualityGood},
+		{"recent timeouts", []int64{40, 50, -1, 45, -1}, QualityUnstable},
+		{"old timeouts forgotten", []int64{-1, -1, 40, 50, 60, 45, 55}, QualityGood},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := &pingHistory{}
+			for _, latency := range tt.results {
+				history.add(latency, time.Now())
+			}
+			if stats := history.stats(500); stats.Quality != tt.want {
+				t.Errorf("Expected %s, got %s (%+v)", tt.want, stats.Quality, stats)
+			}
+		})
+	}
+}
+
+func TestPingHistoryWindow(t *testing.T) {
+	history := &pingHistory{}
+	for range pingWindow + 5 {
+		history.add(-1, time.Now())
+	}
+	history.add(30, time.Now())
+
+	stats := history.stats(500)
+	if len(history.results) != pingWindow || stats.Latency.Timeouts != pingWindow-1 {
+		t.Errorf("Expected the window to keep %d results, got %d with %d timeouts", pingWindow, len(history.results), stats.Latency.Timeouts)
+	}
+	if stats.LastLatency != 30 || stats.LastSeen == nil {
+		t.Errorf("Expected the last pong to be seen, got %+v", stats)
+	}
+}
+
+func TestConnectionQualityOfConnectedParticipants(t *testing.T) {
+	hub := NewHub(nil)
+	pm := NewPingManager(NewHubManager(hub), nil, models.PingConfig{SlowLatency: "300ms"})
+	for _, userID := range []int{1, 2, 3} {
+		client := newTestClient(ClientTypeParticipant)
+		client.UserID = userID
+		hub.addClient(client)
+	}
+	sse := newTestClient(ClientTypeParticipant)
+	sse.UserID = 4
+	sse.Transport = TransportSSE
+	hub.addClient(sse)
+
+	for _, latency := range []int64{50, 60, 70} {
+		pm.record(1, latency)
+		pm.record(2, latency*10)
+	}
+	pm.record(5, -1) // Left the event
+
+	data := pm.ConnectionQuality()
+	if data.Participants != 3 || len(data.Users) != 3 {
+		t.Fatalf("Expected the 3 WebSocket participants, got %+v", data)
+	}
+	if len(data.Degraded) != 1 || data.Degraded[0].UserID != 2 || data.Degraded[0].Quality != QualitySlow {
+		t.Errorf("Expected user 2 to be slow, got %+v", data.Degraded)
+	}
+	if data.Overall.Samples != 6 || data.Overall.Timeouts != 0 {
+		t.Errorf("Expected 6 samples overall, got %+v", data.Overall)
+	}
+	for _, user := range data.Users {
+		if user.UserID == 3 && user.Quality != QualityUnknown {
+			t.Errorf("Expected an unmeasured participant to be unknown, got %+v", user)
+		}
+	}
+}
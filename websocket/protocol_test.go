@@ -0,0 +1,148 @@
+package websocket
+
+import (
+	"bytes"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite protocol.schema.json and the message spec in WebSocket.md")
+
+func TestEveryMessageTypeIsDeclared(t *testing.T) {
+	declared := make(map[MessageType]bool)
+	for _, spec := range MessageSpecs() {
+		if declared[spec.Type] {
+			t.Errorf("%s is declared twice", spec.Type)
+		}
+		declared[spec.Type] = true
+
+		if !IsValidMessageType(spec.Type) {
+			t.Errorf("%s is declared but missing from AllMessageTypes", spec.Type)
+		}
+		if spec.Payload == nil && spec.Request == nil {
+			t.Errorf("%s declares no payload", spec.Type)
+		}
+		if spec.Deprecated != IsDeprecatedMessageType(spec.Type) {
+			t.Errorf("%s: deprecation does not match IsDeprecatedMessageType", spec.Type)
+		}
+	}
+
+	for _, msgType := range AllMessageTypes() {
+		if !declared[msgType] {
+			t.Errorf("%s has no MessageSpec", msgType)
+		}
+	}
+}
+
+func TestBroadcastRejectsUndeclaredPayloads(t *testing.T) {
+	hm := NewHubManager(NewHub(nil))
+
+	tests := []struct {
+		name    string
+		send    func() error
+		wantErr bool
+	}{
+		{"declared payload", func() error { return hm.BroadcastMessage(MessageAnswerReveal, AnswerRevealData{Correct: 2}) }, false},
+		{"pointer to declared payload", func() error { return hm.BroadcastMessage(MessageEventPaused, &PauseData{Paused: true}) }, false},
+		{"undeclared type", func() error { return hm.BroadcastMessage("confetti", AnswerRevealData{}) }, true},
+		{"untyped map", func() error { return hm.BroadcastMessage(MessageAnswerReveal, map[string]any{"correct": 2}) }, true},
+		{"nil data", func() error { return hm.BroadcastToType(MessageQuestionEnd, nil, ClientTypeScreen) }, true},
+		{"payload of another type", func() error { return hm.BroadcastToType(MessageCountdown, AnswerStatsData{}, ClientTypeAdmin) }, true},
+		{"client-only type", func() error { return hm.BroadcastToUser(MessageAnswer, AnswerRequest{}, 1) }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.send()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error: %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestProtocolSchemaIsUpToDate(t *testing.T) {
+	schema, err := ProtocolSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *update {
+		if err := os.WriteFile("protocol.schema.json", schema, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	current, err := os.ReadFile("protocol.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(current, schema) {
+		t.Error("protocol.schema.json is out of date; run go test ./websocket -run TestProtocol -update")
+	}
+}
+
+func TestProtocolDocs(t *testing.T) {
+	const docPath = "../WebSocket.md"
+	content, err := os.ReadFile(docPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := string(content)
+	begin := strings.Index(doc, specBeginMarker)
+	end := strings.Index(doc, specEndMarker)
+	if begin < 0 || end < begin {
+		t.Fatalf("%s has no generated message spec section", docPath)
+	}
+	current := doc[begin : end+len(specEndMarker)+1]
+	generated := ProtocolDocs()
+
+	if *update {
+		doc = doc[:begin] + generated + doc[end+len(specEndMarker)+1:]
+		if err := os.WriteFile(docPath, []byte(doc), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	if current != generated {
+		t.Error("WebSocket.md is out of date; run go test ./websocket -run TestProtocol -update")
+	}
+}
+
+func TestNegotiateProtocol(t *testing.T) {
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantProto  int
+	}{
+		{"", http.StatusOK, 1},
+		{"protocol=1", http.StatusOK, 1},
+		{"protocol=99", http.StatusOK, ProtocolVersion}, // Newer clients fall back to what the server speaks
+		{"protocol=0", http.StatusBadRequest, 0},
+		{"protocol=abc", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "/ws/participant?"+tt.query, nil)
+
+			protocol, ok := negotiateRequest(recorder, request)
+			if ok != (tt.wantStatus == http.StatusOK) || recorder.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d (ok=%v)", tt.wantStatus, recorder.Code, ok)
+			}
+			if protocol != tt.wantProto {
+				t.Errorf("Expected protocol %d, got %d", tt.wantProto, protocol)
+			}
+		})
+	}
+
+	if _, err := NegotiateProtocol(-1); err == nil {
+		t.Error("Expected versions below the minimum to be rejected")
+	}
+}
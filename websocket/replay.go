@@ -0,0 +1,157 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// replayBufferSize is how many messages per client type are kept for clients
+// that reconnect; a client that missed more gets a full initial_sync instead.
+// It must not exceed clientQueueSize, so a replay always fits the empty queue
+// of a new connection.
+const replayBufferSize = 200
+
+// allClientTypes are the audiences of a broadcast to everyone
+var allClientTypes = []ClientType{ClientTypeParticipant, ClientTypeAdmin, ClientTypeScreen}
+
+// sequencedMessage is a broadcast kept for replay
+type sequencedMessage struct {
+	seq     uint64
+	msgType MessageType // Item type for batches; screens only replay what their channel receives
+	data    []byte
+}
+
+// newEpoch identifies one run of the hub, so sequence numbers from before a
+// restart are never mistaken for current ones
+func newEpoch() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// publishRequest is a broadcast waiting for the run loop
+type publishRequest struct {
+	message     TypedMessage
+	clientTypes []ClientType
+}
+
+// Publish sends a message to every client of the given types (all types when
+// none are given). Each client type has its own sequence, so a client sees
+// consecutive numbers and can resume after the last one it received. The data
+// is marshaled here so errors reach the caller; numbering and delivery happen
+// in the run loop.
+func (h *Hub) Publish(message TypedMessage, clientTypes ...ClientType) error {
+	data, err := json.Marshal(message.Data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s message: %v", message.Type, err)
+	}
+	message.Data = json.RawMessage(data)
+
+	h.publish <- publishRequest{message: message, clientTypes: clientTypes}
+	return nil
+}
+
+// publishMessage numbers, remembers and delivers a broadcast
+func (h *Hub) publishMessage(request publishRequest) {
+	clientTypes := request.clientTypes
+	if len(clientTypes) == 0 {
+		clientTypes = allClientTypes
+	}
+	message := request.message
+	msgType := message.Type
+	if batch, ok := message.Data.(BatchData); ok {
+		msgType = batch.Type
+	}
+	droppable := IsDroppableMessageType(msgType)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for _, clientType := range clientTypes {
+		message.Seq = h.seq[clientType] + 1
+		data, err := json.Marshal(message)
+		if err != nil {
+			log.Printf("Error marshaling %s message: %v", message.Type, err)
+			return
+		}
+
+		h.seq[clientType] = message.Seq
+		numbered := sequencedMessage{seq: message.Seq, msgType: msgType, data: data}
+		h.remember(clientType, numbered)
+		h.sendToType(numbered, clientType, droppable)
+	}
+	h.MessagesSent++
+}
+
+// LastSeq returns the sequence number of the last message sent to the client type
+func (h *Hub) LastSeq(clientType ClientType) uint64 {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.seq[clientType]
+}
+
+// remember keeps a message for replay; the caller must hold mutex
+func (h *Hub) remember(clientType ClientType, message sequencedMessage) {
+	buffer := append(h.replay[clientType], message)
+	if len(buffer) > replayBufferSize {
+		buffer = buffer[len(buffer)-replayBufferSize:]
+	}
+	h.replay[clientType] = buffer
+}
+
+// sendToType queues a message for every client of the type, and for screens
+// only on the channels that show it; the caller must hold mutex
+func (h *Hub) sendToType(message sequencedMessage, clientType ClientType, droppable bool) {
+	if clientType == ClientTypeScreen {
+		for channel, clients := range h.byChannel {
+			if !h.channelReceives(channel, message.msgType) {
+				continue
+			}
+			for client := range clients {
+				h.deliver(client, message, droppable)
+			}
+		}
+		return
+	}
+
+	for client := range h.byType[clientType] {
+		h.deliver(client, message, droppable)
+	}
+}
+
+// replayMissed queues exactly the messages a reconnecting client missed since
+// its last sequence number. It returns false when the client needs a full sync
+// instead: a new client, a server restart or a gap older than the buffer.
+// The caller must hold mutex.
+func (h *Hub) replayMissed(client *Client) bool {
+	if client.ResumeEpoch == "" || client.ResumeEpoch != h.epoch {
+		return false
+	}
+
+	current := h.seq[client.Type]
+	if client.ResumeSeq > current {
+		return false
+	}
+	missed := current - client.ResumeSeq
+	buffer := h.replay[client.Type]
+	if missed > uint64(len(buffer)) {
+		return false
+	}
+
+	for _, message := range buffer[len(buffer)-int(missed):] {
+		if client.Type == ClientTypeScreen && !h.channelReceives(client.Channel, message.msgType) {
+			continue
+		}
+		data := message.data
+		if client.Transport == TransportSSE {
+			data = h.sseEvent(message)
+		}
+		select {
+		case client.Send <- data:
+		default:
+			return false
+		}
+	}
+	return true
+}
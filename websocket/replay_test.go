@@ -0,0 +1,105 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func newTestClient(clientType ClientType) *Client {
+	return &Client{Type: clientType, Send: make(chan []byte, clientQueueSize)}
+}
+
+// publish numbers and delivers a message the way the run loop does
+func publish(hub *Hub, message TypedMessage, clientTypes ...ClientType) {
+	hub.publishMessage(publishRequest{message: message, clientTypes: clientTypes})
+}
+
+func receivedSeqs(t *testing.T, client *Client) []uint64 {
+	t.Helper()
+	var seqs []uint64
+	for {
+		select {
+		case data := <-client.Send:
+			var message TypedMessage
+			if err := json.Unmarshal(data, &message); err != nil {
+				t.Fatalf("Failed to unmarshal message: %v", err)
+			}
+			seqs = append(seqs, message.Seq)
+		default:
+			return seqs
+		}
+	}
+}
+
+func TestPublishNumbersPerClientType(t *testing.T) {
+	hub := NewHub(nil)
+	screen := newTestClient(ClientTypeScreen)
+	participant := newTestClient(ClientTypeParticipant)
+	hub.addClient(screen)
+	hub.addClient(participant)
+
+	publish(hub, NewTypedMessage(MessageQuestionStart, nil))
+	publish(hub, NewTypedMessage(MessageEmojiReaction, nil), ClientTypeScreen)
+	publish(hub, NewTypedMessage(MessageAnswerReveal, nil))
+
+	if seqs := receivedSeqs(t, screen); len(seqs) != 3 || seqs[2] != 3 {
+		t.Errorf("Expected screen to receive seq 1-3, got %v", seqs)
+	}
+	// Messages for other client types leave no gap
+	if seqs := receivedSeqs(t, participant); len(seqs) != 2 || seqs[0] != 1 || seqs[1] != 2 {
+		t.Errorf("Expected participant to receive seq 1-2, got %v", seqs)
+	}
+}
+
+func TestReplayMissed(t *testing.T) {
+	hub := NewHub(nil)
+	for range 5 {
+		publish(hub, NewTypedMessage(MessageStateChanged, nil))
+	}
+
+	client := newTestClient(ClientTypeParticipant)
+	client.ResumeEpoch = hub.epoch
+	client.ResumeSeq = 2
+	if !hub.replayMissed(client) {
+		t.Fatal("Expected the missed messages to be replayed")
+	}
+	if seqs := receivedSeqs(t, client); len(seqs) != 3 || seqs[0] != 3 || seqs[2] != 5 {
+		t.Errorf("Expected seq 3-5 to be replayed, got %v", seqs)
+	}
+
+	upToDate := newTestClient(ClientTypeParticipant)
+	upToDate.ResumeEpoch = hub.epoch
+	upToDate.ResumeSeq = 5
+	if !hub.replayMissed(upToDate) || len(receivedSeqs(t, upToDate)) != 0 {
+		t.Error("Expected nothing to replay for an up-to-date client")
+	}
+}
+
+func TestReplayFallsBackToFullSync(t *testing.T) {
+	hub := NewHub(nil)
+	for range replayBufferSize + 10 {
+		publish(hub, NewTypedMessage(MessageStateChanged, nil))
+	}
+
+	tests := []struct {
+		name  string
+		epoch string
+		seq   uint64
+	}{
+		{"new client", "", 0},
+		{"server restarted", "old", 150},
+		{"gap too big", hub.epoch, 5},
+		{"ahead of the server", hub.epoch, replayBufferSize + 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(ClientTypeAdmin)
+			client.ResumeEpoch = tt.epoch
+			client.ResumeSeq = tt.seq
+			if hub.replayMissed(client) {
+				t.Error("Expected a full sync instead of a replay")
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"quiz100/models"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Markers around the generated part of WebSocket.md
+const (
+	specBeginMarker = "<!-- BEGIN GENERATED MESSAGE SPEC (go test ./websocket -run TestProtocolDocs -update) -->"
+	specEndMarker   = "<!-- END GENERATED MESSAGE SPEC -->"
+)
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	rawJSONType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaEnums lists the values of string types that only take known values
+var schemaEnums = []struct {
+	typ    reflect.Type
+	values func() []string
+}{
+	{reflect.TypeOf(models.EventState("")), func() []string { return stringsOf(models.AllStates()) }},
+	{reflect.TypeOf(ClientType("")), func() []string { return stringsOf(everyone) }},
+	{reflect.TypeOf(models.PresenceStatus("")), func() []string {
+		return stringsOf([]models.PresenceStatus{models.PresenceOnline, models.PresenceAway, models.PresenceOffline})
+	}},
+	{reflect.TypeOf(models.ScreenContent("")), func() []string { return stringsOf(models.AllScreenContents()) }},
+	{reflect.TypeOf(MessageType("")), func() []string { return stringsOf(AllMessageTypes()) }},
+}
+
+// enumValues returns the values of an enum type
+func enumValues(t reflect.Type) ([]string, bool) {
+	for _, enum := range schemaEnums {
+		if enum.typ == t {
+			return enum.values(), true
+		}
+	}
+	return nil, false
+}
+
+func stringsOf[T ~string](values []T) []string {
+	result := make([]string, len(values))
+	for i, value := range values {
+		result[i] = string(value)
+	}
+	return result
+}
+
+// schemaBuilder turns Go types into JSON Schema definitions
+type schemaBuilder struct {
+	defs  map[string]any
+	names map[reflect.Type]string
+}
+
+// ProtocolSchema returns a JSON Schema of every message, generated from
+// messageSpecs and the payload structs
+func ProtocolSchema() ([]byte, error) {
+	b := &schemaBuilder{defs: make(map[string]any), names: make(map[reflect.Type]string)}
+
+	var serverMessages, clientMessages []any
+	for _, spec := range messageSpecs {
+		if spec.Payload != nil {
+			name := "server." + string(spec.Type)
+			b.defs[name] = b.envelope(spec, spec.Payload, map[string]any{
+				"target":   b.typeSchema(reflect.TypeOf(ClientType(""))),
+				"seq":      map[string]any{"type": "integer", "minimum": 1},
+				"epoch":    map[string]any{"type": "string"},
+				"protocol": map[string]any{"type": "integer"},
+			})
+			serverMessages = append(serverMessages, ref(name))
+		}
+		if spec.Request != nil {
+			name := "client." + string(spec.Type)
+			b.defs[name] = b.envelope(spec, spec.Request, map[string]any{
+				"request_id": map[string]any{"type": "string"},
+			})
+			clientMessages = append(clientMessages, ref(name))
+		}
+	}
+	b.defs["ServerMessage"] = map[string]any{"oneOf": serverMessages}
+	b.defs["ClientMessage"] = map[string]any{"oneOf": clientMessages}
+
+	schema := map[string]any{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"$id":         fmt.Sprintf("quiz100/websocket/v%d", ProtocolVersion),
+		"title":       "quiz100 WebSocket protocol",
+		"description": fmt.Sprintf("Protocol version %d (clients may ask for %d-%d)", ProtocolVersion, MinProtocolVersion, ProtocolVersion),
+		"oneOf":       []any{ref("ServerMessage"), ref("ClientMessage")},
+		"$defs":       b.defs,
+	}
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal schema: %v", err)
+	}
+	return append(data, '\n'), nil
+}
+
+func ref(name string) map[string]any {
+	return map[string]any{"$ref": "#/$defs/" + name}
+}
+
+// envelope describes a whole message of the spec's type with the given data
+func (b *schemaBuilder) envelope(spec MessageSpec, payload any, extra map[string]any) map[string]any {
+	properties := map[string]any{
+		"type": map[string]any{"const": string(spec.Type)},
+		"data": b.typeSchema(reflect.TypeOf(payload)),
+	}
+	for name, schema := range extra {
+		properties[name] = schema
+	}
+
+	schema := map[string]any{
+		"type":                 "object",
+		"description":          spec.Description,
+		"properties":           properties,
+		"required":             []string{"type", "data"},
+		"additionalProperties": false,
+	}
+	if spec.Deprecated {
+		schema["deprecated"] = true
+	}
+	return schema
+}
+
+// typeSchema returns the schema of values of t as encoding/json writes them
+func (b *schemaBuilder) typeSchema(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawJSONType:
+		return map[string]any{}
+	}
+	if values, ok := enumValues(t); ok {
+		return map[string]any{"type": "string", "enum": values}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return map[string]any{"anyOf": []any{b.typeSchema(t.Elem()), map[string]any{"type": "null"}}}
+	case reflect.Interface:
+		return map[string]any{}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		// nil slices are written as null
+		return map[string]any{"type": []string{"array", "null"}, "items": b.typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": []string{"object", "null"}, "additionalProperties": b.typeSchema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		return ref(b.define(t))
+	}
+	panic(fmt.Sprintf("no JSON Schema for %s", t))
+}
+
+// define adds a named struct to $defs once and returns its name
+func (b *schemaBuilder) define(t reflect.Type) string {
+	if name, ok := b.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := b.defs[name]; taken {
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+	b.names[t] = name
+	b.defs[name] = nil // Reserve the name for recursive types
+	b.defs[name] = b.structSchema(t)
+	return name
+}
+
+func (b *schemaBuilder) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	required := []string{}
+	for _, field := range jsonFields(t) {
+		properties[field.name] = b.typeSchema(field.typ)
+		if !field.omitEmpty {
+			required = append(required, field.name)
+		}
+	}
+
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// jsonField is a struct field as encoding/json sees it
+type jsonField struct {
+	name      string
+	typ       reflect.Type
+	omitEmpty bool
+}
+
+// jsonFields lists the fields encoding/json writes for a struct type, in
+// declaration order. Embedded structs without a tag are flattened.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			fields = append(fields, jsonFields(field.Type)...)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, jsonField{
+			name:      name,
+			typ:       field.Type,
+			omitEmpty: strings.Contains(options, "omitempty"),
+		})
+	}
+	return fields
+}
+
+// ProtocolDocs returns the message reference of WebSocket.md, generated from
+// messageSpecs and the payload structs
+func ProtocolDocs() string {
+	var doc strings.Builder
+	doc.WriteString(specBeginMarker + "\n\n")
+	fmt.Fprintf(&doc, "プロトコルバージョン: %d (対応範囲 %d-%d)\n\n", ProtocolVersion, MinProtocolVersion, ProtocolVersion)
+
+	doc.WriteString("### メッセージタイプ一覧\n\n")
+	doc.WriteString("| type | 向き | 対象 | data | 説明 |\n")
+	doc.WriteString("|------|------|------|------|------|\n")
+	for _, spec := range messageSpecs {
+		var directions, payloads []string
+		if spec.Payload != nil {
+			directions = append(directions, "server→client")
+			payloads = append(payloads, docTypeName(reflect.TypeOf(spec.Payload)))
+		}
+		if spec.Request != nil {
+			directions = append(directions, "client→server")
+			payloads = append(payloads, docTypeName(reflect.TypeOf(spec.Request)))
+		}
+		description := spec.Description
+		if spec.Deprecated {
+			description = "**非推奨** " + description
+		}
+		fmt.Fprintf(&doc, "| `%s` | %s | %s | %s | %s |\n", spec.Type,
+			strings.Join(directions, "<br>"), strings.Join(stringsOf(spec.Audience), "/"),
+			strings.Join(payloads, "<br>"), description)
+	}
+
+	doc.WriteString("\n### data の型\n\n")
+	doc.WriteString("`?` の付いたフィールドは省略されることがあります。\n")
+	seen := make(map[reflect.Type]bool)
+	var queue []reflect.Type
+	enqueue := func(t reflect.Type) {
+		for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		if t.Kind() == reflect.Struct && t.Name() != "" && t != timeType && !seen[t] {
+			seen[t] = true
+			queue = append(queue, t)
+		}
+	}
+	for _, spec := range messageSpecs {
+		if spec.Payload != nil {
+			enqueue(reflect.TypeOf(spec.Payload))
+		}
+		if spec.Request != nil {
+			enqueue(reflect.TypeOf(spec.Request))
+		}
+	}
+	for len(queue) > 0 {
+		t := queue[0]
+		queue = queue[1:]
+
+		fmt.Fprintf(&doc, "\n#### %s\n\n", t.Name())
+		fields := jsonFields(t)
+		if len(fields) == 0 {
+			doc.WriteString("空のオブジェクト `{}`\n")
+			continue
+		}
+		doc.WriteString("| フィールド | 型 |\n")
+		doc.WriteString("|------------|----|\n")
+		for _, field := range fields {
+			name := field.name
+			if field.omitEmpty {
+				name += "?"
+			}
+			fmt.Fprintf(&doc, "| `%s` | %s |\n", name, docTypeName(field.typ))
+			enqueue(field.typ)
+		}
+	}
+
+	for _, enum := range schemaEnums {
+		fmt.Fprintf(&doc, "\n#### %s\n\n`%s`\n", enum.typ.Name(), strings.Join(enum.values(), "`, `"))
+	}
+
+	doc.WriteString("\n" + specEndMarker + "\n")
+	return doc.String()
+}
+
+// docTypeName writes a type the way the docs show it
+func docTypeName(t reflect.Type) string {
+	switch {
+	case t == timeType:
+		return "時刻 (RFC 3339)"
+	case t == rawJSONType:
+		return "任意の JSON"
+	}
+	if _, ok := enumValues(t); ok {
+		return "[" + t.Name() + "](#" + strings.ToLower(t.Name()) + ")"
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return docTypeName(t.Elem()) + " \\| null"
+	case reflect.Slice, reflect.Array:
+		return docTypeName(t.Elem()) + "[]"
+	case reflect.Map:
+		return "{string: " + docTypeName(t.Elem()) + "}"
+	case reflect.Interface:
+		return "任意"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Struct:
+		if t.Name() == "" {
+			return "`{}`"
+		}
+		return "[" + t.Name() + "](#" + strings.ToLower(t.Name()) + ")"
+	}
+	return "int"
+}
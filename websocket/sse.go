@@ -0,0 +1,124 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServeSSE streams the messages of a client over Server-Sent Events, for
+// networks that break WebSocket upgrades. The client is registered with the
+// hub like a WebSocket client; only the sending side differs, and requests
+// such as answers go over HTTP.
+func ServeSSE(hub *Hub, w http.ResponseWriter, r *http.Request, clientType ClientType, userID int, sessionID string) {
+	if _, ok := w.(http.Flusher); !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	protocol, ok := negotiateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	client := &Client{
+		Type:        clientType,
+		Transport:   TransportSSE,
+		UserID:      userID,
+		SessionID:   sessionID,
+		Send:        make(chan []byte, clientQueueSize),
+		ConnectedAt: time.Now(),
+		Protocol:    protocol,
+		hub:         hub,
+	}
+	client.ResumeEpoch, client.ResumeSeq = sseResumePoint(r)
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // Keep reverse proxies from buffering the stream
+	header.Set(protocolHeader, strconv.Itoa(protocol))
+	w.WriteHeader(http.StatusOK)
+
+	hub.Register <- client
+	defer func() {
+		hub.Unregister <- client
+	}()
+
+	client.streamSSE(w, r)
+}
+
+// streamSSE writes queued messages and keep-alive comments until the request
+// ends, a write fails or the hub closes the queue
+func (c *Client) streamSSE(w http.ResponseWriter, r *http.Request) {
+	controller := http.NewResponseController(w)
+	write := func(event []byte) error {
+		// Not every ResponseWriter supports deadlines; the write still works without one
+		if err := controller.SetWriteDeadline(time.Now().Add(writeWait)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		if _, err := w.Write(event); err != nil {
+			return err
+		}
+		return controller.Flush()
+	}
+
+	// Browsers reconnect after this many milliseconds
+	if err := write([]byte("retry: 3000\n\n")); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case message, ok := <-c.Send:
+			if !ok {
+				return
+			}
+			// Queued as events by the hub
+			if err := write(message); err != nil {
+				log.Printf("SSE write error: %v", err)
+				return
+			}
+
+		case <-ticker.C:
+			// Comment lines keep proxies from closing an idle stream
+			if err := write([]byte(": keep-alive\n\n")); err != nil {
+				return
+			}
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// sseEvent formats a message as an event when it is queued for an SSE client.
+// Numbered messages carry "<epoch>:<seq>" as the event ID, which the browser
+// sends back as Last-Event-ID when it reconnects.
+func (h *Hub) sseEvent(message sequencedMessage) []byte {
+	if message.seq > 0 {
+		return fmt.Appendf(nil, "id: %s:%d\ndata: %s\n\n", h.epoch, message.seq, message.data)
+	}
+	return fmt.Appendf(nil, "data: %s\n\n", message.data)
+}
+
+// sseResumePoint reads where a reconnecting client left off, from the
+// Last-Event-ID header or, like WebSocket clients, the last_seq and epoch
+// query parameters
+func sseResumePoint(r *http.Request) (string, uint64) {
+	if epoch, seq, ok := strings.Cut(r.Header.Get("Last-Event-ID"), ":"); ok {
+		if lastSeq, err := strconv.ParseUint(seq, 10, 64); err == nil {
+			return epoch, lastSeq
+		}
+	}
+
+	lastSeq, _ := strconv.ParseUint(r.URL.Query().Get("last_seq"), 10, 64)
+	return r.URL.Query().Get("epoch"), lastSeq
+}
@@ -0,0 +1,36 @@
+package websocket
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSSEClientsGetEvents(t *testing.T) {
+	hub := NewHub(nil)
+	client := newTestClient(ClientTypeParticipant)
+	client.Transport = TransportSSE
+	hub.addClient(client)
+
+	publish(hub, NewTypedMessage(MessageQuestionEnd, nil), ClientTypeParticipant)
+	hub.sendDirect(directMessage{client: client, msgType: MessageAck, data: []byte(`{"type":"ack"}`)})
+
+	// Numbered messages carry their sequence number as the event ID
+	want := fmt.Sprintf("id: %s:1\ndata: {\"type\":\"question_end\",\"data\":null,\"seq\":1}\n\n", hub.epoch)
+	if event := string(<-client.Send); event != want {
+		t.Errorf("Expected %q, got %q", want, event)
+	}
+	if event := string(<-client.Send); event != "data: {\"type\":\"ack\"}\n\n" {
+		t.Errorf("Expected an event without ID, got %q", event)
+	}
+
+	// Replayed messages keep their IDs
+	replayed := newTestClient(ClientTypeParticipant)
+	replayed.Transport = TransportSSE
+	replayed.ResumeEpoch = hub.epoch
+	if !hub.replayMissed(replayed) {
+		t.Fatal("Expected the missed message to be replayed")
+	}
+	if event := string(<-replayed.Send); event != want {
+		t.Errorf("Expected %q, got %q", want, event)
+	}
+}
@@ -3,13 +3,11 @@ package websocket
 import (
 	"encoding/json"
 	"log"
-	"net/http"
+	"maps"
 	"quiz100/models"
 	"strconv"
 	"sync"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
 type ClientType string
@@ -20,18 +18,14 @@ const (
 	ClientTypeScreen      ClientType = "screen"
 )
 
-type Client struct {
-	Conn        *websocket.Conn
-	Type        ClientType
-	UserID      int
-	SessionID   string
-	Send        chan []byte
-	ConnectedAt time.Time
-}
-
+// Hub owns the set of connected clients. Its run loop is the only goroutine
+// that changes membership or writes to and closes client queues; everything
+// else reaches clients through the Register, Unregister, publish and direct
+// channels.
 type Hub struct {
-	Clients        map[*Client]bool
-	Broadcast      chan []byte
+	clients        map[*Client]bool
+	byType         map[ClientType]map[*Client]bool // The same clients by audience
+	byChannel      map[string]map[*Client]bool     // Screens by channel (see channels.go)
 	Register       chan *Client
 	Unregister     chan *Client
 	mutex          sync.RWMutex
@@ -39,19 +33,58 @@ type Hub struct {
 	TotalConnected int64
 	MessagesSent   int64
 
+	// Slow consumers: messages dropped from full queues and clients cut off
+	MessagesDropped     int64
+	ClientsDisconnected int64
+
+	publish chan publishRequest
+	direct  chan directMessage
+
+	// High-frequency messages waiting for the batch window (see batch.go);
+	// used only by the run loop
+	batches    map[string]*pendingBatch
+	batchOrder []string
+
 	// State synchronization
 	ClientStates   map[*Client]*ClientState
 	LastEventState *EventSyncData
 	StateSync      chan *StateSyncRequest
 
+	// Message sequencing and replay (see replay.go)
+	epoch  string
+	seq    map[ClientType]uint64
+	replay map[ClientType][]sequencedMessage
+
+	// What each screen channel shows; channels not listed show everything
+	channelShows map[string][]models.ScreenContent
+
+	// Called when clients come and go; guarded by mutex
+	connectionListeners []func(ConnectionEvent)
+
 	// for initialization
 	answerRepo *models.AnswerRepository
 }
 
+// ConnectionEvent reports a client registering with or leaving the hub
+type ConnectionEvent struct {
+	Client    *Client
+	Connected bool
+	Time      time.Time
+}
+
+// directMessage is sent to one client, or to every client of a user when
+// client is nil
+type directMessage struct {
+	client  *Client
+	userID  int
+	msgType MessageType
+	data    []byte
+}
+
 // ClientState tracks individual client synchronization state
 type ClientState struct {
 	LastSyncTime    time.Time
-	SyncVersion     int
+	SyncVersion     uint64 // Sequence number the client was last synced to
 	IsInitialized   bool
 	LastEventState  string
 	LastQuestionNum int
@@ -59,14 +92,15 @@ type ClientState struct {
 
 // EventSyncData contains all data needed for state synchronization
 type EventSyncData struct {
-	EventState      string           `json:"event_state"`
-	QuestionNumber  int              `json:"question_number"`
-	QuestionData    models.Question  `json:"question"`
-	TeamData        []any            `json:"team,omitempty"`             // only sending to admin
-	ParticipantData []map[string]any `json:"participant_data,omitempty"` // only sending to admin
-	AnswerData      map[string]any   `json:"answer_data,omitempty"`      // user_id(string) -> answer_index
-	// SyncVersion     int             `json:"sync_version"`
-	// Timestamp       time.Time       `json:"timestamp"`
+	EventState      string             `json:"event_state"`
+	QuestionNumber  int                `json:"question_number"`
+	QuestionData    models.Question    `json:"question"`
+	TeamData        []any              `json:"team,omitempty"`             // only sending to admin
+	ParticipantData []map[string]any   `json:"participant_data,omitempty"` // only sending to admin
+	AnswerData      map[string]any     `json:"answer_data,omitempty"`      // user_id(string) -> answer_index
+	Paused          bool               `json:"paused,omitempty"`
+	Slide           *models.Slide      `json:"slide,omitempty"`   // Announcement shown over the state
+	Channel         *ScreenChannelData `json:"channel,omitempty"` // only sending to screens: what their channel shows
 }
 
 // StateSyncRequest represents a state synchronization request
@@ -77,48 +111,46 @@ type StateSyncRequest struct {
 }
 
 type Message struct {
-	Type   string     `json:"type"`
-	Data   any        `json:"data"`
-	UserID int        `json:"user_id,omitempty"`
-	Target ClientType `json:"target,omitempty"`
-}
-
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+	Type     string     `json:"type"`
+	Data     any        `json:"data"`
+	UserID   int        `json:"user_id,omitempty"`
+	Target   ClientType `json:"target,omitempty"`
+	Seq      uint64     `json:"seq,omitempty"`      // initial_sync: last sequence number the sync covers
+	Epoch    string     `json:"epoch,omitempty"`    // initial_sync: sent back with last_seq when reconnecting
+	Protocol int        `json:"protocol,omitempty"` // initial_sync: protocol version chosen for the client
 }
 
 func NewHub(answerRepo *models.AnswerRepository) *Hub {
 	return &Hub{
-		Clients:      make(map[*Client]bool),
-		Broadcast:    make(chan []byte),
-		Register:     make(chan *Client),
-		Unregister:   make(chan *Client),
+		clients:      make(map[*Client]bool),
+		byType:       make(map[ClientType]map[*Client]bool),
+		byChannel:    make(map[string]map[*Client]bool),
+		Register:     make(chan *Client, 64),
+		Unregister:   make(chan *Client, 64),
 		StartTime:    time.Now(),
+		publish:      make(chan publishRequest, 1024),
+		direct:       make(chan directMessage, 1024),
+		batches:      make(map[string]*pendingBatch),
 		ClientStates: make(map[*Client]*ClientState),
 		StateSync:    make(chan *StateSyncRequest, 500),
+		epoch:        newEpoch(),
+		seq:          make(map[ClientType]uint64),
+		replay:       make(map[ClientType][]sequencedMessage),
+		channelShows: make(map[string][]models.ScreenContent),
 		answerRepo:   answerRepo,
 	}
 }
 
 func (h *Hub) Run() {
+	var flush <-chan time.Time // Set while batches are pending
+
 	for {
 		select {
 		case client := <-h.Register:
-			h.mutex.Lock()
-			h.Clients[client] = true
-			h.TotalConnected++
-			// Initialize client state for all client types
-			h.ClientStates[client] = &ClientState{
-				LastSyncTime:    time.Now(),
-				SyncVersion:     0,
-				IsInitialized:   false,
-				LastEventState:  "",
-				LastQuestionNum: 0,
+			if h.addClient(client) {
+				log.Printf("Replayed missed messages to client %s (UserID: %d) from seq %d", client.Type, client.UserID, client.ResumeSeq)
+				continue
 			}
-			h.mutex.Unlock()
-			log.Printf("Client registered: %s (UserID: %d)", client.Type, client.UserID)
 
 			// Trigger initial sync for all client types
 			go func() {
@@ -131,19 +163,31 @@ func (h *Hub) Run() {
 
 		case client := <-h.Unregister:
 			h.mutex.Lock()
-			if _, ok := h.Clients[client]; ok {
-				delete(h.Clients, client)
-				delete(h.ClientStates, client) // Clean up client state
-				close(client.Send)
+			if h.removeClient(client) {
 				log.Printf("Client unregistered: %s (UserID: %d)", client.Type, client.UserID)
 			}
 			h.mutex.Unlock()
 
-		case message := <-h.Broadcast:
-			h.broadcastMessage(message)
-			h.mutex.Lock()
-			h.MessagesSent++
-			h.mutex.Unlock()
+		case request := <-h.publish:
+			if !IsBatchedMessageType(request.message.Type) {
+				// Held back items go out first, so clients see messages in the
+				// order they were published
+				h.flushBatches()
+				flush = nil
+				h.publishMessage(request)
+				continue
+			}
+			h.addToBatch(request)
+			if flush == nil {
+				flush = time.After(batchWindow)
+			}
+
+		case <-flush:
+			h.flushBatches()
+			flush = nil
+
+		case message := <-h.direct:
+			h.sendDirect(message)
 
 		case syncRequest := <-h.StateSync:
 			h.handleStateSyncRequest(syncRequest)
@@ -151,77 +195,135 @@ func (h *Hub) Run() {
 	}
 }
 
-func (h *Hub) broadcastMessage(message []byte) {
-	h.mutex.RLock()
-	defer h.mutex.RUnlock()
+// addClient registers a client and replays what it missed since its last
+// sequence number. It reports whether the replay made an initial sync
+// unnecessary. Registering and replaying under one lock means no broadcast is
+// missed or sent twice.
+func (h *Hub) addClient(client *Client) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
 
-	for client := range h.Clients {
-		select {
-		case client.Send <- message:
-		default:
-			close(client.Send)
-			delete(h.Clients, client)
+	h.clients[client] = true
+	if h.byType[client.Type] == nil {
+		h.byType[client.Type] = make(map[*Client]bool)
+	}
+	h.byType[client.Type][client] = true
+	if client.Type == ClientTypeScreen {
+		if h.byChannel[client.Channel] == nil {
+			h.byChannel[client.Channel] = make(map[*Client]bool)
 		}
+		h.byChannel[client.Channel][client] = true
+	}
+	h.TotalConnected++
+	// Initialize client state for all client types
+	h.ClientStates[client] = &ClientState{
+		LastSyncTime: time.Now(),
 	}
+	log.Printf("Client registered: %s (UserID: %d)", client.Type, client.UserID)
+	h.notifyConnection(client, true)
+
+	replayed := h.replayMissed(client)
+	if replayed {
+		h.markSynced(client)
+	}
+	return replayed
 }
 
-func (h *Hub) BroadcastToType(message []byte, clientType ClientType) {
-	h.mutex.RLock()
-	defer h.mutex.RUnlock()
+// removeClient forgets a client and closes its queue, which stops its
+// WritePump. It reports whether the client was still registered, so a client
+// already cut off as a slow consumer is not closed twice. The caller must hold
+// mutex.
+func (h *Hub) removeClient(client *Client) bool {
+	if _, ok := h.clients[client]; !ok {
+		return false
+	}
+	delete(h.clients, client)
+	delete(h.byType[client.Type], client)
+	if client.Type == ClientTypeScreen {
+		delete(h.byChannel[client.Channel], client)
+	}
+	delete(h.ClientStates, client) // Clean up client state
+	close(client.Send)
+	h.notifyConnection(client, false)
+	return true
+}
 
-	for client := range h.Clients {
-		if client.Type == clientType {
-			select {
-			case client.Send <- message:
-			default:
-				close(client.Send)
-				delete(h.Clients, client)
-			}
-		}
+// AddConnectionListener calls listener whenever a client registers or leaves.
+// Listeners run in the hub's run loop with mutex held, so they must return
+// quickly and must not call the hub.
+func (h *Hub) AddConnectionListener(listener func(ConnectionEvent)) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.connectionListeners = append(h.connectionListeners, listener)
+}
+
+// notifyConnection tells the listeners about a client; the caller must hold mutex
+func (h *Hub) notifyConnection(client *Client, connected bool) {
+	event := ConnectionEvent{Client: client, Connected: connected, Time: time.Now()}
+	for _, listener := range h.connectionListeners {
+		listener(event)
 	}
 }
 
-func (h *Hub) BroadcastExceptType(message []byte, excludeType ClientType) {
-	h.mutex.RLock()
-	defer h.mutex.RUnlock()
+// deliver queues a message for a client without blocking the run loop. When
+// the queue is full, droppable messages are skipped; for anything else the
+// client is disconnected, and it catches up through replay or initial_sync
+// when it reconnects. The caller must hold mutex.
+func (h *Hub) deliver(client *Client, message sequencedMessage, droppable bool) bool {
+	data := message.data
+	if client.Transport == TransportSSE {
+		data = h.sseEvent(message)
+	}
 
-	for client := range h.Clients {
-		if client.Type != excludeType {
-			select {
-			case client.Send <- message:
-			default:
-				close(client.Send)
-				delete(h.Clients, client)
-			}
-		}
+	select {
+	case client.Send <- data:
+		return true
+	default:
 	}
+
+	if droppable {
+		h.MessagesDropped++
+		return false
+	}
+	if h.removeClient(client) {
+		h.ClientsDisconnected++
+		log.Printf("Disconnecting slow client %s (UserID: %d): queue full", client.Type, client.UserID)
+	}
+	return false
 }
 
-func (h *Hub) BroadcastToUser(message []byte, userID int) {
-	h.mutex.RLock()
-	defer h.mutex.RUnlock()
+// sendDirect delivers a message that is not numbered and not kept for replay
+func (h *Hub) sendDirect(message directMessage) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
 
-	for client := range h.Clients {
-		if client.UserID == userID {
-			select {
-			case client.Send <- message:
-			default:
-				close(client.Send)
-				delete(h.Clients, client)
-			}
+	droppable := IsDroppableMessageType(message.msgType)
+	if message.client != nil {
+		if h.clients[message.client] {
+			h.deliver(message.client, sequencedMessage{data: message.data}, droppable)
+		}
+		return
+	}
+
+	for client := range h.clients {
+		if client.UserID == message.userID {
+			h.deliver(client, sequencedMessage{data: message.data}, droppable)
 		}
 	}
 }
 
+// SendToUser queues a message for every connection of a user
+func (h *Hub) SendToUser(msgType MessageType, data []byte, userID int) {
+	h.direct <- directMessage{userID: userID, msgType: msgType, data: data}
+}
+
 func (h *Hub) GetClientsByType(clientType ClientType) []*Client {
 	h.mutex.RLock()
 	defer h.mutex.RUnlock()
 
 	var clients []*Client
-	for client := range h.Clients {
-		if client.Type == clientType {
-			clients = append(clients, client)
-		}
+	for client := range h.byType[clientType] {
+		clients = append(clients, client)
 	}
 	return clients
 }
@@ -230,34 +332,39 @@ func (h *Hub) GetClientCount() map[ClientType]int {
 	h.mutex.RLock()
 	defer h.mutex.RUnlock()
 
-	counts := map[ClientType]int{
-		ClientTypeParticipant: 0,
-		ClientTypeAdmin:       0,
-		ClientTypeScreen:      0,
-	}
+	return h.countByType()
+}
 
-	for client := range h.Clients {
-		counts[client.Type]++
+// countByType counts clients per type; the caller must hold mutex
+func (h *Hub) countByType() map[ClientType]int {
+	counts := make(map[ClientType]int, len(allClientTypes))
+	for _, clientType := range allClientTypes {
+		counts[clientType] = len(h.byType[clientType])
 	}
 
 	return counts
 }
 
 type HubStatistics struct {
-	ActiveConnections  map[ClientType]int `json:"active_connections"`
-	TotalConnected     int64              `json:"total_connected"`
-	MessagesSent       int64              `json:"messages_sent"`
-	UptimeSeconds      int64              `json:"uptime_seconds"`
-	AverageConnections float64            `json:"average_connections"`
-	ClientDetails      []ClientInfo       `json:"client_details"`
+	ActiveConnections   map[ClientType]int `json:"active_connections"`
+	TotalConnected      int64              `json:"total_connected"`
+	MessagesSent        int64              `json:"messages_sent"`
+	MessagesDropped     int64              `json:"messages_dropped"`
+	ClientsDisconnected int64              `json:"clients_disconnected"`
+	UptimeSeconds       int64              `json:"uptime_seconds"`
+	AverageConnections  float64            `json:"average_connections"`
+	ClientDetails       []ClientInfo       `json:"client_details"`
 }
 
 type ClientInfo struct {
 	Type           ClientType `json:"type"`
+	Transport      Transport  `json:"transport"`
+	Protocol       int        `json:"protocol"`
+	Channel        string     `json:"channel,omitempty"` // Screens only
 	UserID         int        `json:"user_id"`
-	SessionID      string     `json:"session_id"`
 	ConnectedAt    time.Time  `json:"connected_at"`
 	ConnectionTime int64      `json:"connection_time_seconds"`
+	QueuedMessages int        `json:"queued_messages"`
 }
 
 func (h *Hub) GetStatistics() HubStatistics {
@@ -265,139 +372,78 @@ func (h *Hub) GetStatistics() HubStatistics {
 	defer h.mutex.RUnlock()
 
 	uptime := time.Since(h.StartTime)
-	clientCount := len(h.Clients)
+	clientCount := len(h.clients)
 	averageConnections := float64(h.TotalConnected) / uptime.Hours()
 
 	clientDetails := make([]ClientInfo, 0, clientCount)
-	for client := range h.Clients {
+	for client := range h.clients {
 		clientDetails = append(clientDetails, ClientInfo{
 			Type:           client.Type,
+			Transport:      client.Transport,
+			Protocol:       client.Protocol,
+			Channel:        client.Channel,
 			UserID:         client.UserID,
-			SessionID:      client.SessionID,
 			ConnectedAt:    client.ConnectedAt,
 			ConnectionTime: int64(time.Since(client.ConnectedAt).Seconds()),
+			QueuedMessages: len(client.Send),
 		})
 	}
 
 	return HubStatistics{
-		ActiveConnections:  h.GetClientCount(),
-		TotalConnected:     h.TotalConnected,
-		MessagesSent:       h.MessagesSent,
-		UptimeSeconds:      int64(uptime.Seconds()),
-		AverageConnections: averageConnections,
-		ClientDetails:      clientDetails,
-	}
-}
-
-func (c *Client) ReadPump(hub *Hub, onMessage func(*Client, []byte)) {
-	defer func() {
-		hub.Unregister <- c
-		c.Conn.Close()
-	}()
-
-	c.Conn.SetReadLimit(512)
-
-	for {
-		_, message, err := c.Conn.ReadMessage()
-		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error: %v", err)
-			}
-			break
-		}
-
-		if onMessage != nil {
-			onMessage(c, message)
-		}
-	}
-}
-
-func (c *Client) WritePump() {
-	defer c.Conn.Close()
-
-	for {
-		message, ok := <-c.Send
-		if !ok {
-			c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
-			return
-		}
-
-		if err := c.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
-			log.Printf("WebSocket write error: %v", err)
-			return
-		}
-	}
-}
-
-func ServeWS(hub *Hub, w http.ResponseWriter, r *http.Request, clientType ClientType, userID int, sessionID string, messageHandler *MessageHandler) {
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
-		return
+		ActiveConnections:   h.countByType(),
+		TotalConnected:      h.TotalConnected,
+		MessagesSent:        h.MessagesSent,
+		MessagesDropped:     h.MessagesDropped,
+		ClientsDisconnected: h.ClientsDisconnected,
+		UptimeSeconds:       int64(uptime.Seconds()),
+		AverageConnections:  averageConnections,
+		ClientDetails:       clientDetails,
 	}
-
-	client := &Client{
-		Conn:        conn,
-		Type:        clientType,
-		UserID:      userID,
-		SessionID:   sessionID,
-		Send:        make(chan []byte, 256),
-		ConnectedAt: time.Now(),
-	}
-
-	hub.Register <- client
-
-	// Set up message handling function
-	var onMessage func(*Client, []byte)
-	if messageHandler != nil {
-		onMessage = messageHandler.HandleMessage
-	}
-
-	go client.WritePump()
-	go client.ReadPump(hub, onMessage)
 }
 
 // State synchronization methods
 
 // handleStateSyncRequest processes state synchronization requests
 func (h *Hub) handleStateSyncRequest(request *StateSyncRequest) {
-	h.mutex.Lock()
+	h.mutex.RLock()
 	clientState, exists := h.ClientStates[request.Client]
+	var synced ClientState
+	if exists {
+		synced = *clientState
+	}
+	lastEventState := h.LastEventState
+	h.mutex.RUnlock()
+
 	if !exists {
-		h.mutex.Unlock()
 		return
 	}
-
-	// Get latest event state
-	// eventState := h.LastEventState
-	h.mutex.Unlock()
-
-	if h.LastEventState == nil {
+	if lastEventState == nil {
 		log.Printf("No event state available for sync, skipping client %s (UserID: %d)", request.Client.Type, request.Client.UserID)
 		return
 	}
 
 	// Check if sync is needed
 	if request.SyncType == "initial" ||
-		clientState.LastEventState != h.LastEventState.EventState ||
-		clientState.LastQuestionNum != h.LastEventState.QuestionNumber ||
-		!clientState.IsInitialized {
+		synced.LastEventState != lastEventState.EventState ||
+		synced.LastQuestionNum != lastEventState.QuestionNumber ||
+		!synced.IsInitialized {
 
 		var reducedEventState EventSyncData
-		reducedEventState.EventState = h.LastEventState.EventState
-		reducedEventState.QuestionNumber = h.LastEventState.QuestionNumber
+		reducedEventState.EventState = lastEventState.EventState
+		reducedEventState.QuestionNumber = lastEventState.QuestionNumber
+		reducedEventState.Paused = lastEventState.Paused
+		reducedEventState.Slide = lastEventState.Slide
 		reducedEventState.QuestionData = models.Question{
-			Type:    h.LastEventState.QuestionData.Type,
-			Text:    h.LastEventState.QuestionData.Text,
-			Image:   h.LastEventState.QuestionData.Image,
-			Choices: h.LastEventState.QuestionData.Choices,
-			Correct: h.LastEventState.QuestionData.Correct,
+			Type:    lastEventState.QuestionData.Type,
+			Text:    lastEventState.QuestionData.Text,
+			Image:   lastEventState.QuestionData.Image,
+			Choices: lastEventState.QuestionData.Choices,
+			Correct: lastEventState.QuestionData.Correct,
 		}
-		reducedEventState.TeamData = h.LastEventState.TeamData
-		reducedEventState.ParticipantData = h.LastEventState.ParticipantData
+		reducedEventState.TeamData = lastEventState.TeamData
+		reducedEventState.ParticipantData = lastEventState.ParticipantData
 		reducedEventState.AnswerData = make(map[string]any)
-		// maps.Copy(reducedEventState.AnswerData, h.LastEventState.AnswerData)
-		ans, err := h.answerRepo.GetAnswerByUserAndQuestion(request.Client.UserID, h.LastEventState.QuestionNumber)
+		ans, err := h.answerRepo.GetAnswerByUserAndQuestion(request.Client.UserID, lastEventState.QuestionNumber)
 		if err == nil && ans != nil {
 			// AnswerData uses string keys (user_id as string) -> convert int to string
 			reducedEventState.AnswerData[strconv.Itoa(request.Client.UserID)] = ans.AnswerIndex
@@ -410,38 +456,45 @@ func (h *Hub) handleStateSyncRequest(request *StateSyncRequest) {
 			reducedEventState.ParticipantData = nil
 			// reducedEventState.AnswerData から該当ユーザーのみのデータに絞る
 			// FIXME:
-			for _, v := range h.LastEventState.ParticipantData {
+			for _, v := range lastEventState.ParticipantData {
 				if v["id"] == request.Client.UserID {
 					reducedEventState.ParticipantData = append(reducedEventState.ParticipantData, v)
 				}
 			}
 		case ClientTypeScreen:
 			// reducedEventState.QuestionData.Correct = 0 // invalid data
+			// Screens rebuild the answer stats from everyone's answers
+			maps.Copy(reducedEventState.AnswerData, lastEventState.AnswerData)
+			channel := h.ScreenChannel(request.Client.Channel)
+			reducedEventState.Channel = &channel
 		case ClientTypeAdmin:
-			// do nothing
+			maps.Copy(reducedEventState.AnswerData, lastEventState.AnswerData)
 		default:
 			return
 		}
 		h.sendInitialSync(request.Client, &reducedEventState)
 
-		// Update client state
-		h.mutex.Lock()
-		clientState.LastSyncTime = time.Now()
-		// clientState.SyncVersion = eventState.SyncVersion
-		clientState.IsInitialized = true
-		clientState.LastEventState = h.LastEventState.EventState
-		clientState.LastQuestionNum = h.LastEventState.QuestionNumber
-		h.mutex.Unlock()
-
 		log.Printf("State sync completed for client %s (UserID: %d, SyncType: %s)", request.Client.Type, request.Client.UserID, request.SyncType)
 	}
 }
 
-// sendInitialSync sends initial synchronization data to a client
+// sendInitialSync sends initial synchronization data to a client. It is queued
+// under the lock so the sequence number it carries matches the broadcasts
+// queued before and after it.
 func (h *Hub) sendInitialSync(client *Client, eventState *EventSyncData) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if !h.clients[client] {
+		return // Disconnected in the meantime
+	}
+
 	message := Message{
-		Type: string(MessageInitialSync),
-		Data: eventState,
+		Type:     string(MessageInitialSync),
+		Data:     eventState,
+		Seq:      h.seq[client.Type],
+		Epoch:    h.epoch,
+		Protocol: client.Protocol,
 	}
 
 	jsonData, err := json.Marshal(message)
@@ -450,19 +503,31 @@ func (h *Hub) sendInitialSync(client *Client, eventState *EventSyncData) {
 		return
 	}
 
-	select {
-	case client.Send <- jsonData:
+	if h.deliver(client, sequencedMessage{seq: message.Seq, data: jsonData}, false) {
+		h.markSynced(client)
 		log.Printf("Initial sync sent to client %d", client.UserID)
-	default:
-		log.Printf("Failed to send initial sync to client %d (channel full)", client.UserID)
+	}
+}
+
+// markSynced records that the client is up to date; the caller must hold mutex
+func (h *Hub) markSynced(client *Client) {
+	clientState, ok := h.ClientStates[client]
+	if !ok {
+		return
+	}
+
+	clientState.LastSyncTime = time.Now()
+	clientState.SyncVersion = h.seq[client.Type]
+	clientState.IsInitialized = true
+	if h.LastEventState != nil {
+		clientState.LastEventState = h.LastEventState.EventState
+		clientState.LastQuestionNum = h.LastEventState.QuestionNumber
 	}
 }
 
 // UpdateEventState updates the global event state for synchronization
 func (h *Hub) UpdateEventState(eventState *EventSyncData) {
 	h.mutex.Lock()
-	// eventState.SyncVersion++
-	// eventState.Timestamp = time.Now()
 	h.LastEventState = eventState
 	h.mutex.Unlock()
 